@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+)
+
+// runCommand executes a one-off maintenance command instead of starting the server
+func runCommand(args []string) {
+	switch args[0] {
+	case "verify-audit":
+		verifyAuditCommand()
+	case "bootstrap-admin":
+		bootstrapAdminCommand(args[1:])
+	case "verify-wallets":
+		verifyWalletsCommand(args[1:])
+	default:
+		log.Fatalf("unknown command %q", args[0])
+	}
+}
+
+func verifyAuditCommand() {
+	var conn *ConnectionPool = newDatabasePoolConnection()
+	defer conn.CloseConnection()
+
+	query := queries.NewQuery(conn.Pool)
+	res, err := query.VerifyAuditLogs(context.Background())
+	if err != nil {
+		log.Fatalf("failed to verify audit logs: %v", err)
+	}
+
+	if !res.Valid {
+		fmt.Printf("audit log chain broken at audit_id %d after %d entries: %s\n", res.BrokenAuditID, res.CheckedEntries, res.Reason)
+		conn.CloseConnection()
+		os.Exit(1)
+	}
+	fmt.Printf("audit log chain intact, %d entries verified\n", res.CheckedEntries)
+}
+
+// bootstrapAdminCommand creates the first super admin. The password is read
+// from BOOTSTRAP_ADMIN_PASSWORD or stdin so it stays out of shell history.
+func bootstrapAdminCommand(args []string) {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ExitOnError)
+	name := flags.String("name", "", "admin name")
+	email := flags.String("email", "", "admin email")
+	phone := flags.String("phone", "", "admin phone number")
+	_ = flags.Parse(args)
+
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Print("password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatalf("failed to read password: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	req := &structures.AdminRegisterRequest{
+		AdminName:     *name,
+		AdminEmail:    *email,
+		AdminPhone:    *phone,
+		AdminPassword: password,
+	}
+	if err := newValidator().Validate(req); err != nil {
+		log.Fatalf("invalid admin details: %v", err)
+	}
+	hash, err := (&pkg.PasswordUtils{}).HashPassword(password)
+	if err != nil {
+		log.Fatalf("failed to hash password: %v", err)
+	}
+	req.AdminPassword = hash
+
+	var conn *ConnectionPool = newDatabasePoolConnection()
+	defer conn.CloseConnection()
+
+	query := queries.NewQuery(conn.Pool)
+	query.InitializeDatabase()
+	res, err := query.BootstrapSuperAdmin(context.Background(), req, &structures.AuditLogEntry{
+		ActorRole:  "ADMIN",
+		Action:     "ADMIN_BOOTSTRAP",
+		TargetType: "ADMIN",
+		UserAgent:  "cli",
+	})
+	if errors.Is(err, queries.ErrSuperAdminExists) {
+		conn.CloseConnection()
+		log.Fatalf("a super admin already exists, further admins have to be invited")
+	}
+	if err != nil {
+		conn.CloseConnection()
+		log.Fatalf("failed to create super admin: %v", err)
+	}
+	fmt.Printf("super admin %s created with id %s\n", res.AdminUniqueID, res.AdminID)
+}
+
+// verifyWalletsCommand checks one day's wallet movements against their
+// records, yesterday unless -date is given, and exits non-zero when any
+// wallet drifted or a transfer lost its receiver
+func verifyWalletsCommand(args []string) {
+	flags := flag.NewFlagSet("verify-wallets", flag.ExitOnError)
+	date := flags.String("date", "", "day to check as YYYY-MM-DD, yesterday by default")
+	_ = flags.Parse(args)
+
+	day := pkg.StartOfDay(time.Now()).AddDate(0, 0, -1)
+	if *date != "" {
+		var err error
+		if day, err = pkg.ParseLedgerDate(*date); err != nil {
+			log.Fatalf("invalid -date: %v", err)
+		}
+	}
+
+	var conn *ConnectionPool = newDatabasePoolConnection()
+	defer conn.CloseConnection()
+
+	query := queries.NewQuery(conn.Pool)
+	ctx := context.Background()
+	if _, err := query.CheckWalletIntegrity(ctx, day); err != nil {
+		conn.CloseConnection()
+		log.Fatalf("failed to check wallet integrity: %v", err)
+	}
+	res, err := query.GetIntegrityReport(ctx, "", day)
+	if err != nil {
+		conn.CloseConnection()
+		log.Fatalf("failed to read integrity report: %v", err)
+	}
+
+	for _, d := range res.Drifts {
+		fmt.Printf("drift %s %s %s (%s): opening %s, expected %s, actual %s, off by %s\n",
+			d.CheckDate, d.OwnerType, d.OwnerID, d.OwnerName, d.OpeningBalance, d.ExpectedBalance, d.ActualBalance, d.Drift)
+	}
+	for _, t := range res.OrphanedTransactions {
+		fmt.Printf("orphaned %s transaction %s: %s %s (%s) debited %s for a missing %s\n",
+			t.TransactionService, t.TransactionID, t.TransactorType, t.TransactorID, t.TransactorName, t.Amount, t.ReceiverType)
+	}
+	if len(res.Drifts) > 0 || len(res.OrphanedTransactions) > 0 {
+		fmt.Printf("%s: %d of %d wallets drifted, %d orphaned transactions\n",
+			res.Check.CheckDate, len(res.Drifts), res.Check.AccountsChecked, len(res.OrphanedTransactions))
+		conn.CloseConnection()
+		os.Exit(1)
+	}
+	fmt.Printf("%s: all %d wallets match their records\n", res.Check.CheckDate, res.Check.AccountsChecked)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -31,6 +32,7 @@ func newDatabasePoolConnection() *ConnectionPool {
 	config.MaxConnIdleTime = 5 * time.Minute
 	config.HealthCheckPeriod = 1 * time.Minute
 	config.ConnConfig.ConnectTimeout = 5 * time.Second
+	config.ConnConfig.Tracer = queries.NewQueryTracer()
 
 	// Creating a pool of connections
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
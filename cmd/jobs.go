@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+)
+
+// otpRetention keeps codes around long enough for the hourly OTP caps
+const otpRetention = 24 * time.Hour
+
+// notificationEventRetention keeps dispatched events for support questions
+const notificationEventRetention = 30 * 24 * time.Hour
+
+// notificationBatchSize bounds the events claimed per dispatch
+const notificationBatchSize = 100
+
+// scheduledTransferBatchSize bounds the schedules claimed per tick
+const scheduledTransferBatchSize = 50
+
+// startHousekeeping removes old OTP codes, dispatched notification events,
+// idle rate limit buckets and stale login failures, closes expired pending
+// actions, reports overdue credit lines, snapshots the wallet balances of
+// days that have ended and checks them against their records every hour
+// until ctx is cancelled
+func startHousekeeping(ctx context.Context, query *queries.Query) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			deleted, err := query.PurgeOTPs(ctx, otpRetention)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to purge otp codes", "error", err)
+			} else if deleted > 0 {
+				slog.InfoContext(ctx, "purged otp codes", "deleted", deleted)
+			}
+			deleted, err = query.PurgeNotificationEvents(ctx, notificationEventRetention)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to purge notification events", "error", err)
+			} else if deleted > 0 {
+				slog.InfoContext(ctx, "purged notification events", "deleted", deleted)
+			}
+			deleted, err = query.PurgeRateLimitBuckets(ctx, rateLimitBucketIdle)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to purge rate limit buckets", "error", err)
+			} else if deleted > 0 {
+				slog.InfoContext(ctx, "purged rate limit buckets", "deleted", deleted)
+			}
+			deleted, err = query.PurgeLoginAttempts(ctx, rateLimitBucketIdle)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to purge login attempts", "error", err)
+			} else if deleted > 0 {
+				slog.InfoContext(ctx, "purged login attempts", "deleted", deleted)
+			}
+			expired, err := query.ExpirePendingActions(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to expire pending actions", "error", err)
+			} else if expired > 0 {
+				slog.InfoContext(ctx, "expired pending actions", "expired", expired)
+			}
+			overdue, err := query.MarkOverdueCreditLines(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to mark overdue credit lines", "error", err)
+			} else if overdue > 0 {
+				slog.InfoContext(ctx, "marked overdue credit lines", "overdue", overdue)
+			}
+			released, err := query.ReleaseStalePayoutBatches(ctx, pkg.PayoutBatchStaleAfter)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to release stale payout batches", "error", err)
+			} else if released > 0 {
+				slog.InfoContext(ctx, "released stale payout batches", "released", released)
+			}
+			snapshotted, err := query.SnapshotBalances(ctx, pkg.StartOfDay(time.Now()).AddDate(0, 0, -1))
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to snapshot wallet balances", "error", err)
+			} else if snapshotted > 0 {
+				slog.InfoContext(ctx, "snapshotted wallet balances", "snapshots", snapshotted)
+			}
+			drifted, err := query.RunWalletIntegrityChecks(ctx, pkg.StartOfDay(time.Now()).AddDate(0, 0, -1))
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to check wallet integrity", "error", err)
+			}
+			for _, check := range drifted {
+				slog.WarnContext(ctx, "wallet integrity check found drift", "date", check.CheckDate,
+					"drifted_accounts", check.DriftedAccounts, "orphaned_transactions", check.OrphanedTransactions)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// startNotificationDispatcher moves committed notification events from the
+// outbox to the notification queue every few seconds until ctx is cancelled
+func startNotificationDispatcher(ctx context.Context, query *queries.Query, notifications *pkg.NotificationService) {
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// Keep going while full batches come back, there may be more waiting
+			for dispatchNotificationEvents(ctx, query, notifications) == notificationBatchSize {
+			}
+		}
+	}()
+}
+
+// dispatchNotificationEvents queues one batch of events on every channel the
+// recipient has enabled and returns how many events were claimed. Events that
+// could not be queued at all go back to the outbox.
+func dispatchNotificationEvents(ctx context.Context, query *queries.Query, notifications *pkg.NotificationService) int {
+	deliveries, err := query.ClaimNotificationEvents(ctx, notificationBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to claim notification events", "error", err)
+		}
+		return 0
+	}
+
+	var release []int64
+	for _, d := range deliveries {
+		queued := false
+		for _, channel := range d.Channels {
+			if !notifications.Enabled(channel) {
+				continue
+			}
+			to := d.Phone
+			if channel == pkg.ChannelEmail {
+				to = d.Email
+			}
+			if to == "" {
+				continue
+			}
+			err := notifications.Notify(ctx, pkg.Notification{
+				Channel:  channel,
+				To:       to,
+				Event:    d.EventType,
+				Language: d.Language,
+				Data:     d.Data,
+			})
+			if errors.Is(err, pkg.ErrNotificationQueueFull) || errors.Is(err, pkg.ErrNotificationClosed) {
+				// Retrying an event that is partly queued would send it twice
+				if !queued {
+					release = append(release, d.EventID)
+				}
+				break
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to queue notification", "event_id", d.EventID, "event", d.EventType, "channel", channel, "error", err)
+				continue
+			}
+			queued = true
+		}
+	}
+	if len(release) > 0 {
+		if err := query.ReleaseNotificationEvents(context.WithoutCancel(ctx), release); err != nil {
+			slog.ErrorContext(ctx, "failed to release notification events", "count", len(release), "error", err)
+		}
+		return 0
+	}
+	return len(deliveries)
+}
+
+// startTransferScheduler runs the scheduled transfers that are due every
+// minute until ctx is cancelled
+func startTransferScheduler(ctx context.Context, query *queries.Query) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for runScheduledTransfers(ctx, query) == scheduledTransferBatchSize {
+			}
+		}
+	}()
+}
+
+// runScheduledTransfers runs one batch of due schedules and returns how many
+// were claimed
+func runScheduledTransfers(ctx context.Context, query *queries.Query) int {
+	due, err := query.ClaimDueScheduledTransfers(ctx, scheduledTransferBatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to claim scheduled transfers", "error", err)
+		}
+		return 0
+	}
+	for i := range due {
+		runScheduledTransfer(ctx, query, &due[i])
+	}
+	return len(due)
+}
+
+// runScheduledTransfer moves the funds of one claimed run with the same
+// transfer the owner would make by hand and records the outcome. Runs the
+// owner's wallet cannot cover are skipped, TOP_UP runs that find the
+// recipient at or above the threshold are not recorded at all.
+func runScheduledTransfer(ctx context.Context, query *queries.Query, due *structures.DueScheduledTransfer) {
+	record := func(amount string, status string, reason string) {
+		if err := query.RecordScheduledTransferRun(context.WithoutCancel(ctx), due, amount, status, reason); err != nil {
+			slog.ErrorContext(ctx, "failed to record scheduled transfer run", "schedule_id", due.ScheduleID, "error", err)
+		}
+	}
+	if due.RecipientPhone == "" {
+		record(due.Amount, pkg.ScheduledRunFailed, "recipient no longer exists")
+		return
+	}
+
+	amount, err := scheduledTransferAmount(ctx, query, due)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read scheduled transfer recipient balance", "schedule_id", due.ScheduleID, "error", err)
+		record(due.Amount, pkg.ScheduledRunFailed, "recipient balance could not be read")
+		return
+	}
+	if amount == "" {
+		return
+	}
+
+	switch {
+	case due.OwnerType == "DISTRIBUTOR":
+		err = query.DistributorFundRetailer(ctx, &structures.DistributorFundRetailerRequest{
+			DistributorID: due.OwnerID,
+			PhoneNumber:   due.RecipientPhone,
+			Amount:        amount,
+		})
+	case due.RecipientType == "DISTRIBUTOR":
+		err = query.MasterDistributorFundDistributor(ctx, &structures.MasterDistributorFundRetailerRequest{
+			MasterDistributorID: due.OwnerID,
+			PhoneNumber:         due.RecipientPhone,
+			Amount:              amount,
+		})
+	default:
+		err = query.MasterDistributorFundRetailer(ctx, &structures.MasterDistributorFundRetailerRequest{
+			MasterDistributorID: due.OwnerID,
+			PhoneNumber:         due.RecipientPhone,
+			Amount:              amount,
+		})
+	}
+	switch {
+	case errors.Is(err, queries.ErrInsufficientBalance):
+		record(amount, pkg.ScheduledRunSkipped, "insufficient balance")
+	case err != nil:
+		slog.ErrorContext(ctx, "scheduled transfer failed", "schedule_id", due.ScheduleID, "error", err)
+		record(amount, pkg.ScheduledRunFailed, "transfer could not be completed")
+	default:
+		record(amount, pkg.ScheduledRunSuccess, "")
+	}
+}
+
+// scheduledTransferAmount returns how much a run sends. TOP_UP runs send the
+// difference to the target when the recipient is below the threshold and
+// nothing otherwise.
+func scheduledTransferAmount(ctx context.Context, query *queries.Query, due *structures.DueScheduledTransfer) (string, error) {
+	if due.ScheduleType != pkg.ScheduleTopUp {
+		return due.Amount, nil
+	}
+	var balance string
+	var err error
+	if due.RecipientType == "DISTRIBUTOR" {
+		balance, err = query.GetDistributorWalletBalance(ctx, due.RecipientID)
+	} else {
+		balance, err = query.GetUserWalletBalance(ctx, due.RecipientID)
+	}
+	if err != nil {
+		return "", err
+	}
+	current, _ := strconv.ParseFloat(balance, 64)
+	threshold, _ := strconv.ParseFloat(due.ThresholdAmount, 64)
+	target, _ := strconv.ParseFloat(due.TargetAmount, 64)
+	if current >= threshold {
+		return "", nil
+	}
+	return strconv.FormatFloat(target-current, 'f', 2, 64), nil
+}
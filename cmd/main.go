@@ -24,5 +24,9 @@ func init() {
 }
 
 func main(){
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
 	start()
 }
\ No newline at end of file
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredConnsDesc = prometheus.NewDesc("paybazar_db_pool_acquired_connections", "Connections currently checked out of the pool.", nil, nil)
+	poolIdleConnsDesc     = prometheus.NewDesc("paybazar_db_pool_idle_connections", "Idle connections in the pool.", nil, nil)
+	poolTotalConnsDesc    = prometheus.NewDesc("paybazar_db_pool_total_connections", "Total connections in the pool.", nil, nil)
+	poolMaxConnsDesc      = prometheus.NewDesc("paybazar_db_pool_max_connections", "Maximum size of the pool.", nil, nil)
+	poolAcquireCountDesc  = prometheus.NewDesc("paybazar_db_pool_acquire_total", "Successful connection acquisitions.", nil, nil)
+	poolEmptyAcquireDesc  = prometheus.NewDesc("paybazar_db_pool_empty_acquire_total", "Acquisitions that had to wait for a connection.", nil, nil)
+	poolAcquireTimeDesc   = prometheus.NewDesc("paybazar_db_pool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil)
+
+	payoutCountDesc          = prometheus.NewDesc("paybazar_payouts", "Payout transactions by status.", []string{"status"}, nil)
+	payoutAmountDesc         = prometheus.NewDesc("paybazar_payout_amount", "Payout amount by status.", []string{"status"}, nil)
+	pendingFundRequestsDesc  = prometheus.NewDesc("paybazar_fund_requests_pending", "Fund requests waiting for a decision.", nil, nil)
+	pendingFundAmountDesc    = prometheus.NewDesc("paybazar_fund_requests_pending_amount", "Total amount of pending fund requests.", nil, nil)
+	oldestFundRequestAgeDesc = prometheus.NewDesc("paybazar_fund_requests_oldest_pending_age_seconds", "Age of the oldest pending fund request.", nil, nil)
+	walletFloatDesc          = prometheus.NewDesc("paybazar_wallet_float", "Sum of wallet balances by role.", []string{"role"}, nil)
+	scrapeErrorDesc          = prometheus.NewDesc("paybazar_business_metrics_scrape_error", "1 when the business metrics could not be read.", nil, nil)
+)
+
+// databaseCollector samples pool statistics and business totals on every scrape
+type databaseCollector struct {
+	query *queries.Query
+}
+
+func newDatabaseCollector(query *queries.Query) *databaseCollector {
+	return &databaseCollector{
+		query: query,
+	}
+}
+
+func (dc *databaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		poolAcquiredConnsDesc,
+		poolIdleConnsDesc,
+		poolTotalConnsDesc,
+		poolMaxConnsDesc,
+		poolAcquireCountDesc,
+		poolEmptyAcquireDesc,
+		poolAcquireTimeDesc,
+		payoutCountDesc,
+		payoutAmountDesc,
+		pendingFundRequestsDesc,
+		pendingFundAmountDesc,
+		oldestFundRequestAgeDesc,
+		walletFloatDesc,
+		scrapeErrorDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (dc *databaseCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := dc.query.Pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCountDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquireDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireTimeDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := dc.query.GetOperationalMetrics(ctx)
+	if err != nil {
+		slog.Error("failed to collect business metrics", "error", err)
+		ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 0)
+
+	for status, count := range res.PayoutCountsByStatus {
+		ch <- prometheus.MustNewConstMetric(payoutCountDesc, prometheus.GaugeValue, float64(count), status)
+	}
+	for status, amount := range res.PayoutAmountsByStatus {
+		ch <- prometheus.MustNewConstMetric(payoutAmountDesc, prometheus.GaugeValue, amount, status)
+	}
+	ch <- prometheus.MustNewConstMetric(pendingFundRequestsDesc, prometheus.GaugeValue, float64(res.PendingFundRequests))
+	ch <- prometheus.MustNewConstMetric(pendingFundAmountDesc, prometheus.GaugeValue, res.PendingFundRequestAmount)
+	ch <- prometheus.MustNewConstMetric(oldestFundRequestAgeDesc, prometheus.GaugeValue, res.OldestPendingFundRequestSecs)
+	for role, amount := range res.WalletFloatByRole {
+		ch <- prometheus.MustNewConstMetric(walletFloatDesc, prometheus.GaugeValue, amount, role)
+	}
+}
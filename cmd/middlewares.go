@@ -1,90 +1,189 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type Middlewares struct{}
-
-type LogEntryModel struct {
-	RequestTime  string `json:"request_time"`
-	Method       string `json:"method"`
-	ResponseTime string `json:"response_time"`
-	Status       int    `json:"status"`
-	Level        string `json:"level"`
-	Error        string `json:"error,omitempty"`
+type Middlewares struct {
+	JwtUtils   *pkg.JwtUtils
+	Query      *queries.Query
+	RateLimits pkg.RateLimitStore
 }
 
-func newMiddleware() *Middlewares {
-	return &Middlewares{}
+func newMiddleware(jwtUtils *pkg.JwtUtils, query *queries.Query, rateLimits pkg.RateLimitStore) *Middlewares {
+	return &Middlewares{
+		JwtUtils:   jwtUtils,
+		Query:      query,
+		RateLimits: rateLimits,
+	}
 }
 
-func (m *Middlewares) LoggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+// RequestIDMiddleware tags every request with an id, reusing the caller's
+// X-Request-ID when it is safe to log, and echoes it back in the response.
+func (m *Middlewares) RequestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		// Start time
-		startTime := time.Now()
-		err := next(c)
+		requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		c.SetRequest(c.Request().WithContext(pkg.WithRequestID(c.Request().Context(), requestID)))
+		return next(c)
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
 
-		// Total time taken by the request from start time
-		endTime := time.Since(startTime)
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
 
-		// Creating a log entry with the log entry model
-		entry := LogEntryModel{
-			RequestTime:  startTime.Format("03:04:05 PM"),
-			Method:       c.Request().Method,
-			ResponseTime: endTime.String(),
-			Status:       c.Response().Status,
+// TracingMiddleware opens the server span for a request, continuing a trace
+// started by the caller when trace headers are sent.
+func (m *Middlewares) TracingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
 		}
 
-		// Checking if the response has any errors if yes then set log level to error else info
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := pkg.StartSpan(ctx, req.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", req.Method),
+				attribute.String("http.route", route),
+				attribute.String("client.address", c.RealIP()),
+				attribute.String("request_id", pkg.RequestIDFromContext(ctx)),
+			),
+		)
+		defer span.End()
+		c.SetRequest(req.WithContext(ctx))
+
+		err := next(c)
+
+		status := c.Response().Status
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			status = httpErr.Code
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
 		if err != nil {
-			entry.Level = "ERROR"
-			entry.Error = err.Error()
-		} else {
-			entry.Level = "INFO"
+			span.RecordError(err)
 		}
-		writeLog(entry)
-		return nil
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return err
 	}
 }
 
-func writeLog(entry LogEntryModel) {
-	// Creates a .logs folder which stores every logs in json format
-	if err := os.MkdirAll(".logs", 0755); err != nil {
-		log.Fatalf("failed to create .logs directory: %v", err)
+// MetricsMiddleware records latency and status per route. It runs outside the
+// logger so errors are already rendered when the status is read.
+func (m *Middlewares) MetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		startTime := time.Now()
+		err := next(c)
+
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		pkg.HTTPRequestDuration.WithLabelValues(
+			c.Request().Method,
+			route,
+			strconv.Itoa(c.Response().Status),
+		).Observe(time.Since(startTime).Seconds())
+		return err
 	}
+}
 
-	// Creating a dynamic file based on date to store all the logs based on the date in sepearte file
-	fileName := fmt.Sprintf("%s_logs.log", time.Now().Format("2006-01-02"))
-	path := filepath.Join(".logs", fileName)
+func (m *Middlewares) LoggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		// Start time
+		startTime := time.Now()
+		err := next(c)
 
-	// Opening the file to write the log
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		log.Fatalf("failed to open the log file: %v", err)
-	}
+		// Let echo write the error response so the logged status is the one sent
+		if err != nil {
+			c.Error(err)
+		}
 
-	// Closing the log at the end of function
-	defer file.Close()
+		attrs := []any{
+			"method", c.Request().Method,
+			"route", c.Path(),
+			"path", redactedPath(c),
+			"status", c.Response().Status,
+			"latency_ms", time.Since(startTime).Milliseconds(),
+			"ip", c.RealIP(),
+			"user_agent", c.Request().UserAgent(),
+		}
+		if actor, ok := c.Get(pkg.ActorContextKey).(*structures.Actor); ok {
+			attrs = append(attrs, "actor_id", actor.ID, "actor_role", actor.Role)
+		}
 
-	// Encoding to json Format
-	data, err := json.Marshal(entry)
-	if err != nil {
-		log.Fatalf("failed to marshal the log data: %v", err)
+		ctx := c.Request().Context()
+		switch {
+		case err != nil || c.Response().Status >= http.StatusInternalServerError:
+			if err != nil {
+				attrs = append(attrs, "error", err.Error())
+			}
+			slog.ErrorContext(ctx, "request failed", attrs...)
+		case c.Response().Status >= http.StatusBadRequest:
+			slog.WarnContext(ctx, "request rejected", attrs...)
+		default:
+			slog.InfoContext(ctx, "request completed", attrs...)
+		}
+		return nil
 	}
+}
 
-	// Writing to json file
-	if _, err = file.Write(append(data, '\n')); err != nil {
-		log.Fatalf("failed to write the log to json file: %v", err)
+// redactedPath masks sensitive path parameters such as account numbers
+func redactedPath(c echo.Context) string {
+	path := c.Request().URL.Path
+	for i, name := range c.ParamNames() {
+		if i >= len(c.ParamValues()) {
+			break
+		}
+		if value := c.ParamValues()[i]; value != "" && (pkg.IsSensitiveLogKey(name) || strings.Contains(name, "phone")) {
+			path = strings.Replace(path, value, pkg.RedactedValue, 1)
+		}
 	}
+	return path
 }
 
 func (m *Middlewares) CORSMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
@@ -104,3 +203,196 @@ func (m *Middlewares) CORSMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+// ActorMiddleware resolves the caller from the bearer token when one is sent.
+// Requests without a valid token are passed through untouched.
+func (m *Middlewares) ActorMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header.Get(echo.HeaderAuthorization)
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			return next(c)
+		}
+
+		data, err := m.JwtUtils.ParseToken(token)
+		if err != nil {
+			return next(c)
+		}
+
+		// The most specific id in the token decides the role
+		var actor structures.Actor
+		for _, claim := range []struct{ key, role string }{
+			{"user_id", "USER"},
+			{"distributor_id", "DISTRIBUTOR"},
+			{"master_distributor_id", "MASTER_DISTRIBUTOR"},
+			{"admin_id", "ADMIN"},
+		} {
+			if id, ok := data[claim.key].(string); ok && id != "" {
+				actor = structures.Actor{ID: id, Role: claim.role}
+				break
+			}
+		}
+		if actor.Role == "USER" {
+			actor.DeviceID, _ = data["device_id"].(string)
+		}
+		if actor.Role == "ADMIN" {
+			actor.StaffID, _ = data["staff_id"].(string)
+		}
+		if actor.ID != "" {
+			c.Set(pkg.ActorContextKey, &actor)
+			c.SetRequest(c.Request().WithContext(pkg.WithActor(c.Request().Context(), actor.ID, actor.Role)))
+		}
+		return next(c)
+	}
+}
+
+// DeviceSessionMiddleware holds retailer sessions to the device they were
+// issued for. Sessions from a revoked device, or presented without the
+// fingerprint of the device they are bound to, are turned away.
+func (m *Middlewares) DeviceSessionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		actor, ok := c.Get(pkg.ActorContextKey).(*structures.Actor)
+		if !ok || actor.Role != "USER" {
+			return next(c)
+		}
+		if actor.DeviceID == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Session is not bound to a device, please log in again")
+		}
+
+		fingerprintHash, revoked, err := m.Query.GetUserDeviceSession(c.Request().Context(), actor.ID, actor.DeviceID)
+		if errors.Is(err, pgx.ErrNoRows) || revoked {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Device session revoked, please log in again")
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "DB get device session error", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify device")
+		}
+		fingerprint := c.Request().Header.Get(pkg.DeviceFingerprintHeader)
+		if fingerprint == "" || pkg.HashDeviceFingerprint(fingerprint) != fingerprintHash {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Session does not belong to this device")
+		}
+		return next(c)
+	}
+}
+
+// isLoginChallenge reports whether the request body carries a challenge
+// token issued to the role by its password step
+func (m *Middlewares) isLoginChallenge(c echo.Context, role string) bool {
+	token, _ := requestBody(c)["challenge_token"].(string)
+	if token == "" {
+		return false
+	}
+	data, err := m.JwtUtils.ParseToken(token)
+	if err != nil {
+		return false
+	}
+	accountID, _ := data["two_factor_account_id"].(string)
+	return accountID != "" && data["two_factor_role"] == role
+}
+
+// checkAdminTenant refuses the request when one of the route's tenant scoped
+// params or body fields names something of another admin
+func (m *Middlewares) checkAdminTenant(c echo.Context, route string, adminID string) error {
+	for field, kind := range adminTenantParams[route] {
+		value := c.Param(field)
+		if value == "" {
+			value, _ = requestBody(c)[field].(string)
+		}
+		if value == "" {
+			continue
+		}
+		other, err := m.Query.BelongsToOtherAdmin(c.Request().Context(), kind, value, adminID)
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "DB check tenant error", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check permissions")
+		}
+		if other {
+			return echo.NewHTTPError(http.StatusForbidden, "Access to another admin's data is not allowed")
+		}
+	}
+	return nil
+}
+
+// AdminPermissionMiddleware guards the admin routes. Apart from the login
+// flows every route needs an admin or staff session holding the route's
+// permission, and may only name the admin tenant of that session.
+func (m *Middlewares) AdminPermissionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		route := c.Request().Method + " " + c.Path()
+		if adminPublicRoutes[route] {
+			return next(c)
+		}
+		actor, ok := c.Get(pkg.ActorContextKey).(*structures.Actor)
+		if !ok || actor.Role != "ADMIN" {
+			if adminChallengeRoutes[route] && m.isLoginChallenge(c, "ADMIN") {
+				return next(c)
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, "Admin session required")
+		}
+		adminID := c.Param("admin_id")
+		if adminID == "" {
+			adminID, _ = requestBody(c)["admin_id"].(string)
+		}
+		if adminID != "" && adminID != actor.ID {
+			return echo.NewHTTPError(http.StatusForbidden, "Access to another admin's data is not allowed")
+		}
+		if err := m.checkAdminTenant(c, route, actor.ID); err != nil {
+			return err
+		}
+		if adminTwoFactorRoutes[route] {
+			return next(c)
+		}
+		if adminAccountRoutes[route] {
+			if actor.StaffID != "" {
+				return echo.NewHTTPError(http.StatusForbidden, "Staff users cannot use this route")
+			}
+			return next(c)
+		}
+
+		permission, found := adminRoutePermissions[route]
+		if !found {
+			return echo.NewHTTPError(http.StatusForbidden, "Permission denied")
+		}
+		permissions, err := m.Query.GetActorPermissions(c.Request().Context(), actor.ID, actor.StaffID)
+		if err != nil {
+			slog.ErrorContext(c.Request().Context(), "DB get actor permissions error", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check permissions")
+		}
+		if !slices.Contains(permissions, permission) {
+			return echo.NewHTTPError(http.StatusForbidden, "Permission denied, "+permission+" is required")
+		}
+		return next(c)
+	}
+}
+
+// RateLimitMiddleware applies the default per IP limit and the route's own
+// rate limit policies, and answers 429 with Retry-After once a bucket runs
+// dry. Requests go through when the store cannot be reached.
+func (m *Middlewares) RateLimitMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		route := c.Path()
+		if route == "" || route == "/healthz" || route == "/readyz" || route == "/metrics" {
+			return next(c)
+		}
+		policies := append([]rateLimitPolicy{defaultRateLimit}, routeRateLimits[c.Request().Method+" "+route]...)
+
+		for _, policy := range policies {
+			key := policy.key(c)
+			if key == "" {
+				continue
+			}
+			wait, err := m.RateLimits.Take(c.Request().Context(), policy.name+":"+key, policy.limit)
+			if err != nil {
+				slog.ErrorContext(c.Request().Context(), "rate limit store error", "policy", policy.name, "error", err)
+				continue
+			}
+			if wait > 0 {
+				seconds := max(1, int(math.Ceil(wait.Seconds())))
+				pkg.RateLimitedRequestsTotal.WithLabelValues(route, policy.name).Inc()
+				c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(seconds))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests, try again in "+strconv.Itoa(seconds)+" seconds")
+			}
+		}
+		return next(c)
+	}
+}
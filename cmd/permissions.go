@@ -0,0 +1,154 @@
+package main
+
+import "github.com/Srujankm12/paybazar-api/pkg"
+
+// adminPublicRoutes are the admin routes used before a session exists
+var adminPublicRoutes = map[string]bool{
+	"POST /admin/invite/accept":   true,
+	"POST /admin/login":           true,
+	"POST /admin/staff/login":     true,
+	"POST /admin/password/forgot": true,
+	"POST /admin/password/reset":  true,
+	"POST /admin/login/2fa":       true,
+}
+
+// adminChallengeRoutes enrol two-factor authentication. Admins who must
+// enrol before their first session use them with the challenge token from
+// the password step, everyone else with their session.
+var adminChallengeRoutes = map[string]bool{
+	"POST /admin/2fa/setup":  true,
+	"POST /admin/2fa/enable": true,
+}
+
+// adminAccountRoutes manage the signed in admin's own account. Every admin
+// can use them, staff users cannot.
+var adminAccountRoutes = map[string]bool{
+	"POST /admin/password/change":                   true,
+	"GET /admin/notification/preferences/:owner_id": true,
+	"POST /admin/notification/preferences":          true,
+}
+
+// adminTwoFactorRoutes manage the two-factor authentication of the signed in
+// admin or staff user's own account. Everyone signed in can use them.
+var adminTwoFactorRoutes = map[string]bool{
+	"GET /admin/2fa/status/:id":      true,
+	"POST /admin/2fa/setup":          true,
+	"POST /admin/2fa/enable":         true,
+	"POST /admin/2fa/disable":        true,
+	"POST /admin/2fa/recovery-codes": true,
+}
+
+// adminRoutePermissions holds the permission every other admin route needs,
+// by method and route. Admin routes missing here are refused.
+var adminRoutePermissions = map[string]string{
+	"POST /admin/create/md":          pkg.PermissionMemberCreate,
+	"POST /admin/create/distributor": pkg.PermissionMemberCreate,
+	"POST /admin/create/user":        pkg.PermissionMemberCreate,
+	"POST /admin/bulk/onboard":       pkg.PermissionMemberCreate,
+
+	"GET /admin/get/md/:admin_id":                        pkg.PermissionMemberView,
+	"GET /admin/get/distributors/:master_distributor_id": pkg.PermissionMemberView,
+	"GET /admin/get/users/:distributor_id":               pkg.PermissionMemberView,
+	"GET /admin/get/distributor/:admin_id":               pkg.PermissionMemberView,
+	"GET /admin/get/user/:admin_id":                      pkg.PermissionMemberView,
+	"GET /admin/get/user/phone/:phone":                   pkg.PermissionMemberView,
+	"GET /admin/get/md/phone/:phone":                     pkg.PermissionMemberView,
+	"GET /admin/get/distributor/phone/:phone":            pkg.PermissionMemberView,
+	"GET /admin/user/devices/:admin_id/:user_id":         pkg.PermissionMemberDevices,
+	"POST /admin/user/device/revoke":                     pkg.PermissionMemberDevices,
+
+	"GET /admin/get/fund/requests/:admin_id":     pkg.PermissionFundRequestView,
+	"GET /admin/reject/fund/request/:request_id": pkg.PermissionFundRequestApprove,
+	"POST /admin/accept/fund/request":            pkg.PermissionFundRequestApprove,
+
+	"GET /admin/wallet/get/balance/:admin_id":     pkg.PermissionWalletView,
+	"GET /admin/wallet/get/transactions/:id":      pkg.PermissionWalletView,
+	"GET /admin/revert/get/history":               pkg.PermissionWalletView,
+	"GET /admin/revert/get/history/:phone_number": pkg.PermissionWalletView,
+	"POST /admin/wallet/topup":                    pkg.PermissionWalletTopup,
+	"POST /admin/user/wallet/refund":              pkg.PermissionWalletRefund,
+	"POST /admin/md/wallet/refund":                pkg.PermissionWalletRefund,
+	"POST /admin/distributor/wallet/refund":       pkg.PermissionWalletRefund,
+	"POST /admin/update/payout/request":           pkg.PermissionPayoutOverride,
+
+	"GET /admin/get/tickets/:admin_id": pkg.PermissionTicketView,
+
+	"GET /admin/audit/logs":   pkg.PermissionAuditView,
+	"GET /admin/audit/verify": pkg.PermissionAuditView,
+
+	"GET /admin/2fa/policies": pkg.PermissionSecurityPolicy,
+	"POST /admin/2fa/policy":  pkg.PermissionSecurityPolicy,
+
+	"POST /admin/invite":           pkg.PermissionAdminManage,
+	"GET /admin/invites/:admin_id": pkg.PermissionAdminManage,
+	"POST /admin/invite/revoke":    pkg.PermissionAdminManage,
+	"GET /admin/admins/:admin_id":  pkg.PermissionAdminManage,
+	"POST /admin/role":             pkg.PermissionAdminManage,
+
+	"GET /admin/rbac/permissions":  pkg.PermissionRoleManage,
+	"GET /admin/rbac/roles":        pkg.PermissionRoleManage,
+	"POST /admin/rbac/role":        pkg.PermissionRoleManage,
+	"POST /admin/rbac/role/delete": pkg.PermissionRoleManage,
+	"POST /admin/staff":            pkg.PermissionStaffManage,
+	"GET /admin/staff/:admin_id":   pkg.PermissionStaffManage,
+	"POST /admin/staff/role":       pkg.PermissionStaffManage,
+	"POST /admin/staff/block":      pkg.PermissionStaffManage,
+
+	"GET /admin/approval/policies":   pkg.PermissionApprovalPolicy,
+	"POST /admin/approval/policy":    pkg.PermissionApprovalPolicy,
+	"GET /admin/approvals/:admin_id": pkg.PermissionApprovalDecide,
+	"POST /admin/approval/approve":   pkg.PermissionApprovalDecide,
+	"POST /admin/approval/reject":    pkg.PermissionApprovalDecide,
+
+	"GET /admin/credit/outstanding/:admin_id": pkg.PermissionWalletView,
+
+	"GET /admin/ledger/balance/:admin_id/:owner_type/:owner_id":   pkg.PermissionWalletView,
+	"GET /admin/ledger/snapshots/:admin_id/:owner_type/:owner_id": pkg.PermissionWalletView,
+	"POST /admin/ledger/period/close":                             pkg.PermissionPeriodClose,
+	"GET /admin/ledger/periods":                                   pkg.PermissionWalletView,
+
+	"GET /admin/integrity/checks/:admin_id":       pkg.PermissionAuditView,
+	"GET /admin/integrity/report/:admin_id/:date": pkg.PermissionAuditView,
+	"POST /admin/integrity/check":                 pkg.PermissionAuditView,
+
+	"POST /admin/payout/return":                pkg.PermissionPayoutOverride,
+	"GET /admin/payout/returns/:admin_id":      pkg.PermissionWalletView,
+	"GET /admin/payout/return/rates/:admin_id": pkg.PermissionWalletView,
+}
+
+// adminTenantParams name what the route params and body fields of admin
+// routes point at, by method and route. Whatever they point at has to belong
+// to the admin of the session, like admin_id itself.
+var adminTenantParams = map[string]map[string]string{
+	"POST /admin/create/distributor": {"master_distributor_id": "MASTER_DISTRIBUTOR"},
+	"POST /admin/create/user":        {"master_distributor_id": "MASTER_DISTRIBUTOR", "distributor_id": "DISTRIBUTOR"},
+
+	"GET /admin/get/distributors/:master_distributor_id": {"master_distributor_id": "MASTER_DISTRIBUTOR"},
+	"GET /admin/get/users/:distributor_id":               {"distributor_id": "DISTRIBUTOR"},
+	"GET /admin/get/user/phone/:phone":                   {"phone": "USER_PHONE"},
+	"GET /admin/get/md/phone/:phone":                     {"phone": "MASTER_DISTRIBUTOR_PHONE"},
+	"GET /admin/get/distributor/phone/:phone":            {"phone": "DISTRIBUTOR_PHONE"},
+	"GET /admin/user/devices/:admin_id/:user_id":         {"user_id": "USER"},
+	"POST /admin/user/device/revoke":                     {"user_id": "USER"},
+
+	"GET /admin/reject/fund/request/:request_id": {"request_id": "FUND_REQUEST"},
+	"POST /admin/accept/fund/request":            {"request_id": "FUND_REQUEST"},
+
+	"GET /admin/wallet/get/transactions/:id":      {"id": "WALLET_OWNER"},
+	"GET /admin/revert/get/history/:phone_number": {"phone_number": "MEMBER_PHONE"},
+	"POST /admin/user/wallet/refund":              {"phone_number": "USER_PHONE"},
+	"POST /admin/md/wallet/refund":                {"phone_number": "MASTER_DISTRIBUTOR_PHONE"},
+	"POST /admin/distributor/wallet/refund":       {"phone_number": "DISTRIBUTOR_PHONE"},
+	"POST /admin/update/payout/request":           {"payout_transaction_id": "PAYOUT"},
+	"POST /admin/payout/return":                   {"payout_transaction_id": "PAYOUT"},
+
+	"GET /admin/notification/preferences/:owner_id": {"owner_id": "WALLET_OWNER"},
+	"POST /admin/notification/preferences":          {"owner_id": "WALLET_OWNER"},
+
+	"POST /admin/invite/revoke": {"invite_id": "INVITE"},
+	"POST /admin/staff/role":    {"staff_id": "STAFF"},
+	"POST /admin/staff/block":   {"staff_id": "STAFF"},
+
+	"GET /admin/ledger/balance/:admin_id/:owner_type/:owner_id":   {"owner_id": "WALLET_OWNER"},
+	"GET /admin/ledger/snapshots/:admin_id/:owner_type/:owner_id": {"owner_id": "WALLET_OWNER"},
+}
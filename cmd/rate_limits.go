@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/labstack/echo/v4"
+)
+
+// rateLimitBucketIdle is how long an unused bucket is kept in the database
+const rateLimitBucketIdle = 24 * time.Hour
+
+// rateLimitBodyKey caches the decoded JSON body for account keys
+const rateLimitBodyKey = "rate_limit_body"
+
+// rateLimitPolicy limits requests sharing the same key. Requests the key
+// function returns nothing for are not limited by the policy.
+type rateLimitPolicy struct {
+	name  string
+	limit pkg.RateLimit
+	key   func(c echo.Context) string
+}
+
+// defaultRateLimit applies per IP to every route
+var defaultRateLimit = rateLimitPolicy{"ip", pkg.RateLimit{Burst: 120, Every: 500 * time.Millisecond}, byIP}
+
+// routeRateLimits holds the policies of routes that are costly or guess
+// credentials, by method and route
+var routeRateLimits = map[string][]rateLimitPolicy{
+	"POST /admin/login":         passwordLoginLimits("admin_email"),
+	"POST /admin/staff/login":   passwordLoginLimits("staff_email"),
+	"POST /md/login":            passwordLoginLimits("master_distributor_email"),
+	"POST /distributor/login":   passwordLoginLimits("distributor_email"),
+	"POST /user/login/password": passwordLoginLimits("phone"),
+
+	"POST /admin/invite/accept": codeLimits(),
+	"POST /admin/login/2fa":     codeLimits(),
+	"POST /md/login/2fa":        codeLimits(),
+
+	// Every OTP is an SMS we pay for
+	"POST /user/login/send/otp":         otpSendLimits("phone"),
+	"POST /user/mpin/send/otp":          otpSendLimits("user_id"),
+	"POST /user/otp/send":               otpSendLimits("user_id"),
+	"POST /admin/password/forgot":       otpSendLimits("login"),
+	"POST /md/password/forgot":          otpSendLimits("login"),
+	"POST /distributor/password/forgot": otpSendLimits("login"),
+	"POST /user/password/forgot":        otpSendLimits("login"),
+
+	"POST /user/login/validate/otp":    codeLimits(),
+	"POST /user/login/device/verify":   codeLimits(),
+	"POST /user/verify/mpin":           codeLimits(),
+	"POST /user/mpin/reset":            codeLimits(),
+	"POST /admin/password/reset":       codeLimits(),
+	"POST /md/password/reset":          codeLimits(),
+	"POST /distributor/password/reset": codeLimits(),
+	"POST /user/password/reset":        codeLimits(),
+
+	// The current password is checked on every change
+	"POST /admin/password/change":       passwordChangeLimits(),
+	"POST /md/password/change":          passwordChangeLimits(),
+	"POST /distributor/password/change": passwordChangeLimits(),
+	"POST /user/password/change":        passwordChangeLimits(),
+
+	// Penny drop verifications are charged by the bank
+	"GET /user/payout/:user_id/:phone/:account_number/:ifsc": {
+		{"penny_drop_ip", pkg.RateLimit{Burst: 10, Every: time.Minute}, byIP},
+		{"penny_drop_account", pkg.RateLimit{Burst: 5, Every: 2 * time.Minute}, byAccount("user_id")},
+	},
+}
+
+func passwordLoginLimits(loginField string) []rateLimitPolicy {
+	return []rateLimitPolicy{
+		{"login_ip", pkg.RateLimit{Burst: 20, Every: 30 * time.Second}, byIP},
+		{"login_account", pkg.RateLimit{Burst: 10, Every: 3 * time.Minute}, byAccount(loginField)},
+	}
+}
+
+func passwordChangeLimits() []rateLimitPolicy {
+	return []rateLimitPolicy{
+		{"password_change_ip", pkg.RateLimit{Burst: 20, Every: 30 * time.Second}, byIP},
+		{"password_change_account", pkg.RateLimit{Burst: 5, Every: 3 * time.Minute}, byAccount("")},
+	}
+}
+
+func otpSendLimits(accountField string) []rateLimitPolicy {
+	return []rateLimitPolicy{
+		{"otp_send_ip", pkg.RateLimit{Burst: 10, Every: time.Minute}, byIP},
+		{"otp_send_account", pkg.RateLimit{Burst: 3, Every: 5 * time.Minute}, byAccount(accountField)},
+	}
+}
+
+func codeLimits() []rateLimitPolicy {
+	return []rateLimitPolicy{
+		{"code_ip", pkg.RateLimit{Burst: 20, Every: 30 * time.Second}, byIP},
+	}
+}
+
+func byIP(c echo.Context) string {
+	return c.RealIP()
+}
+
+// byAccount keys on the signed in caller, or else on the named path
+// parameter or JSON body field
+func byAccount(field string) func(c echo.Context) string {
+	return func(c echo.Context) string {
+		if actor, ok := c.Get(pkg.ActorContextKey).(*structures.Actor); ok {
+			if actor.StaffID != "" {
+				return pkg.StaffRole + ":" + actor.StaffID
+			}
+			return actor.Role + ":" + actor.ID
+		}
+		if value := c.Param(field); value != "" {
+			return strings.ToLower(value)
+		}
+		value, _ := requestBody(c)[field].(string)
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+}
+
+// requestBody decodes the JSON body once and puts it back for the handler
+func requestBody(c echo.Context) map[string]any {
+	if body, ok := c.Get(rateLimitBodyKey).(map[string]any); ok {
+		return body
+	}
+	body := map[string]any{}
+	req := c.Request()
+	if req.Body != nil && strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		raw, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+		if err == nil {
+			_ = json.Unmarshal(raw, &body)
+		}
+	}
+	c.Set(rateLimitBodyKey, body)
+	return body
+}
+
+// postgresRateLimitStore shares buckets between instances through the database
+type postgresRateLimitStore struct {
+	query *queries.Query
+}
+
+func (s *postgresRateLimitStore) Take(ctx context.Context, key string, limit pkg.RateLimit) (time.Duration, error) {
+	return s.query.TakeRateLimitToken(ctx, key, limit)
+}
+
+// newRateLimitStore keeps buckets in memory unless RATE_LIMIT_STORE is
+// "postgres"
+func newRateLimitStore(query *queries.Query) pkg.RateLimitStore {
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "postgres":
+		return &postgresRateLimitStore{query: query}
+	case "", "memory":
+		return pkg.NewMemoryRateLimitStore()
+	default:
+		slog.Warn("unknown rate limit store, keeping buckets in memory", "store", store)
+		return pkg.NewMemoryRateLimitStore()
+	}
+}
@@ -6,24 +6,30 @@ import (
 	"github.com/Srujankm12/paybazar-api/internals/repositories"
 	"github.com/Srujankm12/paybazar-api/pkg"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Routes struct {
 	Query         *queries.Query
 	PasswordUtils *pkg.PasswordUtils
 	JwtUtils      *pkg.JwtUtils
-	TwillioUtils  *pkg.TwillioUtils
+	Notifications *pkg.NotificationService
+	OTPUtils      *pkg.OTPUtils
+	TOTPUtils     *pkg.TOTPUtils
 }
 
-func newRoutes(query *queries.Query) *Routes {
+func newRoutes(query *queries.Query, notifications *pkg.NotificationService) *Routes {
 	var passwordUtils = &pkg.PasswordUtils{}
 	var jwtUtils = &pkg.JwtUtils{}
-	var twillioUtils = &pkg.TwillioUtils{}
+	var otpUtils = &pkg.OTPUtils{}
+	var totpUtils = &pkg.TOTPUtils{}
 	return &Routes{
 		Query:         query,
 		PasswordUtils: passwordUtils,
 		JwtUtils:      jwtUtils,
-		TwillioUtils:  twillioUtils,
+		Notifications: notifications,
+		OTPUtils:      otpUtils,
+		TOTPUtils:     totpUtils,
 	}
 }
 
@@ -33,15 +39,34 @@ func (r *Routes) AdminRoutes(rg *echo.Group) {
 		r.Query,
 		r.JwtUtils,
 		r.PasswordUtils,
-		r.TwillioUtils,
+		r.Notifications,
+		r.OTPUtils,
+		r.TOTPUtils,
 	)
 	var authHandler = handlers.NewAuthHandler(authRepo)
-	rg.POST("/register", authHandler.RegisterAdminRequest)
+	rg.POST("/invite/accept", authHandler.AcceptAdminInviteRequest)
 	rg.POST("/login", authHandler.LoginAdminRequest)
+	rg.POST("/staff/login", authHandler.LoginStaffRequest)
+	rg.POST("/password/change", authHandler.ChangePasswordRequest("ADMIN"))
+	rg.POST("/password/forgot", authHandler.ForgotPasswordRequest("ADMIN"))
+	rg.POST("/password/reset", authHandler.ResetPasswordRequest("ADMIN"))
+	rg.POST("/login/2fa", authHandler.CompleteTwoFactorLoginRequest("ADMIN"))
+	rg.GET("/2fa/status/:id", authHandler.GetTwoFactorStatusRequest("ADMIN"))
+	rg.POST("/2fa/setup", authHandler.SetupTwoFactorRequest("ADMIN"))
+	rg.POST("/2fa/enable", authHandler.EnableTwoFactorRequest("ADMIN"))
+	rg.POST("/2fa/disable", authHandler.DisableTwoFactorRequest("ADMIN"))
+	rg.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodesRequest("ADMIN"))
+	rg.GET("/2fa/policies", authHandler.GetTwoFactorPoliciesRequest)
+	rg.POST("/2fa/policy", authHandler.SetTwoFactorPolicyRequest)
 	rg.POST("/create/md", authHandler.RegisterMasterDistributorRequest)
 	rg.POST("/create/distributor", authHandler.RegisterDistributorRequest)
 	rg.POST("/create/user", authHandler.RegisterUserRequest)
 
+	// Member Import Requests
+	var memberImportRepo = repositories.NewMemberImportRepository(r.Query, r.PasswordUtils)
+	var memberImportHandler = handlers.NewMemberImportHandler(memberImportRepo)
+	rg.POST("/bulk/onboard", memberImportHandler.ImportMembersRequest)
+
 	// Fund Request
 	var fundRequestRepo = repositories.NewFundRequestRepository(
 		r.Query,
@@ -54,6 +79,7 @@ func (r *Routes) AdminRoutes(rg *echo.Group) {
 	// Wallet Request
 	var walletRepo = repositories.NewWalletRepository(
 		r.Query,
+		r.TOTPUtils,
 	)
 	var walletHandler = handlers.NewWalletHandler(walletRepo)
 	rg.GET("/wallet/get/balance/:admin_id", walletHandler.GetAdminWalletBalanceRequest)
@@ -84,6 +110,81 @@ func (r *Routes) AdminRoutes(rg *echo.Group) {
 	var ticketRepo = repositories.NewTicketRepo(r.Query)
 	var ticketHan = handlers.NewTicketHandler(ticketRepo)
 	rg.GET("/get/tickets/:admin_id", ticketHan.GetAllTickets)
+
+	// Audit Requests
+	var auditRepo = repositories.NewAuditRepository(r.Query)
+	var auditHandler = handlers.NewAuditHandler(auditRepo)
+	rg.GET("/audit/logs", auditHandler.GetAuditLogsRequest)
+	rg.GET("/audit/verify", auditHandler.VerifyAuditLogsRequest)
+
+	// Notification Requests
+	var notificationRepo = repositories.NewNotificationRepository(r.Query)
+	var notificationHandler = handlers.NewNotificationHandler(notificationRepo)
+	rg.GET("/notification/preferences/:owner_id", notificationHandler.GetNotificationPreferencesRequest)
+	rg.POST("/notification/preferences", notificationHandler.SetNotificationPreferencesRequest)
+
+	// Device Requests
+	var deviceRepo = repositories.NewDeviceRepository(r.Query)
+	var deviceHandler = handlers.NewDeviceHandler(deviceRepo)
+	rg.GET("/user/devices/:admin_id/:user_id", deviceHandler.GetUserDevicesRequest("ADMIN"))
+	rg.POST("/user/device/revoke", deviceHandler.RevokeUserDeviceRequest("ADMIN"))
+
+	// Admin Management Requests
+	var adminRepo = repositories.NewAdminRepository(r.Query, r.Notifications, r.TOTPUtils)
+	var adminHandler = handlers.NewAdminHandler(adminRepo)
+	rg.POST("/invite", adminHandler.CreateAdminInviteRequest)
+	rg.GET("/invites/:admin_id", adminHandler.GetAdminInvitesRequest)
+	rg.POST("/invite/revoke", adminHandler.RevokeAdminInviteRequest)
+	rg.GET("/admins/:admin_id", adminHandler.GetAdminsRequest)
+	rg.POST("/role", adminHandler.UpdateAdminRoleRequest)
+
+	// Roles & Staff Requests
+	var rbacRepo = repositories.NewRBACRepository(r.Query, r.PasswordUtils)
+	var rbacHandler = handlers.NewRBACHandler(rbacRepo)
+	rg.GET("/rbac/permissions", rbacHandler.GetPermissionsRequest)
+	rg.GET("/rbac/roles", rbacHandler.GetRolesRequest)
+	rg.POST("/rbac/role", rbacHandler.SaveRoleRequest)
+	rg.POST("/rbac/role/delete", rbacHandler.DeleteRoleRequest)
+	rg.POST("/staff", rbacHandler.CreateStaffUserRequest)
+	rg.GET("/staff/:admin_id", rbacHandler.GetStaffUsersRequest)
+	rg.POST("/staff/role", rbacHandler.AssignStaffRoleRequest)
+	rg.POST("/staff/block", rbacHandler.SetStaffBlockedRequest)
+
+	// Approval Requests
+	var approvalRepo = repositories.NewApprovalRepository(r.Query, r.TOTPUtils)
+	var approvalHandler = handlers.NewApprovalHandler(approvalRepo)
+	rg.GET("/approval/policies", approvalHandler.GetApprovalPoliciesRequest)
+	rg.POST("/approval/policy", approvalHandler.SetApprovalPolicyRequest)
+	rg.GET("/approvals/:admin_id", approvalHandler.GetPendingActionsRequest)
+	rg.POST("/approval/approve", approvalHandler.ApprovePendingActionRequest)
+	rg.POST("/approval/reject", approvalHandler.RejectPendingActionRequest)
+
+	// Credit Line Requests
+	var creditLineRepo = repositories.NewCreditLineRepository(r.Query)
+	var creditLineHandler = handlers.NewCreditLineHandler(creditLineRepo)
+	rg.GET("/credit/outstanding/:admin_id", creditLineHandler.GetOutstandingCreditRequest)
+
+	// Ledger Requests
+	var ledgerRepo = repositories.NewLedgerRepository(r.Query)
+	var ledgerHandler = handlers.NewLedgerHandler(ledgerRepo)
+	rg.GET("/ledger/balance/:admin_id/:owner_type/:owner_id", ledgerHandler.GetBalanceAsOfRequest)
+	rg.GET("/ledger/snapshots/:admin_id/:owner_type/:owner_id", ledgerHandler.GetBalanceSnapshotsRequest)
+	rg.POST("/ledger/period/close", ledgerHandler.ClosePeriodRequest)
+	rg.GET("/ledger/periods", ledgerHandler.GetClosedPeriodsRequest)
+
+	// Integrity Requests
+	var integrityRepo = repositories.NewIntegrityRepository(r.Query)
+	var integrityHandler = handlers.NewIntegrityHandler(integrityRepo)
+	rg.GET("/integrity/checks/:admin_id", integrityHandler.GetIntegrityChecksRequest)
+	rg.GET("/integrity/report/:admin_id/:date", integrityHandler.GetIntegrityReportRequest)
+	rg.POST("/integrity/check", integrityHandler.RunIntegrityCheckRequest)
+
+	// Payout Return Requests
+	var payoutReturnRepo = repositories.NewPayoutReturnRepository(r.Query, r.TOTPUtils)
+	var payoutReturnHandler = handlers.NewPayoutReturnHandler(payoutReturnRepo)
+	rg.POST("/payout/return", payoutReturnHandler.RecordPayoutReturnRequest)
+	rg.GET("/payout/returns/:admin_id", payoutReturnHandler.GetPayoutReturnsRequest)
+	rg.GET("/payout/return/rates/:admin_id", payoutReturnHandler.GetBankReturnRatesRequest)
 }
 
 func (r *Routes) MasterDistributorRoutes(rg *echo.Group) {
@@ -92,12 +193,23 @@ func (r *Routes) MasterDistributorRoutes(rg *echo.Group) {
 		r.Query,
 		r.JwtUtils,
 		r.PasswordUtils,
-		r.TwillioUtils,
+		r.Notifications,
+		r.OTPUtils,
+		r.TOTPUtils,
 	)
 	var authHandler = handlers.NewAuthHandler(authRepo)
 	rg.POST("/login", authHandler.LoginMasterDistributorRequest)
 	rg.GET("/get/profile/:master_distributor_id", authHandler.GetMasterDistributorProfileRequest)
 	rg.POST("/update/profile", authHandler.UpdateMasterDistributorProfileRequest)
+	rg.POST("/password/change", authHandler.ChangePasswordRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/password/forgot", authHandler.ForgotPasswordRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/password/reset", authHandler.ResetPasswordRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/login/2fa", authHandler.CompleteTwoFactorLoginRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/2fa/status/:id", authHandler.GetTwoFactorStatusRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/2fa/setup", authHandler.SetupTwoFactorRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/2fa/enable", authHandler.EnableTwoFactorRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/2fa/disable", authHandler.DisableTwoFactorRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodesRequest("MASTER_DISTRIBUTOR"))
 
 	// Fund Request
 	var fundRequestRepo = repositories.NewFundRequestRepository(
@@ -110,6 +222,7 @@ func (r *Routes) MasterDistributorRoutes(rg *echo.Group) {
 	// Wallet Request
 	var walletRepo = repositories.NewWalletRepository(
 		r.Query,
+		r.TOTPUtils,
 	)
 	var walletHandler = handlers.NewWalletHandler(walletRepo)
 	rg.GET("/wallet/get/balance/:master_distributor_id", walletHandler.GetMasterDistributorWalletBalanceRequest)
@@ -118,6 +231,37 @@ func (r *Routes) MasterDistributorRoutes(rg *echo.Group) {
 	rg.POST("/fund/distributor", walletHandler.MasterDistributorFundDistributorRequest)
 	rg.POST("/refund/retailer", walletHandler.MasterDistributorRefundUserRequest)
 	rg.POST("/refund/distributor", walletHandler.MasterDistributorRefundDistributorRequest)
+
+	// Notification Requests
+	var notificationRepo = repositories.NewNotificationRepository(r.Query)
+	var notificationHandler = handlers.NewNotificationHandler(notificationRepo)
+	rg.GET("/notification/preferences/:owner_id", notificationHandler.GetNotificationPreferencesRequest)
+	rg.POST("/notification/preferences", notificationHandler.SetNotificationPreferencesRequest)
+
+	// Scheduled Transfer Requests
+	var scheduledTransferRepo = repositories.NewScheduledTransferRepository(r.Query)
+	var scheduledTransferHandler = handlers.NewScheduledTransferHandler(scheduledTransferRepo)
+	rg.POST("/scheduled/transfer", scheduledTransferHandler.CreateScheduledTransferRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/scheduled/transfers/:master_distributor_id", scheduledTransferHandler.GetScheduledTransfersRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/scheduled/transfer/status", scheduledTransferHandler.SetScheduledTransferStatusRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/scheduled/transfer/delete", scheduledTransferHandler.DeleteScheduledTransferRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/scheduled/transfer/runs/:master_distributor_id/:schedule_id", scheduledTransferHandler.GetScheduledTransferRunsRequest("MASTER_DISTRIBUTOR"))
+
+	// Replenishment Requests
+	var replenishmentRepo = repositories.NewReplenishmentRepository(r.Query)
+	var replenishmentHandler = handlers.NewReplenishmentHandler(replenishmentRepo)
+	rg.POST("/replenishment/rule", replenishmentHandler.SaveReplenishmentRuleRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/replenishment/rules/:master_distributor_id", replenishmentHandler.GetReplenishmentRulesRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/replenishment/rule/delete", replenishmentHandler.DeleteReplenishmentRuleRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/replenishment/runs/:master_distributor_id/:user_id", replenishmentHandler.GetReplenishmentRunsRequest("MASTER_DISTRIBUTOR"))
+
+	// Credit Line Requests
+	var creditLineRepo = repositories.NewCreditLineRepository(r.Query)
+	var creditLineHandler = handlers.NewCreditLineHandler(creditLineRepo)
+	rg.POST("/credit/line", creditLineHandler.SaveCreditLineRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/credit/lines/:master_distributor_id", creditLineHandler.GetCreditLinesRequest("MASTER_DISTRIBUTOR"))
+	rg.POST("/credit/line/delete", creditLineHandler.DeleteCreditLineRequest("MASTER_DISTRIBUTOR"))
+	rg.GET("/credit/line/events/:master_distributor_id/:member_id", creditLineHandler.GetCreditLineEventsRequest("MASTER_DISTRIBUTOR"))
 }
 
 func (r *Routes) DistributorRoutes(rg *echo.Group) {
@@ -126,12 +270,17 @@ func (r *Routes) DistributorRoutes(rg *echo.Group) {
 		r.Query,
 		r.JwtUtils,
 		r.PasswordUtils,
-		r.TwillioUtils,
+		r.Notifications,
+		r.OTPUtils,
+		r.TOTPUtils,
 	)
 	var authHandler = handlers.NewAuthHandler(authRepo)
 	rg.POST("/login", authHandler.LoginDistributorRequest)
 	rg.GET("/get/profile/:distributor_id", authHandler.GetDistributorProfileRequest)
 	rg.POST("/update/profile", authHandler.UpdateDistributorProfileRequest)
+	rg.POST("/password/change", authHandler.ChangePasswordRequest("DISTRIBUTOR"))
+	rg.POST("/password/forgot", authHandler.ForgotPasswordRequest("DISTRIBUTOR"))
+	rg.POST("/password/reset", authHandler.ResetPasswordRequest("DISTRIBUTOR"))
 
 	// Fund Request
 	var fundRequestRepo = repositories.NewFundRequestRepository(
@@ -144,12 +293,51 @@ func (r *Routes) DistributorRoutes(rg *echo.Group) {
 	// Wallet Request
 	var walletRepo = repositories.NewWalletRepository(
 		r.Query,
+		r.TOTPUtils,
 	)
 	var walletHandler = handlers.NewWalletHandler(walletRepo)
 	rg.GET("/wallet/get/balance/:distributor_id", walletHandler.GetDistributorWalletBalanceRequest)
 	rg.GET("/wallet/get/transactions/:id", walletHandler.GetTransactionsRequest)
 	rg.POST("/fund/retailer", walletHandler.DistributorFundRetailerRequest)
 	rg.POST("/refund/retailer", walletHandler.DistributorRefundUserRequest)
+
+	// Notification Requests
+	var notificationRepo = repositories.NewNotificationRepository(r.Query)
+	var notificationHandler = handlers.NewNotificationHandler(notificationRepo)
+	rg.GET("/notification/preferences/:owner_id", notificationHandler.GetNotificationPreferencesRequest)
+	rg.POST("/notification/preferences", notificationHandler.SetNotificationPreferencesRequest)
+
+	// Device Requests
+	var deviceRepo = repositories.NewDeviceRepository(r.Query)
+	var deviceHandler = handlers.NewDeviceHandler(deviceRepo)
+	rg.GET("/user/devices/:distributor_id/:user_id", deviceHandler.GetUserDevicesRequest("DISTRIBUTOR"))
+	rg.POST("/user/device/revoke", deviceHandler.RevokeUserDeviceRequest("DISTRIBUTOR"))
+
+	// Scheduled Transfer Requests
+	var scheduledTransferRepo = repositories.NewScheduledTransferRepository(r.Query)
+	var scheduledTransferHandler = handlers.NewScheduledTransferHandler(scheduledTransferRepo)
+	rg.POST("/scheduled/transfer", scheduledTransferHandler.CreateScheduledTransferRequest("DISTRIBUTOR"))
+	rg.GET("/scheduled/transfers/:distributor_id", scheduledTransferHandler.GetScheduledTransfersRequest("DISTRIBUTOR"))
+	rg.POST("/scheduled/transfer/status", scheduledTransferHandler.SetScheduledTransferStatusRequest("DISTRIBUTOR"))
+	rg.POST("/scheduled/transfer/delete", scheduledTransferHandler.DeleteScheduledTransferRequest("DISTRIBUTOR"))
+	rg.GET("/scheduled/transfer/runs/:distributor_id/:schedule_id", scheduledTransferHandler.GetScheduledTransferRunsRequest("DISTRIBUTOR"))
+
+	// Replenishment Requests
+	var replenishmentRepo = repositories.NewReplenishmentRepository(r.Query)
+	var replenishmentHandler = handlers.NewReplenishmentHandler(replenishmentRepo)
+	rg.POST("/replenishment/rule", replenishmentHandler.SaveReplenishmentRuleRequest("DISTRIBUTOR"))
+	rg.GET("/replenishment/rules/:distributor_id", replenishmentHandler.GetReplenishmentRulesRequest("DISTRIBUTOR"))
+	rg.POST("/replenishment/rule/delete", replenishmentHandler.DeleteReplenishmentRuleRequest("DISTRIBUTOR"))
+	rg.GET("/replenishment/runs/:distributor_id/:user_id", replenishmentHandler.GetReplenishmentRunsRequest("DISTRIBUTOR"))
+
+	// Credit Line Requests
+	var creditLineRepo = repositories.NewCreditLineRepository(r.Query)
+	var creditLineHandler = handlers.NewCreditLineHandler(creditLineRepo)
+	rg.POST("/credit/line", creditLineHandler.SaveCreditLineRequest("DISTRIBUTOR"))
+	rg.GET("/credit/lines/:distributor_id", creditLineHandler.GetCreditLinesRequest("DISTRIBUTOR"))
+	rg.POST("/credit/line/delete", creditLineHandler.DeleteCreditLineRequest("DISTRIBUTOR"))
+	rg.GET("/credit/line/events/:distributor_id/:member_id", creditLineHandler.GetCreditLineEventsRequest("DISTRIBUTOR"))
+	rg.GET("/credit/line/own/:distributor_id", creditLineHandler.GetOwnCreditLineRequest("DISTRIBUTOR"))
 }
 
 func (r *Routes) UserRoutes(rg *echo.Group) {
@@ -158,16 +346,27 @@ func (r *Routes) UserRoutes(rg *echo.Group) {
 		r.Query,
 		r.JwtUtils,
 		r.PasswordUtils,
-		r.TwillioUtils,
+		r.Notifications,
+		r.OTPUtils,
+		r.TOTPUtils,
 	)
 
 	var authHandler = handlers.NewAuthHandler(authRepo)
 	rg.POST("/login/send/otp", authHandler.LoginUserSendOTPRequest)
 	rg.POST("/login/validate/otp", authHandler.LoginUserValidateOTPRequest)
+	rg.POST("/login/password", authHandler.LoginUserWithPasswordRequest)
+	rg.POST("/login/device/verify", authHandler.VerifyUserDeviceRequest)
 	rg.POST("/set/mpin", authHandler.SetMpinRequest)
 	rg.POST("/verify/mpin", authHandler.VerifyMPINRequest)
+	rg.POST("/mpin/send/otp", authHandler.SendMpinResetOTPRequest)
+	rg.POST("/mpin/reset", authHandler.ResetMpinRequest)
+	rg.POST("/mpin/change", authHandler.ChangeMpinRequest)
+	rg.POST("/otp/send", authHandler.SendUserOTPRequest)
 	rg.GET("/get/profile/:user_id", authHandler.GetUserProfileRequest)
 	rg.POST("/update/profile", authHandler.UpdateUserProfileRequest)
+	rg.POST("/password/change", authHandler.ChangePasswordRequest("USER"))
+	rg.POST("/password/forgot", authHandler.ForgotPasswordRequest("USER"))
+	rg.POST("/password/reset", authHandler.ResetPasswordRequest("USER"))
 
 	// Fund Request
 	var fundRequestRepo = repositories.NewFundRequestRepository(
@@ -180,15 +379,18 @@ func (r *Routes) UserRoutes(rg *echo.Group) {
 	// Wallet Request
 	var walletRepo = repositories.NewWalletRepository(
 		r.Query,
+		r.TOTPUtils,
 	)
 	var walletHandler = handlers.NewWalletHandler(walletRepo)
 	rg.GET("/wallet/get/balance/:user_id", walletHandler.GetUserWalletBalanceRequest)
+	rg.GET("/wallet/holds/:user_id", walletHandler.GetUserWalletHoldsRequest)
 	rg.GET("/wallet/get/transactions/:id", walletHandler.GetTransactionsRequest)
 
 	// Payout Request
 	var payoutRepo = repositories.NewPayoutRepository(
 		r.Query,
 		r.JwtUtils,
+		r.PasswordUtils,
 	)
 	var payoutHandler = handlers.NewPayoutHandler(payoutRepo)
 	rg.POST("/payout", payoutHandler.PayoutRequest)
@@ -196,6 +398,10 @@ func (r *Routes) UserRoutes(rg *echo.Group) {
 	rg.GET("/payout/:user_id/:phone/:account_number/:ifsc", payoutHandler.VerifyPayoutAccountNumber)
 	rg.GET("/payout/refund/:transaction_id", payoutHandler.PayoutTransactionRefund)
 	rg.GET("/payout/report/:user_id" , payoutHandler.GetPayoutReportRequest)
+	rg.POST("/payout/batch", payoutHandler.CreatePayoutBatchRequest)
+	rg.GET("/payout/batches/:user_id", payoutHandler.GetPayoutBatchesRequest)
+	rg.GET("/payout/batch/:user_id/:batch_id", payoutHandler.GetPayoutBatchRequest)
+	rg.GET("/payout/batch/result/:user_id/:batch_id", payoutHandler.GetPayoutBatchResultRequest)
 
 	// Bank Requests
 	var bankRepo = repositories.NewBankRepo(r.Query)
@@ -215,4 +421,32 @@ func (r *Routes) UserRoutes(rg *echo.Group) {
 	var ticketRepo = repositories.NewTicketRepo(r.Query)
 	var ticketHan = handlers.NewTicketHandler(ticketRepo)
 	rg.POST("/add/ticket", ticketHan.AddNewTicket)
+
+	// Notification Requests
+	var notificationRepo = repositories.NewNotificationRepository(r.Query)
+	var notificationHandler = handlers.NewNotificationHandler(notificationRepo)
+	rg.GET("/notification/preferences/:owner_id", notificationHandler.GetNotificationPreferencesRequest)
+	rg.POST("/notification/preferences", notificationHandler.SetNotificationPreferencesRequest)
+
+	// Device Requests
+	var deviceRepo = repositories.NewDeviceRepository(r.Query)
+	var deviceHandler = handlers.NewDeviceHandler(deviceRepo)
+	rg.GET("/devices/:user_id", deviceHandler.GetUserDevicesRequest("USER"))
+	rg.POST("/device/revoke", deviceHandler.RevokeUserDeviceRequest("USER"))
+
+	// Credit Line Requests
+	var creditLineRepo = repositories.NewCreditLineRepository(r.Query)
+	var creditLineHandler = handlers.NewCreditLineHandler(creditLineRepo)
+	rg.GET("/credit/line/:user_id", creditLineHandler.GetOwnCreditLineRequest("USER"))
+}
+
+func (r *Routes) SystemRoutes(rg *echo.Group) {
+	// Health Requests
+	var healthRepo = repositories.NewHealthRepository(r.Query)
+	var healthHandler = handlers.NewHealthHandler(healthRepo)
+	rg.GET("/healthz", healthHandler.LivenessRequest)
+	rg.GET("/readyz", healthHandler.ReadinessRequest)
+
+	// Metrics
+	rg.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(pkg.MetricsRegistry, promhttp.HandlerOpts{})))
 }
@@ -1,15 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	// "github.com/Shubhangcs/South_canara_agromart_main_http_server/internals/models/queries"
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/pkg"
 	"github.com/labstack/echo/v4"
 )
 
 func start() {
+	// Structured logger used by the whole application
+	slog.SetDefault(pkg.NewLogger())
+
+	// Tracing, exported as configured in the .env file
+	shutdownTracing, err := pkg.SetupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+
+	// Two-factor secrets are sealed with a key from the .env file
+	if err := pkg.CheckTOTPEncryptionKey(); err != nil {
+		log.Fatalf("failed to set up two-factor authentication: %v", err)
+	}
+
 	// Creating a new echo router
 	var router *echo.Echo = echo.New()
 
@@ -30,14 +53,30 @@ func start() {
 	router.Validator = newValidator()
 
 	// middlewares
-	var middlewares *Middlewares = newMiddleware()
+	var middlewares *Middlewares = newMiddleware(&pkg.JwtUtils{}, query, newRateLimitStore(query))
+	router.Use(middlewares.RequestIDMiddleware)
+	router.Use(middlewares.TracingMiddleware)
+	router.Use(middlewares.MetricsMiddleware)
 	router.Use(middlewares.LoggerMiddleware)
 	router.Use(middlewares.CORSMiddleware)
+	router.Use(middlewares.ActorMiddleware)
+	router.Use(middlewares.RateLimitMiddleware)
+	router.Use(middlewares.DeviceSessionMiddleware)
+
+	// metrics read from the database on every scrape
+	pkg.MetricsRegistry.MustRegister(newDatabaseCollector(query))
 
+	// notifications, delivered in the background
+	notifications, err := pkg.NewNotificationService()
+	if err != nil {
+		log.Fatalf("failed to set up notifications: %v", err)
+	}
+	notifications.Start()
 
 	// routes
-	var routes *Routes = newRoutes(query)
-	adminRouterGroup := router.Group("/admin")
+	var routes *Routes = newRoutes(query, notifications)
+	routes.SystemRoutes(router.Group(""))
+	adminRouterGroup := router.Group("/admin", middlewares.AdminPermissionMiddleware)
 	userRouterGroup := router.Group("/user")
 	distributorRouterGroup := router.Group("/distributor")
 	masterDistributorRouterGroup := router.Group("/md")
@@ -51,5 +90,41 @@ func start() {
 	if serverPort == "" {
 		log.Fatalln("failed to start server, server port not declared in .env file")
 	}
-	log.Fatal(router.Start(serverPort))
+
+	// Stop on SIGINT / SIGTERM, or when the listener fails
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// background jobs, stopped together with the server
+	startHousekeeping(ctx, query)
+	startNotificationDispatcher(ctx, query, notifications)
+	startTransferScheduler(ctx, query)
+
+	go func() {
+		if err := router.Start(serverPort); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server stopped unexpectedly", "error", err)
+			stop()
+		}
+	}()
+	<-ctx.Done()
+
+	// Let in-flight requests finish before the database pool is closed
+	slog.Info("shutting down server", "timeout", shutdownTimeout().String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	if err := router.Shutdown(shutdownCtx); err != nil {
+		slog.Error("graceful shutdown did not complete", "error", err)
+	}
+	if err := notifications.Shutdown(shutdownCtx); err != nil {
+		slog.Error("notification queue not drained", "error", err)
+	}
+}
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT (e.g. "30s"), defaulting to 30 seconds
+func shutdownTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return 30 * time.Second
+	}
+	return timeout
 }
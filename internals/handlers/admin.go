@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type adminHandler struct {
+	adminRepo interfaces.AdminInterface
+}
+
+func NewAdminHandler(adminRepo interfaces.AdminInterface) *adminHandler {
+	return &adminHandler{
+		adminRepo: adminRepo,
+	}
+}
+
+func adminRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.AdminResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.AdminResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (ah *adminHandler) CreateAdminInviteRequest(e echo.Context) error {
+	res, err := ah.adminRepo.CreateAdminInvite(e)
+	if err != nil {
+		return adminRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AdminResponse{Message: "admin invite created successfully", Status: "success", Data: res})
+}
+
+func (ah *adminHandler) GetAdminInvitesRequest(e echo.Context) error {
+	res, err := ah.adminRepo.GetAdminInvites(e)
+	if err != nil {
+		return adminRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AdminResponse{Message: "admin invites fetched successfully", Status: "success", Data: res})
+}
+
+func (ah *adminHandler) RevokeAdminInviteRequest(e echo.Context) error {
+	if err := ah.adminRepo.RevokeAdminInvite(e); err != nil {
+		return adminRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AdminResponse{Message: "admin invite revoked successfully", Status: "success"})
+}
+
+func (ah *adminHandler) GetAdminsRequest(e echo.Context) error {
+	res, err := ah.adminRepo.GetAdmins(e)
+	if err != nil {
+		return adminRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AdminResponse{Message: "admins fetched successfully", Status: "success", Data: res})
+}
+
+func (ah *adminHandler) UpdateAdminRoleRequest(e echo.Context) error {
+	if err := ah.adminRepo.UpdateAdminRole(e); err != nil {
+		return adminRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AdminResponse{Message: "admin role updated successfully", Status: "success"})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type approvalHandler struct {
+	approvalRepo interfaces.ApprovalInterface
+}
+
+func NewApprovalHandler(approvalRepo interfaces.ApprovalInterface) *approvalHandler {
+	return &approvalHandler{
+		approvalRepo: approvalRepo,
+	}
+}
+
+func approvalRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.ApprovalResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.ApprovalResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (ah *approvalHandler) GetApprovalPoliciesRequest(e echo.Context) error {
+	res, err := ah.approvalRepo.GetApprovalPolicies(e)
+	if err != nil {
+		return approvalRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.ApprovalResponse{Message: "approval policies fetched successfully", Status: "success", Data: res})
+}
+
+func (ah *approvalHandler) SetApprovalPolicyRequest(e echo.Context) error {
+	if err := ah.approvalRepo.SetApprovalPolicy(e); err != nil {
+		return approvalRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.ApprovalResponse{Message: "approval policy updated successfully", Status: "success"})
+}
+
+func (ah *approvalHandler) GetPendingActionsRequest(e echo.Context) error {
+	res, err := ah.approvalRepo.GetPendingActions(e)
+	if err != nil {
+		return approvalRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.ApprovalResponse{Message: "pending actions fetched successfully", Status: "success", Data: res})
+}
+
+func (ah *approvalHandler) ApprovePendingActionRequest(e echo.Context) error {
+	res, err := ah.approvalRepo.ApprovePendingAction(e)
+	if err != nil {
+		return approvalRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.ApprovalResponse{Message: "pending action approved and executed", Status: "success", Data: res})
+}
+
+func (ah *approvalHandler) RejectPendingActionRequest(e echo.Context) error {
+	res, err := ah.approvalRepo.RejectPendingAction(e)
+	if err != nil {
+		return approvalRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.ApprovalResponse{Message: "pending action rejected", Status: "success", Data: res})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type auditHandler struct {
+	auditRepo interfaces.AuditInterface
+}
+
+func NewAuditHandler(auditRepo interfaces.AuditInterface) *auditHandler {
+	return &auditHandler{
+		auditRepo: auditRepo,
+	}
+}
+
+// respondWithError inspects error; if it's an *echo.HTTPError use its code/message,
+// otherwise return 500 and a generic message.
+func auditRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.AuditResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.AuditResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (ah *auditHandler) GetAuditLogsRequest(e echo.Context) error {
+	res, err := ah.auditRepo.GetAuditLogs(e)
+	if err != nil {
+		return auditRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuditResponse{
+		Message: "audit logs fetched successfully",
+		Status:  "success",
+		Data:    map[string]any{"audit_logs": res},
+	})
+}
+
+func (ah *auditHandler) VerifyAuditLogsRequest(e echo.Context) error {
+	res, err := ah.auditRepo.VerifyAuditLogs(e)
+	if err != nil {
+		return auditRespondWithError(e, err)
+	}
+	message := "audit log chain is intact"
+	if !res.Valid {
+		message = "audit log chain is broken"
+	}
+	return e.JSON(http.StatusOK, structures.AuditResponse{
+		Message: message,
+		Status:  "success",
+		Data:    res,
+	})
+}
@@ -30,8 +30,8 @@ func authRespondWithError(e echo.Context, err error) error {
 	return e.JSON(http.StatusInternalServerError, structures.AuthResponse{Message: "Internal server error", Status: "failed"})
 }
 
-func (ah *authHandler) RegisterAdminRequest(e echo.Context) error {
-	token, err := ah.authRepo.RegisterAdmin(e)
+func (ah *authHandler) AcceptAdminInviteRequest(e echo.Context) error {
+	token, err := ah.authRepo.AcceptAdminInvite(e)
 	if err != nil {
 		return authRespondWithError(e, err)
 	}
@@ -62,20 +62,43 @@ func (ah *authHandler) RegisterUserRequest(e echo.Context) error {
 	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "user registration successful", Status: "success", Data: map[string]string{"token": token}})
 }
 
+// loginMessage tells a completed login apart from one waiting for a second
+// factor or a new device to be verified
+func loginMessage(res *structures.LoginResult, completed string) string {
+	if res.TwoFactorEnrollmentRequired {
+		return "two-factor enrolment required"
+	}
+	if res.TwoFactorRequired {
+		return "two-factor verification required"
+	}
+	if res.DeviceVerificationRequired {
+		return "device verification required"
+	}
+	return completed
+}
+
 func (ah *authHandler) LoginAdminRequest(e echo.Context) error {
-	token, err := ah.authRepo.LoginAdmin(e)
+	res, err := ah.authRepo.LoginAdmin(e)
 	if err != nil {
 		return authRespondWithError(e, err)
 	}
-	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "admin login successful", Status: "success", Data: map[string]string{"token": token}})
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: loginMessage(res, "admin login successful"), Status: "success", Data: res})
 }
 
 func (ah *authHandler) LoginMasterDistributorRequest(e echo.Context) error {
-	token, err := ah.authRepo.LoginMasterDistributor(e)
+	res, err := ah.authRepo.LoginMasterDistributor(e)
 	if err != nil {
 		return authRespondWithError(e, err)
 	}
-	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "master distributor login successful", Status: "success", Data: map[string]string{"token": token}})
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: loginMessage(res, "master distributor login successful"), Status: "success", Data: res})
+}
+
+func (ah *authHandler) LoginStaffRequest(e echo.Context) error {
+	res, err := ah.authRepo.LoginStaff(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: loginMessage(res, "staff login successful"), Status: "success", Data: res})
 }
 
 func (ah *authHandler) LoginDistributorRequest(e echo.Context) error {
@@ -95,11 +118,11 @@ func (ah *authHandler) LoginUserSendOTPRequest(e echo.Context) error {
 }
 
 func (ah *authHandler) LoginUserValidateOTPRequest(e echo.Context) error {
-	token, err := ah.authRepo.LoginUserValidateOTP(e)
+	res, err := ah.authRepo.LoginUserValidateOTP(e)
 	if err != nil {
 		return authRespondWithError(e, err)
 	}
-	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "user login successful", Status: "success", Data: map[string]string{"token": token}})
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: loginMessage(res, "user login successful"), Status: "success", Data: res})
 }
 
 func (ah *authHandler) SetMpinRequest(e echo.Context) error {
@@ -126,6 +149,79 @@ func (ah *authHandler) VerifyMPINRequest(e echo.Context) error {
 	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "mpin verification successful", Status: "success"})
 }
 
+func (ah *authHandler) SendMpinResetOTPRequest(e echo.Context) error {
+	message, err := ah.authRepo.SendMpinResetOTP(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: message, Status: "success"})
+}
+
+func (ah *authHandler) SendUserOTPRequest(e echo.Context) error {
+	message, err := ah.authRepo.SendUserOTP(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: message, Status: "success"})
+}
+
+func (ah *authHandler) ResetMpinRequest(e echo.Context) error {
+	err := ah.authRepo.ResetUserMpin(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "mpin reset successful", Status: "success"})
+}
+
+func (ah *authHandler) ChangeMpinRequest(e echo.Context) error {
+	err := ah.authRepo.ChangeUserMpin(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "mpin changed successfully", Status: "success"})
+}
+
+func (ah *authHandler) LoginUserWithPasswordRequest(e echo.Context) error {
+	res, err := ah.authRepo.LoginUserWithPassword(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: loginMessage(res, "user login successful"), Status: "success", Data: res})
+}
+
+// ChangePasswordRequest changes the password of an account of the role
+func (ah *authHandler) ChangePasswordRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		err := ah.authRepo.ChangePassword(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.AuthResponse{Message: "password changed successfully", Status: "success"})
+	}
+}
+
+// ForgotPasswordRequest sends a password reset OTP for an account of the role
+func (ah *authHandler) ForgotPasswordRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		message, err := ah.authRepo.ForgotPassword(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.AuthResponse{Message: message, Status: "success"})
+	}
+}
+
+// ResetPasswordRequest resets the password of an account of the role with an OTP
+func (ah *authHandler) ResetPasswordRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		err := ah.authRepo.ResetPassword(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.AuthResponse{Message: "password reset successful", Status: "success"})
+	}
+}
+
 func (ah *authHandler) GetUserProfileRequest(e echo.Context) error {
 	res, err := ah.authRepo.GetUserProfile(e)
 	if err != nil {
@@ -184,3 +280,11 @@ func (ah *authHandler) GetDistributorProfileRequest(e echo.Context) error {
 		},
 	})
 }
+
+func (ah *authHandler) VerifyUserDeviceRequest(e echo.Context) error {
+	res, err := ah.authRepo.VerifyUserDevice(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.AuthResponse{Message: "device verified, user login successful", Status: "success", Data: res})
+}
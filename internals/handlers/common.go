@@ -38,7 +38,7 @@ func commonRespondWithError(e echo.Context, err error) error {
 // Get all master distributors under an admin
 func (ch *commonHandler) GetAllMasterDistributorsByAdminID(e echo.Context) error {
 	adminID := e.Param("admin_id")
-	res, err := ch.commonRepo.GetAllMasterDistributorsByAdminID(adminID)
+	res, err := ch.commonRepo.GetAllMasterDistributorsByAdminID(e.Request().Context(), adminID)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -61,7 +61,7 @@ func (ch *commonHandler) GetAllMasterDistributorsByAdminID(e echo.Context) error
 // Get all distributors under a master distributor
 func (ch *commonHandler) GetAllDistributorsByMasterDistributorID(e echo.Context) error {
 	masterDistributorID := e.Param("master_distributor_id")
-	res, err := ch.commonRepo.GetAllDistributorsByMasterDistributorID(masterDistributorID)
+	res, err := ch.commonRepo.GetAllDistributorsByMasterDistributorID(e.Request().Context(), masterDistributorID)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -84,7 +84,7 @@ func (ch *commonHandler) GetAllDistributorsByMasterDistributorID(e echo.Context)
 // Get all users under a distributor
 func (ch *commonHandler) GetAllUsersByDistributorID(e echo.Context) error {
 	distributorID := e.Param("distributor_id")
-	res, err := ch.commonRepo.GetAllUsersByDistributorID(distributorID)
+	res, err := ch.commonRepo.GetAllUsersByDistributorID(e.Request().Context(), distributorID)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -107,7 +107,7 @@ func (ch *commonHandler) GetAllUsersByDistributorID(e echo.Context) error {
 // Get all distributors under a master distributor
 func (ch *commonHandler) GetAllDistributorsByAdminID(e echo.Context) error {
 	masterDistributorID := e.Param("admin_id")
-	res, err := ch.commonRepo.GetAllDistributorsByAdminID(masterDistributorID)
+	res, err := ch.commonRepo.GetAllDistributorsByAdminID(e.Request().Context(), masterDistributorID)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -130,7 +130,7 @@ func (ch *commonHandler) GetAllDistributorsByAdminID(e echo.Context) error {
 // Get all users under a distributor
 func (ch *commonHandler) GetAllUsersByAdminID(e echo.Context) error {
 	distributorID := e.Param("admin_id")
-	res, err := ch.commonRepo.GetAllUsersByAdminID(distributorID)
+	res, err := ch.commonRepo.GetAllUsersByAdminID(e.Request().Context(), distributorID)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -152,7 +152,7 @@ func (ch *commonHandler) GetAllUsersByAdminID(e echo.Context) error {
 
 func (ch *commonHandler) GetUserByPhone(e echo.Context) error {
 	phone := e.Param("phone")
-	res, err := ch.commonRepo.GetUserByPhoneNumber(phone)
+	res, err := ch.commonRepo.GetUserByPhoneNumber(e.Request().Context(), phone)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -165,7 +165,7 @@ func (ch *commonHandler) GetUserByPhone(e echo.Context) error {
 
 func (ch *commonHandler) GetMasterDistributorByPhone(e echo.Context) error {
 	phone := e.Param("phone")
-	res, err := ch.commonRepo.GetMasterDistributorByPhoneNumber(phone)
+	res, err := ch.commonRepo.GetMasterDistributorByPhoneNumber(e.Request().Context(), phone)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
@@ -178,7 +178,7 @@ func (ch *commonHandler) GetMasterDistributorByPhone(e echo.Context) error {
 
 func (ch *commonHandler) GetDistributorByPhone(e echo.Context) error {
 	phone := e.Param("phone")
-	res, err := ch.commonRepo.GetDistributorByPhoneNumber(phone)
+	res, err := ch.commonRepo.GetDistributorByPhoneNumber(e.Request().Context(), phone)
 	if err != nil {
 		return commonRespondWithError(e, err)
 	}
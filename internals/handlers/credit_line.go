@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type creditLineHandler struct {
+	creditLineRepo interfaces.CreditLineInterface
+}
+
+func NewCreditLineHandler(creditLineRepo interfaces.CreditLineInterface) *creditLineHandler {
+	return &creditLineHandler{
+		creditLineRepo: creditLineRepo,
+	}
+}
+
+func creditRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.CreditResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.CreditResponse{Message: "Internal server error", Status: "failed"})
+}
+
+// SaveCreditLineRequest extends credit to a member for a lender of the role
+func (ch *creditLineHandler) SaveCreditLineRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ch.creditLineRepo.SaveCreditLine(e, role)
+		if err != nil {
+			return creditRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.CreditResponse{Message: "credit line saved successfully", Status: "success", Data: map[string]any{"credit_line": res}})
+	}
+}
+
+func (ch *creditLineHandler) GetCreditLinesRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ch.creditLineRepo.GetCreditLines(e, role)
+		if err != nil {
+			return creditRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.CreditResponse{Message: "credit lines fetched successfully", Status: "success", Data: map[string]any{"credit_lines": res}})
+	}
+}
+
+// GetOwnCreditLineRequest returns the credit line of a member of the role
+func (ch *creditLineHandler) GetOwnCreditLineRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ch.creditLineRepo.GetOwnCreditLine(e, role)
+		if err != nil {
+			return creditRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.CreditResponse{Message: "credit line fetched successfully", Status: "success", Data: map[string]any{"credit_line": res}})
+	}
+}
+
+func (ch *creditLineHandler) GetOutstandingCreditRequest(e echo.Context) error {
+	res, err := ch.creditLineRepo.GetOutstandingCredit(e)
+	if err != nil {
+		return creditRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.CreditResponse{Message: "outstanding credit fetched successfully", Status: "success", Data: map[string]any{"credit_lines": res}})
+}
+
+func (ch *creditLineHandler) DeleteCreditLineRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if err := ch.creditLineRepo.DeleteCreditLine(e, role); err != nil {
+			return creditRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.CreditResponse{Message: "credit line deleted successfully", Status: "success"})
+	}
+}
+
+func (ch *creditLineHandler) GetCreditLineEventsRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ch.creditLineRepo.GetCreditLineEvents(e, role)
+		if err != nil {
+			return creditRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.CreditResponse{Message: "credit line history fetched successfully", Status: "success", Data: map[string]any{"events": res}})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type deviceHandler struct {
+	deviceRepo interfaces.DeviceInterface
+}
+
+func NewDeviceHandler(deviceRepo interfaces.DeviceInterface) *deviceHandler {
+	return &deviceHandler{
+		deviceRepo: deviceRepo,
+	}
+}
+
+func deviceRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.DeviceResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.DeviceResponse{Message: "Internal server error", Status: "failed"})
+}
+
+// GetUserDevicesRequest lists a retailer's devices for a caller of the role
+func (dh *deviceHandler) GetUserDevicesRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := dh.deviceRepo.GetUserDevices(e, role)
+		if err != nil {
+			return deviceRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.DeviceResponse{Message: "devices fetched successfully", Status: "success", Data: map[string]any{"devices": res}})
+	}
+}
+
+// RevokeUserDeviceRequest revokes a retailer's device for a caller of the role
+func (dh *deviceHandler) RevokeUserDeviceRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if err := dh.deviceRepo.RevokeUserDevice(e, role); err != nil {
+			return deviceRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.DeviceResponse{Message: "device revoked successfully", Status: "success"})
+	}
+}
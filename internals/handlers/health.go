@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type healthHandler struct {
+	healthRepo interfaces.HealthInterface
+}
+
+func NewHealthHandler(healthRepo interfaces.HealthInterface) *healthHandler {
+	return &healthHandler{
+		healthRepo: healthRepo,
+	}
+}
+
+// LivenessRequest only reports that the process is serving requests
+func (hh *healthHandler) LivenessRequest(e echo.Context) error {
+	return e.JSON(http.StatusOK, structures.HealthResponse{
+		Message: "service is alive",
+		Status:  "success",
+	})
+}
+
+// ReadinessRequest reports whether the database and providers are usable
+func (hh *healthHandler) ReadinessRequest(e echo.Context) error {
+	report := hh.healthRepo.Readiness(e)
+	if !report.Ready {
+		return e.JSON(http.StatusServiceUnavailable, structures.HealthResponse{
+			Message: "service is not ready",
+			Status:  "failed",
+			Data:    report,
+		})
+	}
+	return e.JSON(http.StatusOK, structures.HealthResponse{
+		Message: "service is ready",
+		Status:  "success",
+		Data:    report,
+	})
+}
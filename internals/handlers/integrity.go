@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type integrityHandler struct {
+	integrityRepo interfaces.IntegrityInterface
+}
+
+func NewIntegrityHandler(integrityRepo interfaces.IntegrityInterface) *integrityHandler {
+	return &integrityHandler{
+		integrityRepo: integrityRepo,
+	}
+}
+
+func integrityRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.IntegrityResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.IntegrityResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (ih *integrityHandler) GetIntegrityChecksRequest(e echo.Context) error {
+	res, err := ih.integrityRepo.GetIntegrityChecks(e)
+	if err != nil {
+		return integrityRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.IntegrityResponse{Message: "integrity checks fetched successfully", Status: "success", Data: map[string]any{"checks": res}})
+}
+
+func (ih *integrityHandler) GetIntegrityReportRequest(e echo.Context) error {
+	res, err := ih.integrityRepo.GetIntegrityReport(e)
+	if err != nil {
+		return integrityRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.IntegrityResponse{Message: "integrity report fetched successfully", Status: "success", Data: map[string]any{"report": res}})
+}
+
+func (ih *integrityHandler) RunIntegrityCheckRequest(e echo.Context) error {
+	res, err := ih.integrityRepo.RunIntegrityCheck(e)
+	if err != nil {
+		return integrityRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.IntegrityResponse{Message: "integrity check completed", Status: "success", Data: map[string]any{"report": res}})
+}
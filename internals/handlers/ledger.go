@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type ledgerHandler struct {
+	ledgerRepo interfaces.LedgerInterface
+}
+
+func NewLedgerHandler(ledgerRepo interfaces.LedgerInterface) *ledgerHandler {
+	return &ledgerHandler{
+		ledgerRepo: ledgerRepo,
+	}
+}
+
+func ledgerRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.LedgerResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.LedgerResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (lh *ledgerHandler) GetBalanceAsOfRequest(e echo.Context) error {
+	res, err := lh.ledgerRepo.GetBalanceAsOf(e)
+	if err != nil {
+		return ledgerRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.LedgerResponse{Message: "balance fetched successfully", Status: "success", Data: map[string]any{"balance": res}})
+}
+
+func (lh *ledgerHandler) GetBalanceSnapshotsRequest(e echo.Context) error {
+	res, err := lh.ledgerRepo.GetBalanceSnapshots(e)
+	if err != nil {
+		return ledgerRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.LedgerResponse{Message: "balance snapshots fetched successfully", Status: "success", Data: map[string]any{"snapshots": res}})
+}
+
+func (lh *ledgerHandler) ClosePeriodRequest(e echo.Context) error {
+	res, err := lh.ledgerRepo.ClosePeriod(e)
+	if err != nil {
+		return ledgerRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.LedgerResponse{Message: "period closed successfully", Status: "success", Data: map[string]any{"period": res}})
+}
+
+func (lh *ledgerHandler) GetClosedPeriodsRequest(e echo.Context) error {
+	res, err := lh.ledgerRepo.GetClosedPeriods(e)
+	if err != nil {
+		return ledgerRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.LedgerResponse{Message: "closed periods fetched successfully", Status: "success", Data: map[string]any{"periods": res}})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type memberImportHandler struct {
+	memberImportRepo interfaces.MemberImportInterface
+}
+
+func NewMemberImportHandler(memberImportRepo interfaces.MemberImportInterface) *memberImportHandler {
+	return &memberImportHandler{
+		memberImportRepo: memberImportRepo,
+	}
+}
+
+func memberImportRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.MemberImportResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.MemberImportResponse{Message: "Internal server error", Status: "failed"})
+}
+
+// ImportMembersRequest bulk onboards members from a CSV upload. A dry run or
+// an import that was rolled back still answers 200 with the per-row errors.
+func (mh *memberImportHandler) ImportMembersRequest(e echo.Context) error {
+	res, err := mh.memberImportRepo.ImportMembers(e)
+	if err != nil {
+		return memberImportRespondWithError(e, err)
+	}
+	message := "members imported successfully"
+	switch {
+	case res.DryRun:
+		message = "dry run completed"
+	case !res.Committed:
+		message = "no members were imported"
+	}
+	return e.JSON(http.StatusOK, structures.MemberImportResponse{Message: message, Status: "success", Data: map[string]any{"import": res}})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type notificationHandler struct {
+	notificationRepo interfaces.NotificationInterface
+}
+
+func NewNotificationHandler(notificationRepo interfaces.NotificationInterface) *notificationHandler {
+	return &notificationHandler{
+		notificationRepo: notificationRepo,
+	}
+}
+
+func notificationRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.NotificationResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.NotificationResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (nh *notificationHandler) GetNotificationPreferencesRequest(e echo.Context) error {
+	res, err := nh.notificationRepo.GetNotificationPreferences(e)
+	if err != nil {
+		return notificationRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.NotificationResponse{
+		Message: "notification preferences fetched successfully",
+		Status:  "success",
+		Data:    res,
+	})
+}
+
+func (nh *notificationHandler) SetNotificationPreferencesRequest(e echo.Context) error {
+	if err := nh.notificationRepo.SetNotificationPreferences(e); err != nil {
+		return notificationRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.NotificationResponse{
+		Message: "notification preferences saved successfully",
+		Status:  "success",
+	})
+}
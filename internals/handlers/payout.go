@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
@@ -59,7 +60,7 @@ func (ph *payoutHandler) VerifyPayoutAccountNumber(e echo.Context) error {
 
 func (ph *payoutHandler) PayoutTransactionRefund(e echo.Context) error {
 	transactionId := e.Param("transaction_id")
-	err := ph.payoutRepo.RefundPayoutTransaction(transactionId)
+	err := ph.payoutRepo.RefundPayoutTransaction(e.Request().Context(), transactionId)
 	if err != nil {
 		return e.JSON(http.StatusBadRequest, err)
 	}
@@ -73,3 +74,37 @@ func (ph *payoutHandler) GetPayoutReportRequest(e echo.Context) error {
 	}
 	return e.JSON(http.StatusOK, structures.FundRequestResponse{Message: "fund request report fetched successfully", Status: "success", Data: map[string]any{"reports": res}})
 }
+
+func (ph *payoutHandler) CreatePayoutBatchRequest(e echo.Context) error {
+	res, err := ph.payoutRepo.CreatePayoutBatch(e)
+	if err != nil {
+		return payoutRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusAccepted, structures.PayoutBatchResponse{Message: "payout batch accepted", Status: "success", Data: map[string]any{"batch": res}})
+}
+
+func (ph *payoutHandler) GetPayoutBatchesRequest(e echo.Context) error {
+	res, err := ph.payoutRepo.GetPayoutBatches(e)
+	if err != nil {
+		return payoutRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.PayoutBatchResponse{Message: "payout batches fetched successfully", Status: "success", Data: map[string]any{"batches": res}})
+}
+
+func (ph *payoutHandler) GetPayoutBatchRequest(e echo.Context) error {
+	res, err := ph.payoutRepo.GetPayoutBatch(e)
+	if err != nil {
+		return payoutRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.PayoutBatchResponse{Message: "payout batch fetched successfully", Status: "success", Data: map[string]any{"batch": res}})
+}
+
+// GetPayoutBatchResultRequest downloads a batch's per-row results as CSV
+func (ph *payoutHandler) GetPayoutBatchResultRequest(e echo.Context) error {
+	res, fileName, err := ph.payoutRepo.GetPayoutBatchResult(e)
+	if err != nil {
+		return payoutRespondWithError(e, err)
+	}
+	e.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	return e.Blob(http.StatusOK, "text/csv", res)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type payoutReturnHandler struct {
+	payoutReturnRepo interfaces.PayoutReturnInterface
+}
+
+func NewPayoutReturnHandler(payoutReturnRepo interfaces.PayoutReturnInterface) *payoutReturnHandler {
+	return &payoutReturnHandler{
+		payoutReturnRepo: payoutReturnRepo,
+	}
+}
+
+func payoutReturnRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.PayoutReturnResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.PayoutReturnResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (prh *payoutReturnHandler) RecordPayoutReturnRequest(e echo.Context) error {
+	res, err := prh.payoutReturnRepo.RecordPayoutReturn(e)
+	if err != nil {
+		return payoutReturnRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.PayoutReturnResponse{Message: "payout return recorded successfully", Status: "success", Data: map[string]any{"return": res}})
+}
+
+func (prh *payoutReturnHandler) GetPayoutReturnsRequest(e echo.Context) error {
+	res, err := prh.payoutReturnRepo.GetPayoutReturns(e)
+	if err != nil {
+		return payoutReturnRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.PayoutReturnResponse{Message: "payout returns fetched successfully", Status: "success", Data: map[string]any{"returns": res}})
+}
+
+func (prh *payoutReturnHandler) GetBankReturnRatesRequest(e echo.Context) error {
+	res, err := prh.payoutReturnRepo.GetBankReturnRates(e)
+	if err != nil {
+		return payoutReturnRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.PayoutReturnResponse{Message: "return rates fetched successfully", Status: "success", Data: map[string]any{"banks": res}})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type rbacHandler struct {
+	rbacRepo interfaces.RBACInterface
+}
+
+func NewRBACHandler(rbacRepo interfaces.RBACInterface) *rbacHandler {
+	return &rbacHandler{
+		rbacRepo: rbacRepo,
+	}
+}
+
+func rbacRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.RBACResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.RBACResponse{Message: "Internal server error", Status: "failed"})
+}
+
+func (rh *rbacHandler) GetPermissionsRequest(e echo.Context) error {
+	res, err := rh.rbacRepo.GetPermissions(e)
+	if err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "permissions fetched successfully", Status: "success", Data: res})
+}
+
+func (rh *rbacHandler) GetRolesRequest(e echo.Context) error {
+	res, err := rh.rbacRepo.GetRoles(e)
+	if err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "roles fetched successfully", Status: "success", Data: res})
+}
+
+func (rh *rbacHandler) SaveRoleRequest(e echo.Context) error {
+	if err := rh.rbacRepo.SaveRole(e); err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "role saved successfully", Status: "success"})
+}
+
+func (rh *rbacHandler) DeleteRoleRequest(e echo.Context) error {
+	if err := rh.rbacRepo.DeleteRole(e); err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "role deleted successfully", Status: "success"})
+}
+
+func (rh *rbacHandler) CreateStaffUserRequest(e echo.Context) error {
+	res, err := rh.rbacRepo.CreateStaffUser(e)
+	if err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "staff user created successfully", Status: "success", Data: res})
+}
+
+func (rh *rbacHandler) GetStaffUsersRequest(e echo.Context) error {
+	res, err := rh.rbacRepo.GetStaffUsers(e)
+	if err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "staff users fetched successfully", Status: "success", Data: res})
+}
+
+func (rh *rbacHandler) AssignStaffRoleRequest(e echo.Context) error {
+	if err := rh.rbacRepo.AssignStaffRole(e); err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "staff role updated successfully", Status: "success"})
+}
+
+func (rh *rbacHandler) SetStaffBlockedRequest(e echo.Context) error {
+	if err := rh.rbacRepo.SetStaffBlocked(e); err != nil {
+		return rbacRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.RBACResponse{Message: "staff user updated successfully", Status: "success"})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type replenishmentHandler struct {
+	replenishmentRepo interfaces.ReplenishmentInterface
+}
+
+func NewReplenishmentHandler(replenishmentRepo interfaces.ReplenishmentInterface) *replenishmentHandler {
+	return &replenishmentHandler{
+		replenishmentRepo: replenishmentRepo,
+	}
+}
+
+func replenishmentRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.ReplenishmentResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.ReplenishmentResponse{Message: "Internal server error", Status: "failed"})
+}
+
+// SaveReplenishmentRuleRequest sets a retailer's replenishment rule for a
+// caller of the role
+func (rh *replenishmentHandler) SaveReplenishmentRuleRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := rh.replenishmentRepo.SaveReplenishmentRule(e, role)
+		if err != nil {
+			return replenishmentRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ReplenishmentResponse{Message: "replenishment rule saved successfully", Status: "success", Data: map[string]any{"rule": res}})
+	}
+}
+
+func (rh *replenishmentHandler) GetReplenishmentRulesRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := rh.replenishmentRepo.GetReplenishmentRules(e, role)
+		if err != nil {
+			return replenishmentRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ReplenishmentResponse{Message: "replenishment rules fetched successfully", Status: "success", Data: map[string]any{"rules": res}})
+	}
+}
+
+func (rh *replenishmentHandler) DeleteReplenishmentRuleRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if err := rh.replenishmentRepo.DeleteReplenishmentRule(e, role); err != nil {
+			return replenishmentRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ReplenishmentResponse{Message: "replenishment rule deleted successfully", Status: "success"})
+	}
+}
+
+func (rh *replenishmentHandler) GetReplenishmentRunsRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := rh.replenishmentRepo.GetReplenishmentRuns(e, role)
+		if err != nil {
+			return replenishmentRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ReplenishmentResponse{Message: "replenishment runs fetched successfully", Status: "success", Data: map[string]any{"runs": res}})
+	}
+}
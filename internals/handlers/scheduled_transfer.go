@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type scheduledTransferHandler struct {
+	scheduledTransferRepo interfaces.ScheduledTransferInterface
+}
+
+func NewScheduledTransferHandler(scheduledTransferRepo interfaces.ScheduledTransferInterface) *scheduledTransferHandler {
+	return &scheduledTransferHandler{
+		scheduledTransferRepo: scheduledTransferRepo,
+	}
+}
+
+func scheduledTransferRespondWithError(e echo.Context, err error) error {
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		msg := fmt.Sprint(httpErr.Message)
+		return e.JSON(httpErr.Code, structures.ScheduledTransferResponse{Message: msg, Status: "failed"})
+	}
+	return e.JSON(http.StatusInternalServerError, structures.ScheduledTransferResponse{Message: "Internal server error", Status: "failed"})
+}
+
+// CreateScheduledTransferRequest sets up a scheduled transfer for a caller of
+// the role
+func (sh *scheduledTransferHandler) CreateScheduledTransferRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := sh.scheduledTransferRepo.CreateScheduledTransfer(e, role)
+		if err != nil {
+			return scheduledTransferRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ScheduledTransferResponse{Message: "scheduled transfer created successfully", Status: "success", Data: map[string]any{"scheduled_transfer": res}})
+	}
+}
+
+func (sh *scheduledTransferHandler) GetScheduledTransfersRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := sh.scheduledTransferRepo.GetScheduledTransfers(e, role)
+		if err != nil {
+			return scheduledTransferRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ScheduledTransferResponse{Message: "scheduled transfers fetched successfully", Status: "success", Data: map[string]any{"scheduled_transfers": res}})
+	}
+}
+
+// SetScheduledTransferStatusRequest pauses or resumes a scheduled transfer
+func (sh *scheduledTransferHandler) SetScheduledTransferStatusRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if err := sh.scheduledTransferRepo.SetScheduledTransferStatus(e, role); err != nil {
+			return scheduledTransferRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ScheduledTransferResponse{Message: "scheduled transfer updated successfully", Status: "success"})
+	}
+}
+
+func (sh *scheduledTransferHandler) DeleteScheduledTransferRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if err := sh.scheduledTransferRepo.DeleteScheduledTransfer(e, role); err != nil {
+			return scheduledTransferRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ScheduledTransferResponse{Message: "scheduled transfer deleted successfully", Status: "success"})
+	}
+}
+
+func (sh *scheduledTransferHandler) GetScheduledTransferRunsRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := sh.scheduledTransferRepo.GetScheduledTransferRuns(e, role)
+		if err != nil {
+			return scheduledTransferRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.ScheduledTransferResponse{Message: "scheduled transfer runs fetched successfully", Status: "success", Data: map[string]any{"runs": res}})
+	}
+}
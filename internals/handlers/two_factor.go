@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+// CompleteTwoFactorLoginRequest finishes a login of the role with a second factor
+func (ah *authHandler) CompleteTwoFactorLoginRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		token, err := ah.authRepo.CompleteTwoFactorLogin(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.AuthResponse{Message: "login successful", Status: "success", Data: map[string]string{"token": token}})
+	}
+}
+
+func (ah *authHandler) GetTwoFactorStatusRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ah.authRepo.GetTwoFactorStatus(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "two-factor status fetched successfully", Status: "success", Data: res})
+	}
+}
+
+func (ah *authHandler) SetupTwoFactorRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ah.authRepo.SetupTwoFactor(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "scan the secret and confirm it with a code", Status: "success", Data: res})
+	}
+}
+
+func (ah *authHandler) EnableTwoFactorRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		res, err := ah.authRepo.EnableTwoFactor(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "two-factor authentication enabled, store the recovery codes safely", Status: "success", Data: res})
+	}
+}
+
+func (ah *authHandler) DisableTwoFactorRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		if err := ah.authRepo.DisableTwoFactor(e, role); err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "two-factor authentication disabled", Status: "success"})
+	}
+}
+
+func (ah *authHandler) RegenerateRecoveryCodesRequest(role string) echo.HandlerFunc {
+	return func(e echo.Context) error {
+		codes, err := ah.authRepo.RegenerateRecoveryCodes(e, role)
+		if err != nil {
+			return authRespondWithError(e, err)
+		}
+		return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "recovery codes regenerated", Status: "success", Data: map[string]any{"recovery_codes": codes}})
+	}
+}
+
+func (ah *authHandler) GetTwoFactorPoliciesRequest(e echo.Context) error {
+	res, err := ah.authRepo.GetTwoFactorPolicies(e)
+	if err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "two-factor policies fetched successfully", Status: "success", Data: map[string]any{"policies": res}})
+}
+
+func (ah *authHandler) SetTwoFactorPolicyRequest(e echo.Context) error {
+	if err := ah.authRepo.SetTwoFactorPolicy(e); err != nil {
+		return authRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.TwoFactorResponse{Message: "two-factor policy updated", Status: "success"})
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/interfaces"
@@ -77,15 +77,40 @@ func (wh *walletHandler) GetUserWalletBalanceRequest(e echo.Context) error {
 	return e.JSON(http.StatusOK, structures.WalletResponse{
 		Message: "user wallet balance fetched successfully",
 		Status:  "success",
-		Data:    map[string]string{"balance": res},
+		Data:    map[string]string{"balance": res.Available, "available": res.Available, "held": res.Held, "total": res.Total},
+	})
+}
+
+func (wh *walletHandler) GetUserWalletHoldsRequest(e echo.Context) error {
+	res, err := wh.walletRepo.GetUserWalletHolds(e)
+	if err != nil {
+		return walletRespondWithError(e, err)
+	}
+	return e.JSON(http.StatusOK, structures.WalletResponse{
+		Message: "wallet holds fetched successfully",
+		Status:  "success",
+		Data:    map[string]any{"holds": res},
+	})
+}
+
+// pendingApprovalResponse answers an operation held back for a second
+// approver with 202 and the pending action
+func pendingApprovalResponse(e echo.Context, pending *structures.PendingAction) error {
+	return e.JSON(http.StatusAccepted, structures.WalletResponse{
+		Message: "pending approval by a second approver",
+		Status:  "pending",
+		Data:    pending,
 	})
 }
 
 func (wh *walletHandler) AdminWalletTopupRequest(e echo.Context) error {
-	res, err := wh.walletRepo.AdminWalletTopup(e)
+	res, pending, err := wh.walletRepo.AdminWalletTopup(e)
 	if err != nil {
 		return walletRespondWithError(e, err)
 	}
+	if pending != nil {
+		return pendingApprovalResponse(e, pending)
+	}
 	return e.JSON(http.StatusOK, structures.WalletResponse{Message: res, Status: "success"})
 }
 
@@ -102,10 +127,13 @@ func (wh *walletHandler) GetTransactionsRequest(e echo.Context) error {
 }
 
 func (wh *walletHandler) UserRefundRequest(e echo.Context) error {
-	err := wh.walletRepo.UserRefund(e)
+	pending, err := wh.walletRepo.UserRefund(e)
 	if err != nil {
 		return walletRespondWithError(e, err)
 	}
+	if pending != nil {
+		return pendingApprovalResponse(e, pending)
+	}
 	return e.JSON(http.StatusOK, structures.WalletResponse{
 		Message: "refund success",
 		Status:  "success",
@@ -113,10 +141,13 @@ func (wh *walletHandler) UserRefundRequest(e echo.Context) error {
 }
 
 func (wh *walletHandler) MasterDistributorRefundRequest(e echo.Context) error {
-	err := wh.walletRepo.MasterDistributorRefund(e)
+	pending, err := wh.walletRepo.MasterDistributorRefund(e)
 	if err != nil {
 		return walletRespondWithError(e, err)
 	}
+	if pending != nil {
+		return pendingApprovalResponse(e, pending)
+	}
 	return e.JSON(http.StatusOK, structures.WalletResponse{
 		Message: "refund success",
 		Status:  "success",
@@ -124,10 +155,13 @@ func (wh *walletHandler) MasterDistributorRefundRequest(e echo.Context) error {
 }
 
 func (wh *walletHandler) DistributorRefundRequest(e echo.Context) error {
-	err := wh.walletRepo.DistributorRefund(e)
+	pending, err := wh.walletRepo.DistributorRefund(e)
 	if err != nil {
 		return walletRespondWithError(e, err)
 	}
+	if pending != nil {
+		return pendingApprovalResponse(e, pending)
+	}
 	return e.JSON(http.StatusOK, structures.WalletResponse{
 		Message: "refund success",
 		Status:  "success",
@@ -168,7 +202,7 @@ func (wh *walletHandler) DistributorFundRetailerRequest(e echo.Context) error {
 }
 
 func (wh *walletHandler) GetRevertHistory(e echo.Context) error {
-	res, err := wh.walletRepo.GetRevertHistory()
+	res, err := wh.walletRepo.GetRevertHistory(e)
 	if err != nil {
 		return walletRespondWithError(e, err)
 	}
@@ -180,7 +214,7 @@ func (wh *walletHandler) GetRevertHistory(e echo.Context) error {
 }
 
 func (wh *walletHandler) GetRevertHistoryPhone(e echo.Context) error {
-	res, err := wh.walletRepo.GetRevertHistoryPhone(e.Param("phone_number"))
+	res, err := wh.walletRepo.GetRevertHistoryPhone(e.Request().Context(), e.Param("phone_number"))
 	if err != nil {
 		return walletRespondWithError(e, err)
 	}
@@ -225,11 +259,14 @@ func (wh *walletHandler) DistributorRefundUserRequest(e echo.Context) error {
 }
 
 func (wh *walletHandler) UpdateTransactionStatus(e echo.Context) error {
-	err := wh.walletRepo.UpdatePayoutTransaction(e)
+	pending, err := wh.walletRepo.UpdatePayoutTransaction(e)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(e.Request().Context(), "update payout transaction failed", "error", err)
 		return walletRespondWithError(e, err)
 	}
+	if pending != nil {
+		return pendingApprovalResponse(e, pending)
+	}
 	return e.JSON(http.StatusOK, structures.WalletResponse{
 		Message: "status update success",
 		Status:  "success",
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type AdminInterface interface {
+	CreateAdminInvite(echo.Context) (*structures.AdminInviteCreated, error)
+	GetAdminInvites(echo.Context) (*[]structures.AdminInvite, error)
+	RevokeAdminInvite(echo.Context) error
+	GetAdmins(echo.Context) (*[]structures.AdminAccount, error)
+	UpdateAdminRole(echo.Context) error
+}
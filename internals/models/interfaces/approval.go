@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type ApprovalInterface interface {
+	GetApprovalPolicies(echo.Context) (*[]structures.ApprovalPolicy, error)
+	SetApprovalPolicy(echo.Context) error
+	GetPendingActions(echo.Context) (*[]structures.PendingAction, error)
+	ApprovePendingAction(echo.Context) (*structures.PendingAction, error)
+	RejectPendingAction(echo.Context) (*structures.PendingAction, error)
+}
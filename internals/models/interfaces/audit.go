@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type AuditInterface interface {
+	GetAuditLogs(echo.Context) (*[]structures.AuditLog, error)
+	VerifyAuditLogs(echo.Context) (*structures.AuditVerificationResult, error)
+}
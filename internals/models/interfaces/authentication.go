@@ -6,17 +6,35 @@ import (
 )
 
 type AuthInterface interface {
-	RegisterAdmin(echo.Context) (string, error)
+	AcceptAdminInvite(echo.Context) (string, error)
 	RegisterMasterDistributor(echo.Context) (string, error)
 	RegisterDistributor(echo.Context) (string, error)
 	RegisterUser(echo.Context) (string, error)
-	LoginAdmin(echo.Context) (string, error)
-	LoginMasterDistributor(echo.Context) (string, error)
+	LoginAdmin(echo.Context) (*structures.LoginResult, error)
+	LoginMasterDistributor(echo.Context) (*structures.LoginResult, error)
+	LoginStaff(echo.Context) (*structures.LoginResult, error)
 	LoginDistributor(echo.Context) (string, error)
 	LoginUserSendOTP(echo.Context) (string, error)
-	LoginUserValidateOTP(echo.Context) (string, error)
+	LoginUserValidateOTP(echo.Context) (*structures.LoginResult, error)
 	SetUserMpin(echo.Context) (string, error)
 	VerifyMPIN(echo.Context) error
+	SendMpinResetOTP(echo.Context) (string, error)
+	ResetUserMpin(echo.Context) error
+	ChangeUserMpin(echo.Context) error
+	SendUserOTP(echo.Context) (string, error)
+	ChangePassword(e echo.Context, role string) error
+	ForgotPassword(e echo.Context, role string) (string, error)
+	ResetPassword(e echo.Context, role string) error
+	LoginUserWithPassword(echo.Context) (*structures.LoginResult, error)
+	VerifyUserDevice(echo.Context) (*structures.LoginResult, error)
+	CompleteTwoFactorLogin(e echo.Context, role string) (string, error)
+	GetTwoFactorStatus(e echo.Context, role string) (*structures.TwoFactorStatus, error)
+	SetupTwoFactor(e echo.Context, role string) (*structures.TwoFactorSetupResponse, error)
+	EnableTwoFactor(e echo.Context, role string) (*structures.TwoFactorEnableResponse, error)
+	DisableTwoFactor(e echo.Context, role string) error
+	RegenerateRecoveryCodes(e echo.Context, role string) ([]string, error)
+	GetTwoFactorPolicies(echo.Context) (*[]structures.TwoFactorPolicy, error)
+	SetTwoFactorPolicy(echo.Context) error
 	UpdateUserProfile(echo.Context) error
 	GetUserProfile(echo.Context) (*structures.GetUserProfile, error)
 	UpdateMasterDistributorProfile(echo.Context) error
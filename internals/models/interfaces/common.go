@@ -1,14 +1,18 @@
 package interfaces
 
-import "github.com/Srujankm12/paybazar-api/internals/models/structures"
+import (
+	"context"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+)
 
 type CommonInterface interface {
-	GetAllMasterDistributorsByAdminID(adminId string) (*[]structures.MasterDistributorGetResponse, error)
-	GetAllDistributorsByMasterDistributorID(masterDistributorId string) (*[]structures.DistributorGetResponse, error)
-	GetAllUsersByDistributorID(distributorId string) (*[]structures.UserGetResponse, error)
-	GetAllDistributorsByAdminID(adminId string) (*[]structures.DistributorGetResponse, error)
-	GetAllUsersByAdminID(adminId string) (*[]structures.UserGetResponse, error)
-	GetUserByPhoneNumber(string) (*structures.UserGetResponse, error)
-	GetMasterDistributorByPhoneNumber(string) (*structures.MasterDistributorGetResponse, error)
-	GetDistributorByPhoneNumber(string) (*structures.DistributorGetResponse, error)
+	GetAllMasterDistributorsByAdminID(ctx context.Context, adminId string) (*[]structures.MasterDistributorGetResponse, error)
+	GetAllDistributorsByMasterDistributorID(ctx context.Context, masterDistributorId string) (*[]structures.DistributorGetResponse, error)
+	GetAllUsersByDistributorID(ctx context.Context, distributorId string) (*[]structures.UserGetResponse, error)
+	GetAllDistributorsByAdminID(ctx context.Context, adminId string) (*[]structures.DistributorGetResponse, error)
+	GetAllUsersByAdminID(ctx context.Context, adminId string) (*[]structures.UserGetResponse, error)
+	GetUserByPhoneNumber(context.Context, string) (*structures.UserGetResponse, error)
+	GetMasterDistributorByPhoneNumber(context.Context, string) (*structures.MasterDistributorGetResponse, error)
+	GetDistributorByPhoneNumber(context.Context, string) (*structures.DistributorGetResponse, error)
 }
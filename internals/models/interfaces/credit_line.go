@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type CreditLineInterface interface {
+	SaveCreditLine(e echo.Context, role string) (*structures.CreditLine, error)
+	GetCreditLines(e echo.Context, role string) (*[]structures.CreditLine, error)
+	GetOwnCreditLine(e echo.Context, role string) (*structures.CreditLine, error)
+	GetOutstandingCredit(e echo.Context) (*[]structures.CreditLine, error)
+	DeleteCreditLine(e echo.Context, role string) error
+	GetCreditLineEvents(e echo.Context, role string) (*[]structures.CreditLineEvent, error)
+}
@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type DeviceInterface interface {
+	GetUserDevices(e echo.Context, role string) (*[]structures.UserDevice, error)
+	RevokeUserDevice(e echo.Context, role string) error
+}
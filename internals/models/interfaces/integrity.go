@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type IntegrityInterface interface {
+	GetIntegrityChecks(e echo.Context) (*[]structures.IntegrityCheck, error)
+	GetIntegrityReport(e echo.Context) (*structures.IntegrityReport, error)
+	RunIntegrityCheck(e echo.Context) (*structures.IntegrityReport, error)
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type LedgerInterface interface {
+	GetBalanceAsOf(e echo.Context) (*structures.BalanceAsOf, error)
+	GetBalanceSnapshots(e echo.Context) (*[]structures.BalanceSnapshot, error)
+	ClosePeriod(e echo.Context) (*structures.ClosedPeriod, error)
+	GetClosedPeriods(e echo.Context) (*[]structures.ClosedPeriod, error)
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type MemberImportInterface interface {
+	ImportMembers(echo.Context) (*structures.MemberImport, error)
+}
@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationInterface interface {
+	GetNotificationPreferences(echo.Context) (*structures.NotificationPreferencesResponse, error)
+	SetNotificationPreferences(echo.Context) error
+}
@@ -1,6 +1,8 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
 	"github.com/labstack/echo/v4"
 )
@@ -9,6 +11,10 @@ type PayoutInterface interface {
 	PayoutRequest(echo.Context) (string, error)
 	GetPayoutTransactions(echo.Context) (*[]structures.GetPayoutLogs, error)
 	VerifyAccountNumber(echo.Context) (*structures.PayoutVerifyAccountResponse, error)
-	RefundPayoutTransaction(string) error
+	RefundPayoutTransaction(context.Context, string) error
 	GetPayoutReports(echo.Context) ([]structures.PayoutReportResponse, error)
+	CreatePayoutBatch(echo.Context) (*structures.PayoutBatch, error)
+	GetPayoutBatches(echo.Context) ([]structures.PayoutBatch, error)
+	GetPayoutBatch(echo.Context) (*structures.PayoutBatch, error)
+	GetPayoutBatchResult(echo.Context) ([]byte, string, error)
 }
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type PayoutReturnInterface interface {
+	RecordPayoutReturn(e echo.Context) (*structures.PayoutReturn, error)
+	GetPayoutReturns(e echo.Context) (*[]structures.PayoutReturn, error)
+	GetBankReturnRates(e echo.Context) (*[]structures.BankReturnRate, error)
+}
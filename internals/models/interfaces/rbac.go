@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type RBACInterface interface {
+	GetPermissions(echo.Context) (*[]structures.Permission, error)
+	GetRoles(echo.Context) (*[]structures.Role, error)
+	SaveRole(echo.Context) error
+	DeleteRole(echo.Context) error
+	CreateStaffUser(echo.Context) (*structures.StaffUser, error)
+	GetStaffUsers(echo.Context) (*[]structures.StaffUser, error)
+	AssignStaffRole(echo.Context) error
+	SetStaffBlocked(echo.Context) error
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type ReplenishmentInterface interface {
+	SaveReplenishmentRule(e echo.Context, role string) (*structures.ReplenishmentRule, error)
+	GetReplenishmentRules(e echo.Context, role string) (*[]structures.ReplenishmentRule, error)
+	DeleteReplenishmentRule(e echo.Context, role string) error
+	GetReplenishmentRuns(e echo.Context, role string) (*[]structures.ReplenishmentRun, error)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type ScheduledTransferInterface interface {
+	CreateScheduledTransfer(e echo.Context, role string) (*structures.ScheduledTransfer, error)
+	GetScheduledTransfers(e echo.Context, role string) (*[]structures.ScheduledTransfer, error)
+	SetScheduledTransferStatus(e echo.Context, role string) error
+	DeleteScheduledTransfer(e echo.Context, role string) error
+	GetScheduledTransferRuns(e echo.Context, role string) (*[]structures.ScheduledTransferRun, error)
+}
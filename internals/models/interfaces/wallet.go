@@ -1,6 +1,8 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
 	"github.com/labstack/echo/v4"
 )
@@ -9,19 +11,20 @@ type WalletInterface interface {
 	GetAdminWalletBalance(echo.Context) (string, error)
 	GetMasterDistributorWalletBalance(echo.Context) (string, error)
 	GetDistributorWalletBalance(echo.Context) (string, error)
-	GetUserWalletBalance(echo.Context) (string, error)
-	AdminWalletTopup(echo.Context) (string, error)
+	GetUserWalletBalance(echo.Context) (*structures.WalletSummary, error)
+	GetUserWalletHolds(echo.Context) ([]structures.WalletHold, error)
+	AdminWalletTopup(echo.Context) (string, *structures.PendingAction, error)
 	GetTransactions(echo.Context) (*[]structures.WalletTransaction, error)
-	DistributorRefund(echo.Context) error
-	MasterDistributorRefund(echo.Context) error
-	UserRefund(echo.Context) error
+	DistributorRefund(echo.Context) (*structures.PendingAction, error)
+	MasterDistributorRefund(echo.Context) (*structures.PendingAction, error)
+	UserRefund(echo.Context) (*structures.PendingAction, error)
 	MasterDistributorFundRetailer(echo.Context) error
 	DistributorFundRetailer(echo.Context) error
-	GetRevertHistoryPhone(string) (*[]structures.GetRevertHistory, error)
-	GetRevertHistory() (*[]structures.GetRevertHistory, error)
+	GetRevertHistoryPhone(context.Context, string) (*[]structures.GetRevertHistory, error)
+	GetRevertHistory(echo.Context) (*[]structures.GetRevertHistory, error)
 	MasterDistributorFundDistributor(echo.Context) error
 	MasterDistributorRefundUser(echo.Context) error
 	MasterDistributorRefundDistributor(echo.Context) error
 	DistributorRefundRetailer(echo.Context) error 
-	UpdatePayoutTransaction(echo.Context) error
+	UpdatePayoutTransaction(echo.Context) (*structures.PendingAction, error)
 }
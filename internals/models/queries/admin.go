@@ -0,0 +1,361 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrSuperAdminExists   = errors.New("super admin already exists")
+	ErrAdminInviteInvalid = errors.New("invalid or expired admin invite")
+	ErrAdminEmailTaken    = errors.New("admin email already registered")
+	ErrLastSuperAdmin     = errors.New("last super admin")
+)
+
+// adminInviteColumns selects an admin_invites row as structures.AdminInvite
+const adminInviteColumns = `
+	invite_id::TEXT, admin_email, admin_role, invited_by::TEXT,
+	CASE
+		WHEN accepted_at IS NOT NULL THEN 'ACCEPTED'
+		WHEN revoked_at IS NOT NULL THEN 'REVOKED'
+		WHEN expires_at <= NOW() THEN 'EXPIRED'
+		ELSE 'PENDING'
+	END,
+	expires_at, accepted_at, accepted_admin_id::TEXT, revoked_at, created_at
+`
+
+func scanAdminInvite(row pgx.Row, invite *structures.AdminInvite) error {
+	return row.Scan(
+		&invite.InviteID,
+		&invite.AdminEmail,
+		&invite.AdminRole,
+		&invite.InvitedBy,
+		&invite.Status,
+		&invite.ExpiresAt,
+		&invite.AcceptedAt,
+		&invite.AcceptedAdminID,
+		&invite.RevokedAt,
+		&invite.CreatedAt,
+	)
+}
+
+// insertAdmin creates an admin with the role inside tx
+func insertAdmin(ctx context.Context, tx pgx.Tx, req *structures.AdminRegisterRequest, role string) (*structures.AdminAuthResponse, error) {
+	var res structures.AdminAuthResponse
+	err := tx.QueryRow(ctx, `
+		INSERT INTO admins (admin_name, admin_phone, admin_email, admin_password, admin_role)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING admin_id::TEXT, admin_unique_id, admin_name, admin_role;
+	`, req.AdminName, req.AdminPhone, req.AdminEmail, req.AdminPassword, role).Scan(
+		&res.AdminID,
+		&res.AdminUniqueID,
+		&res.AdminName,
+		&res.AdminRole,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert admin: %w", err)
+	}
+	return &res, nil
+}
+
+// BootstrapSuperAdmin creates the first super admin. Once one exists it
+// returns ErrSuperAdminExists, further admins have to be invited.
+func (q *Query) BootstrapSuperAdmin(ctx context.Context, req *structures.AdminRegisterRequest, audit *structures.AuditLogEntry) (*structures.AdminAuthResponse, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('admin_bootstrap'));`); err != nil {
+		return nil, fmt.Errorf("lock admin bootstrap: %w", err)
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM admins WHERE admin_role = $1);
+	`, pkg.AdminRoleSuperAdmin).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check super admin: %w", err)
+	}
+	if exists {
+		return nil, ErrSuperAdminExists
+	}
+
+	res, err := insertAdmin(ctx, tx, req, pkg.AdminRoleSuperAdmin)
+	if err != nil {
+		return nil, err
+	}
+	audit.ActorID = res.AdminID
+	audit.TargetID = res.AdminID
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+func (q *Query) GetAdminRole(ctx context.Context, adminID string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var role string
+	err := q.Pool.QueryRow(ctx, `SELECT admin_role FROM admins WHERE admin_id = $1;`, adminID).Scan(&role)
+	return role, err
+}
+
+// CreateAdminInvite stores a new invite, replacing any invite still pending
+// for the same email
+func (q *Query) CreateAdminInvite(ctx context.Context, invite *structures.AdminInviteCreate, audit *structures.AuditLogEntry) (*structures.AdminInvite, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var taken bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM admins WHERE LOWER(admin_email) = LOWER($1));
+	`, invite.AdminEmail).Scan(&taken); err != nil {
+		return nil, fmt.Errorf("check admin email: %w", err)
+	}
+	if taken {
+		return nil, ErrAdminEmailTaken
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE admin_invites SET revoked_at = NOW()
+		WHERE LOWER(admin_email) = LOWER($1)
+		AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW();
+	`, invite.AdminEmail); err != nil {
+		return nil, fmt.Errorf("revoke pending invites: %w", err)
+	}
+
+	var res structures.AdminInvite
+	if err := scanAdminInvite(tx.QueryRow(ctx, `
+		INSERT INTO admin_invites (token_hash, admin_email, admin_role, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, NOW() + make_interval(secs => $5))
+		RETURNING `+adminInviteColumns+`;
+	`, invite.TokenHash, strings.ToLower(invite.AdminEmail), invite.AdminRole, invite.InvitedBy, invite.TTL.Seconds()), &res); err != nil {
+		return nil, fmt.Errorf("insert admin invite: %w", err)
+	}
+
+	audit.TargetID = res.InviteID
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+// AcceptAdminInvite registers the invited admin with the invite's role and
+// uses the invite up. Unknown, used, revoked or expired invites, and emails
+// other than the invited one, give ErrAdminInviteInvalid.
+func (q *Query) AcceptAdminInvite(ctx context.Context, tokenHash string, req *structures.AdminRegisterRequest, audit *structures.AuditLogEntry) (*structures.AdminAuthResponse, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var inviteID, email, role string
+	err = tx.QueryRow(ctx, `
+		SELECT invite_id::TEXT, admin_email, admin_role
+		FROM admin_invites
+		WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		FOR UPDATE;
+	`, tokenHash).Scan(&inviteID, &email, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAdminInviteInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select admin invite: %w", err)
+	}
+	if !strings.EqualFold(email, req.AdminEmail) {
+		return nil, ErrAdminInviteInvalid
+	}
+
+	res, err := insertAdmin(ctx, tx, req, role)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE admin_invites SET accepted_at = NOW(), accepted_admin_id = $2
+		WHERE invite_id = $1;
+	`, inviteID, res.AdminID); err != nil {
+		return nil, fmt.Errorf("accept admin invite: %w", err)
+	}
+
+	audit.ActorID = res.AdminID
+	audit.TargetID = res.AdminID
+	audit.After = map[string]string{"invite_id": inviteID, "admin_email": email, "admin_role": role}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+func (q *Query) GetAdminInvites(ctx context.Context) (*[]structures.AdminInvite, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+adminInviteColumns+`
+		FROM admin_invites
+		ORDER BY created_at DESC
+		LIMIT 200;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.AdminInvite
+	for rows.Next() {
+		var invite structures.AdminInvite
+		if err := scanAdminInvite(rows, &invite); err != nil {
+			return nil, err
+		}
+		res = append(res, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RevokeAdminInvite withdraws a pending invite. Invites that are no longer
+// pending give pgx.ErrNoRows.
+func (q *Query) RevokeAdminInvite(ctx context.Context, inviteID string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE admin_invites SET revoked_at = NOW()
+		WHERE invite_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW();
+	`, inviteID)
+	if err != nil {
+		return fmt.Errorf("revoke admin invite: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (q *Query) GetAdmins(ctx context.Context) (*[]structures.AdminAccount, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT
+			admin_id::TEXT, admin_unique_id, admin_name, admin_email, admin_phone,
+			admin_role, admin_blocked, created_at
+		FROM admins
+		ORDER BY created_at;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.AdminAccount
+	for rows.Next() {
+		var admin structures.AdminAccount
+		if err := rows.Scan(
+			&admin.AdminID,
+			&admin.AdminUniqueID,
+			&admin.AdminName,
+			&admin.AdminEmail,
+			&admin.AdminPhone,
+			&admin.AdminRole,
+			&admin.AdminBlocked,
+			&admin.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, admin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateAdminRole changes an admin's role. Demoting the only super admin
+// left gives ErrLastSuperAdmin, an unknown admin pgx.ErrNoRows.
+func (q *Query) UpdateAdminRole(ctx context.Context, adminID string, role string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	// Lock the super admins so two demotions cannot both pass the check
+	var superAdmins int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM admins WHERE admin_role = $1 FOR UPDATE
+		) s;
+	`, pkg.AdminRoleSuperAdmin).Scan(&superAdmins); err != nil {
+		return fmt.Errorf("lock super admins: %w", err)
+	}
+
+	var current string
+	if err := tx.QueryRow(ctx, `
+		SELECT admin_role FROM admins WHERE admin_id = $1 FOR UPDATE;
+	`, adminID).Scan(&current); err != nil {
+		return err
+	}
+	if current == pkg.AdminRoleSuperAdmin && role != pkg.AdminRoleSuperAdmin && superAdmins <= 1 {
+		return ErrLastSuperAdmin
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE admins SET admin_role = $2 WHERE admin_id = $1;
+	`, adminID, role); err != nil {
+		return fmt.Errorf("update admin role: %w", err)
+	}
+
+	audit.Before = map[string]string{"admin_role": current}
+	audit.After = map[string]string{"admin_role": role}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
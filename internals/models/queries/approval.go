@@ -0,0 +1,548 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrPendingActionClosed  = errors.New("pending action already decided")
+	ErrPendingActionExpired = errors.New("pending action expired")
+	ErrSameApprover         = errors.New("maker cannot decide own pending action")
+)
+
+const pendingActionColumns = `
+	action_id::TEXT, admin_id::TEXT, action_type, amount::TEXT, payload, maker_id, maker_role,
+	action_status, COALESCE(checker_id, ''), COALESCE(checker_role, ''),
+	COALESCE(rejection_reason, ''), COALESCE(failure_reason, ''),
+	expires_at, decided_at, created_at
+`
+
+func scanPendingAction(row pgx.Row, action *structures.PendingAction) error {
+	var payload []byte
+	if err := row.Scan(
+		&action.ActionID,
+		&action.AdminID,
+		&action.ActionType,
+		&action.Amount,
+		&payload,
+		&action.MakerID,
+		&action.MakerRole,
+		&action.Status,
+		&action.CheckerID,
+		&action.CheckerRole,
+		&action.RejectionReason,
+		&action.FailureReason,
+		&action.ExpiresAt,
+		&action.DecidedAt,
+		&action.CreatedAt,
+	); err != nil {
+		return err
+	}
+	action.Payload = json.RawMessage(payload)
+	return nil
+}
+
+// GetApprovalPolicies returns the admin's policy for every action type
+func (q *Query) GetApprovalPolicies(ctx context.Context, adminID string) (*[]structures.ApprovalPolicy, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT
+			$3::TEXT, a.action_type, COALESCE(p.threshold_amount, 0)::TEXT, COALESCE(p.ttl_minutes, $2),
+			COALESCE(p.window_minutes, 0), COALESCE(p.enabled, FALSE), COALESCE(p.updated_by, ''), COALESCE(p.updated_at, 'epoch')
+		FROM unnest($1::TEXT[]) AS a(action_type)
+		LEFT JOIN approval_policies p ON p.action_type = a.action_type AND p.admin_id::TEXT = $3
+		ORDER BY a.action_type;
+	`, pkg.ApprovalActionTypes, int(pkg.DefaultApprovalTTL.Minutes()), adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.ApprovalPolicy
+	for rows.Next() {
+		var policy structures.ApprovalPolicy
+		if err := rows.Scan(
+			&policy.AdminID,
+			&policy.ActionType,
+			&policy.ThresholdAmount,
+			&policy.TTLMinutes,
+			&policy.WindowMinutes,
+			&policy.Enabled,
+			&policy.UpdatedBy,
+			&policy.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SetApprovalPolicy replaces the admin's policy for the action type
+func (q *Query) SetApprovalPolicy(ctx context.Context, policy *structures.ApprovalPolicy, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var before *structures.ApprovalPolicy
+	var previous structures.ApprovalPolicy
+	err = tx.QueryRow(ctx, `
+		SELECT admin_id::TEXT, action_type, threshold_amount::TEXT, ttl_minutes, window_minutes, enabled, updated_by, updated_at
+		FROM approval_policies
+		WHERE admin_id = $1 AND action_type = $2
+		FOR UPDATE;
+	`, policy.AdminID, policy.ActionType).Scan(
+		&previous.AdminID,
+		&previous.ActionType,
+		&previous.ThresholdAmount,
+		&previous.TTLMinutes,
+		&previous.WindowMinutes,
+		&previous.Enabled,
+		&previous.UpdatedBy,
+		&previous.UpdatedAt,
+	)
+	if err == nil {
+		before = &previous
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("select approval policy: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO approval_policies (admin_id, action_type, threshold_amount, ttl_minutes, window_minutes, enabled, updated_by, updated_at)
+		VALUES ($1, $2, $3::NUMERIC, $4, $5, $6, $7, NOW())
+		ON CONFLICT (admin_id, action_type) DO UPDATE SET
+			threshold_amount = EXCLUDED.threshold_amount,
+			ttl_minutes = EXCLUDED.ttl_minutes,
+			window_minutes = EXCLUDED.window_minutes,
+			enabled = EXCLUDED.enabled,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW();
+	`, policy.AdminID, policy.ActionType, policy.ThresholdAmount, policy.TTLMinutes, policy.WindowMinutes, policy.Enabled, policy.UpdatedBy); err != nil {
+		return fmt.Errorf("set approval policy: %w", err)
+	}
+
+	audit.Before = before
+	audit.After = policy
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ApprovalRequired reports whether the operation has to wait for a checker
+// under its admin's policy, and for how long the pending action stays open.
+// Under a policy with a window the amount is added to what the maker, and
+// separately what anyone on the target, ran without approval within it, so
+// splitting an operation into smaller ones does not get round the threshold.
+// An operation let through is added to those sums straight away.
+func (q *Query) ApprovalRequired(ctx context.Context, check *structures.ApprovalCheck) (bool, int, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var ttlMinutes, windowMinutes int
+	err = tx.QueryRow(ctx, `
+		SELECT ttl_minutes, window_minutes FROM approval_policies
+		WHERE admin_id::TEXT = $1 AND action_type = $2 AND enabled;
+	`, check.AdminID, check.ActionType).Scan(&ttlMinutes, &windowMinutes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("select approval policy: %w", err)
+	}
+
+	// The maker is always locked before the target, so two checks never
+	// wait on each other
+	if windowMinutes > 0 {
+		if _, err := tx.Exec(ctx, `
+			SELECT pg_advisory_xact_lock(hashtext('approval_maker:' || $1 || ':' || $2 || ':' || $3));
+		`, check.AdminID, check.ActionType, check.MakerID); err != nil {
+			return false, 0, fmt.Errorf("lock approval maker: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			SELECT pg_advisory_xact_lock(hashtext('approval_target:' || $1 || ':' || $2 || ':' || $3));
+		`, check.AdminID, check.ActionType, check.Target); err != nil {
+			return false, 0, fmt.Errorf("lock approval target: %w", err)
+		}
+	}
+
+	var required bool
+	if err := tx.QueryRow(ctx, `
+		SELECT
+			$5::NUMERIC + COALESCE(SUM(t.amount) FILTER (WHERE t.maker_id = $3), 0) > p.threshold_amount
+			OR $5::NUMERIC + COALESCE(SUM(t.amount) FILTER (WHERE t.target = $4), 0) > p.threshold_amount
+		FROM approval_policies p
+		LEFT JOIN approval_tallies t ON t.admin_id = p.admin_id AND t.action_type = p.action_type
+			AND (t.maker_id = $3 OR t.target = $4)
+			AND t.created_at > NOW() - make_interval(mins => p.window_minutes)
+		WHERE p.admin_id::TEXT = $1 AND p.action_type = $2
+		GROUP BY p.threshold_amount;
+	`, check.AdminID, check.ActionType, check.MakerID, check.Target, check.Amount).Scan(&required); err != nil {
+		return false, 0, fmt.Errorf("sum approval tallies: %w", err)
+	}
+	if required {
+		return true, ttlMinutes, nil
+	}
+	if windowMinutes == 0 {
+		return false, 0, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO approval_tallies (admin_id, action_type, maker_id, target, amount)
+		VALUES ($1, $2, $3, $4, $5::NUMERIC);
+	`, check.AdminID, check.ActionType, check.MakerID, check.Target, check.Amount); err != nil {
+		return false, 0, fmt.Errorf("insert approval tally: %w", err)
+	}
+	// Windows are at most a week, older tallies no longer count
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM approval_tallies
+		WHERE admin_id::TEXT = $1 AND action_type = $2 AND created_at < NOW() - INTERVAL '7 days';
+	`, check.AdminID, check.ActionType); err != nil {
+		return false, 0, fmt.Errorf("delete old approval tallies: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, 0, fmt.Errorf("commit: %w", err)
+	}
+	return false, 0, nil
+}
+
+func (q *Query) GetPayoutAmount(ctx context.Context, payoutTransactionID string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var amount string
+	err := q.Pool.QueryRow(ctx, `
+		SELECT amount::TEXT FROM payout_service WHERE payout_transaction_id = $1;
+	`, payoutTransactionID).Scan(&amount)
+	return amount, err
+}
+
+func (q *Query) CreatePendingAction(ctx context.Context, create *structures.PendingActionCreate, audit *structures.AuditLogEntry) (*structures.PendingAction, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	payload, err := json.Marshal(create.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal pending action payload: %w", err)
+	}
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var res structures.PendingAction
+	if err := scanPendingAction(tx.QueryRow(ctx, `
+		INSERT INTO pending_actions (admin_id, action_type, amount, payload, maker_id, maker_role, expires_at)
+		VALUES ($1, $2, $3::NUMERIC, $4::JSONB, $5, $6, NOW() + make_interval(secs => $7))
+		RETURNING `+pendingActionColumns+`;
+	`, create.AdminID, create.ActionType, create.Amount, string(payload), create.MakerID, create.MakerRole, create.TTL.Seconds()), &res); err != nil {
+		return nil, fmt.Errorf("insert pending action: %w", err)
+	}
+
+	audit.TargetID = res.ActionID
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+func (q *Query) GetPendingActions(ctx context.Context, adminID string, status string) (*[]structures.PendingAction, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+pendingActionColumns+`
+		FROM pending_actions
+		WHERE admin_id = $1 AND ($2 = '' OR action_status = $2)
+		ORDER BY created_at DESC
+		LIMIT 200;
+	`, adminID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.PendingAction
+	for rows.Next() {
+		var action structures.PendingAction
+		if err := scanPendingAction(rows, &action); err != nil {
+			return nil, err
+		}
+		res = append(res, action)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// expiredActionAudit is the audit entry of a pending action closed for
+// running out of time
+func expiredActionAudit(actionID string, actionType string) *structures.AuditLogEntry {
+	return &structures.AuditLogEntry{
+		ActorID:    "system",
+		ActorRole:  "SYSTEM",
+		Action:     "PENDING_ACTION_EXPIRE",
+		TargetType: "PENDING_ACTION",
+		TargetID:   actionID,
+		Before:     map[string]string{"action_status": pkg.PendingActionPending},
+		After:      map[string]string{"action_status": pkg.PendingActionExpired, "action_type": actionType},
+	}
+}
+
+// lockPendingAction locks a pending action of the admin tenant
+func lockPendingAction(ctx context.Context, tx pgx.Tx, adminID string, actionID string) (*structures.PendingAction, error) {
+	var action structures.PendingAction
+	err := scanPendingAction(tx.QueryRow(ctx, `
+		SELECT `+pendingActionColumns+`
+		FROM pending_actions
+		WHERE action_id = $1 AND admin_id = $2
+		FOR UPDATE;
+	`, actionID, adminID), &action)
+	if err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// decidePendingAction checks a locked pending action is still open for a
+// checker. Expired actions are closed as EXPIRED on the way.
+func decidePendingAction(ctx context.Context, tx pgx.Tx, action *structures.PendingAction, checkerID string) (*structures.PendingAction, error) {
+	actionID := action.ActionID
+	if action.Status != pkg.PendingActionPending {
+		return nil, ErrPendingActionClosed
+	}
+	if !action.ExpiresAt.After(time.Now()) {
+		if _, err := tx.Exec(ctx, `
+			UPDATE pending_actions SET action_status = 'EXPIRED', decided_at = NOW() WHERE action_id = $1;
+		`, actionID); err != nil {
+			return nil, fmt.Errorf("expire pending action: %w", err)
+		}
+		if err := insertAuditLog(ctx, tx, expiredActionAudit(action.ActionID, action.ActionType)); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("commit: %w", err)
+		}
+		return nil, ErrPendingActionExpired
+	}
+	if action.MakerID == checkerID {
+		return nil, ErrSameApprover
+	}
+	return action, nil
+}
+
+// ApprovePendingAction hands an open pending action to the checker for
+// execution and FinishPendingAction records the outcome. An action still
+// APPROVED was cut off before its outcome was recorded and is handed back
+// as it is so the checker can resume it.
+func (q *Query) ApprovePendingAction(ctx context.Context, adminID string, actionID string, audit *structures.AuditLogEntry) (*structures.PendingAction, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	locked, err := lockPendingAction(ctx, tx, adminID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	if locked.Status == pkg.PendingActionApproved {
+		if locked.MakerID == audit.ActorID {
+			return nil, ErrSameApprover
+		}
+		return locked, nil
+	}
+	action, err := decidePendingAction(ctx, tx, locked, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	var res structures.PendingAction
+	if err := scanPendingAction(tx.QueryRow(ctx, `
+		UPDATE pending_actions
+		SET action_status = 'APPROVED', checker_id = $2, checker_role = $3, decided_at = NOW()
+		WHERE action_id = $1
+		RETURNING `+pendingActionColumns+`;
+	`, actionID, audit.ActorID, audit.ActorRole), &res); err != nil {
+		return nil, fmt.Errorf("approve pending action: %w", err)
+	}
+
+	audit.Before = map[string]string{"action_status": action.Status}
+	audit.After = map[string]string{"action_status": res.Status, "action_type": res.ActionType, "maker_id": res.MakerID}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+// PendingActionRan reports whether an approved action's execution already
+// committed, going by its audit entry
+func (q *Query) PendingActionRan(ctx context.Context, actionID string) (bool, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var ran bool
+	err := q.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM audit_logs WHERE request_id = $1);
+	`, pkg.PendingActionRequestID(actionID)).Scan(&ran)
+	if err != nil {
+		return false, fmt.Errorf("check pending action execution: %w", err)
+	}
+	return ran, nil
+}
+
+// FinishPendingAction records whether an approved action ran. An empty
+// failure marks it EXECUTED.
+func (q *Query) FinishPendingAction(ctx context.Context, actionID string, failure string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	status := pkg.PendingActionExecuted
+	if failure != "" {
+		status = pkg.PendingActionFailed
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE pending_actions SET action_status = $2, failure_reason = NULLIF($3, '')
+		WHERE action_id = $1 AND action_status = 'APPROVED';
+	`, actionID, status, failure)
+	if err != nil {
+		return fmt.Errorf("finish pending action: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	audit.Before = map[string]string{"action_status": pkg.PendingActionApproved}
+	audit.After = map[string]string{"action_status": status, "failure_reason": failure}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (q *Query) RejectPendingAction(ctx context.Context, adminID string, actionID string, reason string, audit *structures.AuditLogEntry) (*structures.PendingAction, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	locked, err := lockPendingAction(ctx, tx, adminID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	action, err := decidePendingAction(ctx, tx, locked, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	var res structures.PendingAction
+	if err := scanPendingAction(tx.QueryRow(ctx, `
+		UPDATE pending_actions
+		SET action_status = 'REJECTED', checker_id = $2, checker_role = $3, rejection_reason = $4, decided_at = NOW()
+		WHERE action_id = $1
+		RETURNING `+pendingActionColumns+`;
+	`, actionID, audit.ActorID, audit.ActorRole, reason), &res); err != nil {
+		return nil, fmt.Errorf("reject pending action: %w", err)
+	}
+
+	audit.Before = map[string]string{"action_status": action.Status}
+	audit.After = map[string]string{"action_status": res.Status, "rejection_reason": reason}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+// ExpirePendingActions closes the pending actions nobody decided in time and
+// returns how many were closed
+func (q *Query) ExpirePendingActions(ctx context.Context) (int64, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE pending_actions SET action_status = 'EXPIRED', decided_at = NOW()
+		WHERE action_status = 'PENDING' AND expires_at <= NOW()
+		RETURNING action_id::TEXT, action_type;
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("expire pending actions: %w", err)
+	}
+	type expiredAction struct{ id, actionType string }
+	var expired []expiredAction
+	for rows.Next() {
+		var action expiredAction
+		if err := rows.Scan(&action.id, &action.actionType); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, action)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, action := range expired {
+		if err := insertAuditLog(ctx, tx, expiredActionAudit(action.id, action.actionType)); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return int64(len(expired)), nil
+}
@@ -0,0 +1,347 @@
+package queries
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/jackc/pgx/v5"
+)
+
+// genesisAuditHash is the previous hash of the very first audit entry
+const genesisAuditHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// auditHash chains an audit entry to its predecessor. When AUDIT_LOG_SECRET is set the
+// chain is keyed, so rewriting history also requires the secret.
+func auditHash(prevHash string, fields ...string) string {
+	var h hash.Hash
+	if secret := os.Getenv("AUDIT_LOG_SECRET"); secret != "" {
+		h = hmac.New(sha256.New, []byte(secret))
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(prevHash))
+	for _, field := range fields {
+		h.Write([]byte{0x1f})
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// auditRecord is what an audit entry's hash covers, with the payloads as
+// jsonb prints them
+type auditRecord struct {
+	actorID, actorRole, action, targetType, targetID string
+	ipAddress, userAgent, requestID, before, after   string
+	createdAt                                        time.Time
+}
+
+// hash chains the record to the entry before it
+func (r *auditRecord) hash(prevHash string) string {
+	return auditHash(
+		prevHash,
+		r.actorID,
+		r.actorRole,
+		r.action,
+		r.targetType,
+		r.targetID,
+		r.ipAddress,
+		r.userAgent,
+		r.requestID,
+		r.before,
+		r.after,
+		strconv.FormatInt(r.createdAt.UnixMicro(), 10),
+	)
+}
+
+// auditLinkBroken returns why a stored entry does not follow the entry whose
+// hash was expectedPrev, or "" when it does
+func auditLinkBroken(expectedPrev string, prevHash string, entryHash string, r *auditRecord) string {
+	if prevHash != expectedPrev {
+		return "previous hash does not match the preceding entry"
+	}
+	if !hmac.Equal([]byte(r.hash(prevHash)), []byte(entryHash)) {
+		return "entry hash does not match its contents"
+	}
+	return ""
+}
+
+func marshalAuditData(data any) (*string, error) {
+	if data == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	value := string(raw)
+	return &value, nil
+}
+
+// insertAuditLog appends an entry to the hash chained audit log inside the caller's
+// transaction, so the audit record commits or rolls back together with the action.
+func insertAuditLog(ctx context.Context, tx pgx.Tx, entry *structures.AuditLogEntry) error {
+	if entry == nil {
+		return fmt.Errorf("audit entry is required")
+	}
+
+	before, err := marshalAuditData(entry.Before)
+	if err != nil {
+		return fmt.Errorf("marshal audit before data: %w", err)
+	}
+	after, err := marshalAuditData(entry.After)
+	if err != nil {
+		return fmt.Errorf("marshal audit after data: %w", err)
+	}
+
+	// Serialize writers so every entry sees the latest hash
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit_logs'));`); err != nil {
+		return fmt.Errorf("lock audit log: %w", err)
+	}
+
+	prevHash := genesisAuditHash
+	err = tx.QueryRow(ctx, `SELECT entry_hash FROM audit_logs ORDER BY audit_id DESC LIMIT 1;`).Scan(&prevHash)
+	if err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("select last audit hash: %w", err)
+	}
+
+	// Normalize the payloads through jsonb so the hash matches what is stored
+	var beforeText, afterText string
+	var createdAt time.Time
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE($1::jsonb::text, ''), COALESCE($2::jsonb::text, ''), NOW();
+	`, before, after).Scan(&beforeText, &afterText, &createdAt); err != nil {
+		return fmt.Errorf("normalize audit data: %w", err)
+	}
+
+	record := auditRecord{
+		actorID:    entry.ActorID,
+		actorRole:  entry.ActorRole,
+		action:     entry.Action,
+		targetType: entry.TargetType,
+		targetID:   entry.TargetID,
+		ipAddress:  entry.IPAddress,
+		userAgent:  entry.UserAgent,
+		requestID:  entry.RequestID,
+		before:     beforeText,
+		after:      afterText,
+		createdAt:  createdAt,
+	}
+	entryHash := record.hash(prevHash)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_logs (
+			actor_id,
+			actor_role,
+			action,
+			target_type,
+			target_id,
+			ip_address,
+			user_agent,
+			request_id,
+			before_data,
+			after_data,
+			prev_hash,
+			entry_hash,
+			created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8,
+			$9::jsonb, $10::jsonb,
+			$11, $12, $13
+		);
+	`,
+		entry.ActorID,
+		entry.ActorRole,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.RequestID,
+		before,
+		after,
+		prevHash,
+		entryHash,
+		createdAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert audit log: %w", err)
+	}
+	return nil
+}
+
+func (q *Query) GetAuditLogs(ctx context.Context, filter *structures.AuditLogFilter) (*[]structures.AuditLog, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if filter.From != "" {
+		addCondition("created_at >= $%d::timestamptz", filter.From)
+	}
+	if filter.To != "" {
+		addCondition("created_at < $%d::timestamptz", filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset := max(filter.Offset, 0)
+
+	query := `
+		SELECT
+			audit_id,
+			actor_id,
+			actor_role,
+			action,
+			target_type,
+			target_id,
+			ip_address,
+			user_agent,
+			request_id,
+			COALESCE(before_data, 'null'::jsonb),
+			COALESCE(after_data, 'null'::jsonb),
+			prev_hash,
+			entry_hash,
+			created_at::TEXT
+		FROM audit_logs
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY audit_id DESC LIMIT $%d OFFSET $%d;", len(args)-1, len(args))
+
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []structures.AuditLog
+	for rows.Next() {
+		var l structures.AuditLog
+		if err := rows.Scan(
+			&l.AuditID,
+			&l.ActorID,
+			&l.ActorRole,
+			&l.Action,
+			&l.TargetType,
+			&l.TargetID,
+			&l.IPAddress,
+			&l.UserAgent,
+			&l.RequestID,
+			&l.BeforeData,
+			&l.AfterData,
+			&l.PrevHash,
+			&l.EntryHash,
+			&l.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &logs, nil
+}
+
+// VerifyAuditLogs walks the whole chain in insertion order and recomputes every hash.
+func (q *Query) VerifyAuditLogs(ctx context.Context) (*structures.AuditVerificationResult, error) {
+	const query = `
+		SELECT
+			audit_id,
+			actor_id,
+			actor_role,
+			action,
+			target_type,
+			target_id,
+			ip_address,
+			user_agent,
+			request_id,
+			COALESCE(before_data::text, ''),
+			COALESCE(after_data::text, ''),
+			prev_hash,
+			entry_hash,
+			created_at
+		FROM audit_logs
+		ORDER BY audit_id ASC;
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := structures.AuditVerificationResult{Valid: true}
+	expectedPrev := genesisAuditHash
+	for rows.Next() {
+		var (
+			auditID             int64
+			r                   auditRecord
+			prevHash, entryHash string
+		)
+		if err := rows.Scan(
+			&auditID,
+			&r.actorID,
+			&r.actorRole,
+			&r.action,
+			&r.targetType,
+			&r.targetID,
+			&r.ipAddress,
+			&r.userAgent,
+			&r.requestID,
+			&r.before,
+			&r.after,
+			&prevHash,
+			&entryHash,
+			&r.createdAt,
+		); err != nil {
+			return nil, err
+		}
+		res.CheckedEntries++
+
+		if reason := auditLinkBroken(expectedPrev, prevHash, entryHash, &r); reason != "" {
+			res.Valid = false
+			res.BrokenAuditID = auditID
+			res.Reason = reason
+			return &res, nil
+		}
+		expectedPrev = entryHash
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
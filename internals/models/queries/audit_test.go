@@ -0,0 +1,118 @@
+package queries
+
+import (
+	"testing"
+	"time"
+)
+
+// auditLink is an entry as stored, with the hashes it was written with
+type auditLink struct {
+	record              auditRecord
+	prevHash, entryHash string
+}
+
+// buildAuditChain chains the records the way insertAuditLog does
+func buildAuditChain(records ...auditRecord) []auditLink {
+	prev := genesisAuditHash
+	chain := make([]auditLink, 0, len(records))
+	for _, r := range records {
+		hash := r.hash(prev)
+		chain = append(chain, auditLink{record: r, prevHash: prev, entryHash: hash})
+		prev = hash
+	}
+	return chain
+}
+
+// verifyAuditChain walks the chain the way VerifyAuditLogs does and returns
+// the index of the first broken entry and why, or -1
+func verifyAuditChain(chain []auditLink) (int, string) {
+	expectedPrev := genesisAuditHash
+	for i, link := range chain {
+		if reason := auditLinkBroken(expectedPrev, link.prevHash, link.entryHash, &link.record); reason != "" {
+			return i, reason
+		}
+		expectedPrev = link.entryHash
+	}
+	return -1, ""
+}
+
+func testAuditRecords() []auditRecord {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 123456000, time.UTC)
+	return []auditRecord{
+		{actorID: "admin-1", actorRole: "ADMIN", action: "USER_BLOCK", targetType: "USER", targetID: "user-1",
+			ipAddress: "10.0.0.1", requestID: "req-1", before: `{"blocked": false}`, after: `{"blocked": true}`, createdAt: at},
+		{actorID: "admin-1", actorRole: "ADMIN", action: "WALLET_TOPUP", targetType: "ADMIN", targetID: "admin-1",
+			requestID: "req-2", after: `{"amount": "5000"}`, createdAt: at.Add(time.Minute)},
+		{actorID: "md-1", actorRole: "MASTER_DISTRIBUTOR", action: "FUND_TRANSFER", targetType: "DISTRIBUTOR",
+			targetID: "dist-1", userAgent: "curl/8", createdAt: at.Add(2 * time.Minute)},
+	}
+}
+
+func TestAuditChainVerifies(t *testing.T) {
+	t.Setenv("AUDIT_LOG_SECRET", "")
+	chain := buildAuditChain(testAuditRecords()...)
+	if i, reason := verifyAuditChain(chain); i != -1 {
+		t.Fatalf("untouched chain broken at %d: %s", i, reason)
+	}
+	if chain[0].prevHash != genesisAuditHash {
+		t.Errorf("first entry chained to %s, want the genesis hash", chain[0].prevHash)
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	t.Setenv("AUDIT_LOG_SECRET", "")
+	tests := []struct {
+		name   string
+		tamper func(chain []auditLink)
+		broken int
+		reason string
+	}{
+		{"edited payload", func(c []auditLink) { c[1].record.after = `{"amount": "50000"}` }, 1,
+			"entry hash does not match its contents"},
+		{"edited actor", func(c []auditLink) { c[0].record.actorID = "admin-2" }, 0,
+			"entry hash does not match its contents"},
+		{"moved timestamp", func(c []auditLink) { c[2].record.createdAt = c[2].record.createdAt.Add(time.Microsecond) }, 2,
+			"entry hash does not match its contents"},
+		{"deleted entry", func(c []auditLink) { c[1] = c[2] }, 1,
+			"previous hash does not match the preceding entry"},
+		{"rehashed entry", func(c []auditLink) {
+			c[1].record.after = `{"amount": "50000"}`
+			c[1].entryHash = c[1].record.hash(c[1].prevHash)
+		}, 2, "previous hash does not match the preceding entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := buildAuditChain(testAuditRecords()...)
+			tt.tamper(chain)
+			i, reason := verifyAuditChain(chain)
+			if i != tt.broken || reason != tt.reason {
+				t.Errorf("broken at %d (%s), want %d (%s)", i, reason, tt.broken, tt.reason)
+			}
+		})
+	}
+}
+
+func TestAuditHashIsKeyedBySecret(t *testing.T) {
+	record := testAuditRecords()[0]
+
+	t.Setenv("AUDIT_LOG_SECRET", "")
+	plain := record.hash(genesisAuditHash)
+	t.Setenv("AUDIT_LOG_SECRET", "first secret")
+	keyed := record.hash(genesisAuditHash)
+	if plain == keyed {
+		t.Fatal("keyed hash equals the plain hash")
+	}
+
+	chain := buildAuditChain(testAuditRecords()...)
+	t.Setenv("AUDIT_LOG_SECRET", "second secret")
+	if i, _ := verifyAuditChain(chain); i != 0 {
+		t.Errorf("chain written with another secret broken at %d, want 0", i)
+	}
+}
+
+func TestAuditHashSeparatesFields(t *testing.T) {
+	t.Setenv("AUDIT_LOG_SECRET", "")
+	if auditHash(genesisAuditHash, "ab", "c") == auditHash(genesisAuditHash, "a", "bc") {
+		t.Error("moving text between fields kept the hash")
+	}
+}
@@ -2,40 +2,41 @@ package queries
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/jackc/pgx/v5"
 )
 
-func (q *Query) CreateAdmin(req *structures.AdminRegisterRequest) (*structures.AdminAuthResponse, error) {
-	var res structures.AdminAuthResponse
+// rowQuerier runs a single row query on the pool or inside a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
 
-	query := `
-		INSERT INTO admins (
-			admin_name,
-			admin_phone,
-			admin_email,
-			admin_password
-		)
-		VALUES (
-			$1, $2, $3, $4
-		)
-		RETURNING admin_id, admin_unique_id, admin_name;
+// Profile snapshots for the audit log, credentials are never copied
+const (
+	userProfileSnapshotQuery = `
+		SELECT to_jsonb(users.*) - 'user_password' - 'user_mpin'
+		FROM users WHERE user_id=$1 FOR UPDATE;
 	`
+	masterDistributorProfileSnapshotQuery = `
+		SELECT to_jsonb(master_distributors.*) - 'master_distributor_password'
+		FROM master_distributors WHERE master_distributor_id=$1 FOR UPDATE;
+	`
+	distributorProfileSnapshotQuery = `
+		SELECT to_jsonb(distributors.*) - 'distributor_password'
+		FROM distributors WHERE distributor_id=$1 FOR UPDATE;
+	`
+)
 
-	err := q.Pool.QueryRow(
-		context.Background(),
-		query,
-		req.AdminName,
-		req.AdminPhone,
-		req.AdminEmail,
-		req.AdminPassword,
-	).Scan(&res.AdminID, &res.AdminUniqueID, &res.AdminName)
+func (q *Query) CreateMasterDistributor(ctx context.Context, req *structures.MasterDistributorRegisterRequest) (*structures.MasterDistributorAuthResponse, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
 
-	return &res, err
+	return insertMasterDistributor(ctx, q.Pool, req)
 }
 
-func (q *Query) CreateMasterDistributor(req *structures.MasterDistributorRegisterRequest) (*structures.MasterDistributorAuthResponse, error) {
+func insertMasterDistributor(ctx context.Context, db rowQuerier, req *structures.MasterDistributorRegisterRequest) (*structures.MasterDistributorAuthResponse, error) {
 	var res structures.MasterDistributorAuthResponse
 
 	query := `
@@ -70,8 +71,8 @@ func (q *Query) CreateMasterDistributor(req *structures.MasterDistributorRegiste
 			admin_id;
 	`
 
-	err := q.Pool.QueryRow(
-		context.Background(),
+	err := db.QueryRow(
+		ctx,
 		query,
 		req.AdminID,
 		req.MasterDistributorName,
@@ -99,7 +100,14 @@ func (q *Query) CreateMasterDistributor(req *structures.MasterDistributorRegiste
 	return &res, err
 }
 
-func (q *Query) CreateDistributor(req *structures.DistributorRegisterRequest) (*structures.DistributorAuthResponse, error) {
+func (q *Query) CreateDistributor(ctx context.Context, req *structures.DistributorRegisterRequest) (*structures.DistributorAuthResponse, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	return insertDistributor(ctx, q.Pool, req)
+}
+
+func insertDistributor(ctx context.Context, db rowQuerier, req *structures.DistributorRegisterRequest) (*structures.DistributorAuthResponse, error) {
 	var res structures.DistributorAuthResponse
 
 	query := `
@@ -136,8 +144,8 @@ func (q *Query) CreateDistributor(req *structures.DistributorRegisterRequest) (*
 			distributor_name;
 	`
 
-	err := q.Pool.QueryRow(
-		context.Background(),
+	err := db.QueryRow(
+		ctx,
 		query,
 		req.MasterDistributorID,      // $1
 		req.AdminID,                  // $2
@@ -167,7 +175,14 @@ func (q *Query) CreateDistributor(req *structures.DistributorRegisterRequest) (*
 	return &res, err
 }
 
-func (q *Query) CreateUser(req *structures.UserRegistrationRequest) (*structures.UserAuthResponse, error) {
+func (q *Query) CreateUser(ctx context.Context, req *structures.UserRegistrationRequest) (*structures.UserAuthResponse, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	return insertUser(ctx, q.Pool, req)
+}
+
+func insertUser(ctx context.Context, db rowQuerier, req *structures.UserRegistrationRequest) (*structures.UserAuthResponse, error) {
 	var res structures.UserAuthResponse
 
 	query := `
@@ -206,8 +221,8 @@ func (q *Query) CreateUser(req *structures.UserRegistrationRequest) (*structures
 			admin_id;
 	`
 
-	err := q.Pool.QueryRow(
-		context.Background(),
+	err := db.QueryRow(
+		ctx,
 		query,
 		req.AdminID,              // $1
 		req.MasterDistributorID,  // $2
@@ -240,75 +255,55 @@ func (q *Query) CreateUser(req *structures.UserRegistrationRequest) (*structures
 }
 
 
-func (q *Query) GetAdminPassword(email string) (string, error) {
+func (q *Query) GetAdminPassword(ctx context.Context, email string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var password string
 	query := `SELECT admin_password FROM admins WHERE admin_email=$1`
-	err := q.Pool.QueryRow(context.Background(), query, email).Scan(&password)
+	err := q.Pool.QueryRow(ctx, query, email).Scan(&password)
 	return password, err
 }
 
-func (q *Query) GetMasterDistributorPassword(email string) (string, error) {
+func (q *Query) GetMasterDistributorPassword(ctx context.Context, email string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var password string
 	query := `SELECT master_distributor_password FROM master_distributors WHERE master_distributor_email=$1`
-	err := q.Pool.QueryRow(context.Background(), query, email).Scan(&password)
+	err := q.Pool.QueryRow(ctx, query, email).Scan(&password)
 	return password, err
 }
 
-func (q *Query) GetDistributorPassword(email string) (string, error) {
+func (q *Query) GetDistributorPassword(ctx context.Context, email string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var password string
 	query := `SELECT distributor_password FROM distributors WHERE distributor_email=$1`
-	err := q.Pool.QueryRow(context.Background(), query, email).Scan(&password)
+	err := q.Pool.QueryRow(ctx, query, email).Scan(&password)
 	return password, err
 }
 
-func (q *Query) GenerateOTPForUser(phone string) (string, error) {
-	var otp string
-	query := `
-		INSERT INTO otps (phone)
-		VALUES ($1)
-		RETURNING otp;
-	`
-	err := q.Pool.QueryRow(context.Background(), query, phone).Scan(&otp)
-	return otp, err
-}
+// GetUserLoginByPhone loads the user signing in with a verified phone number
+func (q *Query) GetUserLoginByPhone(ctx context.Context, phone string) (*structures.UserAuthResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
 
-func (q *Query) ValidateOTP(req *structures.UserLoginRequest) (*structures.UserAuthResponse, error) {
 	var res structures.UserAuthResponse
-	var mpin any
-
-	query := `
-	WITH validated AS (
-		SELECT 
-			u.user_id::TEXT AS user_id,
-			u.user_unique_id,
-			u.user_name,
-			u.admin_id::TEXT AS admin_id,
-			u.master_distributor_id::TEXT AS master_distributor_id,
-			u.user_mpin::TEXT as user_mpin,
-			u.distributor_id::TEXT AS distributor_id,
-			o.phone
-		FROM otps o
-		JOIN users u ON u.user_phone = o.phone
-		WHERE o.phone = $1 AND o.otp = $2
-	)
-	DELETE FROM otps 
-	WHERE phone = (SELECT phone FROM validated)
-	RETURNING 
-		(SELECT user_id FROM validated),
-		(SELECT user_unique_id FROM validated),
-		(SELECT user_name FROM validated),
-		(SELECT admin_id FROM validated),
-		(SELECT master_distributor_id FROM validated),
-		(SELECT user_mpin FROM validated),
-		(SELECT distributor_id FROM validated);
-	`
-
-	err := q.Pool.QueryRow(
-		context.Background(),
-		query,
-		req.Phone,
-		req.OTP,
-	).Scan(
+	var mpin string
+	err := q.Pool.QueryRow(ctx, `
+		SELECT
+			user_id::TEXT,
+			user_unique_id,
+			user_name,
+			admin_id::TEXT,
+			master_distributor_id::TEXT,
+			user_mpin,
+			distributor_id::TEXT
+		FROM users
+		WHERE user_phone = $1;
+	`, phone).Scan(
 		&res.UserID,
 		&res.UserUniqueID,
 		&res.UserName,
@@ -317,29 +312,32 @@ func (q *Query) ValidateOTP(req *structures.UserLoginRequest) (*structures.UserA
 		&mpin,
 		&res.DistributorID,
 	)
-
-	if mpin != "" {
-		res.IsMpinSet = true
-	}
-
+	res.IsMpinSet = mpin != ""
 	return &res, err
 }
 
-func (q *Query) LoginAdmin(req *structures.AdminLoginRequest) (*structures.AdminAuthResponse, error) {
+func (q *Query) LoginAdmin(ctx context.Context, req *structures.AdminLoginRequest) (*structures.AdminAuthResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.AdminAuthResponse
 	query := `
 		SELECT 
   			admin_id::TEXT AS admin_id,
   			admin_unique_id,
-  			admin_name
+  			admin_name,
+  			admin_role
 		FROM admins
 		WHERE admin_email = $1;`
 
-	err := q.Pool.QueryRow(context.Background(), query, req.AdminEmail).Scan(&res.AdminID, &res.AdminUniqueID, &res.AdminName)
+	err := q.Pool.QueryRow(ctx, query, req.AdminEmail).Scan(&res.AdminID, &res.AdminUniqueID, &res.AdminName, &res.AdminRole)
 	return &res, err
 }
 
-func (q *Query) LoginMasterDistributor(req *structures.MasterDistributorLoginRequest) (*structures.MasterDistributorAuthResponse, error) {
+func (q *Query) LoginMasterDistributor(ctx context.Context, req *structures.MasterDistributorLoginRequest) (*structures.MasterDistributorAuthResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.MasterDistributorAuthResponse
 
 	query := `
@@ -353,7 +351,7 @@ func (q *Query) LoginMasterDistributor(req *structures.MasterDistributorLoginReq
 	`
 
 	err := q.Pool.QueryRow(
-		context.Background(),
+		ctx,
 		query,
 		req.MasterDistributorEmail,
 	).Scan(
@@ -366,7 +364,10 @@ func (q *Query) LoginMasterDistributor(req *structures.MasterDistributorLoginReq
 	return &res, err
 }
 
-func (q *Query) LoginDistributor(req *structures.DistributorLoginRequest) (*structures.DistributorAuthResponse, error) {
+func (q *Query) LoginDistributor(ctx context.Context, req *structures.DistributorLoginRequest) (*structures.DistributorAuthResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.DistributorAuthResponse
 
 	query := `
@@ -381,7 +382,7 @@ func (q *Query) LoginDistributor(req *structures.DistributorLoginRequest) (*stru
 	`
 
 	err := q.Pool.QueryRow(
-		context.Background(),
+		ctx,
 		query,
 		req.DistributorEmail,
 	).Scan(
@@ -395,50 +396,82 @@ func (q *Query) LoginDistributor(req *structures.DistributorLoginRequest) (*stru
 	return &res, err
 }
 
-func (q *Query) CheckUserExistViaPhone(phone string) (bool, error) {
+func (q *Query) CheckUserExistViaPhone(ctx context.Context, phone string) (bool, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var isUserExist bool
 	query := "SELECT EXISTS(SELECT 1 FROM users WHERE user_phone=$1) as user_exists"
-	err := q.Pool.QueryRow(context.Background(), query, phone).Scan(&isUserExist)
+	err := q.Pool.QueryRow(ctx, query, phone).Scan(&isUserExist)
 	return isUserExist, err
 }
 
-func (q *Query) SetMpin(userId string, mpin string) (*structures.UserAuthResponse, error) {
+// SetMpin stores the first MPIN of a user. An MPIN that is already set can
+// only be replaced through ResetUserMpin.
+func (q *Query) SetMpin(ctx context.Context, userId string, mpinHash string) (*structures.UserAuthResponse, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	var res structures.UserAuthResponse
-	var mpins string
-	query := `UPDATE users SET user_mpin=$1 WHERE user_id=$2 RETURNING admin_id, master_distributor_id, distributor_id, user_id, user_unique_id, user_mpin;`
-	err := q.Pool.QueryRow(context.Background(), query, mpin, userId).Scan(
+	query := `
+		UPDATE users SET
+			user_mpin=$1,
+			user_mpin_failed_attempts=0,
+			user_mpin_locked_until=NULL
+		WHERE user_id=$2 AND user_mpin=''
+		RETURNING admin_id, master_distributor_id, distributor_id, user_id, user_unique_id;
+	`
+	err := q.Pool.QueryRow(ctx, query, mpinHash, userId).Scan(
 		&res.AdminID,
 		&res.MasterDistributorID,
 		&res.DistributorID,
 		&res.UserID,
 		&res.UserUniqueID,
-		&mpins,
 	)
-	if mpins == "" {
-		return nil, fmt.Errorf("mpin not set")
+	if err == pgx.ErrNoRows {
+		var exists bool
+		if err := q.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE user_id=$1)`, userId).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrMpinAlreadySet
+		}
+		return nil, pgx.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
 	}
 	res.IsMpinSet = true
-	return &res, err
+	return &res, nil
 }
 
-func (q *Query) VerifyMPIN(userId string, mpin string) (bool, error) {
-	var isValidMpin bool
-	query := `SELECT EXISTS(SELECT 1 FROM users WHERE user_id=$1 AND user_mpin=$2) as user_exists`
-	err := q.Pool.QueryRow(context.Background(), query, userId, mpin).Scan(&isValidMpin)
-	return isValidMpin, err
-}
+func (q *Query) UpdateProfile(ctx context.Context, req *structures.UpdateUserProfile, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
 
-func (q *Query) UpdateProfile(req *structures.UpdateUserProfile) error {
 	query := `
 		UPDATE users
 		SET user_name=$1 , user_email=$2 , user_phone=$3,
 		user_aadhar_number=$4, user_pan_number=$5,
 		user_city=$6, user_state=$7, user_address=$8,
 		user_pincode=$9, user_date_of_birth=$10,
-		user_gender=$11 WHERE user_id=$12;
+		user_gender=$11 WHERE user_id=$12
+		RETURNING to_jsonb(users.*) - 'user_password' - 'user_mpin';
 	`
-	_, err := q.Pool.Exec(
-		context.Background(),
+
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(ctx, tx)
+
+	var before, after json.RawMessage
+	if err := tx.QueryRow(ctx, userProfileSnapshotQuery, req.UserID).Scan(&before); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		ctx,
 		query,
 		req.UserName,
 		req.UserEmail,
@@ -452,11 +485,23 @@ func (q *Query) UpdateProfile(req *structures.UpdateUserProfile) error {
 		req.UserDateOfBirth,
 		req.UserGender,
 		req.UserID,
-	)
-	return err
+	).Scan(&after)
+	if err != nil {
+		return err
+	}
+
+	audit.Before = before
+	audit.After = after
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-func (q *Query) FetchProfileDetails(userId string) (*structures.GetUserProfile, error) {
+func (q *Query) FetchProfileDetails(ctx context.Context, userId string) (*structures.GetUserProfile, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.GetUserProfile
 	query := `
 		SELECT user_id , user_unique_id ,user_name , user_email , user_phone,
@@ -465,7 +510,7 @@ func (q *Query) FetchProfileDetails(userId string) (*structures.GetUserProfile,
 		user_pincode, user_date_of_birth,
 		user_gender FROM users  WHERE user_id=$1;
 	`
-	err := q.Pool.QueryRow(context.Background(), query, userId).Scan(
+	err := q.Pool.QueryRow(ctx, query, userId).Scan(
 		&res.UserID,
 		&res.UserUniqueID,
 		&res.UserName,
@@ -484,7 +529,10 @@ func (q *Query) FetchProfileDetails(userId string) (*structures.GetUserProfile,
 }
 
 // UpdateMasterDistributorProfile updates the master distributor's profile details.
-func (q *Query) UpdateMasterDistributorProfile(req *structures.UpdateMasterDistributorProfile) error {
+func (q *Query) UpdateMasterDistributorProfile(ctx context.Context, req *structures.UpdateMasterDistributorProfile, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE master_distributors
 		SET master_distributor_name=$1,
@@ -498,11 +546,24 @@ func (q *Query) UpdateMasterDistributorProfile(req *structures.UpdateMasterDistr
 			master_distributor_pincode=$9,
 			master_distributor_date_of_birth=$10,
 			master_distributor_gender=$11
-		WHERE master_distributor_id=$12;
+		WHERE master_distributor_id=$12
+		RETURNING to_jsonb(master_distributors.*) - 'master_distributor_password';
 	`
 
-	_, err := q.Pool.Exec(
-		context.Background(),
+
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(ctx, tx)
+
+	var before, after json.RawMessage
+	if err := tx.QueryRow(ctx, masterDistributorProfileSnapshotQuery, req.MasterDistributorID).Scan(&before); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		ctx,
 		query,
 		req.MasterDistributorName,
 		req.MasterDistributorEmail,
@@ -516,13 +577,24 @@ func (q *Query) UpdateMasterDistributorProfile(req *structures.UpdateMasterDistr
 		req.MasterDistributorDateOfBirth,
 		req.MasterDistributorGender,
 		req.MasterDistributorID,
-	)
+	).Scan(&after)
+	if err != nil {
+		return err
+	}
 
-	return err
+	audit.Before = before
+	audit.After = after
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 // FetchMasterDistributorProfileDetails fetches the master distributor's profile details.
-func (q *Query) FetchMasterDistributorProfileDetails(masterDistributorID string) (*structures.GetMasterDistributorProfile, error) {
+func (q *Query) FetchMasterDistributorProfileDetails(ctx context.Context, masterDistributorID string) (*structures.GetMasterDistributorProfile, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.GetMasterDistributorProfile
 
 	query := `
@@ -545,7 +617,7 @@ func (q *Query) FetchMasterDistributorProfileDetails(masterDistributorID string)
 	`
 
 	err := q.Pool.QueryRow(
-		context.Background(),
+		ctx,
 		query,
 		masterDistributorID,
 	).Scan(
@@ -568,7 +640,10 @@ func (q *Query) FetchMasterDistributorProfileDetails(masterDistributorID string)
 }
 
 // UpdateDistributorProfile updates the distributor's profile details.
-func (q *Query) UpdateDistributorProfile(req *structures.UpdateDistributorProfile) error {
+func (q *Query) UpdateDistributorProfile(ctx context.Context, req *structures.UpdateDistributorProfile, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE distributors
 		SET distributor_name=$1,
@@ -582,11 +657,24 @@ func (q *Query) UpdateDistributorProfile(req *structures.UpdateDistributorProfil
 			distributor_pincode=$9,
 			distributor_date_of_birth=$10,
 			distributor_gender=$11
-		WHERE distributor_id=$12;
+		WHERE distributor_id=$12
+		RETURNING to_jsonb(distributors.*) - 'distributor_password';
 	`
 
-	_, err := q.Pool.Exec(
-		context.Background(),
+
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(ctx, tx)
+
+	var before, after json.RawMessage
+	if err := tx.QueryRow(ctx, distributorProfileSnapshotQuery, req.DistributorID).Scan(&before); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		ctx,
 		query,
 		req.DistributorName,
 		req.DistributorEmail,
@@ -600,13 +688,24 @@ func (q *Query) UpdateDistributorProfile(req *structures.UpdateDistributorProfil
 		req.DistributorDateOfBirth,
 		req.DistributorGender,
 		req.DistributorID,
-	)
+	).Scan(&after)
+	if err != nil {
+		return err
+	}
 
-	return err
+	audit.Before = before
+	audit.After = after
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 // FetchDistributorProfileDetails fetches the distributor's profile details.
-func (q *Query) FetchDistributorProfileDetails(distributorID string) (*structures.GetDistributorProfile, error) {
+func (q *Query) FetchDistributorProfileDetails(ctx context.Context, distributorID string) (*structures.GetDistributorProfile, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.GetDistributorProfile
 
 	query := `
@@ -629,7 +728,7 @@ func (q *Query) FetchDistributorProfileDetails(distributorID string) (*structure
 	`
 
 	err := q.Pool.QueryRow(
-		context.Background(),
+		ctx,
 		query,
 		distributorID,
 	).Scan(
@@ -6,15 +6,21 @@ import (
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
 )
 
-func (q *Query) AddNewBank(req *structures.BankModel) error {
+func (q *Query) AddNewBank(ctx context.Context, req *structures.BankModel) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `INSERT INTO banks (bank_name, ifsc_code) VALUES ($1, $2)`
-	_, err := q.Pool.Exec(context.Background(), query, req.BankName, req.IFSCCode)
+	_, err := q.Pool.Exec(ctx, query, req.BankName, req.IFSCCode)
 	return err
 }
 
-func (q *Query) GetBanks() (*[]structures.BankModel, error) {
+func (q *Query) GetBanks(ctx context.Context) (*[]structures.BankModel, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	query := `SELECT bank_name, ifsc_code FROM banks`
-	rows, err := q.Pool.Query(context.Background(), query)
+	rows, err := q.Pool.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
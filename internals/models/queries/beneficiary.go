@@ -6,15 +6,21 @@ import (
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
 )
 
-func (q *Query) AddNewBeneficiary(req *structures.BeneficiaryModel) error {
+func (q *Query) AddNewBeneficiary(ctx context.Context, req *structures.BeneficiaryModel) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `INSERT INTO beneficiaries (mobile_number, bank_name, ifsc_code, account_number, beneficiary_name, beneficiary_phone) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := q.Pool.Exec(context.Background(), query, req.MobileNumber, req.BankName, req.IFSCCode, req.AccountNumber, req.BeneficiaryName, req.BeneficiaryPhone)
+	_, err := q.Pool.Exec(ctx, query, req.MobileNumber, req.BankName, req.IFSCCode, req.AccountNumber, req.BeneficiaryName, req.BeneficiaryPhone)
 	return err
 }
 
-func (q *Query) GetBeneficiaries(mobileNumber string) (*[]structures.BeneficiaryModel, error) {
+func (q *Query) GetBeneficiaries(ctx context.Context, mobileNumber string) (*[]structures.BeneficiaryModel, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	query := `SELECT beneficiary_id ,mobile_number ,bank_name, ifsc_code, account_number, beneficiary_name, beneficiary_phone, beneficiary_verified FROM beneficiaries WHERE mobile_number = $1`
-	rows, err := q.Pool.Query(context.Background(), query , mobileNumber)
+	rows, err := q.Pool.Query(ctx, query , mobileNumber)
 	if err != nil {
 		return nil, err
 	}
@@ -32,14 +38,20 @@ func (q *Query) GetBeneficiaries(mobileNumber string) (*[]structures.Beneficiary
 	return &beneficiaries, nil
 }
 
-func (q *Query) VerifyBenificary(beneficiaryId string) error {
+func (q *Query) VerifyBenificary(ctx context.Context, beneficiaryId string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `UPDATE beneficiaries SET beneficiary_verified = TRUE WHERE beneficiary_id = $1`
-	_, err := q.Pool.Exec(context.Background(), query, beneficiaryId)	
+	_, err := q.Pool.Exec(ctx, query, beneficiaryId)	
 	return err
 }
 
-func (q *Query) DeleteBeneficiary(beneficiaryId string) error {
+func (q *Query) DeleteBeneficiary(ctx context.Context, beneficiaryId string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `DELETE FROM beneficiaries WHERE beneficiary_id=$1`
-	_, err := q.Pool.Exec(context.Background(),query,beneficiaryId)
+	_, err := q.Pool.Exec(ctx,query,beneficiaryId)
 	return err
 }
@@ -2,12 +2,11 @@ package queries
 
 import (
 	"context"
-	"time"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
 )
 
-func (q *Query) GetAllMasterDistributorsByID(adminId string) (*[]structures.MasterDistributorGetResponse, error) {
+func (q *Query) GetAllMasterDistributorsByID(ctx context.Context, adminId string) (*[]structures.MasterDistributorGetResponse, error) {
 	const query = `
 		SELECT 
 			master_distributor_id,
@@ -25,7 +24,7 @@ func (q *Query) GetAllMasterDistributorsByID(adminId string) (*[]structures.Mast
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, adminId)
@@ -57,7 +56,7 @@ func (q *Query) GetAllMasterDistributorsByID(adminId string) (*[]structures.Mast
 	return &out, nil
 }
 
-func (q *Query) GetAllDistributorsByMasterDistributorID(masterDistributorId string) (*[]structures.DistributorGetResponse, error) {
+func (q *Query) GetAllDistributorsByMasterDistributorID(ctx context.Context, masterDistributorId string) (*[]structures.DistributorGetResponse, error) {
 	const query = `
 		SELECT
 			distributor_id,
@@ -74,7 +73,7 @@ func (q *Query) GetAllDistributorsByMasterDistributorID(masterDistributorId stri
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, masterDistributorId)
@@ -106,7 +105,7 @@ func (q *Query) GetAllDistributorsByMasterDistributorID(masterDistributorId stri
 	return &out, nil
 }
 
-func (q *Query) GetAllUsersByDistributorID(distributorId string) (*[]structures.UserGetResponse, error) {
+func (q *Query) GetAllUsersByDistributorID(ctx context.Context, distributorId string) (*[]structures.UserGetResponse, error) {
 	const query = `
 		SELECT
 			user_id,
@@ -123,7 +122,7 @@ func (q *Query) GetAllUsersByDistributorID(distributorId string) (*[]structures.
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, distributorId)
@@ -155,7 +154,7 @@ func (q *Query) GetAllUsersByDistributorID(distributorId string) (*[]structures.
 	return &out, nil
 }
 
-func (q *Query) GetAllUsersByAdminID(adminId string) (*[]structures.UserGetResponse, error) {
+func (q *Query) GetAllUsersByAdminID(ctx context.Context, adminId string) (*[]structures.UserGetResponse, error) {
 	const query = `
 		SELECT
 			user_id,
@@ -173,7 +172,7 @@ func (q *Query) GetAllUsersByAdminID(adminId string) (*[]structures.UserGetRespo
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, adminId)
@@ -206,7 +205,7 @@ func (q *Query) GetAllUsersByAdminID(adminId string) (*[]structures.UserGetRespo
 	return &out, nil
 }
 
-func (q *Query) GetAllDistributorsByAdminID(adminId string) (*[]structures.DistributorGetResponse, error) {
+func (q *Query) GetAllDistributorsByAdminID(ctx context.Context, adminId string) (*[]structures.DistributorGetResponse, error) {
 	const query = `
 		SELECT
 			distributor_id,
@@ -223,7 +222,7 @@ func (q *Query) GetAllDistributorsByAdminID(adminId string) (*[]structures.Distr
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, adminId)
@@ -255,7 +254,10 @@ func (q *Query) GetAllDistributorsByAdminID(adminId string) (*[]structures.Distr
 	return &out, nil
 }
 
-func (q *Query) GetUserByPhone(phoneNumber string) (*structures.UserGetResponse, error) {
+func (q *Query) GetUserByPhone(ctx context.Context, phoneNumber string) (*structures.UserGetResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.UserGetResponse
 	query := `
 		SELECT
@@ -272,7 +274,7 @@ func (q *Query) GetUserByPhone(phoneNumber string) (*structures.UserGetResponse,
 		ORDER BY
 			created_at DESC;
 	`
-	err := q.Pool.QueryRow(context.Background(), query, phoneNumber).Scan(
+	err := q.Pool.QueryRow(ctx, query, phoneNumber).Scan(
 		&res.UserID,
 		&res.UserUniqueID,
 		&res.UserName,
@@ -283,7 +285,7 @@ func (q *Query) GetUserByPhone(phoneNumber string) (*structures.UserGetResponse,
 	return &res, err
 }
 
-func (q *Query) GetMasterDistributorByPhone(phoneNumber string) (*structures.MasterDistributorGetResponse, error) {
+func (q *Query) GetMasterDistributorByPhone(ctx context.Context, phoneNumber string) (*structures.MasterDistributorGetResponse, error) {
 	var res structures.MasterDistributorGetResponse
 	const query = `
 		SELECT 
@@ -301,7 +303,7 @@ func (q *Query) GetMasterDistributorByPhone(phoneNumber string) (*structures.Mas
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	err := q.Pool.QueryRow(ctx, query, phoneNumber).Scan(
@@ -315,7 +317,10 @@ func (q *Query) GetMasterDistributorByPhone(phoneNumber string) (*structures.Mas
 	return &res, err
 }
 
-func (q *Query) GetDistributorsByPhone(phoneNumber string) (*structures.DistributorGetResponse, error) {
+func (q *Query) GetDistributorsByPhone(ctx context.Context, phoneNumber string) (*structures.DistributorGetResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var res structures.DistributorGetResponse
 	const query = `
 		SELECT
@@ -330,7 +335,7 @@ func (q *Query) GetDistributorsByPhone(phoneNumber string) (*structures.Distribu
 		WHERE
 			distributor_phone=$1;
 	`
-	err := q.Pool.QueryRow(context.Background(), query, phoneNumber).Scan(
+	err := q.Pool.QueryRow(ctx, query, phoneNumber).Scan(
 		&res.DistributorID,
 		&res.DistributorUniqueID,
 		&res.DistributorName,
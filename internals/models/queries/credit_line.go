@@ -0,0 +1,351 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrCreditLentElsewhere = errors.New("credit line belongs to another lender")
+	ErrCreditOutstanding   = errors.New("credit line has outstanding credit")
+)
+
+const creditLineColumns = `
+	c.credit_line_id::TEXT, c.member_id::TEXT, c.member_type, COALESCE(d.distributor_name, u.user_name, ''),
+	c.lender_id::TEXT, c.lender_type, c.credit_limit::TEXT, c.repayment_days, b.balance::TEXT,
+	GREATEST(-b.balance, 0)::TEXT, GREATEST(available_credit(c.member_id) + LEAST(b.balance, 0), 0)::TEXT,
+	c.drawn_at, c.due_at, COALESCE(c.due_at <= NOW(), FALSE), c.created_at, c.updated_at
+`
+
+const creditLineMembers = `
+	LEFT JOIN distributors d ON c.member_type = 'DISTRIBUTOR' AND d.distributor_id = c.member_id
+	LEFT JOIN users u ON c.member_type = 'USER' AND u.user_id = c.member_id
+	CROSS JOIN LATERAL (SELECT COALESCE(d.distributor_wallet_balance, u.user_wallet_balance, 0) AS balance) b
+`
+
+func scanCreditLine(row pgx.Row, line *structures.CreditLine) error {
+	return row.Scan(
+		&line.CreditLineID,
+		&line.MemberID,
+		&line.MemberType,
+		&line.MemberName,
+		&line.LenderID,
+		&line.LenderType,
+		&line.CreditLimit,
+		&line.RepaymentDays,
+		&line.Balance,
+		&line.Outstanding,
+		&line.Available,
+		&line.DrawnAt,
+		&line.DueAt,
+		&line.Overdue,
+		&line.CreatedAt,
+		&line.UpdatedAt,
+	)
+}
+
+func queryCreditLines(rows pgx.Rows, err error) (*[]structures.CreditLine, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.CreditLine
+	for rows.Next() {
+		var line structures.CreditLine
+		if err := scanCreditLine(rows, &line); err != nil {
+			return nil, err
+		}
+		res = append(res, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SaveCreditLine extends credit to a member under the lender or changes its
+// limit and repayment period. A period already running keeps its due date.
+func (q *Query) SaveCreditLine(ctx context.Context, lenderID string, lenderType string, req *structures.CreditLineRequest, audit *structures.AuditLogEntry) (*structures.CreditLine, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if err := checkDownline(ctx, tx, lenderType, lenderID, req.MemberType, req.MemberID); err != nil {
+		return nil, err
+	}
+
+	var before *structures.CreditLine
+	var previous structures.CreditLine
+	err = scanCreditLine(tx.QueryRow(ctx, `
+		SELECT `+creditLineColumns+`
+		FROM credit_lines c
+		`+creditLineMembers+`
+		WHERE c.member_id = $1
+		FOR UPDATE OF c;
+	`, req.MemberID), &previous)
+	if err == nil {
+		if previous.LenderID != lenderID {
+			return nil, ErrCreditLentElsewhere
+		}
+		before = &previous
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("select credit line: %w", err)
+	}
+
+	// A member already below zero starts repaying from now
+	member := walletOwners[req.MemberType]
+	var drawn bool
+	var outstanding string
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT %[1]s < 0, GREATEST(-%[1]s, 0)::TEXT FROM %[2]s WHERE %[3]s = $1;
+	`, member.balanceColumn, member.table, member.idColumn), req.MemberID).Scan(&drawn, &outstanding); err != nil {
+		return nil, fmt.Errorf("read member balance: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO credit_lines (member_id, member_type, lender_id, lender_type, credit_limit, repayment_days, drawn_at, due_at)
+		VALUES ($1, $2, $3, $4, $5::NUMERIC, $6,
+			CASE WHEN $7::BOOLEAN THEN NOW() END, CASE WHEN $7::BOOLEAN THEN NOW() + make_interval(days => $6) END)
+		ON CONFLICT (member_id) DO UPDATE SET
+			credit_limit = EXCLUDED.credit_limit,
+			repayment_days = EXCLUDED.repayment_days;
+	`, req.MemberID, req.MemberType, lenderID, lenderType, req.CreditLimit, req.RepaymentDays, drawn); err != nil {
+		return nil, fmt.Errorf("save credit line: %w", err)
+	}
+	// and the new line takes over what is already drawn
+	if drawn && before == nil {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO credit_line_events (credit_line_id, event_type, amount, outstanding_after)
+			SELECT credit_line_id, 'DRAWN', $2::NUMERIC, $2::NUMERIC FROM credit_lines WHERE member_id = $1;
+		`, req.MemberID, outstanding); err != nil {
+			return nil, fmt.Errorf("insert credit line event: %w", err)
+		}
+	}
+
+	var res structures.CreditLine
+	if err := scanCreditLine(tx.QueryRow(ctx, `
+		SELECT `+creditLineColumns+`
+		FROM credit_lines c
+		`+creditLineMembers+`
+		WHERE c.member_id = $1;
+	`, req.MemberID), &res); err != nil {
+		return nil, fmt.Errorf("select credit line: %w", err)
+	}
+
+	audit.TargetID = res.CreditLineID
+	audit.Before = before
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetCreditLines returns the credit lines the lender has extended
+func (q *Query) GetCreditLines(ctx context.Context, lenderID string) (*[]structures.CreditLine, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+creditLineColumns+`
+		FROM credit_lines c
+		`+creditLineMembers+`
+		WHERE c.lender_id = $1
+		ORDER BY c.due_at NULLS LAST, c.created_at DESC;
+	`, lenderID)
+	return queryCreditLines(rows, err)
+}
+
+// GetMemberCreditLine returns the credit line extended to a member
+func (q *Query) GetMemberCreditLine(ctx context.Context, memberID string) (*structures.CreditLine, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var res structures.CreditLine
+	if err := scanCreditLine(q.Pool.QueryRow(ctx, `
+		SELECT `+creditLineColumns+`
+		FROM credit_lines c
+		`+creditLineMembers+`
+		WHERE c.member_id = $1;
+	`, memberID), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetOutstandingCredit reports every credit line of the admin's network
+// with credit drawn, overdue lines first
+func (q *Query) GetOutstandingCredit(ctx context.Context, adminID string) (*[]structures.CreditLine, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+creditLineColumns+`
+		FROM credit_lines c
+		`+creditLineMembers+`
+		WHERE COALESCE(d.admin_id, u.admin_id) = $1 AND b.balance < 0
+		ORDER BY c.due_at;
+	`, adminID)
+	return queryCreditLines(rows, err)
+}
+
+// DeleteCreditLine withdraws a member's credit line once nothing is owed on it
+func (q *Query) DeleteCreditLine(ctx context.Context, lenderID string, memberID string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var before structures.CreditLine
+	if err := scanCreditLine(tx.QueryRow(ctx, `
+		SELECT `+creditLineColumns+`
+		FROM credit_lines c
+		`+creditLineMembers+`
+		WHERE c.member_id = $1 AND c.lender_id = $2
+		FOR UPDATE OF c;
+	`, memberID, lenderID), &before); err != nil {
+		return err
+	}
+	if before.DrawnAt != nil {
+		return ErrCreditOutstanding
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM credit_lines WHERE credit_line_id = $1;`, before.CreditLineID); err != nil {
+		return fmt.Errorf("delete credit line: %w", err)
+	}
+
+	audit.TargetID = before.CreditLineID
+	audit.Before = before
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetCreditLineEvents returns the latest draws and repayments of a member's
+// credit line under the lender
+func (q *Query) GetCreditLineEvents(ctx context.Context, lenderID string, memberID string, limit int) (*[]structures.CreditLineEvent, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT e.event_id, e.credit_line_id::TEXT, e.event_type, e.amount::TEXT, e.outstanding_after::TEXT, e.created_at
+		FROM credit_line_events e
+		JOIN credit_lines c ON c.credit_line_id = e.credit_line_id
+		WHERE c.member_id = $1 AND c.lender_id = $2
+		ORDER BY e.created_at DESC, e.event_id DESC
+		LIMIT $3;
+	`, memberID, lenderID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.CreditLineEvent
+	for rows.Next() {
+		var event structures.CreditLineEvent
+		if err := rows.Scan(
+			&event.EventID,
+			&event.CreditLineID,
+			&event.EventType,
+			&event.Amount,
+			&event.OutstandingAfter,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MarkOverdueCreditLines records credit lines that passed their due date
+// without being repaid and tells the member and the lender. Each period is
+// reported once.
+func (q *Query) MarkOverdueCreditLines(ctx context.Context) (int64, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	rows, err := tx.Query(ctx, `
+		WITH overdue AS (
+			UPDATE credit_lines SET overdue_notified_at = NOW()
+			WHERE due_at <= NOW() AND overdue_notified_at IS NULL
+			RETURNING credit_line_id, member_id, member_type, lender_id, lender_type, due_at
+		)
+		SELECT o.credit_line_id::TEXT, o.member_id::TEXT, o.member_type, o.lender_id::TEXT, o.lender_type,
+			TO_CHAR(o.due_at AT TIME ZONE 'Asia/Kolkata', 'DD Mon YYYY'),
+			COALESCE(d.distributor_name, u.user_name, ''),
+			GREATEST(-COALESCE(d.distributor_wallet_balance, u.user_wallet_balance, 0), 0)::TEXT
+		FROM overdue o
+		LEFT JOIN distributors d ON o.member_type = 'DISTRIBUTOR' AND d.distributor_id = o.member_id
+		LEFT JOIN users u ON o.member_type = 'USER' AND u.user_id = o.member_id;
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("mark overdue credit lines: %w", err)
+	}
+	type overdueLine struct {
+		creditLineID, memberID, memberType, lenderID, lenderType, dueDate, memberName, outstanding string
+	}
+	var lines []overdueLine
+	for rows.Next() {
+		var l overdueLine
+		if err := rows.Scan(&l.creditLineID, &l.memberID, &l.memberType, &l.lenderID, &l.lenderType, &l.dueDate, &l.memberName, &l.outstanding); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, l := range lines {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO credit_line_events (credit_line_id, event_type, amount, outstanding_after)
+			VALUES ($1, 'OVERDUE', $2::NUMERIC, $2::NUMERIC);
+		`, l.creditLineID, l.outstanding); err != nil {
+			return 0, fmt.Errorf("insert credit line event: %w", err)
+		}
+		data := map[string]string{"member_name": l.memberName, "outstanding": l.outstanding, "due_date": l.dueDate}
+		for _, recipient := range []struct{ id, role string }{{l.memberID, l.memberType}, {l.lenderID, l.lenderType}} {
+			if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+				EventType:     pkg.EventCreditOverdue,
+				RecipientID:   recipient.id,
+				RecipientType: recipient.role,
+				Data:          data,
+			}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int64(len(lines)), nil
+}
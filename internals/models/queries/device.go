@@ -0,0 +1,201 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetUserDeviceState looks up the device a retailer is signing in from,
+// along with how many devices they have
+func (q *Query) GetUserDeviceState(ctx context.Context, userID string, fingerprintHash string) (*structures.UserDeviceState, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var res structures.UserDeviceState
+	err := q.Pool.QueryRow(ctx, `
+		SELECT
+			COALESCE(MAX(device_id::TEXT) FILTER (WHERE fingerprint_hash = $2), ''),
+			COALESCE(BOOL_OR(revoked_at IS NULL) FILTER (WHERE fingerprint_hash = $2), FALSE),
+			COUNT(*) FILTER (WHERE revoked_at IS NULL),
+			COUNT(*)
+		FROM user_devices
+		WHERE user_id = $1;
+	`, userID, fingerprintHash).Scan(&res.DeviceID, &res.Active, &res.ActiveDevices, &res.TotalDevices)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RegisterUserDevice trusts the device for the retailer, reinstating it if
+// it was revoked, and returns its id
+func (q *Query) RegisterUserDevice(ctx context.Context, device *structures.UserDeviceRegistration) (string, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return "", fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var deviceID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_devices (user_id, fingerprint_hash, device_name, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, fingerprint_hash) DO UPDATE SET
+			device_name = EXCLUDED.device_name,
+			ip_address = EXCLUDED.ip_address,
+			user_agent = EXCLUDED.user_agent,
+			last_seen_at = NOW(),
+			revoked_at = NULL,
+			revoked_by = NULL
+		RETURNING device_id::TEXT;
+	`, device.UserID, device.FingerprintHash, device.DeviceName, device.IPAddress, device.UserAgent).Scan(&deviceID)
+	if err != nil {
+		return "", fmt.Errorf("register device: %w", err)
+	}
+
+	if device.Alert {
+		name := device.DeviceName
+		if name == "" {
+			name = "unnamed device"
+		}
+		if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+			EventType:     pkg.EventNewDeviceLogin,
+			RecipientID:   device.UserID,
+			RecipientType: "USER",
+			Data: map[string]string{
+				"device_name": name,
+				"time":        time.Now().Format("02 Jan 2006 15:04"),
+			},
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("commit: %w", err)
+	}
+	return deviceID, nil
+}
+
+// TouchUserDevice records a fresh login from a trusted device
+func (q *Query) TouchUserDevice(ctx context.Context, deviceID string, ipAddress string, userAgent string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	_, err := q.Pool.Exec(ctx, `
+		UPDATE user_devices SET last_seen_at = NOW(), ip_address = $2, user_agent = $3
+		WHERE device_id = $1;
+	`, deviceID, ipAddress, userAgent)
+	return err
+}
+
+// GetUserDeviceSession returns the fingerprint hash of the device a session
+// is bound to and whether it was revoked. Activity is recorded at most every
+// few minutes so busy sessions do not write on every request.
+func (q *Query) GetUserDeviceSession(ctx context.Context, userID string, deviceID string) (string, bool, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	var (
+		fingerprintHash string
+		revoked         bool
+	)
+	err := q.Pool.QueryRow(ctx, `
+		WITH touched AS (
+			UPDATE user_devices SET last_seen_at = NOW()
+			WHERE device_id = $1 AND user_id = $2 AND revoked_at IS NULL
+			AND last_seen_at < NOW() - INTERVAL '5 minutes'
+		)
+		SELECT fingerprint_hash, revoked_at IS NOT NULL
+		FROM user_devices
+		WHERE device_id = $1 AND user_id = $2;
+	`, deviceID, userID).Scan(&fingerprintHash, &revoked)
+	return fingerprintHash, revoked, err
+}
+
+// GetUserDevices lists a retailer's devices, most recently used first. An
+// empty list is returned for retailers outside the scope.
+func (q *Query) GetUserDevices(ctx context.Context, scope *structures.UserDeviceScope) (*[]structures.UserDevice, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT
+			d.device_id::TEXT, d.device_name, d.ip_address, d.user_agent,
+			d.first_seen_at, d.last_seen_at, d.revoked_at, d.revoked_by
+		FROM user_devices d
+		JOIN users u ON u.user_id = d.user_id
+		WHERE d.user_id = $1
+		AND ($2 = '' OR u.distributor_id::TEXT = $2)
+		AND ($3 = '' OR u.admin_id::TEXT = $3)
+		ORDER BY d.revoked_at IS NOT NULL, d.last_seen_at DESC;
+	`, scope.UserID, scope.DistributorID, scope.AdminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.UserDevice
+	for rows.Next() {
+		var device structures.UserDevice
+		if err := rows.Scan(
+			&device.DeviceID,
+			&device.DeviceName,
+			&device.IPAddress,
+			&device.UserAgent,
+			&device.FirstSeenAt,
+			&device.LastSeenAt,
+			&device.RevokedAt,
+			&device.RevokedBy,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RevokeUserDevice ends every session bound to the device. Devices that are
+// already revoked, or belong to a retailer outside the scope, give
+// pgx.ErrNoRows.
+func (q *Query) RevokeUserDevice(ctx context.Context, scope *structures.UserDeviceScope, deviceID string, revokedBy string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE user_devices d SET revoked_at = NOW(), revoked_by = $3
+		FROM users u
+		WHERE d.device_id = $1 AND d.user_id = $2 AND u.user_id = d.user_id
+		AND d.revoked_at IS NULL
+		AND ($4 = '' OR u.distributor_id::TEXT = $4)
+		AND ($5 = '' OR u.admin_id::TEXT = $5);
+	`, deviceID, scope.UserID, revokedBy, scope.DistributorID, scope.AdminID)
+	if err != nil {
+		return fmt.Errorf("revoke device: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
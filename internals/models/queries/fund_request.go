@@ -3,13 +3,13 @@ package queries
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
 	"github.com/jackc/pgx/v5"
 )
 
-func (q *Query) GetFundRequestsById(requesterId string) (*[]structures.GetFundRequestModel, error) {
+func (q *Query) GetFundRequestsById(ctx context.Context, requesterId string) (*[]structures.GetFundRequestModel, error) {
 	var fundRequests []structures.GetFundRequestModel
 
 	const query = `
@@ -33,7 +33,7 @@ func (q *Query) GetFundRequestsById(requesterId string) (*[]structures.GetFundRe
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.readContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, requesterId)
@@ -69,7 +69,7 @@ func (q *Query) GetFundRequestsById(requesterId string) (*[]structures.GetFundRe
 	return &fundRequests, nil
 }
 
-func (q *Query) GetAllFundRequests(adminId string) (*[]structures.GetFundRequestModel, error) {
+func (q *Query) GetAllFundRequests(ctx context.Context, adminId string) (*[]structures.GetFundRequestModel, error) {
 	var fundRequests []structures.GetFundRequestModel
 
 	const query = `
@@ -93,7 +93,7 @@ func (q *Query) GetAllFundRequests(adminId string) (*[]structures.GetFundRequest
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.reportContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, adminId)
@@ -129,7 +129,10 @@ func (q *Query) GetAllFundRequests(adminId string) (*[]structures.GetFundRequest
 	return &fundRequests, nil
 }
 
-func (q *Query) RejectFundRequest(requestId string) error {
+func (q *Query) RejectFundRequest(ctx context.Context, requestId string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	const query = `
 		UPDATE fund_requests
 		SET 
@@ -137,18 +140,53 @@ func (q *Query) RejectFundRequest(requestId string) error {
 			updated_at = NOW()
 		WHERE 
 			request_id = $1
-			AND request_status = 'PENDING';
+			AND request_status = 'PENDING'
+		RETURNING requester_id::TEXT, requester_type, amount::TEXT;
 	`
 
-	_, err := q.Pool.Exec(
-		context.Background(),
+
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollbackTx(ctx, tx)
+
+	var requesterID, requesterType, amount string
+	err = tx.QueryRow(
+		ctx,
 		query,
 		requestId,
-	)
-	return err
+	).Scan(&requesterID, &requesterType, &amount)
+	if err == pgx.ErrNoRows {
+		// Nothing to reject, the request is already decided
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	audit.Before = map[string]string{"request_status": "PENDING"}
+	audit.After = map[string]string{"request_status": "REJECTED", "requester_id": requesterID, "amount": amount}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+		EventType:     pkg.EventFundRequestDecided,
+		RecipientID:   requesterID,
+		RecipientType: requesterType,
+		Data:          map[string]string{"request_id": requestId, "amount": amount, "status": "rejected"},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (q *Query) CreateFundRequest(req *structures.CreateFundRequestModel) error {
+func (q *Query) CreateFundRequest(ctx context.Context, req *structures.CreateFundRequestModel) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	const query = `
 		INSERT INTO fund_requests (
 			admin_id,
@@ -179,7 +217,7 @@ func (q *Query) CreateFundRequest(req *structures.CreateFundRequestModel) error
 	`
 
 	_, err := q.Pool.Exec(
-		context.Background(),
+		ctx,
 		query,
 		req.AdminID,           // $1
 		req.RequesterID,       // $2
@@ -197,16 +235,15 @@ func (q *Query) CreateFundRequest(req *structures.CreateFundRequestModel) error
 }
 
 
-func (q *Query) AcceptFundRequest(req *structures.AcceptFundRequestModel) error {
-	ctx := context.Background()
+func (q *Query) AcceptFundRequest(ctx context.Context, req *structures.AcceptFundRequestModel, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
 
 	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
-	defer func() {
-		_ = tx.Rollback(ctx) // safe no-op if already committed
-	}()
+	defer rollbackTx(ctx, tx)
 
 	// 1) Lock and read fund_request (ensure it's PENDING)
 	var (
@@ -395,7 +432,36 @@ func (q *Query) AcceptFundRequest(req *structures.AcceptFundRequestModel) error
 		return fmt.Errorf("insert transactions: %w", err)
 	}
 
-	// 9) Commit
+	// 9) Record the decision in the audit log
+	audit.Before = map[string]string{
+		"request_status":       "PENDING",
+		"admin_wallet_balance": adminBalanceStr,
+		"requester_balance":    requesterBalanceStr,
+	}
+	audit.After = map[string]string{
+		"request_status": "APPROVED",
+		"requester_id":   requesterID,
+		"requester_type": requesterType,
+		"amount":         amountStr,
+	}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	// 10) Tell the requester about the decision and the credit
+	if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+		EventType:     pkg.EventFundRequestDecided,
+		RecipientID:   requesterID,
+		RecipientType: requesterType,
+		Data:          map[string]string{"request_id": req.RequestID, "amount": amountStr, "status": "approved"},
+	}); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, requesterType, requesterID, amountStr, "fund request approved"); err != nil {
+		return err
+	}
+
+	// 11) Commit
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("commit tx: %w", err)
 	}
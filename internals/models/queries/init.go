@@ -10,10 +10,11 @@ import (
 )
 
 type Query struct {
-	Pool *pgxpool.Pool
+	Pool     *pgxpool.Pool
+	Timeouts Timeouts
 }
 
-func NewQuery(pool *pgxpool.Pool) *Query { return &Query{Pool: pool} }
+func NewQuery(pool *pgxpool.Pool) *Query { return &Query{Pool: pool, Timeouts: TimeoutsFromEnv()} }
 
 func (qr *Query) InitializeDatabase() {
 	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
@@ -25,6 +26,16 @@ func (qr *Query) InitializeDatabase() {
 		// ============================================================
 		`CREATE EXTENSION IF NOT EXISTS pgcrypto;`,
 
+		// ============================================================
+		// Schema Migrations
+		// ============================================================
+		// One-time changes are listed in migration.go and recorded here
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+
 		// ============================================================
 		// Sequences
 		// ============================================================
@@ -227,22 +238,20 @@ func (qr *Query) InitializeDatabase() {
 		// ============================================================
 		// OTPs
 		// ============================================================
-		`CREATE TABLE IF NOT EXISTS otps (
-			otp CHAR(4) NOT NULL DEFAULT LPAD((FLOOR(random()*10000))::INT::TEXT, 4, '0'),
-			phone TEXT,
-			email TEXT,
+		`CREATE TABLE IF NOT EXISTS otp_codes (
+			otp_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			phone TEXT NOT NULL,
+			purpose TEXT NOT NULL CHECK (purpose IN ('LOGIN', 'MPIN_RESET', 'PAYOUT_CONFIRMATION', 'BENEFICIARY_ADD', 'PASSWORD_RESET')),
+			code_hash TEXT NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			max_attempts INT NOT NULL,
+			ip_address TEXT NOT NULL DEFAULT '',
+			expires_at TIMESTAMPTZ NOT NULL,
+			consumed_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		);`,
-		`CREATE OR REPLACE FUNCTION delete_expired_otps() RETURNS trigger AS $$
-		BEGIN
-			DELETE FROM otps WHERE created_at < NOW() - INTERVAL '5 minutes';
-			RETURN NEW;
-		END;
-		$$ LANGUAGE plpgsql;`,
-		`DROP TRIGGER IF EXISTS trg_delete_expired_otps ON otps;`,
-		`CREATE TRIGGER trg_delete_expired_otps
-			AFTER INSERT ON otps
-			FOR EACH ROW EXECUTE FUNCTION delete_expired_otps();`,
+		`CREATE INDEX IF NOT EXISTS idx_otp_codes_phone_purpose ON otp_codes (phone, purpose, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_otp_codes_ip ON otp_codes (ip_address, created_at DESC);`,
 
 		// ============================================================
 		// Unified Transactions
@@ -319,13 +328,747 @@ func (qr *Query) InitializeDatabase() {
 				created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 			);
 		`,
+
+		// ============================================================
+		// Audit Logs (append-only, hash chained)
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS audit_logs (
+			audit_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			actor_id TEXT NOT NULL,
+			actor_role TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL DEFAULT '',
+			ip_address TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			request_id TEXT NOT NULL DEFAULT '',
+			before_data JSONB,
+			after_data JSONB,
+			prev_hash TEXT NOT NULL,
+			entry_hash TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_actor
+			ON audit_logs (actor_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_target
+			ON audit_logs (target_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_action
+			ON audit_logs (action, created_at DESC);`,
+		`CREATE OR REPLACE FUNCTION prevent_audit_log_mutation() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_logs is append-only';
+		END; $$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_audit_logs_append_only ON audit_logs;`,
+		`CREATE TRIGGER trg_audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+			FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_mutation();`,
+		`DROP TRIGGER IF EXISTS trg_audit_logs_no_truncate ON audit_logs;`,
+		`CREATE TRIGGER trg_audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+			FOR EACH STATEMENT EXECUTE FUNCTION prevent_audit_log_mutation();`,
+
+		// ============================================================
+		// MPIN Attempt Limiting
+		// ============================================================
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS user_mpin_failed_attempts INT NOT NULL DEFAULT 0;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS user_mpin_locked_until TIMESTAMPTZ;`,
+
+		// ============================================================
+		// Notifications (outbox written with the money movement)
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS notification_events (
+			event_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			event_type TEXT NOT NULL CHECK (event_type IN ('WALLET_CREDITED','WALLET_DEBITED','FUND_REQUEST_DECIDED','PAYOUT_STATUS_CHANGED','LOW_BALANCE','NEW_DEVICE_LOGIN')),
+			recipient_id UUID NOT NULL,
+			recipient_type TEXT NOT NULL CHECK (recipient_type IN ('ADMIN','MASTER_DISTRIBUTOR','DISTRIBUTOR','USER')),
+			data JSONB NOT NULL DEFAULT '{}',
+			dispatched_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_notification_events_pending
+			ON notification_events (created_at) WHERE dispatched_at IS NULL;`,
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			owner_id UUID NOT NULL,
+			event_type TEXT NOT NULL,
+			channel TEXT NOT NULL CHECK (channel IN ('SMS','EMAIL','WHATSAPP')),
+			enabled BOOLEAN NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (owner_id, event_type, channel)
+		);`,
+		`CREATE TABLE IF NOT EXISTS notification_settings (
+			owner_id UUID PRIMARY KEY,
+			language TEXT NOT NULL DEFAULT 'en',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+
+		// ============================================================
+		// Passwords (reset OTPs and reuse prevention)
+		// ============================================================
+		`ALTER TABLE otp_codes DROP CONSTRAINT IF EXISTS otp_codes_purpose_check;`,
+		`ALTER TABLE otp_codes ADD CONSTRAINT otp_codes_purpose_check
+			CHECK (purpose IN ('LOGIN', 'MPIN_RESET', 'PAYOUT_CONFIRMATION', 'BENEFICIARY_ADD', 'PASSWORD_RESET'));`,
+		`CREATE TABLE IF NOT EXISTS password_history (
+			history_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			owner_id UUID NOT NULL,
+			owner_type TEXT NOT NULL CHECK (owner_type IN ('ADMIN','MASTER_DISTRIBUTOR','DISTRIBUTOR','USER')),
+			password_hash TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_password_history_owner ON password_history (owner_id, created_at DESC);`,
+
+		// ============================================================
+		// Two-Factor Authentication (TOTP)
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS two_factor_credentials (
+			owner_id UUID PRIMARY KEY,
+			owner_type TEXT NOT NULL CHECK (owner_type IN ('ADMIN','MASTER_DISTRIBUTOR')),
+			secret_encrypted TEXT NOT NULL,
+			enabled_at TIMESTAMPTZ,
+			last_step BIGINT NOT NULL DEFAULT 0,
+			failed_attempts INT NOT NULL DEFAULT 0,
+			locked_until TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`ALTER TABLE two_factor_credentials DROP CONSTRAINT IF EXISTS two_factor_credentials_owner_type_check;`,
+		`ALTER TABLE two_factor_credentials ADD CONSTRAINT two_factor_credentials_owner_type_check
+			CHECK (owner_type IN ('ADMIN','MASTER_DISTRIBUTOR','STAFF'));`,
+		`CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+			code_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			owner_id UUID NOT NULL REFERENCES two_factor_credentials(owner_id) ON DELETE CASCADE,
+			code_hash TEXT NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_two_factor_recovery_codes_owner ON two_factor_recovery_codes (owner_id);`,
+		`CREATE TABLE IF NOT EXISTS two_factor_policies (
+			admin_id UUID NOT NULL REFERENCES admins(admin_id) ON DELETE CASCADE,
+			role TEXT NOT NULL CHECK (role IN ('ADMIN','MASTER_DISTRIBUTOR')),
+			enforced BOOLEAN NOT NULL DEFAULT FALSE,
+			grace_until TIMESTAMPTZ,
+			updated_by TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (admin_id, role)
+		);`,
+
+		// ============================================================
+		// Retailer Devices
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS user_devices (
+			device_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			fingerprint_hash TEXT NOT NULL,
+			device_name TEXT NOT NULL DEFAULT '',
+			ip_address TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			revoked_at TIMESTAMPTZ,
+			revoked_by TEXT,
+			UNIQUE (user_id, fingerprint_hash)
+		);`,
+		// New event types are added to this one list, an older, shorter list
+		// would be checked against the events already stored and fail
+		`ALTER TABLE notification_events DROP CONSTRAINT IF EXISTS notification_events_event_type_check;`,
+		`ALTER TABLE notification_events ADD CONSTRAINT notification_events_event_type_check
+			CHECK (event_type IN ('WALLET_CREDITED','WALLET_DEBITED','FUND_REQUEST_DECIDED','PAYOUT_STATUS_CHANGED','LOW_BALANCE','NEW_DEVICE_LOGIN','SCHEDULED_TRANSFER_FAILED','CREDIT_OVERDUE','WALLET_DRIFT'));`,
+
+		// ============================================================
+		// Rate Limiting
+		// ============================================================
+		`CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+			bucket_key TEXT PRIMARY KEY,
+			tokens DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			role TEXT NOT NULL CHECK (role IN ('ADMIN','MASTER_DISTRIBUTOR','DISTRIBUTOR','USER')),
+			login TEXT NOT NULL,
+			failed_attempts INT NOT NULL DEFAULT 0,
+			locked_until TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (role, login)
+		);`,
+
+		// ============================================================
+		// Admin Roles & Invites
+		// ============================================================
+		// Admins created before roles existed keep full access
+		`ALTER TABLE admins ADD COLUMN IF NOT EXISTS admin_role TEXT NOT NULL DEFAULT 'SUPER_ADMIN'
+			CHECK (admin_role IN ('SUPER_ADMIN','FINANCE','SUPPORT','READ_ONLY'));`,
+		`ALTER TABLE admins ALTER COLUMN admin_role SET DEFAULT 'READ_ONLY';`,
+		`CREATE TABLE IF NOT EXISTS admin_invites (
+			invite_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			token_hash TEXT UNIQUE NOT NULL,
+			admin_email TEXT NOT NULL,
+			admin_role TEXT NOT NULL CHECK (admin_role IN ('SUPER_ADMIN','FINANCE','SUPPORT','READ_ONLY')),
+			invited_by UUID NOT NULL REFERENCES admins(admin_id),
+			expires_at TIMESTAMPTZ NOT NULL,
+			accepted_at TIMESTAMPTZ,
+			accepted_admin_id UUID REFERENCES admins(admin_id),
+			revoked_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_invites_email ON admin_invites (LOWER(admin_email));`,
+
+		// ============================================================
+		// Roles, Permissions & Staff
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS rbac_roles (
+			role_name TEXT PRIMARY KEY CHECK (role_name ~ '^[A-Z][A-Z0-9_]{1,49}$'),
+			description TEXT NOT NULL DEFAULT '',
+			is_system BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`ALTER TABLE rbac_roles ADD COLUMN IF NOT EXISTS owner_admin_id UUID REFERENCES admins(admin_id) ON DELETE CASCADE;`,
+		`CREATE INDEX IF NOT EXISTS idx_rbac_roles_owner ON rbac_roles (owner_admin_id);`,
+		`DROP TRIGGER IF EXISTS trg_rbac_roles_updated_at ON rbac_roles;`,
+		`CREATE TRIGGER trg_rbac_roles_updated_at BEFORE UPDATE ON rbac_roles
+			FOR EACH ROW EXECUTE FUNCTION set_updated_at();`,
+		`CREATE TABLE IF NOT EXISTS rbac_role_permissions (
+			role_name TEXT NOT NULL REFERENCES rbac_roles(role_name) ON DELETE CASCADE,
+			permission TEXT NOT NULL,
+			PRIMARY KEY (role_name, permission)
+		);`,
+		`CREATE TABLE IF NOT EXISTS staff_users (
+			staff_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			admin_id UUID NOT NULL REFERENCES admins(admin_id) ON DELETE CASCADE,
+			staff_name TEXT NOT NULL,
+			staff_email TEXT UNIQUE NOT NULL,
+			staff_phone TEXT NOT NULL,
+			staff_password TEXT NOT NULL,
+			role_name TEXT NOT NULL REFERENCES rbac_roles(role_name),
+			staff_blocked BOOLEAN NOT NULL DEFAULT FALSE,
+			created_by TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_staff_users_admin ON staff_users (admin_id);`,
+		`DROP TRIGGER IF EXISTS trg_staff_users_updated_at ON staff_users;`,
+		`CREATE TRIGGER trg_staff_users_updated_at BEFORE UPDATE ON staff_users
+			FOR EACH ROW EXECUTE FUNCTION set_updated_at();`,
+		`ALTER TABLE login_attempts DROP CONSTRAINT IF EXISTS login_attempts_role_check;`,
+		`ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_role_check
+			CHECK (role IN ('ADMIN','MASTER_DISTRIBUTOR','DISTRIBUTOR','USER','STAFF'));`,
+
+		// ============================================================
+		// Maker-Checker Approvals
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS approval_policies (
+			admin_id UUID NOT NULL REFERENCES admins(admin_id) ON DELETE CASCADE,
+			action_type TEXT NOT NULL CHECK (action_type IN ('WALLET_TOPUP','USER_REFUND','MASTER_DISTRIBUTOR_REFUND','DISTRIBUTOR_REFUND','PAYOUT_STATUS_UPDATE')),
+			threshold_amount NUMERIC(20,2) NOT NULL CHECK (threshold_amount >= 0),
+			ttl_minutes INT NOT NULL DEFAULT 1440 CHECK (ttl_minutes > 0),
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			updated_by TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (admin_id, action_type)
+		);`,
+		`ALTER TABLE approval_policies ADD COLUMN IF NOT EXISTS window_minutes INT NOT NULL DEFAULT 0
+			CHECK (window_minutes BETWEEN 0 AND 10080);`,
+		// Operations run without approval under a policy with a window, summed
+		// against the threshold
+		`CREATE TABLE IF NOT EXISTS approval_tallies (
+			tally_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			admin_id UUID NOT NULL REFERENCES admins(admin_id) ON DELETE CASCADE,
+			action_type TEXT NOT NULL,
+			maker_id TEXT NOT NULL,
+			target TEXT NOT NULL,
+			amount NUMERIC(20,2) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_approval_tallies_maker
+			ON approval_tallies (admin_id, action_type, maker_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_approval_tallies_target
+			ON approval_tallies (admin_id, action_type, target, created_at);`,
+		`CREATE TABLE IF NOT EXISTS pending_actions (
+			action_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			admin_id UUID NOT NULL REFERENCES admins(admin_id) ON DELETE CASCADE,
+			action_type TEXT NOT NULL CHECK (action_type IN ('WALLET_TOPUP','USER_REFUND','MASTER_DISTRIBUTOR_REFUND','DISTRIBUTOR_REFUND','PAYOUT_STATUS_UPDATE')),
+			amount NUMERIC(20,2) NOT NULL,
+			payload JSONB NOT NULL,
+			maker_id TEXT NOT NULL,
+			maker_role TEXT NOT NULL,
+			action_status TEXT NOT NULL DEFAULT 'PENDING' CHECK (action_status IN ('PENDING','APPROVED','EXECUTED','FAILED','REJECTED','EXPIRED')),
+			checker_id TEXT,
+			checker_role TEXT,
+			rejection_reason TEXT,
+			failure_reason TEXT,
+			expires_at TIMESTAMPTZ NOT NULL,
+			decided_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_pending_actions_admin
+			ON pending_actions (admin_id, action_status, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_pending_actions_expiry
+			ON pending_actions (expires_at) WHERE action_status = 'PENDING';`,
+		// An approved action writes one audit entry with its own changes, so
+		// two checkers resuming it at once cannot both run it
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_pending_action
+			ON audit_logs (request_id) WHERE request_id LIKE 'pending_action:%';`,
+
+		// ============================================================
+		// Scheduled Transfers
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS scheduled_transfers (
+			schedule_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			owner_id UUID NOT NULL,
+			owner_type TEXT NOT NULL CHECK (owner_type IN ('MASTER_DISTRIBUTOR','DISTRIBUTOR')),
+			recipient_id UUID NOT NULL,
+			recipient_type TEXT NOT NULL CHECK (recipient_type IN ('DISTRIBUTOR','USER')),
+			schedule_type TEXT NOT NULL CHECK (schedule_type IN ('DAILY','WEEKLY','TOP_UP')),
+			amount NUMERIC(20,2) CHECK (amount > 0),
+			run_time TEXT,
+			weekday INT CHECK (weekday BETWEEN 0 AND 6),
+			threshold_amount NUMERIC(20,2) CHECK (threshold_amount >= 0),
+			target_amount NUMERIC(20,2),
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			next_run_at TIMESTAMPTZ NOT NULL,
+			last_run_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			CHECK (schedule_type <> 'DAILY' OR (amount IS NOT NULL AND run_time IS NOT NULL)),
+			CHECK (schedule_type <> 'WEEKLY' OR (amount IS NOT NULL AND run_time IS NOT NULL AND weekday IS NOT NULL)),
+			CHECK (schedule_type <> 'TOP_UP' OR (threshold_amount IS NOT NULL AND target_amount > threshold_amount))
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_owner ON scheduled_transfers (owner_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due
+			ON scheduled_transfers (next_run_at) WHERE active;`,
+		`DROP TRIGGER IF EXISTS trg_scheduled_transfers_updated_at ON scheduled_transfers;`,
+		`CREATE TRIGGER trg_scheduled_transfers_updated_at BEFORE UPDATE ON scheduled_transfers
+			FOR EACH ROW EXECUTE FUNCTION set_updated_at();`,
+		`CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+			run_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			schedule_id UUID NOT NULL REFERENCES scheduled_transfers(schedule_id) ON DELETE CASCADE,
+			scheduled_for TIMESTAMPTZ NOT NULL,
+			amount NUMERIC(20,2) NOT NULL,
+			run_status TEXT NOT NULL CHECK (run_status IN ('SUCCESS','SKIPPED','FAILED')),
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_scheduled_transfer_runs_schedule
+			ON scheduled_transfer_runs (schedule_id, created_at DESC);`,
+
+		// ============================================================
+		// Auto Replenishment
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS replenishment_rules (
+			rule_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID UNIQUE NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			funder_id UUID NOT NULL,
+			funder_type TEXT NOT NULL CHECK (funder_type IN ('MASTER_DISTRIBUTOR','DISTRIBUTOR')),
+			threshold_amount NUMERIC(20,2) NOT NULL CHECK (threshold_amount >= 0),
+			target_amount NUMERIC(20,2) NOT NULL,
+			daily_cap NUMERIC(20,2) NOT NULL CHECK (daily_cap > 0),
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			CHECK (target_amount > threshold_amount)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_replenishment_rules_funder ON replenishment_rules (funder_id);`,
+		`DROP TRIGGER IF EXISTS trg_replenishment_rules_updated_at ON replenishment_rules;`,
+		`CREATE TRIGGER trg_replenishment_rules_updated_at BEFORE UPDATE ON replenishment_rules
+			FOR EACH ROW EXECUTE FUNCTION set_updated_at();`,
+		`CREATE TABLE IF NOT EXISTS replenishment_runs (
+			run_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			rule_id UUID NOT NULL REFERENCES replenishment_rules(rule_id) ON DELETE CASCADE,
+			payout_transaction_id UUID,
+			amount NUMERIC(20,2) NOT NULL,
+			run_status TEXT NOT NULL CHECK (run_status IN ('SUCCESS','SKIPPED')),
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_replenishment_runs_rule
+			ON replenishment_runs (rule_id, created_at DESC);`,
+
+		// ============================================================
+		// Credit Lines
+		// ============================================================
+		// Credit is an overdraft of the member's own wallet. A draw is the
+		// movement that takes the wallet below zero and a repayment is whatever
+		// brings it back. credit_line_events records each of them; the ledger
+		// snapshots total them and the integrity check holds every balance
+		// below zero to the outstanding credit on record.
+		`CREATE TABLE IF NOT EXISTS credit_lines (
+			credit_line_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			member_id UUID UNIQUE NOT NULL,
+			member_type TEXT NOT NULL CHECK (member_type IN ('DISTRIBUTOR','USER')),
+			lender_id UUID NOT NULL,
+			lender_type TEXT NOT NULL CHECK (lender_type IN ('MASTER_DISTRIBUTOR','DISTRIBUTOR')),
+			credit_limit NUMERIC(20,2) NOT NULL CHECK (credit_limit > 0),
+			repayment_days INT NOT NULL CHECK (repayment_days BETWEEN 1 AND 90),
+			drawn_at TIMESTAMPTZ,
+			due_at TIMESTAMPTZ,
+			overdue_notified_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_credit_lines_lender ON credit_lines (lender_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_credit_lines_due
+			ON credit_lines (due_at) WHERE overdue_notified_at IS NULL;`,
+		`DROP TRIGGER IF EXISTS trg_credit_lines_updated_at ON credit_lines;`,
+		`CREATE TRIGGER trg_credit_lines_updated_at BEFORE UPDATE ON credit_lines
+			FOR EACH ROW EXECUTE FUNCTION set_updated_at();`,
+		`CREATE TABLE IF NOT EXISTS credit_line_events (
+			event_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			credit_line_id UUID NOT NULL REFERENCES credit_lines(credit_line_id) ON DELETE CASCADE,
+			event_type TEXT NOT NULL CHECK (event_type IN ('DRAWN','RECOVERED','OVERDUE')),
+			amount NUMERIC(20,2) NOT NULL,
+			outstanding_after NUMERIC(20,2) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_credit_line_events_line
+			ON credit_line_events (credit_line_id, created_at DESC);`,
+		// How far below zero a member may take their wallet. Overdue credit
+		// lines lend nothing more until they are repaid.
+		`CREATE OR REPLACE FUNCTION available_credit(member UUID) RETURNS NUMERIC AS $$
+			SELECT COALESCE((
+				SELECT credit_limit FROM credit_lines
+				WHERE member_id = member AND (due_at IS NULL OR due_at > NOW())
+			), 0);
+		$$ LANGUAGE sql STABLE;`,
+		// Every balance movement of a member with a credit line draws on it or
+		// repays it. The repayment period starts when the balance goes below
+		// zero and ends when it is back at zero or above.
+		`CREATE OR REPLACE FUNCTION sync_credit_line() RETURNS trigger AS $$
+		DECLARE
+			member UUID;
+			old_balance NUMERIC;
+			new_balance NUMERIC;
+		BEGIN
+			IF TG_TABLE_NAME = 'users' THEN
+				member := NEW.user_id;
+				old_balance := OLD.user_wallet_balance;
+				new_balance := NEW.user_wallet_balance;
+			ELSE
+				member := NEW.distributor_id;
+				old_balance := OLD.distributor_wallet_balance;
+				new_balance := NEW.distributor_wallet_balance;
+			END IF;
+			IF LEAST(old_balance, 0) = LEAST(new_balance, 0) THEN
+				RETURN NEW;
+			END IF;
+			IF old_balance >= 0 THEN
+				UPDATE credit_lines
+				SET drawn_at = NOW(), due_at = NOW() + make_interval(days => repayment_days)
+				WHERE member_id = member;
+			ELSIF new_balance >= 0 THEN
+				UPDATE credit_lines
+				SET drawn_at = NULL, due_at = NULL, overdue_notified_at = NULL
+				WHERE member_id = member;
+			END IF;
+			INSERT INTO credit_line_events (credit_line_id, event_type, amount, outstanding_after)
+			SELECT credit_line_id,
+				CASE WHEN new_balance < old_balance THEN 'DRAWN' ELSE 'RECOVERED' END,
+				ABS(LEAST(new_balance, 0) - LEAST(old_balance, 0)),
+				-LEAST(new_balance, 0)
+			FROM credit_lines WHERE member_id = member;
+			RETURN NEW;
+		END; $$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_users_credit_line ON users;`,
+		`CREATE TRIGGER trg_users_credit_line AFTER UPDATE OF user_wallet_balance ON users
+			FOR EACH ROW EXECUTE FUNCTION sync_credit_line();`,
+		`DROP TRIGGER IF EXISTS trg_distributors_credit_line ON distributors;`,
+		`CREATE TRIGGER trg_distributors_credit_line AFTER UPDATE OF distributor_wallet_balance ON distributors
+			FOR EACH ROW EXECUTE FUNCTION sync_credit_line();`,
+
+		// ============================================================
+		// Bulk Payouts
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS payout_batches (
+			batch_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			mobile_number TEXT NOT NULL,
+			total_rows INT NOT NULL,
+			valid_rows INT NOT NULL,
+			reserved_amount NUMERIC(20,2) NOT NULL DEFAULT 0,
+			reserve_remaining NUMERIC(20,2) NOT NULL DEFAULT 0 CHECK (reserve_remaining >= 0),
+			batch_status TEXT NOT NULL DEFAULT 'PROCESSING' CHECK (batch_status IN ('PROCESSING','COMPLETED')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			completed_at TIMESTAMPTZ
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_payout_batches_user ON payout_batches (user_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_payout_batches_processing
+			ON payout_batches (created_at) WHERE batch_status = 'PROCESSING';`,
+		`CREATE TABLE IF NOT EXISTS payout_batch_rows (
+			batch_id UUID NOT NULL REFERENCES payout_batches(batch_id) ON DELETE CASCADE,
+			row_number INT NOT NULL,
+			account_number TEXT NOT NULL,
+			ifsc_code TEXT NOT NULL,
+			beneficiary_name TEXT NOT NULL,
+			bank_name TEXT NOT NULL DEFAULT '',
+			amount TEXT NOT NULL,
+			transfer_type TEXT NOT NULL,
+			reference TEXT NOT NULL DEFAULT '',
+			row_status TEXT NOT NULL CHECK (row_status IN ('REJECTED','QUEUED','SUCCESS','PENDING','FAILED')),
+			payout_transaction_id UUID,
+			message TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (batch_id, row_number)
+		);`,
+
+		// ============================================================
+		// Wallet Holds
+		// ============================================================
+		`CREATE TABLE IF NOT EXISTS wallet_holds (
+			hold_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			payout_transaction_id UUID UNIQUE NOT NULL,
+			amount NUMERIC(20,2) NOT NULL CHECK (amount >= 0),
+			admin_id UUID NOT NULL,
+			master_distributor_id UUID NOT NULL,
+			distributor_id UUID NOT NULL,
+			admin_share NUMERIC NOT NULL DEFAULT 0,
+			master_distributor_share NUMERIC NOT NULL DEFAULT 0,
+			distributor_share NUMERIC NOT NULL DEFAULT 0,
+			hold_status TEXT NOT NULL DEFAULT 'HELD' CHECK (hold_status IN ('HELD','CAPTURED','RELEASED')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			settled_at TIMESTAMPTZ
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_wallet_holds_user_held
+			ON wallet_holds (user_id) WHERE hold_status = 'HELD';`,
+		`ALTER TABLE wallet_holds ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMPTZ;`,
+		`ALTER TABLE wallet_holds DROP CONSTRAINT IF EXISTS wallet_holds_hold_status_check;`,
+		`ALTER TABLE wallet_holds ADD CONSTRAINT wallet_holds_hold_status_check
+			CHECK (hold_status IN ('HELD','CAPTURED','RELEASED','REFUNDED'));`,
+
+		// ============================================================
+		// Balance History and Period Closing
+		// ============================================================
+		// Balance changes are dated by their transaction, like the records
+		// that explain them, so both fall on the same day. Changes made in one
+		// transaction share that time and are ordered by change_id.
+		`CREATE TABLE IF NOT EXISTS wallet_balance_changes (
+			change_id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			owner_type TEXT NOT NULL CHECK (owner_type IN ('ADMIN','MASTER_DISTRIBUTOR','DISTRIBUTOR','USER')),
+			owner_id UUID NOT NULL,
+			old_balance NUMERIC(20,2) NOT NULL,
+			new_balance NUMERIC(20,2) NOT NULL,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_wallet_balance_changes_owner
+			ON wallet_balance_changes (owner_type, owner_id, changed_at);`,
+		`CREATE OR REPLACE FUNCTION record_wallet_balance_change() RETURNS trigger AS $$
+		BEGIN
+			INSERT INTO wallet_balance_changes (owner_type, owner_id, old_balance, new_balance)
+			VALUES (TG_ARGV[0], (to_jsonb(NEW)->>TG_ARGV[1])::UUID,
+				(to_jsonb(OLD)->>TG_ARGV[2])::NUMERIC, (to_jsonb(NEW)->>TG_ARGV[2])::NUMERIC);
+			RETURN NEW;
+		END; $$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_admins_balance_change ON admins;`,
+		`CREATE TRIGGER trg_admins_balance_change AFTER UPDATE OF admin_wallet_balance ON admins
+			FOR EACH ROW WHEN (OLD.admin_wallet_balance IS DISTINCT FROM NEW.admin_wallet_balance)
+			EXECUTE FUNCTION record_wallet_balance_change('ADMIN', 'admin_id', 'admin_wallet_balance');`,
+		`DROP TRIGGER IF EXISTS trg_master_distributors_balance_change ON master_distributors;`,
+		`CREATE TRIGGER trg_master_distributors_balance_change AFTER UPDATE OF master_distributor_wallet_balance ON master_distributors
+			FOR EACH ROW WHEN (OLD.master_distributor_wallet_balance IS DISTINCT FROM NEW.master_distributor_wallet_balance)
+			EXECUTE FUNCTION record_wallet_balance_change('MASTER_DISTRIBUTOR', 'master_distributor_id', 'master_distributor_wallet_balance');`,
+		`DROP TRIGGER IF EXISTS trg_distributors_balance_change ON distributors;`,
+		`CREATE TRIGGER trg_distributors_balance_change AFTER UPDATE OF distributor_wallet_balance ON distributors
+			FOR EACH ROW WHEN (OLD.distributor_wallet_balance IS DISTINCT FROM NEW.distributor_wallet_balance)
+			EXECUTE FUNCTION record_wallet_balance_change('DISTRIBUTOR', 'distributor_id', 'distributor_wallet_balance');`,
+		`DROP TRIGGER IF EXISTS trg_users_balance_change ON users;`,
+		`CREATE TRIGGER trg_users_balance_change AFTER UPDATE OF user_wallet_balance ON users
+			FOR EACH ROW WHEN (OLD.user_wallet_balance IS DISTINCT FROM NEW.user_wallet_balance)
+			EXECUTE FUNCTION record_wallet_balance_change('USER', 'user_id', 'user_wallet_balance');`,
+		`CREATE OR REPLACE FUNCTION wallet_balance_at(p_owner_type TEXT, p_owner_id UUID, p_at TIMESTAMPTZ, p_current NUMERIC)
+		RETURNS NUMERIC AS $$
+			SELECT COALESCE(
+				(SELECT new_balance FROM wallet_balance_changes
+				WHERE owner_type = p_owner_type AND owner_id = p_owner_id AND changed_at < p_at
+				ORDER BY change_id DESC LIMIT 1),
+				(SELECT old_balance FROM wallet_balance_changes
+				WHERE owner_type = p_owner_type AND owner_id = p_owner_id AND changed_at >= p_at
+				ORDER BY change_id LIMIT 1),
+				p_current
+			);
+		$$ LANGUAGE sql STABLE;`,
+		`CREATE TABLE IF NOT EXISTS wallet_balance_snapshots (
+			owner_type TEXT NOT NULL,
+			owner_id UUID NOT NULL,
+			snapshot_date DATE NOT NULL,
+			opening_balance NUMERIC(20,2) NOT NULL,
+			closing_balance NUMERIC(20,2) NOT NULL,
+			total_credits NUMERIC(20,2) NOT NULL,
+			total_debits NUMERIC(20,2) NOT NULL,
+			service_totals JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (owner_type, owner_id, snapshot_date)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_wallet_balance_snapshots_date ON wallet_balance_snapshots (snapshot_date);`,
+		`ALTER TABLE wallet_balance_snapshots
+			ADD COLUMN IF NOT EXISTS credit_drawn NUMERIC(20,2) NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS credit_recovered NUMERIC(20,2) NOT NULL DEFAULT 0;`,
+		`CREATE TABLE IF NOT EXISTS closed_periods (
+			admin_id UUID NOT NULL REFERENCES admins(admin_id) ON DELETE CASCADE,
+			period_end DATE NOT NULL,
+			closed_by TEXT NOT NULL,
+			closed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (admin_id, period_end)
+		);`,
+		// record_admin_id finds the admin a guarded record belongs to, from its
+		// admin_id, else the member it is about, else the member's phone
+		`CREATE OR REPLACE FUNCTION record_admin_id(rec JSONB) RETURNS UUID AS $$
+		DECLARE
+			member UUID := COALESCE(rec->>'transactor_id', rec->>'user_id', rec->>'member_id')::UUID;
+			tenant UUID;
+		BEGIN
+			IF rec ? 'admin_id' THEN
+				RETURN (rec->>'admin_id')::UUID;
+			END IF;
+			IF member IS NOT NULL THEN
+				SELECT m.admin_id INTO tenant FROM (
+					SELECT admin_id FROM admins WHERE admin_id = member
+					UNION ALL SELECT admin_id FROM master_distributors WHERE master_distributor_id = member
+					UNION ALL SELECT admin_id FROM distributors WHERE distributor_id = member
+					UNION ALL SELECT admin_id FROM users WHERE user_id = member
+				) m LIMIT 1;
+				RETURN tenant;
+			END IF;
+			SELECT m.admin_id INTO tenant FROM (
+				SELECT admin_id FROM master_distributors WHERE master_distributor_phone = rec->>'phone'
+				UNION ALL SELECT admin_id FROM distributors WHERE distributor_phone = rec->>'phone'
+				UNION ALL SELECT admin_id FROM users WHERE user_phone = rec->>'phone'
+			) m LIMIT 1;
+			RETURN tenant;
+		END; $$ LANGUAGE plpgsql STABLE;`,
+		// Every table whose rows feed a wallet balance or its postings is
+		// guarded. The trigger is given the table's status column and the
+		// status of its records still in flight, tables without one pass none.
+		`CREATE OR REPLACE FUNCTION guard_closed_period() RETURNS trigger AS $$
+		DECLARE
+			closed_through DATE;
+			record_time TIMESTAMPTZ;
+			old_status TEXT;
+			new_status TEXT;
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM closed_periods) THEN
+				RETURN NEW;
+			END IF;
+			-- Each admin closes its own books
+			SELECT MAX(period_end) INTO closed_through FROM closed_periods
+			WHERE admin_id = record_admin_id(CASE WHEN TG_OP = 'INSERT' THEN to_jsonb(NEW) ELSE to_jsonb(OLD) END);
+			IF closed_through IS NULL THEN
+				RETURN NEW;
+			END IF;
+			IF TG_OP = 'INSERT' THEN
+				record_time := NEW.created_at;
+			ELSE
+				IF TG_NARGS > 0 THEN
+					old_status := to_jsonb(OLD)->>TG_ARGV[0];
+					new_status := to_jsonb(NEW)->>TG_ARGV[0];
+					-- Records still in flight when their period closed may settle
+					IF old_status IS NOT DISTINCT FROM TG_ARGV[1] THEN
+						RETURN NEW;
+					END IF;
+					-- A bank can return a payout long after it went through, the
+					-- return is booked on the day it is recorded
+					IF old_status = 'SUCCESS' AND new_status IN ('RETURNED','REVERSED') THEN
+						RETURN NEW;
+					END IF;
+				END IF;
+				record_time := OLD.created_at;
+			END IF;
+			IF (record_time AT TIME ZONE 'Asia/Kolkata')::DATE <= closed_through THEN
+				RAISE EXCEPTION 'period closed through % cannot be changed', closed_through USING ERRCODE = 'PB001';
+			END IF;
+			RETURN NEW;
+		END; $$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_payout_service_closed_period ON payout_service;`,
+		`CREATE TRIGGER trg_payout_service_closed_period BEFORE INSERT OR UPDATE ON payout_service
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period('transaction_status', 'PENDING');`,
+		`DROP TRIGGER IF EXISTS trg_transactions_closed_period ON transactions;`,
+		`CREATE TRIGGER trg_transactions_closed_period BEFORE INSERT OR UPDATE ON transactions
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period('transaction_status', 'PENDING');`,
+		`DROP TRIGGER IF EXISTS trg_wallet_holds_closed_period ON wallet_holds;`,
+		`CREATE TRIGGER trg_wallet_holds_closed_period BEFORE INSERT OR UPDATE ON wallet_holds
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period('hold_status', 'HELD');`,
+		`DROP TRIGGER IF EXISTS trg_fund_requests_closed_period ON fund_requests;`,
+		`CREATE TRIGGER trg_fund_requests_closed_period BEFORE INSERT OR UPDATE ON fund_requests
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period('request_status', 'PENDING');`,
+		`DROP TRIGGER IF EXISTS trg_payout_batches_closed_period ON payout_batches;`,
+		`CREATE TRIGGER trg_payout_batches_closed_period BEFORE INSERT OR UPDATE ON payout_batches
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period('batch_status', 'PROCESSING');`,
+		`DROP TRIGGER IF EXISTS trg_revert_history_closed_period ON revert_history;`,
+		`CREATE TRIGGER trg_revert_history_closed_period BEFORE INSERT OR UPDATE ON revert_history
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period();`,
+
+		// ============================================================
+		// Wallet Integrity
+		// ============================================================
+		// Days before every movement was both logged and recorded cannot be
+		// checked, the first full day after this is the first that can
+		`CREATE TABLE IF NOT EXISTS wallet_integrity_start (
+			singleton BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (singleton),
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`INSERT INTO wallet_integrity_start DEFAULT VALUES ON CONFLICT DO NOTHING;`,
+		`ALTER TABLE revert_history ADD COLUMN IF NOT EXISTS member_type TEXT;`,
+		`ALTER TABLE revert_history ADD COLUMN IF NOT EXISTS member_id UUID;`,
+		`ALTER TABLE revert_history ADD COLUMN IF NOT EXISTS reverted_by_type TEXT;`,
+		`ALTER TABLE revert_history ADD COLUMN IF NOT EXISTS reverted_by_id UUID;`,
+		`ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_transaction_service_check;`,
+		`ALTER TABLE transactions ADD CONSTRAINT transactions_transaction_service_check
+			CHECK (transaction_service IN ('FUND_REQUEST','TOPUP','PAYOUT','COMMISSION','FUND_TRANSFER','VERIFICATION'));`,
+		`CREATE TABLE IF NOT EXISTS wallet_integrity_checks (
+			check_date DATE PRIMARY KEY,
+			accounts_checked INT NOT NULL,
+			drifted_accounts INT NOT NULL,
+			orphaned_transactions INT NOT NULL,
+			checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS wallet_drifts (
+			check_date DATE NOT NULL REFERENCES wallet_integrity_checks(check_date) ON DELETE CASCADE,
+			owner_type TEXT NOT NULL,
+			owner_id UUID NOT NULL,
+			tenant_id UUID NOT NULL,
+			opening_balance NUMERIC(20,2) NOT NULL,
+			expected_balance NUMERIC(20,2) NOT NULL,
+			actual_balance NUMERIC(20,2) NOT NULL,
+			drift NUMERIC(20,2) NOT NULL,
+			PRIMARY KEY (check_date, owner_type, owner_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_wallet_drifts_tenant ON wallet_drifts (tenant_id, check_date DESC);`,
+		// How far the credit drawn at the end of the day is from what the
+		// credit line events recorded
+		`ALTER TABLE wallet_drifts ADD COLUMN IF NOT EXISTS credit_drift NUMERIC(20,2) NOT NULL DEFAULT 0;`,
+
+		// ============================================================
+		// Payout Returns
+		// ============================================================
+		`ALTER TABLE payout_service DROP CONSTRAINT IF EXISTS payout_service_transaction_status_check;`,
+		`ALTER TABLE payout_service ADD CONSTRAINT payout_service_transaction_status_check
+			CHECK (transaction_status IN ('PENDING','SUCCESS','FAILED','REFUND','RETURNED','REVERSED'));`,
+		`CREATE TABLE IF NOT EXISTS payout_returns (
+			return_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			payout_transaction_id UUID UNIQUE NOT NULL REFERENCES payout_service(payout_transaction_id) ON DELETE CASCADE,
+			outcome TEXT NOT NULL CHECK (outcome IN ('RETURNED','REVERSED')),
+			return_reference TEXT UNIQUE NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			commission_policy TEXT NOT NULL CHECK (commission_policy IN ('CLAWBACK','KEEP')),
+			principal NUMERIC(20,2) NOT NULL,
+			retailer_refund NUMERIC(20,2) NOT NULL,
+			admin_clawback NUMERIC(20,2) NOT NULL DEFAULT 0,
+			master_distributor_clawback NUMERIC(20,2) NOT NULL DEFAULT 0,
+			distributor_clawback NUMERIC(20,2) NOT NULL DEFAULT 0,
+			user_id UUID NOT NULL,
+			admin_id UUID NOT NULL,
+			master_distributor_id UUID NOT NULL,
+			distributor_id UUID NOT NULL,
+			bank_code TEXT NOT NULL,
+			bank_name TEXT NOT NULL,
+			recorded_by TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_payout_returns_admin ON payout_returns (admin_id, created_at DESC);`,
+		`DROP TRIGGER IF EXISTS trg_payout_returns_closed_period ON payout_returns;`,
+		`CREATE TRIGGER trg_payout_returns_closed_period BEFORE INSERT OR UPDATE ON payout_returns
+			FOR EACH ROW EXECUTE FUNCTION guard_closed_period();`,
 	}
 
 	tx, err := qr.Pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		log.Fatalf("failed to start SQL transaction: %v", err)
 	}
-	defer func() { _ = tx.Rollback(ctx) }()
+	defer rollbackTx(ctx, tx)
 
 	for i, q := range queries {
 		if _, execErr := tx.Exec(ctx, q); execErr != nil {
@@ -333,6 +1076,14 @@ func (qr *Query) InitializeDatabase() {
 		}
 	}
 
+	if err := applySchemaMigrations(ctx, tx); err != nil {
+		log.Fatalf("failed to apply schema migrations: %v", err)
+	}
+
+	if err := syncSystemRoles(ctx, tx); err != nil {
+		log.Fatalf("failed to sync system roles: %v", err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		log.Fatalf("failed to commit transaction: %v", err)
 	}
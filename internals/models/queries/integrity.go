@@ -0,0 +1,483 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrIntegrityNotCovered is returned for days before every wallet movement
+// was recorded, which cannot be checked
+var ErrIntegrityNotCovered = errors.New("day predates integrity checks")
+
+// firstCheckableDay returns the first full day the integrity check covers
+func firstCheckableDay(ctx context.Context, db rowQuerier) (time.Time, error) {
+	var startedAt time.Time
+	if err := db.QueryRow(ctx, `SELECT started_at FROM wallet_integrity_start;`).Scan(&startedAt); err != nil {
+		return time.Time{}, fmt.Errorf("read integrity start: %w", err)
+	}
+	return pkg.StartOfDay(startedAt).AddDate(0, 0, 1), nil
+}
+
+// walletPostings lists every recorded movement of money in or out of a
+// wallet made between $1 and $2, signed as it should have changed the
+// balance:
+//   - a transaction moves its amount for the transactor, out on DEBIT and in
+//     on CREDIT. DEBIT rows also pay the receiver, except fund requests,
+//     which record the receiver's side as a row of its own.
+//   - a payout takes its hold out of the retailer's wallet when it is made,
+//     unless a bulk payout paid for it up front. A released hold comes back;
+//     a captured one pays its commission shares, which a later refund of
+//     the payout writes back as transactions.
+//   - a bulk payout takes its reserve when created and returns what its rows
+//     did not use when completed.
+//   - a revert moves its amount from the member to whoever reverted it.
+//   - a payout return refunds the retailer and takes back any commission
+//     it clawed back.
+//
+// Credit draws and repayments are the wallet crossing zero through the
+// movements above, not postings of their own. recordedCredit holds them to
+// account instead.
+const walletPostings = `
+	SELECT transactor_type AS owner_type, transactor_id AS owner_id,
+		CASE WHEN transaction_type = 'DEBIT' THEN -amount ELSE amount END AS amount
+	FROM transactions
+	WHERE created_at >= $1 AND created_at < $2 AND transaction_status IS DISTINCT FROM 'FAILED'
+	UNION ALL
+	SELECT receiver_type, receiver_id, amount
+	FROM transactions
+	WHERE created_at >= $1 AND created_at < $2 AND transaction_status IS DISTINCT FROM 'FAILED'
+		AND transaction_type = 'DEBIT' AND transaction_service <> 'FUND_REQUEST' AND receiver_id IS NOT NULL
+	UNION ALL
+	SELECT 'USER', h.user_id, -h.amount
+	FROM wallet_holds h
+	WHERE h.created_at >= $1 AND h.created_at < $2
+		AND NOT EXISTS (SELECT 1 FROM payout_batch_rows r WHERE r.payout_transaction_id = h.payout_transaction_id)
+	UNION ALL
+	SELECT 'USER', user_id, amount
+	FROM wallet_holds
+	WHERE hold_status = 'RELEASED' AND settled_at >= $1 AND settled_at < $2
+	UNION ALL
+	SELECT s.owner_type, s.owner_id, ROUND(s.share, 2)
+	FROM wallet_holds h
+	CROSS JOIN LATERAL (VALUES
+		('ADMIN', h.admin_id, h.admin_share),
+		('MASTER_DISTRIBUTOR', h.master_distributor_id, h.master_distributor_share),
+		('DISTRIBUTOR', h.distributor_id, h.distributor_share)
+	) s (owner_type, owner_id, share)
+	WHERE h.hold_status IN ('CAPTURED','REFUNDED') AND h.settled_at >= $1 AND h.settled_at < $2
+	UNION ALL
+	SELECT 'USER', user_id, -reserved_amount
+	FROM payout_batches
+	WHERE created_at >= $1 AND created_at < $2
+	UNION ALL
+	SELECT 'USER', b.user_id, b.reserved_amount - COALESCE((
+		SELECT SUM(h.amount) FROM payout_batch_rows r
+		JOIN wallet_holds h ON h.payout_transaction_id = r.payout_transaction_id
+		WHERE r.batch_id = b.batch_id
+	), 0)
+	FROM payout_batches b
+	WHERE b.batch_status = 'COMPLETED' AND b.completed_at >= $1 AND b.completed_at < $2
+	UNION ALL
+	SELECT member_type, member_id, -amount::NUMERIC
+	FROM revert_history
+	WHERE created_at >= $1 AND created_at < $2 AND member_id IS NOT NULL
+	UNION ALL
+	SELECT reverted_by_type, reverted_by_id, amount::NUMERIC
+	FROM revert_history
+	WHERE created_at >= $1 AND created_at < $2 AND reverted_by_id IS NOT NULL
+	UNION ALL
+	SELECT c.owner_type, c.owner_id, c.amount
+	FROM payout_returns r
+	CROSS JOIN LATERAL (VALUES
+		('USER', r.user_id, r.retailer_refund),
+		('ADMIN', r.admin_id, -r.admin_clawback),
+		('MASTER_DISTRIBUTOR', r.master_distributor_id, -r.master_distributor_clawback),
+		('DISTRIBUTOR', r.distributor_id, -r.distributor_clawback)
+	) c (owner_type, owner_id, amount)
+	WHERE r.created_at >= $1 AND r.created_at < $2
+`
+
+// recordedCredit is the credit the wallet o had drawn at $2 according to its
+// credit line events. Wallets without a credit line never drew any.
+const recordedCredit = `
+	COALESCE((
+		SELECT e.outstanding_after FROM credit_lines l
+		JOIN credit_line_events e ON e.credit_line_id = l.credit_line_id
+		WHERE l.member_type = o.owner_type AND l.member_id = o.owner_id AND e.created_at < $2
+		ORDER BY e.event_id DESC LIMIT 1
+	), 0)
+`
+
+// orphanedTransactions are the transfers of $2 to $3 that debited a sender
+// without finding the receiver
+const orphanedTransactions = `
+	FROM transactions t
+	WHERE t.created_at >= $2 AND t.created_at < $3 AND t.transaction_status IS DISTINCT FROM 'FAILED'
+		AND t.transaction_type = 'DEBIT' AND t.receiver_type IS NOT NULL AND t.receiver_id IS NULL
+`
+
+// walletDay is a wallet's day as the integrity check sees it
+type walletDay struct {
+	ownerType, ownerID, tenantID     string
+	opening, closing, posted, credit string
+}
+
+// walletDayDrift is how far a wallet's day is off. drift is the closing
+// balance less the opening balance plus the postings, creditDrift how far
+// the balance is below zero less the credit on record.
+type walletDayDrift struct {
+	expected, drift, creditDrift string
+	// off is the sum of both, unsigned
+	off *big.Rat
+}
+
+// driftOf checks a wallet's day and returns what is off, or nil when the
+// wallet is in order
+func driftOf(d *walletDay) (*walletDayDrift, error) {
+	var amounts [4]*big.Rat
+	for i, s := range []string{d.opening, d.closing, d.posted, d.credit} {
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount %q for %s %s", s, d.ownerType, d.ownerID)
+		}
+		amounts[i] = r
+	}
+	opening, closing, posted, credit := amounts[0], amounts[1], amounts[2], amounts[3]
+
+	expected := new(big.Rat).Add(opening, posted)
+	drift := new(big.Rat).Sub(closing, expected)
+	drawn := new(big.Rat).Neg(closing)
+	if drawn.Sign() < 0 {
+		drawn.SetInt64(0)
+	}
+	creditDrift := new(big.Rat).Sub(drawn, credit)
+	if drift.Sign() == 0 && creditDrift.Sign() == 0 {
+		return nil, nil
+	}
+	return &walletDayDrift{
+		expected:    expected.FloatString(2),
+		drift:       drift.FloatString(2),
+		creditDrift: creditDrift.FloatString(2),
+		off:         new(big.Rat).Add(new(big.Rat).Abs(drift), new(big.Rat).Abs(creditDrift)),
+	}, nil
+}
+
+// checkDay compares every wallet's movement over a day with its recorded
+// postings, and its balance below zero with its recorded credit, and stores
+// the wallets that disagree. Checking a day again
+// replaces what was found before. The admins whose wallets drifted are sent
+// one alert each.
+func checkDay(ctx context.Context, tx pgx.Tx, day time.Time) (*structures.IntegrityCheck, error) {
+	start, end := dayBounds(day)
+	date := start.Format(pkg.LedgerDateLayout)
+
+	first, err := firstCheckableDay(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if start.Before(first) {
+		return nil, ErrIntegrityNotCovered
+	}
+
+	var res structures.IntegrityCheck
+	if err := tx.QueryRow(ctx, `
+		SELECT $1::DATE::TEXT,
+			(SELECT COUNT(*) FROM (`+walletOwnerBalances+`) o WHERE o.created_at < $3),
+			(SELECT COUNT(*) `+orphanedTransactions+`);
+	`, date, start, end).Scan(&res.CheckDate, &res.AccountsChecked, &res.OrphanedTransactions); err != nil {
+		return nil, fmt.Errorf("count accounts: %w", err)
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO wallet_integrity_checks (check_date, accounts_checked, drifted_accounts, orphaned_transactions)
+		VALUES ($1::DATE, $2, 0, $3)
+		ON CONFLICT (check_date) DO UPDATE SET
+			accounts_checked = EXCLUDED.accounts_checked,
+			drifted_accounts = 0,
+			orphaned_transactions = EXCLUDED.orphaned_transactions,
+			checked_at = NOW()
+		RETURNING checked_at;
+	`, date, res.AccountsChecked, res.OrphanedTransactions).Scan(&res.CheckedAt); err != nil {
+		return nil, fmt.Errorf("record integrity check: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM wallet_drifts WHERE check_date = $1::DATE;`, date); err != nil {
+		return nil, fmt.Errorf("clear wallet drifts: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		WITH postings AS (
+			SELECT owner_type, owner_id, SUM(amount) AS total
+			FROM (`+walletPostings+`) p
+			GROUP BY owner_type, owner_id
+		)
+		SELECT o.owner_type, o.owner_id::TEXT, o.tenant_id::TEXT,
+			(CASE WHEN o.created_at >= $1 THEN 0
+				ELSE wallet_balance_at(o.owner_type, o.owner_id, $1, o.balance) END)::TEXT,
+			wallet_balance_at(o.owner_type, o.owner_id, $2, o.balance)::TEXT,
+			COALESCE(p.total, 0)::TEXT,
+			(`+recordedCredit+`)::TEXT
+		FROM (`+walletOwnerBalances+`) o
+		LEFT JOIN postings p ON p.owner_type = o.owner_type AND p.owner_id = o.owner_id
+		WHERE o.created_at < $2;
+	`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("check wallet balances: %w", err)
+	}
+	type tenantDrift struct {
+		adminID  string
+		accounts int
+		total    *big.Rat
+	}
+	var tenants []*tenantDrift
+	byTenant := make(map[string]*tenantDrift)
+	var drifted struct {
+		ownerTypes, ownerIDs, tenantIDs, openings, expected, closings, drifts, creditDrifts []string
+	}
+	for rows.Next() {
+		var d walletDay
+		if err := rows.Scan(&d.ownerType, &d.ownerID, &d.tenantID, &d.opening, &d.closing, &d.posted, &d.credit); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		off, err := driftOf(&d)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if off == nil {
+			continue
+		}
+		drifted.ownerTypes = append(drifted.ownerTypes, d.ownerType)
+		drifted.ownerIDs = append(drifted.ownerIDs, d.ownerID)
+		drifted.tenantIDs = append(drifted.tenantIDs, d.tenantID)
+		drifted.openings = append(drifted.openings, d.opening)
+		drifted.expected = append(drifted.expected, off.expected)
+		drifted.closings = append(drifted.closings, d.closing)
+		drifted.drifts = append(drifted.drifts, off.drift)
+		drifted.creditDrifts = append(drifted.creditDrifts, off.creditDrift)
+
+		t, ok := byTenant[d.tenantID]
+		if !ok {
+			t = &tenantDrift{adminID: d.tenantID, total: new(big.Rat)}
+			byTenant[d.tenantID] = t
+			tenants = append(tenants, t)
+		}
+		t.accounts++
+		t.total.Add(t.total, off.off)
+		res.DriftedAccounts++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if res.DriftedAccounts > 0 {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO wallet_drifts (
+				check_date, owner_type, owner_id, tenant_id, opening_balance, expected_balance, actual_balance, drift, credit_drift
+			)
+			SELECT $1::DATE, d.*
+			FROM unnest($2::TEXT[], $3::TEXT[]::UUID[], $4::TEXT[]::UUID[], $5::TEXT[]::NUMERIC[], $6::TEXT[]::NUMERIC[],
+				$7::TEXT[]::NUMERIC[], $8::TEXT[]::NUMERIC[], $9::TEXT[]::NUMERIC[]) d;
+		`, date, drifted.ownerTypes, drifted.ownerIDs, drifted.tenantIDs, drifted.openings, drifted.expected,
+			drifted.closings, drifted.drifts, drifted.creditDrifts); err != nil {
+			return nil, fmt.Errorf("record wallet drifts: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE wallet_integrity_checks SET drifted_accounts = $2 WHERE check_date = $1::DATE;
+	`, date, res.DriftedAccounts); err != nil {
+		return nil, fmt.Errorf("record integrity check: %w", err)
+	}
+	for _, t := range tenants {
+		if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+			EventType:     pkg.EventWalletDrift,
+			RecipientID:   t.adminID,
+			RecipientType: "ADMIN",
+			Data:          map[string]string{"date": date, "accounts": fmt.Sprint(t.accounts), "total_drift": t.total.FloatString(2)},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return &res, nil
+}
+
+// CheckWalletIntegrity checks one day, replacing any earlier check of it
+func (q *Query) CheckWalletIntegrity(ctx context.Context, day time.Time) (*structures.IntegrityCheck, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	res, err := checkDay(ctx, tx, day)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RunWalletIntegrityChecks checks every day after the last one checked up
+// to and including through, at most SnapshotCatchUpDays of them, and returns
+// the checks that found drifted wallets or orphaned transactions
+func (q *Query) RunWalletIntegrityChecks(ctx context.Context, through time.Time) ([]structures.IntegrityCheck, error) {
+	var last string
+	readCtx, cancel := q.readContext(ctx)
+	err := q.Pool.QueryRow(readCtx, `
+		SELECT COALESCE(MAX(check_date)::TEXT, '') FROM wallet_integrity_checks;
+	`).Scan(&last)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("read last integrity check: %w", err)
+	}
+
+	through = pkg.StartOfDay(through)
+	from := through
+	if last != "" {
+		lastDay, err := pkg.ParseLedgerDate(last)
+		if err != nil {
+			return nil, err
+		}
+		from = lastDay.AddDate(0, 0, 1)
+	}
+	if earliest := through.AddDate(0, 0, 1-pkg.SnapshotCatchUpDays); from.Before(earliest) {
+		from = earliest
+	}
+	readCtx, cancel = q.readContext(ctx)
+	first, err := firstCheckableDay(readCtx, q.Pool)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if from.Before(first) {
+		from = first
+	}
+
+	var found []structures.IntegrityCheck
+	for day := from; !day.After(through); day = day.AddDate(0, 0, 1) {
+		res, err := q.CheckWalletIntegrity(ctx, day)
+		if err != nil {
+			return found, err
+		}
+		if res.DriftedAccounts > 0 || res.OrphanedTransactions > 0 {
+			found = append(found, *res)
+		}
+	}
+	return found, nil
+}
+
+// GetIntegrityChecks lists the latest checks with the drifted wallets of
+// the admin counted
+func (q *Query) GetIntegrityChecks(ctx context.Context, adminID string, limit int) (*[]structures.IntegrityCheck, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT c.check_date::TEXT, c.accounts_checked,
+			(SELECT COUNT(*) FROM wallet_drifts d WHERE d.check_date = c.check_date AND d.tenant_id = $1),
+			c.orphaned_transactions, c.checked_at
+		FROM wallet_integrity_checks c
+		ORDER BY c.check_date DESC
+		LIMIT $2;
+	`, adminID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []structures.IntegrityCheck{}
+	for rows.Next() {
+		var c structures.IntegrityCheck
+		if err := rows.Scan(&c.CheckDate, &c.AccountsChecked, &c.DriftedAccounts, &c.OrphanedTransactions, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return &res, rows.Err()
+}
+
+// GetIntegrityReport returns what the check of a day found among the
+// admin's wallets. An empty adminID reports on every admin.
+func (q *Query) GetIntegrityReport(ctx context.Context, adminID string, day time.Time) (*structures.IntegrityReport, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	start, end := dayBounds(day)
+	date := start.Format(pkg.LedgerDateLayout)
+
+	res := structures.IntegrityReport{
+		Drifts:               []structures.WalletDrift{},
+		OrphanedTransactions: []structures.OrphanedTransaction{},
+	}
+	if err := q.Pool.QueryRow(ctx, `
+		SELECT check_date::TEXT, accounts_checked, drifted_accounts, orphaned_transactions, checked_at
+		FROM wallet_integrity_checks WHERE check_date = $1::DATE;
+	`, date).Scan(&res.Check.CheckDate, &res.Check.AccountsChecked, &res.Check.DriftedAccounts,
+		&res.Check.OrphanedTransactions, &res.Check.CheckedAt); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT d.check_date::TEXT, d.owner_type, d.owner_id::TEXT, COALESCE(o.owner_name, ''),
+			d.opening_balance::TEXT, d.expected_balance::TEXT, d.actual_balance::TEXT, d.drift::TEXT, d.credit_drift::TEXT
+		FROM wallet_drifts d
+		LEFT JOIN (`+walletOwnerBalances+`) o ON o.owner_type = d.owner_type AND o.owner_id = d.owner_id
+		WHERE d.check_date = $1::DATE AND ($2 = '' OR d.tenant_id::TEXT = $2)
+		ORDER BY ABS(d.drift) + ABS(d.credit_drift) DESC;
+	`, date, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d structures.WalletDrift
+		if err := rows.Scan(&d.CheckDate, &d.OwnerType, &d.OwnerID, &d.OwnerName,
+			&d.OpeningBalance, &d.ExpectedBalance, &d.ActualBalance, &d.Drift, &d.CreditDrift); err != nil {
+			return nil, err
+		}
+		res.Drifts = append(res.Drifts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Orphaned transactions are found again rather than stored, they are
+	// rare and their records do not change
+	rows, err = q.Pool.Query(ctx, `
+		SELECT t.transaction_id::TEXT, t.transactor_type, t.transactor_id::TEXT, COALESCE(t.transactor_name, ''),
+			t.receiver_type, t.transaction_service, t.amount::TEXT, t.created_at
+		`+orphanedTransactions+`
+			AND ($1 = '' OR EXISTS (
+				SELECT 1 FROM (`+walletOwnerBalances+`) o
+				WHERE o.owner_type = t.transactor_type AND o.owner_id = t.transactor_id AND o.tenant_id::TEXT = $1
+			))
+		ORDER BY t.created_at;
+	`, adminID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t structures.OrphanedTransaction
+		if err := rows.Scan(&t.TransactionID, &t.TransactorType, &t.TransactorID, &t.TransactorName,
+			&t.ReceiverType, &t.TransactionService, &t.Amount, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		res.OrphanedTransactions = append(res.OrphanedTransactions, t)
+	}
+	return &res, rows.Err()
+}
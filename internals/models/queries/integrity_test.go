@@ -0,0 +1,182 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestRefundedCapturedPayoutHasNoDrift runs against the database in
+// TEST_DATABASE_URL. Everything happens in one transaction that is rolled
+// back, so today can be checked straight away and nothing is left behind.
+func TestRefundedCapturedPayoutHasNoDrift(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pool.Close()
+	NewQuery(pool).InitializeDatabase()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin tx: %v", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	exec := func(sql string, args ...any) {
+		t.Helper()
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	insert := func(sql string, args ...any) string {
+		t.Helper()
+		var id string
+		if err := tx.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		return id
+	}
+
+	exec(`UPDATE wallet_integrity_start SET started_at = NOW() - INTERVAL '2 days';`)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	adminID := insert(`
+		INSERT INTO admins (admin_name, admin_phone, admin_email, admin_password)
+		VALUES ('Drift Admin', 'A' || $1, 'a' || $1 || '@drift.test', 'x')
+		RETURNING admin_id::TEXT;
+	`, suffix)
+	masterDistributorID := insert(`
+		INSERT INTO master_distributors (admin_id, master_distributor_name, master_distributor_phone,
+			master_distributor_email, master_distributor_password)
+		VALUES ($1, 'Drift MD', 'M' || $2, 'm' || $2 || '@drift.test', 'x')
+		RETURNING master_distributor_id::TEXT;
+	`, adminID, suffix)
+	distributorID := insert(`
+		INSERT INTO distributors (admin_id, master_distributor_id, distributor_name, distributor_phone,
+			distributor_email, distributor_password)
+		VALUES ($1, $2, 'Drift Distributor', 'D' || $3, 'd' || $3 || '@drift.test', 'x')
+		RETURNING distributor_id::TEXT;
+	`, adminID, masterDistributorID, suffix)
+	userID := insert(`
+		INSERT INTO users (admin_id, master_distributor_id, distributor_id, user_name, user_phone,
+			user_email, user_password)
+		VALUES ($1, $2, $3, 'Drift Retailer', 'U' || $4, 'u' || $4 || '@drift.test', 'x')
+		RETURNING user_id::TEXT;
+	`, adminID, masterDistributorID, distributorID, suffix)
+
+	exec(`UPDATE users SET user_wallet_balance = 1000 WHERE user_id = $1;`, userID)
+	exec(`
+		INSERT INTO transactions (transactor_id, transactor_type, transaction_type, transaction_service, amount, transaction_status)
+		VALUES ($1, 'USER', 'CREDIT', 'TOPUP', 1000, 'SUCCESS');
+	`, userID)
+
+	// A payout of 500 with 10 commission goes through and is then refunded
+	payoutTransactionID := insert(`SELECT gen_random_uuid()::TEXT;`)
+	exec(`UPDATE users SET user_wallet_balance = user_wallet_balance - 510 WHERE user_id = $1;`, userID)
+	if err := placePayoutHold(ctx, tx, &payoutHold{
+		userID:              userID,
+		payoutTransactionID: payoutTransactionID,
+		amount:              "510",
+		adminID:             adminID,
+		masterDistributorID: masterDistributorID,
+		distributorID:       distributorID,
+		totalCommission:     "10",
+		adminRate:           "0.2917",
+		masterRate:          "0.0417",
+		distributorRate:     "0.1667",
+	}); err != nil {
+		t.Fatalf("place hold: %v", err)
+	}
+	if err := settlePayoutHold(ctx, tx, payoutTransactionID, "SUCCESS"); err != nil {
+		t.Fatalf("capture hold: %v", err)
+	}
+	if err := refundPayoutHold(ctx, tx, payoutTransactionID); err != nil {
+		t.Fatalf("refund hold: %v", err)
+	}
+
+	check, err := checkDay(ctx, tx, time.Now())
+	if err != nil {
+		t.Fatalf("check day: %v", err)
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT owner_type, drift::TEXT FROM wallet_drifts
+		WHERE check_date = $1::DATE AND owner_id = ANY($2::UUID[]);
+	`, check.CheckDate, []string{adminID, masterDistributorID, distributorID, userID})
+	if err != nil {
+		t.Fatalf("read drifts: %v", err)
+	}
+	drifts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (string, error) {
+		var ownerType, drift string
+		err := row.Scan(&ownerType, &drift)
+		return ownerType + " " + drift, err
+	})
+	if err != nil {
+		t.Fatalf("read drifts: %v", err)
+	}
+	if len(drifts) != 0 {
+		t.Fatalf("refunded captured payout drifted: %v", drifts)
+	}
+}
+
+func TestDriftOf(t *testing.T) {
+	tests := []struct {
+		name                                string
+		day                                 walletDay
+		drifted                             bool
+		expected, drift, creditDrift, total string
+	}{
+		{"in order", walletDay{opening: "1000.00", closing: "490.00", posted: "-510.00", credit: "0"}, false, "", "", "", ""},
+		{"no movement", walletDay{opening: "0", closing: "0", posted: "0", credit: "0"}, false, "", "", "", ""},
+		{"credit drawn on record", walletDay{opening: "100.00", closing: "-410.00", posted: "-510.00", credit: "410.00"},
+			false, "", "", "", ""},
+		{"balance moved without a posting", walletDay{opening: "1000.00", closing: "1200.00", posted: "0", credit: "0"},
+			true, "1000.00", "200.00", "0.00", "200"},
+		{"posting missing from the balance", walletDay{opening: "1000.00", closing: "1000.00", posted: "-3.00", credit: "0"},
+			true, "997.00", "3.00", "0.00", "3"},
+		{"below zero without credit", walletDay{opening: "100.00", closing: "-410.00", posted: "-510.00", credit: "0"},
+			true, "-410.00", "0.00", "410.00", "410"},
+		{"credit on record after repayment", walletDay{opening: "-410.00", closing: "90.00", posted: "500.00", credit: "410.00"},
+			true, "90.00", "0.00", "-410.00", "410"},
+		{"both off", walletDay{opening: "0", closing: "-50.00", posted: "-20.00", credit: "20.00"},
+			true, "-20.00", "-30.00", "30.00", "60"},
+		{"share rounding", walletDay{opening: "0", closing: "3.50", posted: "3.50", credit: "0"}, false, "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			off, err := driftOf(&tt.day)
+			if err != nil {
+				t.Fatalf("driftOf: %v", err)
+			}
+			if (off != nil) != tt.drifted {
+				t.Fatalf("drifted = %v, want %v (%+v)", off != nil, tt.drifted, off)
+			}
+			if off == nil {
+				return
+			}
+			if off.expected != tt.expected || off.drift != tt.drift || off.creditDrift != tt.creditDrift {
+				t.Errorf("expected %s drift %s credit drift %s, want %s %s %s",
+					off.expected, off.drift, off.creditDrift, tt.expected, tt.drift, tt.creditDrift)
+			}
+			if total := off.off.RatString(); total != tt.total {
+				t.Errorf("off by %s, want %s", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestDriftOfRejectsBadAmounts(t *testing.T) {
+	if _, err := driftOf(&walletDay{opening: "1000.00", closing: "", posted: "0", credit: "0"}); err == nil {
+		t.Error("accepted an empty closing balance")
+	}
+}
@@ -0,0 +1,356 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrPeriodClosed is returned when a change would touch a payout or
+	// transaction of a period whose books are closed
+	ErrPeriodClosed        = errors.New("period is closed")
+	ErrPeriodAlreadyClosed = errors.New("period already closed")
+)
+
+// periodClosedCode is the SQLSTATE raised by the closed period guard
+const periodClosedCode = "PB001"
+
+// asPeriodClosed turns a write refused by the closed period guard into
+// ErrPeriodClosed and leaves other errors alone
+func asPeriodClosed(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == periodClosedCode {
+		return ErrPeriodClosed
+	}
+	return err
+}
+
+// walletOwnerBalances lists every wallet with the admin it belongs to
+const walletOwnerBalances = `
+	SELECT 'ADMIN' AS owner_type, admin_id AS owner_id, admin_id AS tenant_id, admin_name AS owner_name,
+		admin_wallet_balance AS balance, created_at FROM admins
+	UNION ALL
+	SELECT 'MASTER_DISTRIBUTOR', master_distributor_id, admin_id, master_distributor_name,
+		master_distributor_wallet_balance, created_at FROM master_distributors
+	UNION ALL
+	SELECT 'DISTRIBUTOR', distributor_id, admin_id, distributor_name, distributor_wallet_balance, created_at FROM distributors
+	UNION ALL
+	SELECT 'USER', user_id, admin_id, user_name, user_wallet_balance, created_at FROM users
+`
+
+// dayBounds returns the first instant of a day in IST and of the day after
+func dayBounds(day time.Time) (time.Time, time.Time) {
+	start := pkg.StartOfDay(day)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// snapshotDay records the opening and closing balance of every wallet that
+// existed on the day, what moved through it and through which service, and
+// the credit it drew and repaid. Days already snapshotted are left as they
+// are.
+func snapshotDay(ctx context.Context, tx pgx.Tx, day time.Time) (int64, error) {
+	start, end := dayBounds(day)
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO wallet_balance_snapshots (
+			owner_type, owner_id, snapshot_date, opening_balance, closing_balance,
+			total_credits, total_debits, service_totals, credit_drawn, credit_recovered
+		)
+		SELECT o.owner_type, o.owner_id, $1::DATE,
+			CASE WHEN o.created_at >= $2 THEN 0 ELSE wallet_balance_at(o.owner_type, o.owner_id, $2, o.balance) END,
+			wallet_balance_at(o.owner_type, o.owner_id, $3, o.balance),
+			COALESCE(c.credits, 0), COALESCE(c.debits, 0), COALESCE(s.totals, '{}'),
+			COALESCE(cr.drawn, 0), COALESCE(cr.recovered, 0)
+		FROM (`+walletOwnerBalances+`) o
+		LEFT JOIN LATERAL (
+			SELECT SUM(GREATEST(new_balance - old_balance, 0)) AS credits,
+				SUM(GREATEST(old_balance - new_balance, 0)) AS debits
+			FROM wallet_balance_changes
+			WHERE owner_type = o.owner_type AND owner_id = o.owner_id
+				AND changed_at >= $2 AND changed_at < $3
+		) c ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT jsonb_object_agg(service, jsonb_build_object('in', amount_in::TEXT, 'out', amount_out::TEXT)) AS totals
+			FROM (
+				SELECT service, SUM(amount_in) AS amount_in, SUM(amount_out) AS amount_out
+				FROM (
+					SELECT transaction_service AS service,
+						CASE WHEN receiver_type = o.owner_type AND receiver_id = o.owner_id THEN amount ELSE 0 END AS amount_in,
+						CASE WHEN transactor_type = o.owner_type AND transactor_id = o.owner_id THEN amount ELSE 0 END AS amount_out
+					FROM transactions
+					WHERE ((transactor_type = o.owner_type AND transactor_id = o.owner_id)
+						OR (receiver_type = o.owner_type AND receiver_id = o.owner_id))
+						AND created_at >= $2 AND created_at < $3
+						AND transaction_status IS DISTINCT FROM 'FAILED'
+					UNION ALL
+					SELECT 'PAYOUT', 0, amount
+					FROM payout_service
+					WHERE o.owner_type = 'USER' AND user_id = o.owner_id
+						AND created_at >= $2 AND created_at < $3
+						AND transaction_status IN ('PENDING', 'SUCCESS', 'RETURNED', 'REVERSED')
+					UNION ALL
+					SELECT 'PAYOUT_RETURN', c.amount_in, c.amount_out
+					FROM payout_returns r
+					CROSS JOIN LATERAL (VALUES
+						('USER', r.user_id, r.retailer_refund, 0),
+						('ADMIN', r.admin_id, 0, r.admin_clawback),
+						('MASTER_DISTRIBUTOR', r.master_distributor_id, 0, r.master_distributor_clawback),
+						('DISTRIBUTOR', r.distributor_id, 0, r.distributor_clawback)
+					) c (owner_type, owner_id, amount_in, amount_out)
+					WHERE c.owner_type = o.owner_type AND c.owner_id = o.owner_id
+						AND r.created_at >= $2 AND r.created_at < $3
+				) t
+				GROUP BY service
+			) g
+		) s ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT SUM(e.amount) FILTER (WHERE e.event_type = 'DRAWN') AS drawn,
+				SUM(e.amount) FILTER (WHERE e.event_type = 'RECOVERED') AS recovered
+			FROM credit_lines l
+			JOIN credit_line_events e ON e.credit_line_id = l.credit_line_id
+			WHERE l.member_type = o.owner_type AND l.member_id = o.owner_id
+				AND e.created_at >= $2 AND e.created_at < $3
+		) cr ON TRUE
+		WHERE o.created_at < $3
+		ON CONFLICT (owner_type, owner_id, snapshot_date) DO NOTHING;
+	`, start.Format(pkg.LedgerDateLayout), start, end)
+	if err != nil {
+		return 0, fmt.Errorf("snapshot balances: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SnapshotBalances snapshots every day after the last one snapshotted up to
+// and including through, at most SnapshotCatchUpDays of them. With no
+// snapshots yet only through itself is taken.
+func (q *Query) SnapshotBalances(ctx context.Context, through time.Time) (int64, error) {
+	var last string
+	readCtx, cancel := q.readContext(ctx)
+	err := q.Pool.QueryRow(readCtx, `
+		SELECT COALESCE(MAX(snapshot_date)::TEXT, '') FROM wallet_balance_snapshots;
+	`).Scan(&last)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("read last snapshot: %w", err)
+	}
+
+	through = pkg.StartOfDay(through)
+	from := through
+	if last != "" {
+		lastDay, err := pkg.ParseLedgerDate(last)
+		if err != nil {
+			return 0, err
+		}
+		from = lastDay.AddDate(0, 0, 1)
+	}
+	if earliest := through.AddDate(0, 0, 1-pkg.SnapshotCatchUpDays); from.Before(earliest) {
+		from = earliest
+	}
+
+	var total int64
+	for day := from; !day.After(through); day = day.AddDate(0, 0, 1) {
+		n, err := q.snapshotBalancesOn(ctx, day)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (q *Query) snapshotBalancesOn(ctx context.Context, day time.Time) (int64, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	n, err := snapshotDay(ctx, tx, day)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit(ctx)
+}
+
+// GetBalanceAsOf returns a wallet's balance at the end of a day. Members who
+// did not exist yet had nothing.
+func (q *Query) GetBalanceAsOf(ctx context.Context, adminID string, ownerType string, ownerID string, day time.Time) (*structures.BalanceAsOf, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	_, end := dayBounds(day)
+	res := structures.BalanceAsOf{
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+		Date:      pkg.StartOfDay(day).Format(pkg.LedgerDateLayout),
+	}
+	if err := q.Pool.QueryRow(ctx, `
+		SELECT (CASE WHEN o.created_at >= $4 THEN 0
+			ELSE wallet_balance_at(o.owner_type, o.owner_id, $4, o.balance) END)::NUMERIC(20,2)::TEXT
+		FROM (`+walletOwnerBalances+`) o
+		WHERE o.owner_type = $1 AND o.owner_id = $2 AND o.tenant_id = $3;
+	`, ownerType, ownerID, adminID, end).Scan(&res.Balance); err != nil {
+		return nil, err
+	}
+
+	var snapshot structures.BalanceSnapshot
+	err := scanBalanceSnapshot(q.Pool.QueryRow(ctx, `
+		SELECT `+balanceSnapshotColumns+`
+		FROM wallet_balance_snapshots
+		WHERE owner_type = $1 AND owner_id = $2 AND snapshot_date = $3::DATE;
+	`, ownerType, ownerID, res.Date), &snapshot)
+	if err == nil {
+		res.Snapshot = &snapshot
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return &res, nil
+}
+
+const balanceSnapshotColumns = `
+	owner_type, owner_id::TEXT, snapshot_date::TEXT, opening_balance::TEXT, closing_balance::TEXT,
+	total_credits::TEXT, total_debits::TEXT, service_totals, credit_drawn::TEXT, credit_recovered::TEXT, created_at
+`
+
+func scanBalanceSnapshot(row pgx.Row, s *structures.BalanceSnapshot) error {
+	return row.Scan(
+		&s.OwnerType,
+		&s.OwnerID,
+		&s.SnapshotDate,
+		&s.OpeningBalance,
+		&s.ClosingBalance,
+		&s.TotalCredits,
+		&s.TotalDebits,
+		&s.ServiceTotals,
+		&s.CreditDrawn,
+		&s.CreditRecovered,
+		&s.CreatedAt,
+	)
+}
+
+// GetBalanceSnapshots lists a wallet's snapshots between two days, oldest
+// first. The wallet must belong to the admin.
+func (q *Query) GetBalanceSnapshots(ctx context.Context, adminID string, ownerType string, ownerID string, from time.Time, to time.Time) (*[]structures.BalanceSnapshot, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var exists bool
+	if err := q.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM (`+walletOwnerBalances+`) o
+			WHERE o.owner_type = $1 AND o.owner_id = $2 AND o.tenant_id = $3
+		);
+	`, ownerType, ownerID, adminID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+balanceSnapshotColumns+`
+		FROM wallet_balance_snapshots
+		WHERE owner_type = $1 AND owner_id = $2 AND snapshot_date BETWEEN $3::DATE AND $4::DATE
+		ORDER BY snapshot_date;
+	`, ownerType, ownerID, from.Format(pkg.LedgerDateLayout), to.Format(pkg.LedgerDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []structures.BalanceSnapshot{}
+	for rows.Next() {
+		var s structures.BalanceSnapshot
+		if err := scanBalanceSnapshot(rows, &s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return &res, rows.Err()
+}
+
+// ClosePeriod closes the admin's books through periodEnd. The day is
+// snapshotted first so its closing balances are on record before the guard
+// takes over. Periods only move forward.
+func (q *Query) ClosePeriod(ctx context.Context, adminID string, periodEnd time.Time, closedBy string, audit *structures.AuditLogEntry) (*structures.ClosedPeriod, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE closed_periods IN EXCLUSIVE MODE;`); err != nil {
+		return nil, fmt.Errorf("lock closed periods: %w", err)
+	}
+	end := pkg.StartOfDay(periodEnd).Format(pkg.LedgerDateLayout)
+	var closedThrough string
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(MAX(period_end)::TEXT, '') FROM closed_periods WHERE admin_id = $1;
+	`, adminID).Scan(&closedThrough); err != nil {
+		return nil, fmt.Errorf("read closed periods: %w", err)
+	}
+	if closedThrough != "" && end <= closedThrough {
+		return nil, ErrPeriodAlreadyClosed
+	}
+
+	if _, err := snapshotDay(ctx, tx, periodEnd); err != nil {
+		return nil, err
+	}
+
+	var res structures.ClosedPeriod
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO closed_periods (admin_id, period_end, closed_by) VALUES ($1, $2::DATE, $3)
+		RETURNING admin_id::TEXT, period_end::TEXT, closed_by, closed_at;
+	`, adminID, end, closedBy).Scan(&res.AdminID, &res.PeriodEnd, &res.ClosedBy, &res.ClosedAt); err != nil {
+		return nil, fmt.Errorf("close period: %w", err)
+	}
+
+	audit.TargetID = res.PeriodEnd
+	audit.Before = map[string]string{"closed_through": closedThrough}
+	audit.After = map[string]string{"closed_through": res.PeriodEnd}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetClosedPeriods lists the closings of the admin's books, latest first
+func (q *Query) GetClosedPeriods(ctx context.Context, adminID string) (*[]structures.ClosedPeriod, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT admin_id::TEXT, period_end::TEXT, closed_by, closed_at FROM closed_periods
+		WHERE admin_id = $1
+		ORDER BY period_end DESC;
+	`, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []structures.ClosedPeriod{}
+	for rows.Next() {
+		var p structures.ClosedPeriod
+		if err := rows.Scan(&p.AdminID, &p.PeriodEnd, &p.ClosedBy, &p.ClosedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return &res, rows.Err()
+}
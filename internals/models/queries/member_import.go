@@ -0,0 +1,224 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// memberImportTables names the table and contact columns of each kind of
+// member an import can create
+var memberImportTables = map[string]struct {
+	table       string
+	phoneColumn string
+	emailColumn string
+}{
+	"MASTER_DISTRIBUTOR": {"master_distributors", "master_distributor_phone", "master_distributor_email"},
+	"DISTRIBUTOR":        {"distributors", "distributor_phone", "distributor_email"},
+	"USER":               {"users", "user_phone", "user_email"},
+}
+
+// ImportMembers creates the members of an import in file order, each inside
+// its own savepoint so one bad row does not take the others with it. Rows that
+// arrive already FAILED are only counted. A dry run and an ATOMIC import with
+// a failed row are rolled back after every row has been tried, so the caller
+// always gets the full list of problems.
+func (q *Query) ImportMembers(ctx context.Context, imp *structures.MemberImport, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	imp.Succeeded, imp.Failed = 0, 0
+	for i := range imp.Rows {
+		row := &imp.Rows[i]
+		if row.RowStatus == pkg.ImportRowFailed {
+			imp.Failed++
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin savepoint: %w", err)
+		}
+		problems, err := importMember(ctx, sp, imp.AdminID, row)
+		if err != nil {
+			rollbackTx(ctx, sp)
+			return fmt.Errorf("import row %d: %w", row.RowNumber, err)
+		}
+		if len(problems) > 0 {
+			rollbackTx(ctx, sp)
+			row.RowStatus, row.Errors = pkg.ImportRowFailed, problems
+			imp.Failed++
+			continue
+		}
+		if err := sp.Commit(ctx); err != nil {
+			return fmt.Errorf("release savepoint: %w", err)
+		}
+		row.RowStatus = pkg.ImportRowCreated
+		imp.Succeeded++
+	}
+
+	rollBack := imp.DryRun || (imp.Mode == pkg.ImportModeAtomic && imp.Failed > 0)
+	for i := range imp.Rows {
+		row := &imp.Rows[i]
+		if row.RowStatus != pkg.ImportRowCreated || !rollBack {
+			continue
+		}
+		row.MemberID, row.MemberUniqueID = "", ""
+		if imp.DryRun {
+			row.RowStatus = pkg.ImportRowValid
+		} else {
+			row.RowStatus = pkg.ImportRowRolledBack
+		}
+	}
+	if rollBack || imp.Succeeded == 0 {
+		return nil
+	}
+
+	audit.After = map[string]any{"mode": imp.Mode, "created": imp.Succeeded, "failed": imp.Failed}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	imp.Committed = true
+	return nil
+}
+
+// importMember creates one member and returns what stopped it, if anything
+func importMember(ctx context.Context, tx pgx.Tx, adminID string, row *structures.MemberImportRow) ([]string, error) {
+	var problems []string
+
+	member := memberImportTables[row.MemberType]
+	var phoneTaken, emailTaken bool
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %[1]s WHERE %[2]s = $1),
+			EXISTS (SELECT 1 FROM %[1]s WHERE LOWER(%[3]s) = LOWER($2));
+	`, member.table, member.phoneColumn, member.emailColumn), row.Phone, row.Email).Scan(&phoneTaken, &emailTaken); err != nil {
+		return nil, fmt.Errorf("check duplicates: %w", err)
+	}
+	if phoneTaken {
+		problems = append(problems, "duplicate phone")
+	}
+	if emailTaken {
+		problems = append(problems, "duplicate email")
+	}
+
+	// Parents are looked up inside the import's transaction, so a parent
+	// created by an earlier row is found like any other
+	var masterDistributorID, distributorID string
+	var err error
+	switch row.MemberType {
+	case "DISTRIBUTOR":
+		err = tx.QueryRow(ctx, `
+			SELECT master_distributor_id::TEXT FROM master_distributors
+			WHERE admin_id = $1 AND (master_distributor_unique_id = $2 OR master_distributor_phone = $2);
+		`, adminID, row.ParentRef).Scan(&masterDistributorID)
+	case "USER":
+		err = tx.QueryRow(ctx, `
+			SELECT distributor_id::TEXT, master_distributor_id::TEXT FROM distributors
+			WHERE admin_id = $1 AND (distributor_unique_id = $2 OR distributor_phone = $2);
+		`, adminID, row.ParentRef).Scan(&distributorID, &masterDistributorID)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		problems = append(problems, "unknown parent")
+	} else if err != nil {
+		return nil, fmt.Errorf("resolve parent: %w", err)
+	}
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	switch row.MemberType {
+	case "MASTER_DISTRIBUTOR":
+		var res *structures.MasterDistributorAuthResponse
+		res, err = insertMasterDistributor(ctx, tx, &structures.MasterDistributorRegisterRequest{
+			AdminID:                       adminID,
+			MasterDistributorName:         row.Name,
+			MasterDistributorEmail:        row.Email,
+			MasterDistributorPassword:     row.Password,
+			MasterDistributorPhoneNumber:  row.Phone,
+			MasterDistributorAadharNumber: row.AadharNumber,
+			MasterDistributorPanNumber:    row.PanNumber,
+			MasterDistributorDateOfBirth:  row.DateOfBirth,
+			MasterDistributorGender:       row.Gender,
+			MasterDistributorCity:         row.City,
+			MasterDistributorState:        row.State,
+			MasterDistributorAddress:      row.Address,
+			MasterDistributorPincode:      row.Pincode,
+			BusinessName:                  row.BusinessName,
+			BusinessType:                  row.BusinessType,
+			GSTNumber:                     row.GSTNumber,
+		})
+		if err == nil {
+			row.MemberID, row.MemberUniqueID = res.MasterDistributorID, res.MasterDistributorUniqueID
+		}
+	case "DISTRIBUTOR":
+		var res *structures.DistributorAuthResponse
+		res, err = insertDistributor(ctx, tx, &structures.DistributorRegisterRequest{
+			AdminID:                 adminID,
+			MasterDistributorID:     masterDistributorID,
+			DistributorName:         row.Name,
+			DistributorEmail:        row.Email,
+			DistributorPassword:     row.Password,
+			DistributorPhone:        row.Phone,
+			DistributorAadharNumber: row.AadharNumber,
+			DistributorPanNumber:    row.PanNumber,
+			DistributorDateOfBirth:  row.DateOfBirth,
+			DistributorGender:       row.Gender,
+			DistributorCity:         row.City,
+			DistributorState:        row.State,
+			DistributorAddress:      row.Address,
+			DistributorPincode:      row.Pincode,
+			BusinessName:            row.BusinessName,
+			BusinessType:            row.BusinessType,
+			GSTNumber:               row.GSTNumber,
+		})
+		if err == nil {
+			row.MemberID, row.MemberUniqueID = res.DistributorID, res.DistributorUniqueID
+		}
+	case "USER":
+		var res *structures.UserAuthResponse
+		res, err = insertUser(ctx, tx, &structures.UserRegistrationRequest{
+			AdminID:             adminID,
+			MasterDistributorID: masterDistributorID,
+			DistributorID:       distributorID,
+			UserName:            row.Name,
+			UserEmail:           row.Email,
+			UserPassword:        row.Password,
+			UserPhone:           row.Phone,
+			UserAadharNumber:    row.AadharNumber,
+			UserPanNumber:       row.PanNumber,
+			UserDateOfBirth:     row.DateOfBirth,
+			UserGender:          row.Gender,
+			UserCity:            row.City,
+			UserState:           row.State,
+			UserAddress:         row.Address,
+			UserPincode:         row.Pincode,
+			BusinessName:        row.BusinessName,
+			BusinessType:        row.BusinessType,
+			GSTNumber:           row.GSTNumber,
+		})
+		if err == nil {
+			row.MemberID, row.MemberUniqueID = res.UserID, res.UserUniqueID
+		}
+	}
+
+	// Any other unique column the row clashes with is reported, not fatal
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return []string{"duplicate value for " + pgErr.ConstraintName}, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,75 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+)
+
+// Ping checks that a pooled connection can reach the database
+func (q *Query) Ping(ctx context.Context) error {
+	return q.Pool.Ping(ctx)
+}
+
+func (q *Query) GetOperationalMetrics(ctx context.Context) (*structures.OperationalMetrics, error) {
+	res := structures.OperationalMetrics{
+		PayoutCountsByStatus:  map[string]int64{},
+		PayoutAmountsByStatus: map[string]float64{},
+		WalletFloatByRole:     map[string]float64{},
+	}
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT transaction_status, COUNT(*), COALESCE(SUM(amount), 0)::FLOAT8
+		FROM payout_service
+		GROUP BY transaction_status;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int64
+		var amount float64
+		if err := rows.Scan(&status, &count, &amount); err != nil {
+			return nil, err
+		}
+		res.PayoutCountsByStatus[status] = count
+		res.PayoutAmountsByStatus[status] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := q.Pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(amount), 0)::FLOAT8,
+			COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)::FLOAT8
+		FROM fund_requests
+		WHERE request_status = 'PENDING';
+	`).Scan(
+		&res.PendingFundRequests,
+		&res.PendingFundRequestAmount,
+		&res.OldestPendingFundRequestSecs,
+	); err != nil {
+		return nil, err
+	}
+
+	var admin, masterDistributor, distributor, user float64
+	if err := q.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COALESCE(SUM(admin_wallet_balance), 0)::FLOAT8 FROM admins),
+			(SELECT COALESCE(SUM(master_distributor_wallet_balance), 0)::FLOAT8 FROM master_distributors),
+			(SELECT COALESCE(SUM(distributor_wallet_balance), 0)::FLOAT8 FROM distributors),
+			(SELECT COALESCE(SUM(user_wallet_balance), 0)::FLOAT8 FROM users);
+	`).Scan(&admin, &masterDistributor, &distributor, &user); err != nil {
+		return nil, err
+	}
+	res.WalletFloatByRole["ADMIN"] = admin
+	res.WalletFloatByRole["MASTER_DISTRIBUTOR"] = masterDistributor
+	res.WalletFloatByRole["DISTRIBUTOR"] = distributor
+	res.WalletFloatByRole["USER"] = user
+
+	return &res, nil
+}
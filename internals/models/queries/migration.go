@@ -0,0 +1,97 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// schemaMigration is a change to the schema that must only ever run once,
+// such as dropping a table, unlike the idempotent statements InitializeDatabase
+// runs on every start
+type schemaMigration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// schemaMigrations are applied in order. Released versions must never be
+// edited or renumbered, new ones are appended.
+var schemaMigrations = []schemaMigration{
+	{1, "replace otps with otp_codes", []string{
+		`DROP TABLE IF EXISTS otps;`,
+		`DROP FUNCTION IF EXISTS delete_expired_otps();`,
+	}},
+	// Custom roles made before they had an owner go to the admin whose staff
+	// hold them. Roles held across admins, or by nobody, are left without an
+	// owner and can no longer be used.
+	{2, "give custom roles an owning admin", []string{
+		`UPDATE rbac_roles r SET owner_admin_id = s.admin_id
+		FROM (
+			SELECT role_name, MIN(admin_id::TEXT)::UUID AS admin_id FROM staff_users
+			GROUP BY role_name HAVING COUNT(DISTINCT admin_id) = 1
+		) s
+		WHERE r.role_name = s.role_name AND NOT r.is_system AND r.owner_admin_id IS NULL;`,
+	}},
+	// A policy set while they were global goes on applying to every admin
+	{3, "key two-factor policies by admin", []string{
+		`ALTER TABLE two_factor_policies ADD COLUMN IF NOT EXISTS admin_id UUID REFERENCES admins(admin_id) ON DELETE CASCADE;`,
+		`ALTER TABLE two_factor_policies DROP CONSTRAINT IF EXISTS two_factor_policies_pkey;`,
+		`INSERT INTO two_factor_policies (admin_id, role, enforced, grace_until, updated_by, updated_at)
+		SELECT a.admin_id, p.role, p.enforced, p.grace_until, p.updated_by, p.updated_at
+		FROM two_factor_policies p CROSS JOIN admins a
+		WHERE p.admin_id IS NULL;`,
+		`DELETE FROM two_factor_policies WHERE admin_id IS NULL;`,
+		`ALTER TABLE two_factor_policies ALTER COLUMN admin_id SET NOT NULL;`,
+		`ALTER TABLE two_factor_policies ADD PRIMARY KEY (admin_id, role);`,
+	}},
+	// Likewise for approval policies
+	{4, "key approval policies by admin", []string{
+		`ALTER TABLE approval_policies ADD COLUMN IF NOT EXISTS admin_id UUID REFERENCES admins(admin_id) ON DELETE CASCADE;`,
+		`ALTER TABLE approval_policies DROP CONSTRAINT IF EXISTS approval_policies_pkey;`,
+		`INSERT INTO approval_policies (admin_id, action_type, threshold_amount, ttl_minutes, enabled, updated_by, updated_at)
+		SELECT a.admin_id, p.action_type, p.threshold_amount, p.ttl_minutes, p.enabled, p.updated_by, p.updated_at
+		FROM approval_policies p CROSS JOIN admins a
+		WHERE p.admin_id IS NULL;`,
+		`DELETE FROM approval_policies WHERE admin_id IS NULL;`,
+		`ALTER TABLE approval_policies ALTER COLUMN admin_id SET NOT NULL;`,
+		`ALTER TABLE approval_policies ADD PRIMARY KEY (admin_id, action_type);`,
+	}},
+	// Periods closed while the books were global stay closed for every admin
+	{5, "close periods per admin", []string{
+		`ALTER TABLE closed_periods ADD COLUMN IF NOT EXISTS admin_id UUID REFERENCES admins(admin_id) ON DELETE CASCADE;`,
+		`ALTER TABLE closed_periods DROP CONSTRAINT IF EXISTS closed_periods_pkey;`,
+		`INSERT INTO closed_periods (admin_id, period_end, closed_by, closed_at)
+		SELECT a.admin_id, p.period_end, p.closed_by, p.closed_at
+		FROM closed_periods p CROSS JOIN admins a
+		WHERE p.admin_id IS NULL;`,
+		`DELETE FROM closed_periods WHERE admin_id IS NULL;`,
+		`ALTER TABLE closed_periods ALTER COLUMN admin_id SET NOT NULL;`,
+		`ALTER TABLE closed_periods ADD PRIMARY KEY (admin_id, period_end);`,
+	}},
+}
+
+// applySchemaMigrations runs the migrations the database has not recorded
+// yet. A second instance starting at the same time waits on the version row
+// and then skips it.
+func applySchemaMigrations(ctx context.Context, tx pgx.Tx) error {
+	for _, m := range schemaMigrations {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO schema_migrations (version, description) VALUES ($1, $2)
+			ON CONFLICT (version) DO NOTHING;
+		`, m.version, m.description)
+		if err != nil {
+			return fmt.Errorf("record schema migration %d: %w", m.version, err)
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		for _, statement := range m.statements {
+			if _, err := tx.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("schema migration %d: %w", m.version, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrMpinNotSet     = errors.New("mpin not set")
+	ErrMpinAlreadySet = errors.New("mpin already set")
+)
+
+// MpinAttemptsPerLockout is the number of consecutive wrong MPINs that lock the account
+const MpinAttemptsPerLockout = 3
+
+// mpinLockoutDuration grows with every lockout the user has already served
+func mpinLockoutDuration(failedAttempts int) time.Duration {
+	switch failedAttempts / MpinAttemptsPerLockout {
+	case 0:
+		return 0
+	case 1:
+		return 5 * time.Minute
+	case 2:
+		return 30 * time.Minute
+	case 3:
+		return 2 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// MpinCheck compares the stored MPIN with the one submitted. When the stored
+// value has to be upgraded, for example a legacy plaintext MPIN, rehash holds
+// the value to store instead.
+type MpinCheck func(stored string) (valid bool, rehash string, err error)
+
+// VerifyUserMpin checks an MPIN while holding the user's row lock, so parallel
+// guesses cannot slip past the attempt counter.
+func (q *Query) VerifyUserMpin(ctx context.Context, userID string, check MpinCheck) (*structures.MpinVerificationResult, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var (
+		stored         string
+		failedAttempts int
+		lockedUntil    *time.Time
+	)
+	if err := tx.QueryRow(ctx, `
+		SELECT user_mpin, user_mpin_failed_attempts, user_mpin_locked_until
+		FROM users
+		WHERE user_id = $1
+		FOR UPDATE;
+	`, userID).Scan(&stored, &failedAttempts, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if stored == "" {
+		return nil, ErrMpinNotSet
+	}
+
+	res := structures.MpinVerificationResult{FailedAttempts: failedAttempts}
+	if lockedUntil != nil && lockedUntil.After(time.Now()) {
+		res.Locked = true
+		res.LockedUntil = *lockedUntil
+		return &res, nil
+	}
+
+	valid, rehash, err := check(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	if valid {
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET
+				user_mpin = COALESCE(NULLIF($2, ''), user_mpin),
+				user_mpin_failed_attempts = 0,
+				user_mpin_locked_until = NULL
+			WHERE user_id = $1;
+		`, userID, rehash); err != nil {
+			return nil, fmt.Errorf("reset mpin attempts: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("commit: %w", err)
+		}
+		return &structures.MpinVerificationResult{Valid: true}, nil
+	}
+
+	res.FailedAttempts++
+	res.RemainingAttempts = MpinAttemptsPerLockout - res.FailedAttempts%MpinAttemptsPerLockout
+	var newLock *time.Time
+	if res.FailedAttempts%MpinAttemptsPerLockout == 0 {
+		until := time.Now().Add(mpinLockoutDuration(res.FailedAttempts))
+		newLock = &until
+		res.Locked = true
+		res.LockedUntil = until
+		res.RemainingAttempts = 0
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET
+			user_mpin_failed_attempts = $2,
+			user_mpin_locked_until = $3
+		WHERE user_id = $1;
+	`, userID, res.FailedAttempts, newLock); err != nil {
+		return nil, fmt.Errorf("record mpin failure: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+func (q *Query) GetUserPhone(ctx context.Context, userID string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var phone string
+	err := q.Pool.QueryRow(ctx, `SELECT user_phone FROM users WHERE user_id = $1;`, userID).Scan(&phone)
+	return phone, err
+}
+
+// ResetUserMpin replaces the MPIN, once the caller has confirmed the OTP sent
+// to the user's phone, and clears any lockout.
+func (q *Query) ResetUserMpin(ctx context.Context, userID string, mpinHash string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users SET
+			user_mpin = $2,
+			user_mpin_failed_attempts = 0,
+			user_mpin_locked_until = NULL
+		WHERE user_id = $1;
+	`, userID, mpinHash)
+	if err != nil {
+		return fmt.Errorf("update mpin: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
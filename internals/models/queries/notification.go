@@ -0,0 +1,371 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// walletOwner names the table and columns holding one role's wallet
+type walletOwner struct {
+	table         string
+	idColumn      string
+	phoneColumn   string
+	balanceColumn string
+	nameColumn    string
+}
+
+var walletOwners = map[string]walletOwner{
+	"ADMIN":              {"admins", "admin_id", "admin_phone", "admin_wallet_balance", "admin_name"},
+	"MASTER_DISTRIBUTOR": {"master_distributors", "master_distributor_id", "master_distributor_phone", "master_distributor_wallet_balance", "master_distributor_name"},
+	"DISTRIBUTOR":        {"distributors", "distributor_id", "distributor_phone", "distributor_wallet_balance", "distributor_name"},
+	"USER":               {"users", "user_id", "user_phone", "user_wallet_balance", "user_name"},
+}
+
+// lowBalanceThreshold reads LOW_BALANCE_THRESHOLD, the wallet balance below
+// which a LOW_BALANCE notification is sent, defaulting to 500
+func lowBalanceThreshold() string {
+	value := os.Getenv("LOW_BALANCE_THRESHOLD")
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return "500"
+	}
+	return value
+}
+
+// insertNotificationEvent adds an event to the outbox inside the caller's
+// transaction, so nobody is told about a movement that rolled back
+func insertNotificationEvent(ctx context.Context, tx pgx.Tx, event *structures.NotificationEvent) error {
+	if event.RecipientID == "" {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO notification_events (event_type, recipient_id, recipient_type, data)
+		VALUES ($1, $2, $3, $4);
+	`, event.EventType, event.RecipientID, event.RecipientType, event.Data); err != nil {
+		return fmt.Errorf("insert notification event: %w", err)
+	}
+	return nil
+}
+
+// walletOwnerIDByPhone returns the id of the wallet owner with the phone
+// number, or an empty id when there is none
+func walletOwnerIDByPhone(ctx context.Context, tx pgx.Tx, ownerType string, phone string) (string, error) {
+	owner := walletOwners[ownerType]
+	var id string
+	err := tx.QueryRow(ctx, fmt.Sprintf(`SELECT %s::TEXT FROM %s WHERE %s = $1;`, owner.idColumn, owner.table, owner.phoneColumn), phone).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return id, err
+}
+
+// recordWalletEvent tells the owner their wallet was credited or debited by
+// amount, with the balance left after the movement. Debits that take the
+// balance below the low balance threshold also raise LOW_BALANCE. Unknown
+// owners are skipped.
+func recordWalletEvent(ctx context.Context, tx pgx.Tx, eventType string, ownerType string, ownerID string, amount string, reason string) error {
+	if ownerID == "" {
+		return nil
+	}
+	owner := walletOwners[ownerType]
+	var balance string
+	err := tx.QueryRow(ctx, fmt.Sprintf(`SELECT %s::TEXT FROM %s WHERE %s = $1;`, owner.balanceColumn, owner.table, owner.idColumn), ownerID).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Nobody to tell, the movement itself decides whether that is an error
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read balance for notification: %w", err)
+	}
+	if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+		EventType:     eventType,
+		RecipientID:   ownerID,
+		RecipientType: ownerType,
+		Data:          map[string]string{"amount": amount, "balance": balance, "reason": reason},
+	}); err != nil {
+		return err
+	}
+	if eventType == pkg.EventWalletDebited {
+		return recordLowBalance(ctx, tx, ownerType, ownerID, amount)
+	}
+	return nil
+}
+
+// recordLowBalance raises LOW_BALANCE when a debit of amount has just taken
+// the owner's balance below the threshold. Balances that were already low
+// do not raise it again.
+func recordLowBalance(ctx context.Context, tx pgx.Tx, ownerType string, ownerID string, amount string) error {
+	owner := walletOwners[ownerType]
+	threshold := lowBalanceThreshold()
+	var balance string
+	var crossed bool
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT %[1]s::TEXT, (%[1]s < $2::NUMERIC AND %[1]s + $3::NUMERIC >= $2::NUMERIC)
+		FROM %[2]s WHERE %[3]s = $1;
+	`, owner.balanceColumn, owner.table, owner.idColumn), ownerID, threshold, amount).Scan(&balance, &crossed); err != nil {
+		return fmt.Errorf("check low balance: %w", err)
+	}
+	if !crossed {
+		return nil
+	}
+	return insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+		EventType:     pkg.EventLowBalance,
+		RecipientID:   ownerID,
+		RecipientType: ownerType,
+		Data:          map[string]string{"balance": balance, "threshold": threshold},
+	})
+}
+
+// payoutStatusText is how each payout status reads in a notification
+var payoutStatusText = map[string]string{
+	"PENDING":  "pending",
+	"SUCCESS":  "successful",
+	"FAILED":   "failed",
+	"REFUND":   "refunded",
+	"RETURNED": "returned by the bank",
+	"REVERSED": "reversed",
+}
+
+// recordPayoutStatus tells the retailer their payout moved to status
+func recordPayoutStatus(ctx context.Context, tx pgx.Tx, payoutTransactionID string, status string) error {
+	var userID, amount, beneficiaryName string
+	if err := tx.QueryRow(ctx, `
+		SELECT user_id::TEXT, amount::TEXT, beneficiary_name
+		FROM payout_service
+		WHERE payout_transaction_id = $1;
+	`, payoutTransactionID).Scan(&userID, &amount, &beneficiaryName); err != nil {
+		return fmt.Errorf("read payout for notification: %w", err)
+	}
+	return insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+		EventType:     pkg.EventPayoutStatusChanged,
+		RecipientID:   userID,
+		RecipientType: "USER",
+		Data: map[string]string{
+			"payout_transaction_id": payoutTransactionID,
+			"amount":                amount,
+			"beneficiary_name":      beneficiaryName,
+			"status":                payoutStatusText[status],
+		},
+	})
+}
+
+// ClaimNotificationEvents marks up to limit undelivered events as dispatched
+// and returns them with the recipient's contacts, language and channels.
+// Concurrent dispatchers never claim the same event.
+func (q *Query) ClaimNotificationEvents(ctx context.Context, limit int) ([]structures.NotificationDelivery, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT
+			e.event_id,
+			e.event_type,
+			e.recipient_id::TEXT,
+			e.recipient_type,
+			e.data,
+			COALESCE(u.user_phone, d.distributor_phone, m.master_distributor_phone, a.admin_phone, ''),
+			COALESCE(u.user_email, d.distributor_email, m.master_distributor_email, a.admin_email, ''),
+			COALESCE(s.language, '')
+		FROM notification_events e
+		LEFT JOIN users u ON e.recipient_type = 'USER' AND u.user_id = e.recipient_id
+		LEFT JOIN distributors d ON e.recipient_type = 'DISTRIBUTOR' AND d.distributor_id = e.recipient_id
+		LEFT JOIN master_distributors m ON e.recipient_type = 'MASTER_DISTRIBUTOR' AND m.master_distributor_id = e.recipient_id
+		LEFT JOIN admins a ON e.recipient_type = 'ADMIN' AND a.admin_id = e.recipient_id
+		LEFT JOIN notification_settings s ON s.owner_id = e.recipient_id
+		WHERE e.dispatched_at IS NULL
+		ORDER BY e.event_id
+		LIMIT $1
+		FOR UPDATE OF e SKIP LOCKED;
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []structures.NotificationDelivery
+	for rows.Next() {
+		var d structures.NotificationDelivery
+		if err := rows.Scan(&d.EventID, &d.EventType, &d.RecipientID, &d.RecipientType, &d.Data, &d.Phone, &d.Email, &d.Language); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	eventIDs := make([]int64, 0, len(deliveries))
+	ownerIDs := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		eventIDs = append(eventIDs, d.EventID)
+		ownerIDs = append(ownerIDs, d.RecipientID)
+	}
+
+	preferences := map[string]bool{}
+	rows, err = tx.Query(ctx, `
+		SELECT owner_id::TEXT, event_type, channel, enabled
+		FROM notification_preferences
+		WHERE owner_id = ANY($1::UUID[]);
+	`, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var ownerID, eventType, channel string
+		var enabled bool
+		if err := rows.Scan(&ownerID, &eventType, &channel, &enabled); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		preferences[ownerID+"/"+eventType+"/"+channel] = enabled
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range deliveries {
+		d := &deliveries[i]
+		for _, channel := range pkg.NotificationChannels {
+			enabled, ok := preferences[d.RecipientID+"/"+d.EventType+"/"+channel]
+			if !ok {
+				enabled = pkg.DefaultChannelEnabled(channel)
+			}
+			if enabled {
+				d.Channels = append(d.Channels, channel)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE notification_events SET dispatched_at = NOW() WHERE event_id = ANY($1);
+	`, eventIDs); err != nil {
+		return nil, fmt.Errorf("mark events dispatched: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ReleaseNotificationEvents puts claimed events back in the outbox so a later
+// dispatch picks them up again
+func (q *Query) ReleaseNotificationEvents(ctx context.Context, eventIDs []int64) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	_, err := q.Pool.Exec(ctx, `
+		UPDATE notification_events SET dispatched_at = NULL WHERE event_id = ANY($1);
+	`, eventIDs)
+	return err
+}
+
+// PurgeNotificationEvents deletes events dispatched longer than retention ago
+func (q *Query) PurgeNotificationEvents(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tag, err := q.Pool.Exec(ctx, `
+		DELETE FROM notification_events
+		WHERE dispatched_at < NOW() - make_interval(secs => $1);
+	`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetNotificationPreferences returns the owner's setting for every event and
+// channel, filling the defaults in where nothing was chosen
+func (q *Query) GetNotificationPreferences(ctx context.Context, ownerID string) (*structures.NotificationPreferencesResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	res := structures.NotificationPreferencesResponse{OwnerID: ownerID, Language: pkg.DefaultLanguage}
+	err := q.Pool.QueryRow(ctx, `SELECT language FROM notification_settings WHERE owner_id = $1;`, ownerID).Scan(&res.Language)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT event_type, channel, enabled FROM notification_preferences WHERE owner_id = $1;
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	chosen := map[string]bool{}
+	for rows.Next() {
+		var eventType, channel string
+		var enabled bool
+		if err := rows.Scan(&eventType, &channel, &enabled); err != nil {
+			return nil, err
+		}
+		chosen[eventType+"/"+channel] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, eventType := range pkg.NotificationEvents {
+		for _, channel := range pkg.NotificationChannels {
+			enabled, ok := chosen[eventType+"/"+channel]
+			if !ok {
+				enabled = pkg.DefaultChannelEnabled(channel)
+			}
+			res.Preferences = append(res.Preferences, structures.NotificationPreference{
+				EventType: eventType,
+				Channel:   channel,
+				Enabled:   enabled,
+			})
+		}
+	}
+	return &res, nil
+}
+
+func (q *Query) SetNotificationPreferences(ctx context.Context, req *structures.NotificationPreferencesRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if req.Language != "" {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO notification_settings (owner_id, language)
+			VALUES ($1, $2)
+			ON CONFLICT (owner_id) DO UPDATE SET language = EXCLUDED.language, updated_at = NOW();
+		`, req.OwnerID, req.Language); err != nil {
+			return fmt.Errorf("save notification language: %w", err)
+		}
+	}
+	for _, preference := range req.Preferences {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO notification_preferences (owner_id, event_type, channel, enabled)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (owner_id, event_type, channel) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW();
+		`, req.OwnerID, preference.EventType, preference.Channel, preference.Enabled); err != nil {
+			return fmt.Errorf("save notification preference: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
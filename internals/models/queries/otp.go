@@ -0,0 +1,192 @@
+package queries
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidOTP          = errors.New("invalid or expired otp")
+	ErrOTPAttemptsExceeded = errors.New("otp attempts exceeded")
+)
+
+// OTPThrottleError is returned when a new OTP is refused because of the
+// resend cooldown or an hourly cap.
+type OTPThrottleError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *OTPThrottleError) Error() string {
+	return fmt.Sprintf("otp throttled: %s, retry after %s", e.Reason, e.RetryAfter)
+}
+
+// IssueOTP stores a new OTP hash for the phone and purpose, replacing any code
+// still outstanding. Requests for the same phone are serialized so the
+// cooldown and the hourly caps hold under concurrent requests.
+func (q *Query) IssueOTP(ctx context.Context, issue *structures.OTPIssue) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('otp:' || $1));`, issue.Phone); err != nil {
+		return fmt.Errorf("lock phone: %w", err)
+	}
+
+	var sinceLast *float64
+	if err := tx.QueryRow(ctx, `
+		SELECT EXTRACT(EPOCH FROM NOW() - MAX(created_at))::FLOAT8
+		FROM otp_codes
+		WHERE phone = $1 AND purpose = $2;
+	`, issue.Phone, issue.Purpose).Scan(&sinceLast); err != nil {
+		return fmt.Errorf("check cooldown: %w", err)
+	}
+	if sinceLast != nil {
+		if wait := issue.ResendCooldown - time.Duration(*sinceLast*float64(time.Second)); wait > 0 {
+			return &OTPThrottleError{Reason: "resend cooldown", RetryAfter: wait}
+		}
+	}
+
+	var phoneCount int
+	var phoneOldest *float64
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*), EXTRACT(EPOCH FROM NOW() - MIN(created_at))::FLOAT8
+		FROM otp_codes
+		WHERE phone = $1 AND created_at > NOW() - INTERVAL '1 hour';
+	`, issue.Phone).Scan(&phoneCount, &phoneOldest); err != nil {
+		return fmt.Errorf("check phone cap: %w", err)
+	}
+	if phoneCount >= issue.PhoneHourlyCap {
+		return &OTPThrottleError{Reason: "phone hourly limit", RetryAfter: hourlyCapWait(phoneOldest)}
+	}
+
+	if issue.IPAddress != "" {
+		var ipCount int
+		var ipOldest *float64
+		if err := tx.QueryRow(ctx, `
+			SELECT COUNT(*), EXTRACT(EPOCH FROM NOW() - MIN(created_at))::FLOAT8
+			FROM otp_codes
+			WHERE ip_address = $1 AND created_at > NOW() - INTERVAL '1 hour';
+		`, issue.IPAddress).Scan(&ipCount, &ipOldest); err != nil {
+			return fmt.Errorf("check ip cap: %w", err)
+		}
+		if ipCount >= issue.IPHourlyCap {
+			return &OTPThrottleError{Reason: "ip hourly limit", RetryAfter: hourlyCapWait(ipOldest)}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE otp_codes SET expires_at = NOW()
+		WHERE phone = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW();
+	`, issue.Phone, issue.Purpose); err != nil {
+		return fmt.Errorf("expire previous otps: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO otp_codes (phone, purpose, code_hash, max_attempts, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW() + make_interval(secs => $6));
+	`, issue.Phone, issue.Purpose, issue.CodeHash, issue.MaxAttempts, issue.IPAddress, issue.TTL.Seconds()); err != nil {
+		return fmt.Errorf("insert otp: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// hourlyCapWait is the time until the oldest code in the hourly window drops out of it
+func hourlyCapWait(oldestSecs *float64) time.Duration {
+	if oldestSecs == nil {
+		return time.Hour
+	}
+	wait := time.Hour - time.Duration(*oldestSecs*float64(time.Second))
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+// VerifyOTP checks a code hash against the latest live OTP for the phone and
+// purpose. A match consumes the OTP; a miss uses up one attempt, and the
+// OTP is expired once its attempts run out.
+func (q *Query) VerifyOTP(ctx context.Context, phone string, purpose string, codeHash string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var (
+		otpID       string
+		storedHash  string
+		attempts    int
+		maxAttempts int
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT otp_id::TEXT, code_hash, attempts, max_attempts
+		FROM otp_codes
+		WHERE phone = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE;
+	`, phone, purpose).Scan(&otpID, &storedHash, &attempts, &maxAttempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidOTP
+	}
+	if err != nil {
+		return fmt.Errorf("load otp: %w", err)
+	}
+	if attempts >= maxAttempts {
+		return ErrOTPAttemptsExceeded
+	}
+
+	if subtle.ConstantTimeCompare([]byte(storedHash), []byte(codeHash)) == 1 {
+		if _, err := tx.Exec(ctx, `UPDATE otp_codes SET consumed_at = NOW() WHERE otp_id = $1;`, otpID); err != nil {
+			return fmt.Errorf("consume otp: %w", err)
+		}
+		return tx.Commit(ctx)
+	}
+
+	attempts++
+	if _, err := tx.Exec(ctx, `
+		UPDATE otp_codes SET
+			attempts = $2,
+			expires_at = CASE WHEN $2 >= max_attempts THEN NOW() ELSE expires_at END
+		WHERE otp_id = $1;
+	`, otpID, attempts); err != nil {
+		return fmt.Errorf("record otp attempt: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	if attempts >= maxAttempts {
+		return ErrOTPAttemptsExceeded
+	}
+	return ErrInvalidOTP
+}
+
+// PurgeOTPs deletes codes older than retention. Codes are kept past their
+// expiry for a while because the hourly caps are counted from them.
+func (q *Query) PurgeOTPs(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tag, err := q.Pool.Exec(ctx, `
+		DELETE FROM otp_codes WHERE created_at < NOW() - make_interval(secs => $1);
+	`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,142 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// passwordOwner names the table and columns holding one role's credentials
+type passwordOwner struct {
+	table          string
+	idColumn       string
+	phoneColumn    string
+	emailColumn    string
+	passwordColumn string
+}
+
+var passwordOwners = map[string]passwordOwner{
+	"ADMIN":              {"admins", "admin_id", "admin_phone", "admin_email", "admin_password"},
+	"MASTER_DISTRIBUTOR": {"master_distributors", "master_distributor_id", "master_distributor_phone", "master_distributor_email", "master_distributor_password"},
+	"DISTRIBUTOR":        {"distributors", "distributor_id", "distributor_phone", "distributor_email", "distributor_password"},
+	"USER":               {"users", "user_id", "user_phone", "user_email", "user_password"},
+	pkg.StaffRole:        {"staff_users", "staff_id", "staff_phone", "staff_email", "staff_password"},
+}
+
+func getPasswordOwner(role string) (passwordOwner, error) {
+	owner, ok := passwordOwners[role]
+	if !ok {
+		return passwordOwner{}, fmt.Errorf("unknown role %q", role)
+	}
+	return owner, nil
+}
+
+// GetPasswordAccountByLogin finds the account of the role signing in with the
+// email address or phone number
+func (q *Query) GetPasswordAccountByLogin(ctx context.Context, role string, login string) (*structures.PasswordAccount, error) {
+	owner, err := getPasswordOwner(role)
+	if err != nil {
+		return nil, err
+	}
+	return q.getPasswordAccount(ctx, role, fmt.Sprintf(`%s = $1 OR %s = $1`, owner.emailColumn, owner.phoneColumn), login)
+}
+
+// GetPasswordAccountByID loads the credentials of the role's account
+func (q *Query) GetPasswordAccountByID(ctx context.Context, role string, id string) (*structures.PasswordAccount, error) {
+	owner, err := getPasswordOwner(role)
+	if err != nil {
+		return nil, err
+	}
+	return q.getPasswordAccount(ctx, role, fmt.Sprintf(`%s = $1`, owner.idColumn), id)
+}
+
+func (q *Query) getPasswordAccount(ctx context.Context, role string, where string, arg string) (*structures.PasswordAccount, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	owner := passwordOwners[role]
+	res := structures.PasswordAccount{Role: role}
+	err := q.Pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT %s::TEXT, %s, %s, %s FROM %s WHERE %s;
+	`, owner.idColumn, owner.phoneColumn, owner.emailColumn, owner.passwordColumn, owner.table, where), arg).Scan(
+		&res.ID,
+		&res.Phone,
+		&res.Email,
+		&res.PasswordHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetPasswordHistory returns the account's most recent password hashes,
+// newest first
+func (q *Query) GetPasswordHistory(ctx context.Context, ownerID string, limit int) ([]string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT password_hash FROM password_history
+		WHERE owner_id = $1
+		ORDER BY created_at DESC, history_id DESC
+		LIMIT $2;
+	`, ownerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// UpdatePassword stores a new password hash for the account, records it in
+// the password history and trims the history to keep entries.
+func (q *Query) UpdatePassword(ctx context.Context, role string, id string, passwordHash string, keep int, audit *structures.AuditLogEntry) error {
+	owner, err := getPasswordOwner(role)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET %s = $2 WHERE %s = $1;`, owner.table, owner.passwordColumn, owner.idColumn), id, passwordHash)
+	if err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO password_history (owner_id, owner_type, password_hash)
+		VALUES ($1, $2, $3);
+	`, id, role, passwordHash); err != nil {
+		return fmt.Errorf("insert password history: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM password_history
+		WHERE owner_id = $1
+		AND history_id NOT IN (
+			SELECT history_id FROM password_history
+			WHERE owner_id = $1
+			ORDER BY created_at DESC, history_id DESC
+			LIMIT $2
+		);
+	`, id, keep); err != nil {
+		return fmt.Errorf("trim password history: %w", err)
+	}
+
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -2,18 +2,25 @@ package queries
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
 )
 
-func (q *Query) CheckUserBalance(userId string, amount string, commission string) (bool, error) {
+// CheckUserBalance tells a retailer early whether a payout can be afforded.
+// InitilizePayoutRequest checks again when it debits the wallet.
+func (q *Query) CheckUserBalance(ctx context.Context, userId string, amount string, commission string) (bool, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var hasBalance bool
 	query := `SELECT 
     CASE 
-        WHEN user_wallet_balance >= ($2::numeric + $3::numeric) THEN TRUE
+        WHEN user_wallet_balance + available_credit(user_id) >= ($2::numeric + $3::numeric) THEN TRUE
         ELSE FALSE
     END AS has_sufficient_balance
 FROM 
@@ -21,29 +28,27 @@ FROM
 WHERE 
     user_id = $1;
 `
-	err := q.Pool.QueryRow(context.Background(), query, userId, amount, commission).Scan(&hasBalance)
+	err := q.Pool.QueryRow(ctx, query, userId, amount, commission).Scan(&hasBalance)
 	return hasBalance, err
 }
 
-func (q *Query) CheckMpin(userID string, mpin string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1 AND user_mpin = $2)`
-	var hasMpin bool
-	err := q.Pool.QueryRow(context.Background(), query, userID, mpin).Scan(&hasMpin)
-	return hasMpin, err
-}
-
 func (q *Query) InitilizePayoutRequest(
+	ctx context.Context,
 	req *structures.PayoutInitilizationRequest,
 ) (*structures.PayoutApiRequest, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// One span around the whole transaction, the statements are traced below it
+	ctx, span := pkg.StartSpan(ctx, "InitilizePayoutRequest")
+	defer span.End()
+
+	ctx, cancel := q.writeContext(ctx)
 	defer cancel()
 
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	/* -------------------------------- USER HIERARCHY -------------------------------- */
 
@@ -197,8 +202,9 @@ func (q *Query) InitilizePayoutRequest(
 
 	/* -------------------------------- TOTAL DEBIT -------------------------------- */
 
+	// Rounded to the paisa so the hold releases exactly what left the wallet
 	const calcTotalDebit = `
-		SELECT ($1::NUMERIC + ($2::NUMERIC * $3::NUMERIC))::NUMERIC;
+		SELECT ROUND($1::NUMERIC + ($2::NUMERIC * $3::NUMERIC), 2)::NUMERIC;
 	`
 
 	var totalDebit string
@@ -214,14 +220,27 @@ func (q *Query) InitilizePayoutRequest(
 
 	/* -------------------------------- UPDATE USER WALLET -------------------------------- */
 
+	// The balance is checked again here, a payout made since CheckUserBalance
+	// may have taken it
 	const updateUserWallet = `
 		UPDATE users
 		SET user_wallet_balance = user_wallet_balance - $1::NUMERIC
-		WHERE user_id=$2;
+		WHERE user_id=$2 AND user_wallet_balance + available_credit(user_id) >= $1::NUMERIC;
 	`
 
-	if _, err := tx.Exec(ctx, updateUserWallet, totalDebit, req.UserID); err != nil {
-		return nil, err
+	// Rows of a bulk payout were paid for when the batch reserved its total
+	if req.BatchID != "" {
+		if err := drawPayoutBatchReserve(ctx, tx, req.BatchID, totalDebit); err != nil {
+			return nil, err
+		}
+	} else {
+		tag, err := tx.Exec(ctx, updateUserWallet, totalDebit, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, ErrInsufficientBalance
+		}
 	}
 
 	var afterBalance string
@@ -229,6 +248,12 @@ func (q *Query) InitilizePayoutRequest(
 		return nil, err
 	}
 
+	if req.BatchID == "" {
+		if err := recordLowBalance(ctx, tx, "USER", req.UserID, totalDebit); err != nil {
+			return nil, err
+		}
+	}
+
 	/* -------------------------------- PAYOUT REPORT -------------------------------- */
 
 	const insertPayoutReport = `
@@ -250,44 +275,35 @@ func (q *Query) InitilizePayoutRequest(
 		return nil, err
 	}
 
-	/* -------------------------------- DISTRIBUTION -------------------------------- */
-
-	const updateAdminWallet = `
-		UPDATE admins
-		SET admin_wallet_balance = admin_wallet_balance + ($1::NUMERIC * $2::NUMERIC)
-		WHERE admin_id=$3;
-	`
-
-	if _, err := tx.Exec(ctx, updateAdminWallet, totalCommission, commission.Admin, userDetails.adminID); err != nil {
+	/* -------------------------------- HOLD -------------------------------- */
+
+	// The debit is only held until the provider settles the payout. The
+	// commission shares are paid out when the hold is captured.
+	if err := placePayoutHold(ctx, tx, &payoutHold{
+		userID:              req.UserID,
+		payoutTransactionID: res.PartnerRequestID,
+		amount:              totalDebit,
+		adminID:             userDetails.adminID,
+		masterDistributorID: userDetails.masterDistributorID,
+		distributorID:       userDetails.distributorID,
+		totalCommission:     totalCommission,
+		adminRate:           commission.Admin,
+		masterRate:          commission.MasterDistributor,
+		distributorRate:     commission.Distributor,
+	}); err != nil {
 		return nil, err
 	}
 
-	const updateMasterDistributorWallet = `
-		UPDATE master_distributors
-		SET master_distributor_wallet_balance =
-			master_distributor_wallet_balance + ($1::NUMERIC * $2::NUMERIC)
-		WHERE master_distributor_id=$3;
-	`
+	/* -------------------------------- AUTO REPLENISHMENT -------------------------------- */
 
-	if _, err := tx.Exec(ctx, updateMasterDistributorWallet, totalCommission, commission.MasterDistributor, userDetails.masterDistributorID); err != nil {
-		return nil, err
-	}
-
-	const updateDistributorWallet = `
-		UPDATE distributors
-		SET distributor_wallet_balance =
-			distributor_wallet_balance + ($1::NUMERIC * $2::NUMERIC)
-		WHERE distributor_id=$3;
-	`
-
-	if _, err := tx.Exec(ctx, updateDistributorWallet, totalCommission, commission.Distributor, userDetails.distributorID); err != nil {
-		return nil, err
+	if req.BatchID == "" {
+		replenishAfterPayout(ctx, tx, req.UserID, res.PartnerRequestID)
 	}
 
 	return &res, tx.Commit(ctx)
 }
 
-func (q *Query) FinalPayout(req *structures.PayoutFinal) error {
+func (q *Query) FinalPayout(ctx context.Context, req *structures.PayoutFinal) error {
 	query := `
 		UPDATE payout_service SET
 		operator_transaction_id=$1, order_id=$2,
@@ -295,28 +311,52 @@ func (q *Query) FinalPayout(req *structures.PayoutFinal) error {
 		WHERE payout_transaction_id=$4;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, span := pkg.StartSpan(ctx, "FinalPayout")
+	defer span.End()
+
+	var status string
+	switch req.Status {
+	case 1:
+		status = "SUCCESS"
+	case 2:
+		status = "PENDING"
+	case 3:
+		status = "FAILED"
+	default:
+		return nil
+	}
+
+	ctx, cancel := q.writeContext(ctx)
 	defer cancel()
 
-	if req.Status == 1 {
-		if _, err := q.Pool.Exec(ctx, query, req.OpertaorTransactionID, req.OrderID, "SUCCESS", req.PartnerRequestID); err != nil {
-			return err
-		}
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	if req.Status == 2 {
-		if _, err := q.Pool.Exec(ctx, query, req.OpertaorTransactionID, req.OrderID, "PENDING", req.PartnerRequestID); err != nil {
-			return err
-		}
+	defer rollbackTx(ctx, tx)
+
+	if _, err := tx.Exec(ctx, query, req.OpertaorTransactionID, req.OrderID, status, req.PartnerRequestID); err != nil {
+		return err
+	}
+
+	if err := settlePayoutHold(ctx, tx, req.PartnerRequestID, status); err != nil {
+		return err
 	}
-	if req.Status == 3 {
-		if _, err := q.Pool.Exec(ctx, query, req.OpertaorTransactionID, req.OrderID, "FAILED", req.PartnerRequestID); err != nil {
+
+	// The retailer already knows a payout they just made is pending
+	if status != "PENDING" {
+		if err := recordPayoutStatus(ctx, tx, req.PartnerRequestID, status); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	return tx.Commit(ctx)
 }
 
-func (q *Query) GetPayoutTransactions(userId string) (*[]structures.GetPayoutLogs, error) {
+func (q *Query) GetPayoutTransactions(ctx context.Context, userId string) (*[]structures.GetPayoutLogs, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	query := `
 SELECT 
     ps.payout_transaction_id,
@@ -333,7 +373,7 @@ SELECT
 FROM payout_service ps
 WHERE ps.user_id = $1;
 	`
-	res, err := q.Pool.Query(context.Background(), query, userId)
+	res, err := q.Pool.Query(ctx, query, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -368,13 +408,15 @@ WHERE ps.user_id = $1;
 	return &payoutTransactions, nil
 }
 
-func (q *Query) DeductUserBalanceForVerification(userId string) error {
-	ctx := context.Background()
+func (q *Query) DeductUserBalanceForVerification(ctx context.Context, userId string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to start transaction: %w", err)
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	query := `
 		WITH admin_user AS (
@@ -386,13 +428,25 @@ func (q *Query) DeductUserBalanceForVerification(userId string) error {
 		deduct AS (
 			UPDATE users
 			SET user_wallet_balance = user_wallet_balance - 3
-			WHERE user_id = $1 AND user_wallet_balance >= 3
+			WHERE user_id = $1 AND user_wallet_balance + available_credit(user_id) >= 3
 			RETURNING 3 AS amount_deducted
 		)
 		UPDATE admins
 		SET admin_wallet_balance = admin_wallet_balance + (SELECT amount_deducted FROM deduct)
 		WHERE admin_id = (SELECT admin_id FROM admin_user);
 	`
+	// The fee goes into the history like any other movement between wallets
+	recordFee := `
+		INSERT INTO transactions (
+			transactor_id, receiver_id, transactor_name, receiver_name, transactor_type, receiver_type,
+			transaction_type, transaction_service, amount, transaction_status, remarks
+		)
+		SELECT u.user_id, a.admin_id, u.user_name, a.admin_name, 'USER', 'ADMIN',
+			'DEBIT', 'VERIFICATION', 3, 'SUCCESS', 'BENEFICIARY VERIFICATION FEE'
+		FROM users u
+		JOIN admins a ON a.admin_id = u.admin_id
+		WHERE u.user_id = $1;
+	`
 
 	// Execute the combined transaction query
 	cmdTag, err := tx.Exec(ctx, query, userId)
@@ -405,6 +459,10 @@ func (q *Query) DeductUserBalanceForVerification(userId string) error {
 		return fmt.Errorf("insufficient balance")
 	}
 
+	if _, err := tx.Exec(ctx, recordFee, userId); err != nil {
+		return fmt.Errorf("record verification fee: %w", err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -412,9 +470,12 @@ func (q *Query) DeductUserBalanceForVerification(userId string) error {
 	return nil
 }
 
-func (q *Query) PayoutTransactionRefund(req *structures.PayoutRefund) error {
+func (q *Query) PayoutTransactionRefund(ctx context.Context, req *structures.PayoutRefund) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	getUserDetailsQuery := `
-		SELECT user_id, amount, commision FROM payout_service WHERE payout_transaction_id=$1;
+		SELECT user_id, amount, commision, transaction_status FROM payout_service WHERE payout_transaction_id=$1 FOR UPDATE;
 	`
 	getAllIdsFromUser := `
 		SELECT master_distributor_id, distributor_id, admin_id FROM users WHERE user_id=$1;
@@ -452,45 +513,119 @@ func (q *Query) PayoutTransactionRefund(req *structures.PayoutRefund) error {
 		WHERE payout_transaction_id=$1;
 	`
 
-	tx, err := q.Pool.Begin(context.Background())
+	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to refund database error")
 	}
-	defer func() { _ = tx.Rollback(context.Background()) }()
+	defer rollbackTx(ctx, tx)
 
 	var transactionDetails struct {
 		UserID     string
 		Amount     string
 		Commission string
+		Status     string
 	}
-	if err := tx.QueryRow(context.Background(), getUserDetailsQuery, req.TransactionID).Scan(
+	if err := tx.QueryRow(ctx, getUserDetailsQuery, req.TransactionID).Scan(
 		&transactionDetails.UserID,
 		&transactionDetails.Amount,
 		&transactionDetails.Commission,
+		&transactionDetails.Status,
 	); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to execuite transaction")
 	}
 
+	// A returned payout was already paid back by its return
+	if transactionDetails.Status == pkg.PayoutReturned || transactionDetails.Status == pkg.PayoutReversed {
+		return ErrPayoutAlreadyReturned
+	}
+	if transactionDetails.Status == "REFUND" {
+		return fmt.Errorf("payout already refunded")
+	}
+
+	// A payout still on hold has paid no commission yet, so refunding it
+	// only releases the hold
+	var holdStatus string
+	err = tx.QueryRow(ctx, `SELECT hold_status FROM wallet_holds WHERE payout_transaction_id=$1 FOR UPDATE;`, req.TransactionID).Scan(&holdStatus)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+		return fmt.Errorf("failed to read payout hold")
+	}
+	switch holdStatus {
+	case pkg.HoldReleased, pkg.HoldRefunded:
+		return fmt.Errorf("payout amount already released")
+	case pkg.HoldCaptured:
+		// A captured payout paid the shares stored on its hold, so those are
+		// what is taken back
+		err := refundPayoutHold(ctx, tx, req.TransactionID)
+		if errors.Is(err, ErrInsufficientBalance) {
+			return err
+		}
+		if errors.Is(asPeriodClosed(err), ErrPeriodClosed) {
+			return ErrPeriodClosed
+		}
+		if err != nil {
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to refund captured payout")
+		}
+		if _, err := tx.Exec(ctx, updateTransactionStatus, req.TransactionID); err != nil {
+			if errors.Is(asPeriodClosed(err), ErrPeriodClosed) {
+				return ErrPeriodClosed
+			}
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to update transaction status")
+		}
+		if err := recordPayoutStatus(ctx, tx, req.TransactionID, "REFUND"); err != nil {
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to record refund notification")
+		}
+		if err := tx.Commit(ctx); err != nil {
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to commit transaction")
+		}
+		return nil
+	case pkg.HoldHeld:
+		if err := settlePayoutHold(ctx, tx, req.TransactionID, "FAILED"); err != nil {
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to release payout hold")
+		}
+		if _, err := tx.Exec(ctx, updateTransactionStatus, req.TransactionID); err != nil {
+			if errors.Is(asPeriodClosed(err), ErrPeriodClosed) {
+				return ErrPeriodClosed
+			}
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to update transaction status")
+		}
+		if err := recordPayoutStatus(ctx, tx, req.TransactionID, "REFUND"); err != nil {
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to record refund notification")
+		}
+		if err := tx.Commit(ctx); err != nil {
+			slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+			return fmt.Errorf("failed to commit transaction")
+		}
+		return nil
+	}
+
 	var usersDetails struct {
 		MasterDistributorID string
 		DistributorID       string
 		AdminID             string
 	}
 
-	if err := tx.QueryRow(context.Background(), getAllIdsFromUser, transactionDetails.UserID).Scan(
+	if err := tx.QueryRow(ctx, getAllIdsFromUser, transactionDetails.UserID).Scan(
 		&usersDetails.MasterDistributorID,
 		&usersDetails.DistributorID,
 		&usersDetails.AdminID,
 	); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to get user details")
 	}
 
-	aCut, err := tx.Exec(context.Background(), adminCutAmount, transactionDetails.Commission, usersDetails.AdminID)
+	aCut, err := tx.Exec(ctx, adminCutAmount, transactionDetails.Commission, usersDetails.AdminID)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to execuite admin deduct transaction")
 	}
 
@@ -498,9 +633,9 @@ func (q *Query) PayoutTransactionRefund(req *structures.PayoutRefund) error {
 		return fmt.Errorf("insufficient balance in admin")
 	}
 
-	mCut, err := tx.Exec(context.Background(), masterDistributorCutAmount, transactionDetails.Commission, usersDetails.MasterDistributorID)
+	mCut, err := tx.Exec(ctx, masterDistributorCutAmount, transactionDetails.Commission, usersDetails.MasterDistributorID)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to execuite md deduct transaction")
 	}
 
@@ -508,9 +643,9 @@ func (q *Query) PayoutTransactionRefund(req *structures.PayoutRefund) error {
 		return fmt.Errorf("insufficient balance in md")
 	}
 
-	dCut, err := tx.Exec(context.Background(), distributorCutAmount, transactionDetails.Commission, usersDetails.DistributorID)
+	dCut, err := tx.Exec(ctx, distributorCutAmount, transactionDetails.Commission, usersDetails.DistributorID)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to execuite md deduct transaction")
 	}
 
@@ -518,44 +653,112 @@ func (q *Query) PayoutTransactionRefund(req *structures.PayoutRefund) error {
 		return fmt.Errorf("insufficient balance in distributor")
 	}
 
-	_, err = tx.Exec(context.Background(), addAmountToUser, transactionDetails.Commission, transactionDetails.UserID, transactionDetails.Amount)
+	_, err = tx.Exec(ctx, addAmountToUser, transactionDetails.Commission, transactionDetails.UserID, transactionDetails.Amount)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to add amount to user")
 	}
 
-	_, err = tx.Exec(context.Background(), updateTransactionStatus, req.TransactionID)
+	// The cuts and the credit above land on the wallets rounded to the paisa
+	var refunded string
+	shares := []payoutRefundShare{
+		{"ADMIN", usersDetails.AdminID, ""},
+		{"MASTER_DISTRIBUTOR", usersDetails.MasterDistributorID, ""},
+		{"DISTRIBUTOR", usersDetails.DistributorID, ""},
+	}
+	if err := tx.QueryRow(ctx, `
+		SELECT ROUND($1::NUMERIC * 0.2917, 2)::TEXT, ROUND($1::NUMERIC * 0.0417, 2)::TEXT,
+			ROUND($1::NUMERIC * 0.1667, 2)::TEXT, (ROUND($1::NUMERIC * 0.5, 2) + $2::NUMERIC)::TEXT;
+	`, transactionDetails.Commission, transactionDetails.Amount).Scan(
+		&shares[0].amount, &shares[1].amount, &shares[2].amount, &refunded,
+	); err != nil {
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+		return fmt.Errorf("failed to record refund")
+	}
+	if err := recordPayoutRefund(ctx, tx, req.TransactionID, transactionDetails.UserID, refunded, shares); err != nil {
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+		return fmt.Errorf("failed to record refund")
+	}
+
+	_, err = tx.Exec(ctx, updateTransactionStatus, req.TransactionID)
+	if errors.Is(asPeriodClosed(err), ErrPeriodClosed) {
+		return ErrPeriodClosed
+	}
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to update transaction status")
 	}
 
-	if err := tx.Commit(context.Background()); err != nil {
-		log.Println(err)
+	if err := recordPayoutStatus(ctx, tx, req.TransactionID, "REFUND"); err != nil {
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
+		return fmt.Errorf("failed to record refund notification")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		slog.Error("query failed", "query", "PayoutTransactionRefund", "error", err)
 		return fmt.Errorf("failed to commit transaction")
 	}
 	return nil
 }
 
-func (q *Query) UpdatePayoutTransaction(req *structures.UpdatePayoutTransaction) error {
+func (q *Query) UpdatePayoutTransaction(ctx context.Context, req *structures.UpdatePayoutTransaction, audit *structures.AuditLogEntry) error {
+	selectQuery := `
+		SELECT COALESCE(operator_transaction_id, ''), transaction_status
+		FROM payout_service
+		WHERE payout_transaction_id=$1
+		FOR UPDATE;
+	`
 	query := `
 		UPDATE payout_service SET
 		operator_transaction_id=$1,transaction_status=$2
 		WHERE payout_transaction_id=$3;
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := q.writeContext(ctx)
 	defer cancel()
 
-	if _, err := q.Pool.Exec(ctx, query, req.OperatorTransactionID, req.Status, req.PayoutTransactionID); err != nil {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer rollbackTx(ctx, tx)
+
+	var operatorTransactionID, status string
+	if err := tx.QueryRow(ctx, selectQuery, req.PayoutTransactionID).Scan(&operatorTransactionID, &status); err != nil {
+		return err
+	}
+	// A returned payout's money has already been settled by its return
+	if status == pkg.PayoutReturned || status == pkg.PayoutReversed {
+		return ErrPayoutAlreadyReturned
+	}
+
+	if _, err := tx.Exec(ctx, query, req.OperatorTransactionID, req.Status, req.PayoutTransactionID); err != nil {
+		return asPeriodClosed(err)
+	}
+
+	if req.Status != status {
+		if err := settlePayoutHold(ctx, tx, req.PayoutTransactionID, req.Status); err != nil {
+			return asPeriodClosed(err)
+		}
+		if err := recordPayoutStatus(ctx, tx, req.PayoutTransactionID, req.Status); err != nil {
+			return err
+		}
+	}
+
+	audit.Before = map[string]string{"operator_transaction_id": operatorTransactionID, "transaction_status": status}
+	audit.After = map[string]string{"operator_transaction_id": req.OperatorTransactionID, "transaction_status": req.Status}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (q *Query) GetPayoutReports(
 	ctx context.Context,
 	userID string,
 ) ([]structures.PayoutReportResponse, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
 
 	const query = `
 		SELECT
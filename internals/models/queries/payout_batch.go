@@ -0,0 +1,387 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPayoutBatchEmpty is returned when none of a batch's rows can be paid
+var ErrPayoutBatchEmpty = errors.New("no valid rows in the batch")
+
+// CreatePayoutBatch stores a bulk payout and reserves what its valid rows
+// will cost, commission included. Rows whose account is not one of the
+// remitter's saved beneficiaries are rejected here. The batch is not created
+// at all when the wallet cannot cover the reserve.
+func (q *Query) CreatePayoutBatch(ctx context.Context, batch *structures.PayoutBatch) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO payout_batches (user_id, mobile_number, total_rows, valid_rows)
+		VALUES ($1, $2, $3, 0)
+		RETURNING batch_id::TEXT, batch_status, created_at;
+	`, batch.UserID, batch.MobileNumber, len(batch.Rows)).Scan(&batch.BatchID, &batch.BatchStatus, &batch.CreatedAt); err != nil {
+		return fmt.Errorf("insert payout batch: %w", err)
+	}
+
+	batch.ValidRows = 0
+	for i := range batch.Rows {
+		row := &batch.Rows[i]
+		if row.RowStatus == "" {
+			err := tx.QueryRow(ctx, `
+				SELECT bank_name FROM beneficiaries
+				WHERE mobile_number = $1 AND account_number = $2 AND UPPER(ifsc_code) = $3
+				LIMIT 1;
+			`, batch.MobileNumber, row.AccountNumber, row.IFSCCode).Scan(&row.BankName)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				row.RowStatus = pkg.PayoutRowRejected
+				row.Message = "account is not a saved beneficiary"
+			case err != nil:
+				return fmt.Errorf("select beneficiary: %w", err)
+			default:
+				row.RowStatus = pkg.PayoutRowQueued
+				batch.ValidRows++
+			}
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO payout_batch_rows (
+				batch_id, row_number, account_number, ifsc_code, beneficiary_name, bank_name,
+				amount, transfer_type, reference, row_status, message
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
+		`, batch.BatchID, row.RowNumber, row.AccountNumber, row.IFSCCode, row.BeneficiaryName, row.BankName,
+			row.Amount, row.TransferType, row.Reference, row.RowStatus, row.Message); err != nil {
+			return fmt.Errorf("insert payout batch row: %w", err)
+		}
+	}
+	if batch.ValidRows == 0 {
+		return ErrPayoutBatchEmpty
+	}
+
+	// Each row is reserved for exactly what InitilizePayoutRequest will debit
+	// for it, rounded up to the paisa
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(CEIL(
+			(r.amount::NUMERIC + ROUND(r.amount::NUMERIC * rate.total / 100, 2) * rate.retailer) * 100
+		) / 100), 0)::NUMERIC(20,2)::TEXT
+		FROM payout_batch_rows r,
+			(SELECT CASE WHEN c.user_id IS NULL THEN 1.2 ELSE c.commision END AS total,
+				CASE WHEN c.user_id IS NULL THEN 0.50 ELSE c.user_commision END AS retailer
+			FROM users u LEFT JOIN commisions c ON c.user_id = u.user_id
+			WHERE u.user_id = $2 LIMIT 1) rate
+		WHERE r.batch_id = $1 AND r.row_status = 'QUEUED';
+	`, batch.BatchID, batch.UserID).Scan(&batch.ReservedAmount); err != nil {
+		return fmt.Errorf("calculate payout batch reserve: %w", err)
+	}
+
+	var covered bool
+	if err := tx.QueryRow(ctx, `
+		SELECT user_wallet_balance + available_credit(user_id) >= $2::NUMERIC
+		FROM users WHERE user_id = $1 FOR UPDATE;
+	`, batch.UserID, batch.ReservedAmount).Scan(&covered); err != nil {
+		return fmt.Errorf("lock user wallet: %w", err)
+	}
+	if !covered {
+		return ErrInsufficientBalance
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET user_wallet_balance = user_wallet_balance - $2::NUMERIC WHERE user_id = $1;
+	`, batch.UserID, batch.ReservedAmount); err != nil {
+		return fmt.Errorf("debit user wallet: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE payout_batches SET valid_rows = $2, reserved_amount = $3::NUMERIC, reserve_remaining = $3::NUMERIC
+		WHERE batch_id = $1;
+	`, batch.BatchID, batch.ValidRows, batch.ReservedAmount); err != nil {
+		return fmt.Errorf("update payout batch reserve: %w", err)
+	}
+	batch.ReserveRemaining = batch.ReservedAmount
+
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "USER", batch.UserID, batch.ReservedAmount, "bulk payout reserve"); err != nil {
+		return err
+	}
+	replenishAfterPayout(ctx, tx, batch.UserID, "")
+
+	return tx.Commit(ctx)
+}
+
+// drawPayoutBatchReserve pays for one row of a batch out of its reserve
+func drawPayoutBatchReserve(ctx context.Context, tx pgx.Tx, batchID string, amount string) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE payout_batches SET reserve_remaining = reserve_remaining - $2::NUMERIC
+		WHERE batch_id = $1 AND batch_status = 'PROCESSING' AND reserve_remaining >= $2::NUMERIC;
+	`, batchID, amount)
+	if err != nil {
+		return fmt.Errorf("draw payout batch reserve: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
+// GetQueuedPayoutBatchRows returns the rows of a batch still waiting to be
+// sent to the provider
+func (q *Query) GetQueuedPayoutBatchRows(ctx context.Context, batchID string) ([]structures.PayoutBatchRow, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+payoutBatchRowColumns+`
+		FROM payout_batch_rows
+		WHERE batch_id = $1 AND row_status = 'QUEUED'
+		ORDER BY row_number;
+	`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	return collectPayoutBatchRows(rows)
+}
+
+// UpdatePayoutBatchRow records how a row of a batch got on
+func (q *Query) UpdatePayoutBatchRow(ctx context.Context, batchID string, row *structures.PayoutBatchRow) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	_, err := q.Pool.Exec(ctx, `
+		UPDATE payout_batch_rows
+		SET row_status = $3, payout_transaction_id = COALESCE($4::UUID, payout_transaction_id),
+			message = $5, updated_at = NOW()
+		WHERE batch_id = $1 AND row_number = $2;
+	`, batchID, row.RowNumber, row.RowStatus, nullIfEmpty(row.PayoutTransactionID), row.Message)
+	return err
+}
+
+// CompletePayoutBatch closes a batch and returns what is left of its reserve
+// to the wallet. Completing a batch twice does nothing.
+func (q *Query) CompletePayoutBatch(ctx context.Context, batchID string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if err := completePayoutBatch(ctx, tx, batchID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func completePayoutBatch(ctx context.Context, tx pgx.Tx, batchID string) error {
+	var userID string
+	err := tx.QueryRow(ctx, `SELECT user_id::TEXT FROM payout_batches WHERE batch_id = $1;`, batchID).Scan(&userID)
+	if err != nil {
+		return fmt.Errorf("select payout batch: %w", err)
+	}
+
+	// The wallet is locked before the batch, in the same order as the rows'
+	// payouts take them
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE user_id = $1 FOR UPDATE;`, userID); err != nil {
+		return fmt.Errorf("lock user wallet: %w", err)
+	}
+
+	var remaining string
+	var refund bool
+	err = tx.QueryRow(ctx, `
+		SELECT reserve_remaining::TEXT, reserve_remaining > 0
+		FROM payout_batches
+		WHERE batch_id = $1 AND batch_status = 'PROCESSING'
+		FOR UPDATE;
+	`, batchID).Scan(&remaining, &refund)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lock payout batch: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE payout_batches
+		SET batch_status = 'COMPLETED', reserve_remaining = 0, completed_at = NOW()
+		WHERE batch_id = $1;
+	`, batchID); err != nil {
+		return fmt.Errorf("complete payout batch: %w", err)
+	}
+	if !refund {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET user_wallet_balance = user_wallet_balance + $2::NUMERIC WHERE user_id = $1;
+	`, userID, remaining); err != nil {
+		return fmt.Errorf("release payout batch reserve: %w", err)
+	}
+	return recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "USER", userID, remaining, "unused bulk payout reserve")
+}
+
+// ReleaseStalePayoutBatches completes batches that have been processing for
+// longer than maxAge, which only happens when the server stopped half way
+// through one. Rows that never reached the provider are failed and their
+// share of the reserve goes back to the wallet.
+func (q *Query) ReleaseStalePayoutBatches(ctx context.Context, maxAge time.Duration) (int, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT batch_id::TEXT FROM payout_batches
+		WHERE batch_status = 'PROCESSING' AND created_at < $1;
+	`, time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, err
+	}
+	batchIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	for _, batchID := range batchIDs {
+		tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("begin tx: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE payout_batch_rows
+			SET row_status = 'FAILED', message = 'not processed before the batch expired', updated_at = NOW()
+			WHERE batch_id = $1 AND row_status = 'QUEUED';
+		`, batchID); err != nil {
+			rollbackTx(ctx, tx)
+			return 0, fmt.Errorf("fail queued payout batch rows: %w", err)
+		}
+		if err := completePayoutBatch(ctx, tx, batchID); err != nil {
+			rollbackTx(ctx, tx)
+			return 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(batchIDs), nil
+}
+
+const payoutBatchColumns = `
+	batch_id::TEXT, user_id::TEXT, mobile_number, total_rows, valid_rows, reserved_amount::TEXT,
+	reserve_remaining::TEXT, batch_status, created_at, completed_at
+`
+
+const payoutBatchRowColumns = `
+	row_number, account_number, ifsc_code, beneficiary_name, bank_name, amount, transfer_type,
+	reference, row_status, COALESCE(payout_transaction_id::TEXT, ''), message
+`
+
+func scanPayoutBatch(row pgx.Row, b *structures.PayoutBatch) error {
+	return row.Scan(
+		&b.BatchID,
+		&b.UserID,
+		&b.MobileNumber,
+		&b.TotalRows,
+		&b.ValidRows,
+		&b.ReservedAmount,
+		&b.ReserveRemaining,
+		&b.BatchStatus,
+		&b.CreatedAt,
+		&b.CompletedAt,
+	)
+}
+
+func collectPayoutBatchRows(rows pgx.Rows) ([]structures.PayoutBatchRow, error) {
+	defer rows.Close()
+
+	var res []structures.PayoutBatchRow
+	for rows.Next() {
+		var r structures.PayoutBatchRow
+		if err := rows.Scan(
+			&r.RowNumber,
+			&r.AccountNumber,
+			&r.IFSCCode,
+			&r.BeneficiaryName,
+			&r.BankName,
+			&r.Amount,
+			&r.TransferType,
+			&r.Reference,
+			&r.RowStatus,
+			&r.PayoutTransactionID,
+			&r.Message,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// GetPayoutBatches lists a retailer's bulk payouts, newest first
+func (q *Query) GetPayoutBatches(ctx context.Context, userID string) ([]structures.PayoutBatch, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+payoutBatchColumns+`
+		FROM payout_batches
+		WHERE user_id = $1
+		ORDER BY created_at DESC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.PayoutBatch
+	for rows.Next() {
+		var b structures.PayoutBatch
+		if err := scanPayoutBatch(rows, &b); err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, rows.Err()
+}
+
+// GetPayoutBatch returns one of a retailer's bulk payouts with every row and
+// how many rows ended in each status
+func (q *Query) GetPayoutBatch(ctx context.Context, userID string, batchID string) (*structures.PayoutBatch, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var b structures.PayoutBatch
+	if err := scanPayoutBatch(q.Pool.QueryRow(ctx, `
+		SELECT `+payoutBatchColumns+`
+		FROM payout_batches
+		WHERE batch_id = $1 AND user_id = $2;
+	`, batchID, userID), &b); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+payoutBatchRowColumns+`
+		FROM payout_batch_rows
+		WHERE batch_id = $1
+		ORDER BY row_number;
+	`, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if b.Rows, err = collectPayoutBatchRows(rows); err != nil {
+		return nil, err
+	}
+
+	b.StatusCounts = make(map[string]int)
+	for _, r := range b.Rows {
+		b.StatusCounts[strings.ToLower(r.RowStatus)]++
+	}
+	return &b, nil
+}
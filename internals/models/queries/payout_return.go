@@ -0,0 +1,257 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrPayoutNotReturnable is returned when a payout that never went
+	// through is recorded as returned
+	ErrPayoutNotReturnable   = errors.New("payout is not successful")
+	ErrPayoutAlreadyReturned = errors.New("payout already returned")
+	ErrReturnReferenceUsed   = errors.New("return reference already used")
+)
+
+const payoutReturnColumns = `
+	return_id::TEXT, payout_transaction_id::TEXT, outcome, return_reference, reason, commission_policy,
+	principal::TEXT, retailer_refund::TEXT, admin_clawback::TEXT, master_distributor_clawback::TEXT,
+	distributor_clawback::TEXT, user_id::TEXT, bank_code, bank_name, recorded_by, created_at
+`
+
+func payoutReturnDest(r *structures.PayoutReturn) []any {
+	return []any{
+		&r.ReturnID,
+		&r.PayoutTransactionID,
+		&r.Outcome,
+		&r.ReturnReference,
+		&r.Reason,
+		&r.CommissionPolicy,
+		&r.Principal,
+		&r.RetailerRefund,
+		&r.AdminClawback,
+		&r.MasterDistributorClawback,
+		&r.DistributorClawback,
+		&r.UserID,
+		&r.BankCode,
+		&r.BankName,
+		&r.RecordedBy,
+		&r.CreatedAt,
+	}
+}
+
+// RecordPayoutReturn books a successful payout of the admin's coming back.
+// The retailer gets the principal back, and under the CLAWBACK policy also
+// the commission they paid, which is then taken back from the admin, master
+// distributor and distributor it was shared with. Payouts that predate
+// holds have no recorded shares and always keep their commission.
+func (q *Query) RecordPayoutReturn(ctx context.Context, req *structures.PayoutReturnRequest, recordedBy string, audit *structures.AuditLogEntry) (*structures.PayoutReturn, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var status string
+	if err := tx.QueryRow(ctx, `
+		SELECT ps.transaction_status
+		FROM payout_service ps
+		JOIN users u ON u.user_id = ps.user_id
+		WHERE ps.payout_transaction_id = $1 AND u.admin_id = $2
+		FOR UPDATE OF ps;
+	`, req.PayoutTransactionID, req.AdminID).Scan(&status); err != nil {
+		return nil, err
+	}
+	switch status {
+	case pkg.PayoutReturned, pkg.PayoutReversed:
+		return nil, ErrPayoutAlreadyReturned
+	case "SUCCESS":
+	default:
+		return nil, ErrPayoutNotReturnable
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE payout_service SET transaction_status = $2 WHERE payout_transaction_id = $1;
+	`, req.PayoutTransactionID, req.Outcome); err != nil {
+		return nil, asPeriodClosed(err)
+	}
+
+	var res structures.PayoutReturn
+	var adminID, masterDistributorID, distributorID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO payout_returns (
+			payout_transaction_id, outcome, return_reference, reason, commission_policy,
+			principal, retailer_refund, admin_clawback, master_distributor_clawback, distributor_clawback,
+			user_id, admin_id, master_distributor_id, distributor_id, bank_code, bank_name, recorded_by
+		)
+		SELECT ps.payout_transaction_id, $2, $3, $4, p.policy,
+			ps.amount,
+			CASE WHEN p.policy = 'CLAWBACK' THEN h.amount ELSE ps.amount END,
+			CASE WHEN p.policy = 'CLAWBACK' THEN ROUND(h.admin_share, 2) ELSE 0 END,
+			CASE WHEN p.policy = 'CLAWBACK' THEN ROUND(h.master_distributor_share, 2) ELSE 0 END,
+			CASE WHEN p.policy = 'CLAWBACK' THEN ROUND(h.distributor_share, 2) ELSE 0 END,
+			ps.user_id,
+			COALESCE(h.admin_id, u.admin_id),
+			COALESCE(h.master_distributor_id, u.master_distributor_id),
+			COALESCE(h.distributor_id, u.distributor_id),
+			UPPER(LEFT(ps.ifsc_code, 4)), ps.bank_name, $6
+		FROM payout_service ps
+		JOIN users u ON u.user_id = ps.user_id
+		LEFT JOIN wallet_holds h ON h.payout_transaction_id = ps.payout_transaction_id AND h.hold_status = 'CAPTURED'
+		CROSS JOIN LATERAL (
+			SELECT CASE WHEN h.hold_id IS NULL THEN 'KEEP' ELSE $5 END AS policy
+		) p
+		WHERE ps.payout_transaction_id = $1
+		RETURNING `+payoutReturnColumns+`,
+			admin_id::TEXT, master_distributor_id::TEXT, distributor_id::TEXT;
+	`, req.PayoutTransactionID, req.Outcome, req.ReturnReference, req.Reason, req.CommissionPolicy, recordedBy,
+	).Scan(append(payoutReturnDest(&res), &adminID, &masterDistributorID, &distributorID)...)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrReturnReferenceUsed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("insert payout return: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET user_wallet_balance = user_wallet_balance + $2::NUMERIC WHERE user_id = $1;
+	`, res.UserID, res.RetailerRefund); err != nil {
+		return nil, fmt.Errorf("refund returned payout: %w", err)
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "USER", res.UserID, res.RetailerRefund, "returned payout refunded"); err != nil {
+		return nil, err
+	}
+	for _, clawback := range []struct {
+		ownerType string
+		ownerID   string
+		amount    string
+	}{
+		{"ADMIN", adminID, res.AdminClawback},
+		{"MASTER_DISTRIBUTOR", masterDistributorID, res.MasterDistributorClawback},
+		{"DISTRIBUTOR", distributorID, res.DistributorClawback},
+	} {
+		if clawback.amount == "0.00" {
+			continue
+		}
+		// The commission is taken back even when it was spent since, the
+		// wallet goes below zero until it is topped up again
+		owner := walletOwners[clawback.ownerType]
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`
+			UPDATE %[1]s SET %[2]s = %[2]s - $2::NUMERIC WHERE %[3]s = $1;
+		`, owner.table, owner.balanceColumn, owner.idColumn), clawback.ownerID, clawback.amount); err != nil {
+			return nil, fmt.Errorf("claw back payout commission: %w", err)
+		}
+		if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, clawback.ownerType, clawback.ownerID, clawback.amount, "returned payout commission clawed back"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordPayoutStatus(ctx, tx, req.PayoutTransactionID, req.Outcome); err != nil {
+		return nil, err
+	}
+
+	audit.TargetID = req.PayoutTransactionID
+	audit.Before = map[string]string{"transaction_status": status}
+	audit.After = map[string]string{
+		"transaction_status": res.Outcome,
+		"return_reference":   res.ReturnReference,
+		"commission_policy":  res.CommissionPolicy,
+		"retailer_refund":    res.RetailerRefund,
+	}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetPayoutReturns lists the returns of the admin's payouts, latest first
+func (q *Query) GetPayoutReturns(ctx context.Context, adminID string, limit int) (*[]structures.PayoutReturn, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+payoutReturnColumns+`
+		FROM payout_returns
+		WHERE admin_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2;
+	`, adminID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []structures.PayoutReturn{}
+	for rows.Next() {
+		var r structures.PayoutReturn
+		if err := rows.Scan(payoutReturnDest(&r)...); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return &res, rows.Err()
+}
+
+// GetBankReturnRates compares, bank by bank, the admin's payouts made from
+// the start of from to the end of to that went through with those of them
+// that later came back. Banks with the most returns come first.
+func (q *Query) GetBankReturnRates(ctx context.Context, adminID string, from time.Time, to time.Time) (*[]structures.BankReturnRate, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
+	start, _ := dayBounds(from)
+	_, end := dayBounds(to)
+	rows, err := q.Pool.Query(ctx, `
+		SELECT UPPER(LEFT(ps.ifsc_code, 4)) AS bank_code,
+			MIN(ps.bank_name),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE ps.transaction_status = 'RETURNED'),
+			COUNT(*) FILTER (WHERE ps.transaction_status = 'REVERSED'),
+			ROUND(100.0 * COUNT(*) FILTER (WHERE ps.transaction_status IN ('RETURNED','REVERSED')) / COUNT(*), 2)::TEXT,
+			SUM(ps.amount)::TEXT,
+			COALESCE(SUM(ps.amount) FILTER (WHERE ps.transaction_status IN ('RETURNED','REVERSED')), 0)::TEXT
+		FROM payout_service ps
+		JOIN users u ON u.user_id = ps.user_id
+		WHERE u.admin_id = $1 AND ps.created_at >= $2 AND ps.created_at < $3
+			AND ps.transaction_status IN ('SUCCESS','RETURNED','REVERSED')
+		GROUP BY bank_code
+		ORDER BY COUNT(*) FILTER (WHERE ps.transaction_status IN ('RETURNED','REVERSED')) DESC, COUNT(*) DESC;
+	`, adminID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []structures.BankReturnRate{}
+	for rows.Next() {
+		var r structures.BankReturnRate
+		if err := rows.Scan(
+			&r.BankCode,
+			&r.BankName,
+			&r.Payouts,
+			&r.Returned,
+			&r.Reversed,
+			&r.ReturnRate,
+			&r.PayoutAmount,
+			&r.ReturnedAmount,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return &res, rows.Err()
+}
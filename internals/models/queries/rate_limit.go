@@ -0,0 +1,140 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// TakeRateLimitToken spends one token from the bucket under key, refilling it
+// for the time since it was last used. It returns zero, or how long to wait
+// for the next token when the bucket is empty.
+func (q *Query) TakeRateLimitToken(ctx context.Context, key string, limit pkg.RateLimit) (time.Duration, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	// Refused requests leave the row alone, so the refill keeps counting
+	// from the last request that was let through
+	var tokens float64
+	err := q.Pool.QueryRow(ctx, `
+		INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, updated_at)
+		VALUES ($1, $2::FLOAT8 - 1, NOW())
+		ON CONFLICT (bucket_key) DO UPDATE SET
+			tokens = LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 / $3) - 1,
+			updated_at = NOW()
+		WHERE LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 / $3) >= 1
+		RETURNING tokens;
+	`, key, limit.Burst, limit.Every.Seconds()).Scan(&tokens)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+
+	var wait float64
+	err = q.Pool.QueryRow(ctx, `
+		SELECT GREATEST(0, 1 - (tokens + EXTRACT(EPOCH FROM NOW() - updated_at)::FLOAT8 / $2)) * $2
+		FROM rate_limit_buckets
+		WHERE bucket_key = $1;
+	`, key, limit.Every.Seconds()).Scan(&wait)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait * float64(time.Second)), nil
+}
+
+// PurgeRateLimitBuckets deletes buckets unused for longer than idle
+func (q *Query) PurgeRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tag, err := q.Pool.Exec(ctx, `
+		DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - make_interval(secs => $1);
+	`, idle.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetLoginLockedUntil returns when the lockout on a login ends, or nil when
+// it is not locked
+func (q *Query) GetLoginLockedUntil(ctx context.Context, role string, login string) (*time.Time, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var lockedUntil *time.Time
+	err := q.Pool.QueryRow(ctx, `
+		SELECT locked_until FROM login_attempts
+		WHERE role = $1 AND login = $2 AND locked_until > NOW();
+	`, role, strings.ToLower(login)).Scan(&lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return lockedUntil, err
+}
+
+// RecordLoginFailure counts a wrong password for the login, which is locked
+// every pkg.LoginAttemptsPerLockout consecutive failures. Logins that do not
+// exist are counted too, so a lockout does not tell them apart.
+func (q *Query) RecordLoginFailure(ctx context.Context, role string, login string) (*structures.LoginAttemptResult, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	var res structures.LoginAttemptResult
+	var lockedUntil *time.Time
+	err := q.Pool.QueryRow(ctx, `
+		INSERT INTO login_attempts AS a (role, login, failed_attempts, locked_until)
+		VALUES ($1, $2, 1, CASE WHEN $3 <= 1 THEN NOW() + make_interval(secs => $4) END)
+		ON CONFLICT (role, login) DO UPDATE SET
+			failed_attempts = a.failed_attempts + 1,
+			locked_until = CASE
+				WHEN (a.failed_attempts + 1) % $3 = 0 THEN NOW() + make_interval(secs => $4)
+				ELSE a.locked_until
+			END,
+			updated_at = NOW()
+		RETURNING failed_attempts, locked_until;
+	`, role, strings.ToLower(login), pkg.LoginAttemptsPerLockout, pkg.LoginLockoutDuration.Seconds()).Scan(&res.FailedAttempts, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil && lockedUntil.After(time.Now()) {
+		res.Locked = true
+		res.LockedUntil = *lockedUntil
+	}
+	return &res, nil
+}
+
+// ClearLoginFailures resets the count once the login succeeds
+func (q *Query) ClearLoginFailures(ctx context.Context, role string, login string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	_, err := q.Pool.Exec(ctx, `
+		DELETE FROM login_attempts WHERE role = $1 AND login = $2;
+	`, role, strings.ToLower(login))
+	return err
+}
+
+// PurgeLoginAttempts deletes counts of logins that have not failed for
+// longer than idle and are not locked
+func (q *Query) PurgeLoginAttempts(ctx context.Context, idle time.Duration) (int64, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tag, err := q.Pool.Exec(ctx, `
+		DELETE FROM login_attempts
+		WHERE updated_at < NOW() - make_interval(secs => $1)
+		AND (locked_until IS NULL OR locked_until < NOW());
+	`, idle.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
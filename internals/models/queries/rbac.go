@@ -0,0 +1,433 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleNameTaken   = errors.New("role name used by another admin")
+	ErrSystemRole      = errors.New("system roles cannot be changed")
+	ErrRoleInUse       = errors.New("role is assigned to staff users")
+	ErrStaffEmailTaken = errors.New("staff email already registered")
+)
+
+// syncSystemRoles restores the built-in roles and their permissions
+func syncSystemRoles(ctx context.Context, tx pgx.Tx) error {
+	for name, role := range pkg.SystemRoles {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO rbac_roles (role_name, description, is_system)
+			VALUES ($1, $2, TRUE)
+			ON CONFLICT (role_name) DO UPDATE SET description = EXCLUDED.description, is_system = TRUE;
+		`, name, role.Description); err != nil {
+			return fmt.Errorf("upsert role %s: %w", name, err)
+		}
+		if err := replaceRolePermissions(ctx, tx, name, pkg.SystemRolePermissions(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replaceRolePermissions(ctx context.Context, tx pgx.Tx, roleName string, permissions []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM rbac_role_permissions WHERE role_name = $1;`, roleName); err != nil {
+		return fmt.Errorf("delete role permissions: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO rbac_role_permissions (role_name, permission)
+		SELECT $1, UNNEST($2::TEXT[])
+		ON CONFLICT DO NOTHING;
+	`, roleName, permissions); err != nil {
+		return fmt.Errorf("insert role permissions: %w", err)
+	}
+	return nil
+}
+
+// availableRole matches the built-in roles and the custom roles of the admin
+// in $2
+const availableRole = `(is_system OR owner_admin_id = $2)`
+
+// GetActorPermissions returns what an admin, or a staff user working for the
+// admin, may do. Blocked and unknown accounts, and staff holding a role of
+// another admin, have no permissions.
+func (q *Query) GetActorPermissions(ctx context.Context, adminID string, staffID string) ([]string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var permissions []string
+	err := q.Pool.QueryRow(ctx, `
+		SELECT COALESCE(ARRAY_AGG(p.permission), '{}')
+		FROM rbac_role_permissions p
+		WHERE p.role_name = (
+			CASE WHEN $2 = '' THEN
+				(SELECT admin_role FROM admins WHERE admin_id = $1 AND NOT admin_blocked)
+			ELSE
+				(SELECT s.role_name FROM staff_users s JOIN rbac_roles r ON r.role_name = s.role_name
+				WHERE s.staff_id::TEXT = $2 AND s.admin_id = $1 AND NOT s.staff_blocked
+					AND (r.is_system OR r.owner_admin_id = $1))
+			END
+		);
+	`, adminID, staffID).Scan(&permissions)
+	return permissions, err
+}
+
+// GetRolePermissions returns the permissions of a role the admin can use.
+// Roles of other admins give ErrRoleNotFound.
+func (q *Query) GetRolePermissions(ctx context.Context, adminID string, roleName string) ([]string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var permissions []string
+	err := q.Pool.QueryRow(ctx, `
+		SELECT ARRAY(SELECT permission FROM rbac_role_permissions WHERE role_name = r.role_name)
+		FROM rbac_roles r
+		WHERE r.role_name = $1 AND `+availableRole+`;
+	`, roleName, adminID).Scan(&permissions)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	return permissions, err
+}
+
+// GetRoles returns the built-in roles and the admin's custom roles
+func (q *Query) GetRoles(ctx context.Context, adminID string) (*[]structures.Role, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT
+			r.role_name, r.description, r.is_system,
+			ARRAY(SELECT permission FROM rbac_role_permissions p WHERE p.role_name = r.role_name ORDER BY permission),
+			r.updated_at
+		FROM rbac_roles r
+		WHERE r.is_system OR r.owner_admin_id = $1
+		ORDER BY r.is_system DESC, r.role_name;
+	`, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.Role
+	for rows.Next() {
+		var role structures.Role
+		if err := rows.Scan(&role.RoleName, &role.Description, &role.IsSystem, &role.Permissions, &role.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SaveRole creates a custom role of the admin or replaces its description and
+// permissions. The audit entry is recorded as ROLE_CREATE when the role is
+// new. Role names are shared by all admins, a name another admin uses gives
+// ErrRoleNameTaken.
+func (q *Query) SaveRole(ctx context.Context, req *structures.RoleRequest, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var (
+		isSystem bool
+		ownerID  string
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT is_system, COALESCE(owner_admin_id::TEXT, '') FROM rbac_roles WHERE role_name = $1 FOR UPDATE;
+	`, req.RoleName).Scan(&isSystem, &ownerID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("select role: %w", err)
+	}
+	if isSystem {
+		return ErrSystemRole
+	}
+	if err == nil && ownerID != req.AdminID {
+		return ErrRoleNameTaken
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		audit.Action = "ROLE_CREATE"
+	} else {
+		before, err := rolePermissions(ctx, tx, req.RoleName)
+		if err != nil {
+			return err
+		}
+		audit.Before = map[string]any{"permissions": before}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO rbac_roles (role_name, description, owner_admin_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (role_name) DO UPDATE SET description = EXCLUDED.description;
+	`, req.RoleName, req.Description, req.AdminID); err != nil {
+		return fmt.Errorf("upsert role: %w", err)
+	}
+	if err := replaceRolePermissions(ctx, tx, req.RoleName, req.Permissions); err != nil {
+		return err
+	}
+
+	audit.After = map[string]any{"description": req.Description, "permissions": req.Permissions}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// rolePermissions reads a role's permissions inside tx
+func rolePermissions(ctx context.Context, tx pgx.Tx, roleName string) ([]string, error) {
+	var permissions []string
+	err := tx.QueryRow(ctx, `
+		SELECT ARRAY(SELECT permission FROM rbac_role_permissions WHERE role_name = $1 ORDER BY permission);
+	`, roleName).Scan(&permissions)
+	return permissions, err
+}
+
+// DeleteRole removes a custom role of the admin that no staff user holds
+func (q *Query) DeleteRole(ctx context.Context, adminID string, roleName string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var isSystem bool
+	err = tx.QueryRow(ctx, `
+		SELECT is_system FROM rbac_roles WHERE role_name = $1 AND `+availableRole+` FOR UPDATE;
+	`, roleName, adminID).Scan(&isSystem)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("select role: %w", err)
+	}
+	if isSystem {
+		return ErrSystemRole
+	}
+	var inUse bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM staff_users WHERE role_name = $1);
+	`, roleName).Scan(&inUse); err != nil {
+		return fmt.Errorf("check role use: %w", err)
+	}
+	if inUse {
+		return ErrRoleInUse
+	}
+
+	before, err := rolePermissions(ctx, tx, roleName)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM rbac_roles WHERE role_name = $1;`, roleName); err != nil {
+		return fmt.Errorf("delete role: %w", err)
+	}
+
+	audit.Before = map[string]any{"permissions": before}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CreateStaffUser adds a staff user working for the admin. An unknown role,
+// or one of another admin, gives ErrRoleNotFound.
+func (q *Query) CreateStaffUser(ctx context.Context, req *structures.StaffCreateRequest, createdBy string, audit *structures.AuditLogEntry) (*structures.StaffUser, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM rbac_roles WHERE role_name = $1 AND `+availableRole+`);
+	`, req.RoleName, req.AdminID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check role: %w", err)
+	}
+	if !exists {
+		return nil, ErrRoleNotFound
+	}
+
+	var taken bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM staff_users WHERE staff_email = LOWER($1));
+	`, req.StaffEmail).Scan(&taken); err != nil {
+		return nil, fmt.Errorf("check staff email: %w", err)
+	}
+	if taken {
+		return nil, ErrStaffEmailTaken
+	}
+
+	var res structures.StaffUser
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO staff_users (admin_id, staff_name, staff_email, staff_phone, staff_password, role_name, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING staff_id::TEXT, admin_id::TEXT, staff_name, staff_email, staff_phone, role_name, staff_blocked, created_by, created_at;
+	`, req.AdminID, req.StaffName, strings.ToLower(req.StaffEmail), req.StaffPhone, req.StaffPassword, req.RoleName, createdBy).Scan(
+		&res.StaffID,
+		&res.AdminID,
+		&res.StaffName,
+		&res.StaffEmail,
+		&res.StaffPhone,
+		&res.RoleName,
+		&res.StaffBlocked,
+		&res.CreatedBy,
+		&res.CreatedAt,
+	); err != nil {
+		return nil, fmt.Errorf("insert staff user: %w", err)
+	}
+
+	audit.TargetID = res.StaffID
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+func (q *Query) GetStaffUsers(ctx context.Context, adminID string) (*[]structures.StaffUser, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT staff_id::TEXT, admin_id::TEXT, staff_name, staff_email, staff_phone, role_name, staff_blocked, created_by, created_at
+		FROM staff_users
+		WHERE admin_id = $1
+		ORDER BY created_at;
+	`, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.StaffUser
+	for rows.Next() {
+		var staff structures.StaffUser
+		if err := rows.Scan(
+			&staff.StaffID,
+			&staff.AdminID,
+			&staff.StaffName,
+			&staff.StaffEmail,
+			&staff.StaffPhone,
+			&staff.RoleName,
+			&staff.StaffBlocked,
+			&staff.CreatedBy,
+			&staff.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, staff)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateStaffUser assigns a role to, or blocks, a staff user of the admin.
+// Empty roleName or nil blocked leave that field as it is. Staff users of
+// other admins give pgx.ErrNoRows, unknown roles and roles of other admins
+// ErrRoleNotFound.
+func (q *Query) UpdateStaffUser(ctx context.Context, adminID string, staffID string, roleName string, blocked *bool, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if roleName != "" {
+		var exists bool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM rbac_roles WHERE role_name = $1 AND `+availableRole+`);
+		`, roleName, adminID).Scan(&exists); err != nil {
+			return fmt.Errorf("check role: %w", err)
+		}
+		if !exists {
+			return ErrRoleNotFound
+		}
+	}
+
+	var (
+		currentRole    string
+		currentBlocked bool
+	)
+	if err := tx.QueryRow(ctx, `
+		SELECT role_name, staff_blocked FROM staff_users
+		WHERE staff_id = $1 AND admin_id = $2
+		FOR UPDATE;
+	`, staffID, adminID).Scan(&currentRole, &currentBlocked); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE staff_users SET
+			role_name = COALESCE(NULLIF($2, ''), role_name),
+			staff_blocked = COALESCE($3, staff_blocked)
+		WHERE staff_id = $1;
+	`, staffID, roleName, blocked); err != nil {
+		return fmt.Errorf("update staff user: %w", err)
+	}
+
+	audit.Before = map[string]any{"role_name": currentRole, "staff_blocked": currentBlocked}
+	after := map[string]any{}
+	if roleName != "" {
+		after["role_name"] = roleName
+	}
+	if blocked != nil {
+		after["staff_blocked"] = *blocked
+	}
+	audit.After = after
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (q *Query) GetStaffPassword(ctx context.Context, email string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var password string
+	err := q.Pool.QueryRow(ctx, `
+		SELECT staff_password FROM staff_users WHERE staff_email = LOWER($1) AND NOT staff_blocked;
+	`, email).Scan(&password)
+	return password, err
+}
+
+func (q *Query) LoginStaff(ctx context.Context, email string) (*structures.StaffAuthResponse, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var res structures.StaffAuthResponse
+	err := q.Pool.QueryRow(ctx, `
+		SELECT staff_id::TEXT, admin_id::TEXT, staff_name, role_name
+		FROM staff_users
+		WHERE staff_email = LOWER($1) AND NOT staff_blocked;
+	`, email).Scan(&res.StaffID, &res.AdminID, &res.StaffName, &res.RoleName)
+	return &res, err
+}
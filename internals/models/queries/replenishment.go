@@ -0,0 +1,314 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRuleFundedElsewhere is returned when a retailer's replenishment rule
+// belongs to another funder
+var ErrRuleFundedElsewhere = errors.New("replenishment rule belongs to another funder")
+
+// replenishmentRuleColumns reads a rule along with what it sent since dayStart ($1)
+const replenishmentRuleColumns = `
+	r.rule_id::TEXT, r.user_id::TEXT, u.user_name, r.funder_id::TEXT, r.funder_type,
+	r.threshold_amount::TEXT, r.target_amount::TEXT, r.daily_cap::TEXT, r.active,
+	(SELECT COALESCE(SUM(amount), 0)::TEXT FROM replenishment_runs
+		WHERE rule_id = r.rule_id AND run_status = 'SUCCESS' AND created_at >= $1),
+	r.created_at, r.updated_at
+`
+
+func scanReplenishmentRule(row pgx.Row, rule *structures.ReplenishmentRule) error {
+	return row.Scan(
+		&rule.RuleID,
+		&rule.UserID,
+		&rule.UserName,
+		&rule.FunderID,
+		&rule.FunderType,
+		&rule.ThresholdAmount,
+		&rule.TargetAmount,
+		&rule.DailyCap,
+		&rule.Active,
+		&rule.ReplenishedToday,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+}
+
+// SaveReplenishmentRule sets the rule of a retailer under the funder. A
+// retailer has one rule, which only its funder can change.
+func (q *Query) SaveReplenishmentRule(ctx context.Context, funderID string, funderType string, req *structures.ReplenishmentRuleRequest, audit *structures.AuditLogEntry) (*structures.ReplenishmentRule, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if err := checkDownline(ctx, tx, funderType, funderID, "USER", req.UserID); err != nil {
+		return nil, err
+	}
+
+	dayStart := pkg.StartOfDay(time.Now())
+	var before *structures.ReplenishmentRule
+	var previous structures.ReplenishmentRule
+	err = scanReplenishmentRule(tx.QueryRow(ctx, `
+		SELECT `+replenishmentRuleColumns+`
+		FROM replenishment_rules r
+		JOIN users u ON u.user_id = r.user_id
+		WHERE r.user_id = $2
+		FOR UPDATE OF r;
+	`, dayStart, req.UserID), &previous)
+	if err == nil {
+		if previous.FunderID != funderID {
+			return nil, ErrRuleFundedElsewhere
+		}
+		before = &previous
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("select replenishment rule: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO replenishment_rules (user_id, funder_id, funder_type, threshold_amount, target_amount, daily_cap, active)
+		VALUES ($1, $2, $3, $4::NUMERIC, $5::NUMERIC, $6::NUMERIC, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			threshold_amount = EXCLUDED.threshold_amount,
+			target_amount = EXCLUDED.target_amount,
+			daily_cap = EXCLUDED.daily_cap,
+			active = EXCLUDED.active;
+	`, req.UserID, funderID, funderType, req.ThresholdAmount, req.TargetAmount, req.DailyCap, req.Active); err != nil {
+		return nil, fmt.Errorf("save replenishment rule: %w", err)
+	}
+
+	var res structures.ReplenishmentRule
+	if err := scanReplenishmentRule(tx.QueryRow(ctx, `
+		SELECT `+replenishmentRuleColumns+`
+		FROM replenishment_rules r
+		JOIN users u ON u.user_id = r.user_id
+		WHERE r.user_id = $2;
+	`, dayStart, req.UserID), &res); err != nil {
+		return nil, fmt.Errorf("select replenishment rule: %w", err)
+	}
+
+	audit.TargetID = res.RuleID
+	audit.Before = before
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (q *Query) GetReplenishmentRules(ctx context.Context, funderID string) (*[]structures.ReplenishmentRule, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+replenishmentRuleColumns+`
+		FROM replenishment_rules r
+		JOIN users u ON u.user_id = r.user_id
+		WHERE r.funder_id = $2
+		ORDER BY u.user_name;
+	`, pkg.StartOfDay(time.Now()), funderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.ReplenishmentRule
+	for rows.Next() {
+		var rule structures.ReplenishmentRule
+		if err := scanReplenishmentRule(rows, &rule); err != nil {
+			return nil, err
+		}
+		res = append(res, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (q *Query) DeleteReplenishmentRule(ctx context.Context, funderID string, userID string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var before structures.ReplenishmentRule
+	if err := scanReplenishmentRule(tx.QueryRow(ctx, `
+		DELETE FROM replenishment_rules r
+		USING users u
+		WHERE u.user_id = r.user_id AND r.user_id = $2 AND r.funder_id = $3
+		RETURNING `+replenishmentRuleColumns+`;
+	`, pkg.StartOfDay(time.Now()), userID, funderID), &before); err != nil {
+		return err
+	}
+
+	audit.TargetID = before.RuleID
+	audit.Before = before
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetReplenishmentRuns returns the latest top-ups of a retailer's rule under
+// the funder
+func (q *Query) GetReplenishmentRuns(ctx context.Context, funderID string, userID string, limit int) (*[]structures.ReplenishmentRun, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT rr.run_id, rr.rule_id::TEXT, COALESCE(rr.payout_transaction_id::TEXT, ''),
+			rr.amount::TEXT, rr.run_status, rr.reason, rr.created_at
+		FROM replenishment_runs rr
+		JOIN replenishment_rules r ON r.rule_id = rr.rule_id
+		WHERE r.user_id = $1 AND r.funder_id = $2
+		ORDER BY rr.created_at DESC
+		LIMIT $3;
+	`, userID, funderID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.ReplenishmentRun
+	for rows.Next() {
+		var run structures.ReplenishmentRun
+		if err := rows.Scan(
+			&run.RunID,
+			&run.RuleID,
+			&run.PayoutTransactionID,
+			&run.Amount,
+			&run.Status,
+			&run.Reason,
+			&run.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// replenishAfterPayout tops the retailer up from their rule's funder when the
+// payout in tx has left them below the threshold. It runs inside the payout
+// transaction with the retailer's wallet locked, so concurrent payouts see
+// each other's top-ups and never trigger a second one. A top-up that cannot
+// be made never fails the payout.
+func replenishAfterPayout(ctx context.Context, tx pgx.Tx, userID string, payoutTransactionID string) {
+	if err := replenishUserWallet(ctx, tx, userID, payoutTransactionID); err != nil {
+		slog.ErrorContext(ctx, "auto replenishment failed", "user_id", userID, "error", err)
+	}
+}
+
+func replenishUserWallet(ctx context.Context, tx pgx.Tx, userID string, payoutTransactionID string) error {
+	// A savepoint keeps a failed top-up from aborting the payout
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin savepoint: %w", err)
+	}
+	defer rollbackTx(ctx, sp)
+
+	var ruleID, funderID, funderType, amount string
+	var below bool
+	err = sp.QueryRow(ctx, `
+		SELECT r.rule_id::TEXT, r.funder_id::TEXT, r.funder_type,
+			u.user_wallet_balance < r.threshold_amount,
+			GREATEST(LEAST(
+				r.target_amount - u.user_wallet_balance,
+				r.daily_cap - (SELECT COALESCE(SUM(amount), 0) FROM replenishment_runs
+					WHERE rule_id = r.rule_id AND run_status = 'SUCCESS' AND created_at >= $2)
+			), 0)::NUMERIC(20,2)::TEXT
+		FROM replenishment_rules r
+		JOIN users u ON u.user_id = r.user_id
+		WHERE r.user_id = $1 AND r.active
+		FOR UPDATE OF r;
+	`, userID, pkg.StartOfDay(time.Now())).Scan(&ruleID, &funderID, &funderType, &below, &amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("select replenishment rule: %w", err)
+	}
+	if !below {
+		return nil
+	}
+
+	recordRun := func(amount string, status string, reason string) error {
+		if _, err := sp.Exec(ctx, `
+			INSERT INTO replenishment_runs (rule_id, payout_transaction_id, amount, run_status, reason)
+			VALUES ($1, $2, $3::NUMERIC, $4, $5);
+		`, ruleID, nullIfEmpty(payoutTransactionID), amount, status, reason); err != nil {
+			return fmt.Errorf("insert replenishment run: %w", err)
+		}
+		return sp.Commit(ctx)
+	}
+
+	var capReached bool
+	if err := sp.QueryRow(ctx, `SELECT $1::NUMERIC <= 0;`, amount).Scan(&capReached); err != nil {
+		return err
+	}
+	if capReached {
+		return recordRun("0", pkg.ReplenishmentSkipped, "daily cap reached")
+	}
+
+	funder := walletOwners[funderType]
+	tag, err := sp.Exec(ctx, fmt.Sprintf(`
+		UPDATE %[1]s SET %[2]s = %[2]s - $1::NUMERIC
+		WHERE %[3]s = $2 AND %[2]s >= $1::NUMERIC;
+	`, funder.table, funder.balanceColumn, funder.idColumn), amount, funderID)
+	if err != nil {
+		return fmt.Errorf("debit funder: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return recordRun(amount, pkg.ReplenishmentSkipped, "insufficient funder balance")
+	}
+	if _, err := sp.Exec(ctx, `
+		UPDATE users SET user_wallet_balance = user_wallet_balance + $1::NUMERIC WHERE user_id = $2;
+	`, amount, userID); err != nil {
+		return fmt.Errorf("credit retailer: %w", err)
+	}
+
+	if _, err := sp.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO transactions (
+			transactor_id, receiver_id, transactor_name, receiver_name, transactor_type, receiver_type,
+			transaction_type, transaction_service, amount, transaction_status, remarks
+		) VALUES (
+			$1, $2,
+			(SELECT %[1]s FROM %[2]s WHERE %[3]s = $1),
+			(SELECT user_name FROM users WHERE user_id = $2),
+			$3, 'USER', 'DEBIT', 'FUND_TRANSFER', $4, 'SUCCESS', 'AUTO REPLENISHMENT TO USER'
+		);
+	`, funder.nameColumn, funder.table, funder.idColumn), funderID, userID, funderType, amount); err != nil {
+		return fmt.Errorf("insert replenishment transaction: %w", err)
+	}
+
+	if err := recordWalletEvent(ctx, sp, pkg.EventWalletDebited, funderType, funderID, amount, "auto replenishment of retailer"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, sp, pkg.EventWalletCredited, "USER", userID, amount, "auto replenishment"); err != nil {
+		return err
+	}
+	return recordRun(amount, pkg.ReplenishmentSuccess, "")
+}
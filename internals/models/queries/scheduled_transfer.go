@@ -0,0 +1,421 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotInDownline is returned when a schedule or rule names a member who is
+// not under its owner
+var ErrNotInDownline = errors.New("recipient is not in the owner's downline")
+
+const scheduledTransferColumns = `
+	s.schedule_id::TEXT, s.owner_id::TEXT, s.owner_type, s.recipient_id::TEXT, s.recipient_type,
+	COALESCE(d.distributor_name, u.user_name, ''), s.schedule_type, s.amount::TEXT, s.run_time,
+	s.weekday, s.threshold_amount::TEXT, s.target_amount::TEXT, s.active, s.next_run_at,
+	s.last_run_at, s.created_at, s.updated_at
+`
+
+const scheduledTransferRecipients = `
+	LEFT JOIN distributors d ON s.recipient_type = 'DISTRIBUTOR' AND d.distributor_id = s.recipient_id
+	LEFT JOIN users u ON s.recipient_type = 'USER' AND u.user_id = s.recipient_id
+`
+
+func scanScheduledTransfer(row pgx.Row, s *structures.ScheduledTransfer) error {
+	return row.Scan(
+		&s.ScheduleID,
+		&s.OwnerID,
+		&s.OwnerType,
+		&s.RecipientID,
+		&s.RecipientType,
+		&s.RecipientName,
+		&s.ScheduleType,
+		&s.Amount,
+		&s.RunTime,
+		&s.Weekday,
+		&s.ThresholdAmount,
+		&s.TargetAmount,
+		&s.Active,
+		&s.NextRunAt,
+		&s.LastRunAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+}
+
+// getScheduledTransfer reads one of the owner's schedules, locking it when
+// forUpdate is set
+func getScheduledTransfer(ctx context.Context, tx pgx.Tx, ownerID string, scheduleID string, forUpdate bool) (*structures.ScheduledTransfer, error) {
+	lock := ""
+	if forUpdate {
+		lock = "FOR UPDATE OF s"
+	}
+	var res structures.ScheduledTransfer
+	if err := scanScheduledTransfer(tx.QueryRow(ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM scheduled_transfers s
+		`+scheduledTransferRecipients+`
+		WHERE s.schedule_id = $1 AND s.owner_id = $2
+		`+lock+`;
+	`, scheduleID, ownerID), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// checkDownline returns ErrNotInDownline unless the member is under the
+// owner. Both member tables carry the ids of everyone above them.
+func checkDownline(ctx context.Context, tx pgx.Tx, ownerType string, ownerID string, memberType string, memberID string) error {
+	member := walletOwners[memberType]
+	var inDownline bool
+	if err := tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE %s = $1 AND %s = $2);
+	`, member.table, member.idColumn, walletOwners[ownerType].idColumn), memberID, ownerID).Scan(&inDownline); err != nil {
+		return fmt.Errorf("check downline: %w", err)
+	}
+	if !inDownline {
+		return ErrNotInDownline
+	}
+	return nil
+}
+
+// nullIfEmpty stores optional schedule fields as NULL
+func nullIfEmpty(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// CreateScheduledTransfer adds a schedule for a recipient under the owner
+func (q *Query) CreateScheduledTransfer(ctx context.Context, create *structures.ScheduledTransferCreate, audit *structures.AuditLogEntry) (*structures.ScheduledTransfer, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	req := create.Request
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if err := checkDownline(ctx, tx, create.OwnerType, create.OwnerID, req.RecipientType, req.RecipientID); err != nil {
+		return nil, err
+	}
+
+	var scheduleID string
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO scheduled_transfers (
+			owner_id, owner_type, recipient_id, recipient_type, schedule_type, amount,
+			run_time, weekday, threshold_amount, target_amount, next_run_at
+		) VALUES ($1, $2, $3, $4, $5, $6::NUMERIC, $7, $8, $9::NUMERIC, $10::NUMERIC, $11)
+		RETURNING schedule_id::TEXT;
+	`,
+		create.OwnerID,
+		create.OwnerType,
+		req.RecipientID,
+		req.RecipientType,
+		req.ScheduleType,
+		nullIfEmpty(req.Amount),
+		nullIfEmpty(req.RunTime),
+		req.Weekday,
+		nullIfEmpty(req.ThresholdAmount),
+		nullIfEmpty(req.TargetAmount),
+		create.NextRunAt,
+	).Scan(&scheduleID); err != nil {
+		return nil, fmt.Errorf("insert scheduled transfer: %w", err)
+	}
+
+	res, err := getScheduledTransfer(ctx, tx, create.OwnerID, scheduleID, false)
+	if err != nil {
+		return nil, fmt.Errorf("select scheduled transfer: %w", err)
+	}
+	audit.TargetID = scheduleID
+	audit.After = res
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (q *Query) GetScheduledTransfers(ctx context.Context, ownerID string) (*[]structures.ScheduledTransfer, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT `+scheduledTransferColumns+`
+		FROM scheduled_transfers s
+		`+scheduledTransferRecipients+`
+		WHERE s.owner_id = $1
+		ORDER BY s.created_at DESC;
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.ScheduledTransfer
+	for rows.Next() {
+		var s structures.ScheduledTransfer
+		if err := scanScheduledTransfer(rows, &s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SetScheduledTransferActive pauses or resumes one of the owner's schedules.
+// A resumed schedule picks up at its next run from now, runs missed while
+// paused are not made up.
+func (q *Query) SetScheduledTransferActive(ctx context.Context, ownerID string, scheduleID string, active bool, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	before, err := getScheduledTransfer(ctx, tx, ownerID, scheduleID, true)
+	if err != nil {
+		return err
+	}
+
+	nextRunAt := before.NextRunAt
+	if active && !before.Active {
+		runTime, weekday := "", 0
+		if before.RunTime != nil {
+			runTime = *before.RunTime
+		}
+		if before.Weekday != nil {
+			weekday = *before.Weekday
+		}
+		if nextRunAt, err = pkg.NextScheduledRun(before.ScheduleType, runTime, weekday, time.Now()); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE scheduled_transfers SET active = $2, next_run_at = $3 WHERE schedule_id = $1;
+	`, scheduleID, active, nextRunAt); err != nil {
+		return fmt.Errorf("update scheduled transfer: %w", err)
+	}
+
+	after := *before
+	after.Active = active
+	after.NextRunAt = nextRunAt
+	audit.Before = before
+	audit.After = after
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (q *Query) DeleteScheduledTransfer(ctx context.Context, ownerID string, scheduleID string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	before, err := getScheduledTransfer(ctx, tx, ownerID, scheduleID, true)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM scheduled_transfers WHERE schedule_id = $1;`, scheduleID); err != nil {
+		return fmt.Errorf("delete scheduled transfer: %w", err)
+	}
+
+	audit.Before = before
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetScheduledTransferRuns returns the latest runs of one of the owner's
+// schedules
+func (q *Query) GetScheduledTransferRuns(ctx context.Context, ownerID string, scheduleID string, limit int) (*[]structures.ScheduledTransferRun, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT r.run_id, r.schedule_id::TEXT, r.scheduled_for, r.amount::TEXT, r.run_status, r.reason, r.created_at
+		FROM scheduled_transfer_runs r
+		JOIN scheduled_transfers s ON s.schedule_id = r.schedule_id
+		WHERE r.schedule_id = $1 AND s.owner_id = $2
+		ORDER BY r.created_at DESC
+		LIMIT $3;
+	`, scheduleID, ownerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.ScheduledTransferRun
+	for rows.Next() {
+		var run structures.ScheduledTransferRun
+		if err := rows.Scan(
+			&run.RunID,
+			&run.ScheduleID,
+			&run.ScheduledFor,
+			&run.Amount,
+			&run.Status,
+			&run.Reason,
+			&run.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ClaimDueScheduledTransfers claims up to limit active schedules whose run
+// is due and moves each on to its next run, so a run is attempted once even
+// when the transfer itself fails. Concurrent schedulers never claim the same
+// schedule.
+func (q *Query) ClaimDueScheduledTransfers(ctx context.Context, limit int) ([]structures.DueScheduledTransfer, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT
+			s.schedule_id::TEXT, s.owner_id::TEXT, s.owner_type, s.recipient_id::TEXT, s.recipient_type,
+			COALESCE(d.distributor_name, u.user_name, ''), COALESCE(d.distributor_phone, u.user_phone, ''),
+			s.schedule_type, COALESCE(s.amount, 0)::TEXT, COALESCE(s.threshold_amount, 0)::TEXT,
+			COALESCE(s.target_amount, 0)::TEXT, COALESCE(s.run_time, ''), COALESCE(s.weekday, 0), s.next_run_at
+		FROM scheduled_transfers s
+		`+scheduledTransferRecipients+`
+		WHERE s.active AND s.next_run_at <= NOW()
+		ORDER BY s.next_run_at
+		LIMIT $1
+		FOR UPDATE OF s SKIP LOCKED;
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select due scheduled transfers: %w", err)
+	}
+
+	type claim struct {
+		due     structures.DueScheduledTransfer
+		runTime string
+		weekday int
+	}
+	var claims []claim
+	for rows.Next() {
+		var c claim
+		if err := rows.Scan(
+			&c.due.ScheduleID,
+			&c.due.OwnerID,
+			&c.due.OwnerType,
+			&c.due.RecipientID,
+			&c.due.RecipientType,
+			&c.due.RecipientName,
+			&c.due.RecipientPhone,
+			&c.due.ScheduleType,
+			&c.due.Amount,
+			&c.due.ThresholdAmount,
+			&c.due.TargetAmount,
+			&c.runTime,
+			&c.weekday,
+			&c.due.ScheduledFor,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := make([]structures.DueScheduledTransfer, 0, len(claims))
+	for _, c := range claims {
+		nextRunAt, err := pkg.NextScheduledRun(c.due.ScheduleType, c.runTime, c.weekday, now)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", c.due.ScheduleID, err)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE scheduled_transfers SET next_run_at = $2 WHERE schedule_id = $1;
+		`, c.due.ScheduleID, nextRunAt); err != nil {
+			return nil, fmt.Errorf("advance scheduled transfer: %w", err)
+		}
+		res = append(res, c.due)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RecordScheduledTransferRun stores the outcome of a claimed run and tells
+// the owner about runs that were skipped or failed
+func (q *Query) RecordScheduledTransferRun(ctx context.Context, due *structures.DueScheduledTransfer, amount string, status string, reason string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO scheduled_transfer_runs (schedule_id, scheduled_for, amount, run_status, reason)
+		VALUES ($1, $2, $3::NUMERIC, $4, $5);
+	`, due.ScheduleID, due.ScheduledFor, amount, status, reason); err != nil {
+		return fmt.Errorf("insert scheduled transfer run: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE scheduled_transfers SET last_run_at = NOW() WHERE schedule_id = $1;
+	`, due.ScheduleID); err != nil {
+		return fmt.Errorf("update scheduled transfer: %w", err)
+	}
+
+	if status != pkg.ScheduledRunSuccess {
+		if err := insertNotificationEvent(ctx, tx, &structures.NotificationEvent{
+			EventType:     pkg.EventScheduledTransferFailed,
+			RecipientID:   due.OwnerID,
+			RecipientType: due.OwnerType,
+			Data: map[string]string{
+				"schedule_id":    due.ScheduleID,
+				"recipient_name": due.RecipientName,
+				"amount":         amount,
+				"status":         strings.ToLower(status),
+				"reason":         reason,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
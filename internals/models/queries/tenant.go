@@ -0,0 +1,54 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+)
+
+// memberAdmins lists the admin of every member with its id and phone
+const memberAdmins = `
+	SELECT admin_id, admin_id::TEXT AS id, admin_phone AS phone FROM admins
+	UNION ALL SELECT admin_id, master_distributor_id::TEXT, master_distributor_phone FROM master_distributors
+	UNION ALL SELECT admin_id, distributor_id::TEXT, distributor_phone FROM distributors
+	UNION ALL SELECT admin_id, user_id::TEXT, user_phone FROM users
+`
+
+// tenantLookups select the admin owning a tenant scoped resource, by the
+// kind of value a route names the resource with
+var tenantLookups = map[string]string{
+	"MASTER_DISTRIBUTOR":       `SELECT admin_id FROM master_distributors WHERE master_distributor_id::TEXT = $1`,
+	"DISTRIBUTOR":              `SELECT admin_id FROM distributors WHERE distributor_id::TEXT = $1`,
+	"USER":                     `SELECT admin_id FROM users WHERE user_id::TEXT = $1`,
+	"MASTER_DISTRIBUTOR_PHONE": `SELECT admin_id FROM master_distributors WHERE master_distributor_phone = $1`,
+	"DISTRIBUTOR_PHONE":        `SELECT admin_id FROM distributors WHERE distributor_phone = $1`,
+	"USER_PHONE":               `SELECT admin_id FROM users WHERE user_phone = $1`,
+	"WALLET_OWNER":             `SELECT admin_id FROM (` + memberAdmins + `) m WHERE id = $1`,
+	"MEMBER_PHONE":             `SELECT admin_id FROM (` + memberAdmins + `) m WHERE phone = $1`,
+	"FUND_REQUEST":             `SELECT admin_id FROM fund_requests WHERE request_id::TEXT = $1`,
+	"PAYOUT": `
+		SELECT u.admin_id FROM payout_service p JOIN users u ON u.user_id = p.user_id
+		WHERE p.payout_transaction_id::TEXT = $1
+	`,
+	"STAFF":  `SELECT admin_id FROM staff_users WHERE staff_id::TEXT = $1`,
+	"INVITE": `SELECT invited_by FROM admin_invites WHERE invite_id::TEXT = $1`,
+}
+
+// BelongsToOtherAdmin reports whether the value names a resource of the kind
+// that belongs to an admin other than the given one. Values naming nothing
+// belong to nobody.
+func (q *Query) BelongsToOtherAdmin(ctx context.Context, kind string, value string, adminID string) (bool, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	lookup, ok := tenantLookups[kind]
+	if !ok {
+		return false, fmt.Errorf("unknown tenant kind %s", kind)
+	}
+	var other bool
+	if err := q.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM (`+lookup+`) t(admin_id) WHERE admin_id::TEXT <> $2);
+	`, value, adminID).Scan(&other); err != nil {
+		return false, fmt.Errorf("check tenant of %s: %w", kind, err)
+	}
+	return other, nil
+}
@@ -6,7 +6,10 @@ import (
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
 )
 
-func (q *Query) AddNewTicket(req structures.Ticket) error {
+func (q *Query) AddNewTicket(ctx context.Context, req structures.Ticket) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	query := `INSERT INTO tickets(
 		admin_id,
 		name,
@@ -22,14 +25,17 @@ func (q *Query) AddNewTicket(req structures.Ticket) error {
 		$5,
 		$6 
 	)`
-	_, err := q.Pool.Exec(context.Background(), query, req.AdminID, req.Name, req.Subject, req.Mobile, req.Email, req.Message)
+	_, err := q.Pool.Exec(ctx, query, req.AdminID, req.Name, req.Subject, req.Mobile, req.Email, req.Message)
 	return err
 }
 
-func (q *Query) GetAllTickets(adminId string) (*[]structures.Ticket, error) {
+func (q *Query) GetAllTickets(ctx context.Context, adminId string) (*[]structures.Ticket, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	query := `SELECT admin_id, name, subject, phone, email, message FROM tickets WHERE admin_id=$1`
 
-	res, err := q.Pool.Query(context.Background(), query, adminId)
+	res, err := q.Pool.Query(ctx, query, adminId)
 	if err != nil {
 		return nil, err
 	}
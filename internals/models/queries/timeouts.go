@@ -0,0 +1,73 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Timeouts bounds how long each kind of database operation may run. The
+// deadline is applied on top of the caller's context, so a request that is
+// cancelled earlier still stops its queries earlier.
+type Timeouts struct {
+	// Read covers single lookups and short listings
+	Read time.Duration
+	// Write covers statements and transactions that change data
+	Write time.Duration
+	// Report covers listings that scan large ranges of history
+	Report time.Duration
+}
+
+// rollbackTimeout bounds the rollback issued after the caller's context is gone
+const rollbackTimeout = 5 * time.Second
+
+// TimeoutsFromEnv reads DB_READ_TIMEOUT, DB_WRITE_TIMEOUT and DB_REPORT_TIMEOUT
+// (Go durations such as "5s"), falling back to defaults for missing or
+// invalid values.
+func TimeoutsFromEnv() Timeouts {
+	return Timeouts{
+		Read:   durationFromEnv("DB_READ_TIMEOUT", 5*time.Second),
+		Write:  durationFromEnv("DB_WRITE_TIMEOUT", 15*time.Second),
+		Report: durationFromEnv("DB_REPORT_TIMEOUT", 60*time.Second),
+	}
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		slog.Warn("invalid duration in environment, using default", "key", key, "value", value, "default", fallback.String())
+		return fallback
+	}
+	return duration
+}
+
+func (q *Query) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, q.Timeouts.Read)
+}
+
+func (q *Query) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, q.Timeouts.Write)
+}
+
+func (q *Query) reportContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, q.Timeouts.Report)
+}
+
+// rollbackTx rolls back tx even when ctx is already cancelled or past its
+// deadline, so the connection goes back to the pool clean. Rolling back a
+// committed transaction is a no-op.
+func rollbackTx(ctx context.Context, tx pgx.Tx) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		slog.ErrorContext(ctx, "transaction rollback failed", "error", err)
+	}
+}
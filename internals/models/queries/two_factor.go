@@ -0,0 +1,368 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication not set up")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TOTPCheck verifies the submitted code against the stored, encrypted secret.
+// Only time steps after lastStep may match; the matching step is returned.
+type TOTPCheck func(encryptedSecret string, lastStep int64) (step int64, valid bool, err error)
+
+// SaveTwoFactorSecret stores a new secret awaiting confirmation, replacing
+// any earlier unconfirmed one. Enabled secrets are never overwritten.
+func (q *Query) SaveTwoFactorSecret(ctx context.Context, ownerID string, ownerType string, encryptedSecret string) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tag, err := q.Pool.Exec(ctx, `
+		INSERT INTO two_factor_credentials (owner_id, owner_type, secret_encrypted)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (owner_id) DO UPDATE SET
+			secret_encrypted = EXCLUDED.secret_encrypted,
+			last_step = 0,
+			failed_attempts = 0,
+			locked_until = NULL,
+			created_at = NOW()
+		WHERE two_factor_credentials.enabled_at IS NULL;
+	`, ownerID, ownerType, encryptedSecret)
+	if err != nil {
+		return fmt.Errorf("save two-factor secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// replaceRecoveryCodes swaps all of the owner's recovery codes for new ones
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, ownerID string, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM two_factor_recovery_codes WHERE owner_id = $1;`, ownerID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO two_factor_recovery_codes (owner_id, code_hash)
+		SELECT $1, unnest($2::TEXT[]);
+	`, ownerID, codeHashes); err != nil {
+		return fmt.Errorf("insert recovery codes: %w", err)
+	}
+	return nil
+}
+
+// EnableTwoFactor turns two-factor authentication on once the first code from
+// the authenticator app matches the pending secret.
+func (q *Query) EnableTwoFactor(ctx context.Context, ownerID string, check TOTPCheck, recoveryCodeHashes []string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var (
+		secret    string
+		enabledAt *time.Time
+		lastStep  int64
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT secret_encrypted, enabled_at, last_step
+		FROM two_factor_credentials
+		WHERE owner_id = $1
+		FOR UPDATE;
+	`, ownerID).Scan(&secret, &enabledAt, &lastStep)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTwoFactorNotSetUp
+	}
+	if err != nil {
+		return err
+	}
+	if enabledAt != nil {
+		return ErrTwoFactorAlreadyEnabled
+	}
+
+	step, valid, err := check(secret, lastStep)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE two_factor_credentials SET enabled_at = NOW(), last_step = $2
+		WHERE owner_id = $1;
+	`, ownerID, step); err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	if err := replaceRecoveryCodes(ctx, tx, ownerID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// VerifyTwoFactor checks a TOTP code, or failing that a recovery code, while
+// holding the credential's row lock so parallel guesses cannot slip past the
+// attempt counter. Accepted TOTP steps are remembered to stop replays and
+// recovery codes are used up.
+func (q *Query) VerifyTwoFactor(ctx context.Context, ownerID string, check TOTPCheck, recoveryCodeHash string) (*structures.TwoFactorVerification, error) {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var (
+		secret         string
+		enabledAt      *time.Time
+		lastStep       int64
+		failedAttempts int
+		lockedUntil    *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT secret_encrypted, enabled_at, last_step, failed_attempts, locked_until
+		FROM two_factor_credentials
+		WHERE owner_id = $1
+		FOR UPDATE;
+	`, ownerID).Scan(&secret, &enabledAt, &lastStep, &failedAttempts, &lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && enabledAt == nil) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil && lockedUntil.After(time.Now()) {
+		return &structures.TwoFactorVerification{Locked: true, LockedUntil: *lockedUntil}, nil
+	}
+
+	var res structures.TwoFactorVerification
+	step, valid, err := check(secret, lastStep)
+	if err != nil {
+		return nil, err
+	}
+	if valid {
+		res.Valid = true
+		lastStep = step
+	} else if recoveryCodeHash != "" {
+		tag, err := tx.Exec(ctx, `
+			UPDATE two_factor_recovery_codes SET used_at = NOW()
+			WHERE code_id = (
+				SELECT code_id FROM two_factor_recovery_codes
+				WHERE owner_id = $1 AND code_hash = $2 AND used_at IS NULL
+				LIMIT 1
+			);
+		`, ownerID, recoveryCodeHash)
+		if err != nil {
+			return nil, fmt.Errorf("use recovery code: %w", err)
+		}
+		res.Valid = tag.RowsAffected() > 0
+		res.UsedRecoveryCode = res.Valid
+	}
+
+	var newLock *time.Time
+	if res.Valid {
+		failedAttempts = 0
+	} else {
+		failedAttempts++
+		if failedAttempts >= pkg.TwoFactorMaxAttempts {
+			until := time.Now().Add(pkg.TwoFactorLockout)
+			newLock = &until
+			failedAttempts = 0
+			res.Locked = true
+			res.LockedUntil = until
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE two_factor_credentials SET
+			last_step = $2,
+			failed_attempts = $3,
+			locked_until = $4
+		WHERE owner_id = $1;
+	`, ownerID, lastStep, failedAttempts, newLock); err != nil {
+		return nil, fmt.Errorf("record two-factor attempt: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of an enabled account
+func (q *Query) RegenerateRecoveryCodes(ctx context.Context, ownerID string, recoveryCodeHashes []string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	var enabled bool
+	err = tx.QueryRow(ctx, `
+		SELECT enabled_at IS NOT NULL FROM two_factor_credentials WHERE owner_id = $1 FOR UPDATE;
+	`, ownerID).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		return ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, ownerID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DisableTwoFactor removes the secret and recovery codes of the account
+func (q *Query) DisableTwoFactor(ctx context.Context, ownerID string, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM two_factor_credentials WHERE owner_id = $1 AND enabled_at IS NOT NULL;`, ownerID)
+	if err != nil {
+		return fmt.Errorf("disable two-factor: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (q *Query) GetTwoFactorStatus(ctx context.Context, ownerID string) (*structures.TwoFactorStatus, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var res structures.TwoFactorStatus
+	err := q.Pool.QueryRow(ctx, `
+		SELECT
+			c.enabled_at,
+			(SELECT COUNT(*) FROM two_factor_recovery_codes r WHERE r.owner_id = c.owner_id AND r.used_at IS NULL)
+		FROM two_factor_credentials c
+		WHERE c.owner_id = $1;
+	`, ownerID).Scan(&res.EnabledAt, &res.RecoveryCodesLeft)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Enabled = res.EnabledAt != nil
+	if !res.Enabled {
+		res.RecoveryCodesLeft = 0
+	}
+	return &res, nil
+}
+
+// GetTwoFactorPolicy returns the role's policy for the account, the one set
+// by the admin the account belongs to. Admins and their staff users belong to
+// the admin itself. Policies are not enforced until the admin sets them.
+func (q *Query) GetTwoFactorPolicy(ctx context.Context, accountID string, role string) (*structures.TwoFactorPolicy, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	res := structures.TwoFactorPolicy{Role: role}
+	err := q.Pool.QueryRow(ctx, `
+		SELECT admin_id::TEXT, enforced, grace_until, updated_by, updated_at
+		FROM two_factor_policies
+		WHERE role = $2 AND admin_id = (
+			SELECT admin_id FROM admins WHERE admin_id::TEXT = $1
+			UNION ALL SELECT admin_id FROM staff_users WHERE staff_id::TEXT = $1
+			UNION ALL SELECT admin_id FROM master_distributors WHERE master_distributor_id::TEXT = $1
+			LIMIT 1
+		);
+	`, accountID, role).Scan(&res.AdminID, &res.Enforced, &res.GraceUntil, &res.UpdatedBy, &res.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetTwoFactorPolicies returns the admin's policy for every role
+func (q *Query) GetTwoFactorPolicies(ctx context.Context, adminID string) (*[]structures.TwoFactorPolicy, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT $1::TEXT, r.role, COALESCE(p.enforced, FALSE), p.grace_until, COALESCE(p.updated_by, ''), COALESCE(p.updated_at, 'epoch')
+		FROM unnest(ARRAY['ADMIN', 'MASTER_DISTRIBUTOR']) AS r(role)
+		LEFT JOIN two_factor_policies p ON p.role = r.role AND p.admin_id::TEXT = $1
+		ORDER BY r.role;
+	`, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.TwoFactorPolicy
+	for rows.Next() {
+		var policy structures.TwoFactorPolicy
+		if err := rows.Scan(&policy.AdminID, &policy.Role, &policy.Enforced, &policy.GraceUntil, &policy.UpdatedBy, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (q *Query) SetTwoFactorPolicy(ctx context.Context, policy *structures.TwoFactorPolicy, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer rollbackTx(ctx, tx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO two_factor_policies (admin_id, role, enforced, grace_until, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (admin_id, role) DO UPDATE SET
+			enforced = EXCLUDED.enforced,
+			grace_until = EXCLUDED.grace_until,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW();
+	`, policy.AdminID, policy.Role, policy.Enforced, policy.GraceUntil, policy.UpdatedBy); err != nil {
+		return fmt.Errorf("set two-factor policy: %w", err)
+	}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
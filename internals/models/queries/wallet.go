@@ -2,25 +2,40 @@ package queries
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
 	"github.com/jackc/pgx/v5"
 )
 
+// ErrInsufficientBalance is returned by fund transfers and refunds the wallet
+// they draw from cannot cover
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
 // Admin Wallet Functions
 
-func (q *Query) GetAdminWalletBalance(adminId string) (string, error) {
+func (q *Query) GetAdminWalletBalance(ctx context.Context, adminId string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var balance string
 	query := `SELECT admin_wallet_balance FROM admins WHERE admin_id=$1`
-	err := q.Pool.QueryRow(context.Background(), query, adminId).Scan(&balance)
+	err := q.Pool.QueryRow(ctx, query, adminId).Scan(&balance)
 	return balance, err
 }
 
-func (q *Query) AdminWalletTopup(req *structures.AdminWalletTopupRequest) error {
-	query1 := `UPDATE admins SET admin_wallet_balance = admin_wallet_balance + $2::numeric WHERE admin_id=$1 RETURNING admin_name`
+func (q *Query) AdminWalletTopup(ctx context.Context, req *structures.AdminWalletTopupRequest, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
+	query1 := `
+		UPDATE admins SET admin_wallet_balance = admin_wallet_balance + $2::numeric
+		WHERE admin_id=$1
+		RETURNING admin_name, (admin_wallet_balance - $2::numeric)::TEXT, admin_wallet_balance::TEXT
+	`
 	query2 := `INSERT INTO 
 	transactions(
 		transactor_id,
@@ -48,21 +63,28 @@ func (q *Query) AdminWalletTopup(req *structures.AdminWalletTopupRequest) error
 		$4
 	)
 	`
-	var adminName string
-	tx, err := q.Pool.BeginTx(context.Background(), pgx.TxOptions{})
+	var adminName, beforeBalance, afterBalance string
+	tx, err := q.Pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return err
 	}
-	defer func() { _ = tx.Rollback(context.Background()) }()
+	defer rollbackTx(ctx, tx)
 
-	if err := tx.QueryRow(context.Background(), query1, req.AdminId, req.Amount).Scan(&adminName); err != nil {
+	if err := tx.QueryRow(ctx, query1, req.AdminId, req.Amount).Scan(&adminName, &beforeBalance, &afterBalance); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(context.Background(), query2, req.AdminId, adminName, req.Amount, req.Remarks); err != nil {
+	if _, err := tx.Exec(ctx, query2, req.AdminId, adminName, req.Amount, req.Remarks); err != nil {
 		return err
 	}
-	if err := tx.Commit(context.Background()); err != nil {
+
+	audit.Before = map[string]string{"admin_wallet_balance": beforeBalance}
+	audit.After = map[string]string{"admin_wallet_balance": afterBalance, "amount": req.Amount, "remarks": req.Remarks}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
 	return nil
@@ -70,32 +92,41 @@ func (q *Query) AdminWalletTopup(req *structures.AdminWalletTopupRequest) error
 
 // Master Distributor Wallet Function
 
-func (q *Query) GetMasterDistributorWalletBalance(masterDistributorId string) (string, error) {
+func (q *Query) GetMasterDistributorWalletBalance(ctx context.Context, masterDistributorId string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var balance string
 	query := `SELECT master_distributor_wallet_balance FROM master_distributors WHERE master_distributor_id=$1`
-	err := q.Pool.QueryRow(context.Background(), query, masterDistributorId).Scan(&balance)
+	err := q.Pool.QueryRow(ctx, query, masterDistributorId).Scan(&balance)
 	return balance, err
 }
 
 // Distributor Wallet Function
 
-func (q *Query) GetDistributorWalletBalance(distributorId string) (string, error) {
+func (q *Query) GetDistributorWalletBalance(ctx context.Context, distributorId string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var balance string
 	query := `SELECT distributor_wallet_balance FROM distributors WHERE distributor_id=$1`
-	err := q.Pool.QueryRow(context.Background(), query, distributorId).Scan(&balance)
+	err := q.Pool.QueryRow(ctx, query, distributorId).Scan(&balance)
 	return balance, err
 }
 
 // User Wallet Function
 
-func (q *Query) GetUserWalletBalance(userId string) (string, error) {
+func (q *Query) GetUserWalletBalance(ctx context.Context, userId string) (string, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
 	var balance string
 	query := `SELECT user_wallet_balance FROM users WHERE user_id=$1`
-	err := q.Pool.QueryRow(context.Background(), query, userId).Scan(&balance)
+	err := q.Pool.QueryRow(ctx, query, userId).Scan(&balance)
 	return balance, err
 }
 
-func (q *Query) GetTransactions(userId string) (*[]structures.WalletTransaction, error) {
+func (q *Query) GetTransactions(ctx context.Context, userId string) (*[]structures.WalletTransaction, error) {
 	const query = `
 		SELECT
 			transaction_id,
@@ -119,7 +150,7 @@ func (q *Query) GetTransactions(userId string) (*[]structures.WalletTransaction,
 			created_at DESC;
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := q.reportContext(ctx)
 	defer cancel()
 
 	rows, err := q.Pool.Query(ctx, query, userId)
@@ -157,11 +188,15 @@ func (q *Query) GetTransactions(userId string) (*[]structures.WalletTransaction,
 	return &transactions, nil
 }
 
-func (q *Query) UserRefund(req *structures.RefundRequest) error {
+func (q *Query) UserRefund(ctx context.Context, req *structures.RefundRequest, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateUserWalletBalanceQuery := `
 		UPDATE users
 		SET user_wallet_balance = user_wallet_balance - $1::NUMERIC
-		WHERE user_phone = $2 AND user_wallet_balance >= $1::NUMERIC;
+		WHERE user_phone = $2 AND user_wallet_balance >= $1::NUMERIC
+		RETURNING user_id::TEXT, (user_wallet_balance + $1::NUMERIC)::TEXT, user_wallet_balance::TEXT;
 	`
 	updateAdminWalletBalanceQuery := `
 		UPDATE admins
@@ -170,56 +205,74 @@ func (q *Query) UserRefund(req *structures.RefundRequest) error {
 	`
 	addToHistory := `
 		WITH user_details AS(
-			SELECT user_unique_id, user_name, user_phone
+			SELECT user_unique_id, user_name, user_phone, user_id
 			FROM users WHERE user_phone=$1
 		)
-		INSERT INTO revert_history(unique_id,name,phone,amount)
+		INSERT INTO revert_history(unique_id,name,phone,amount,member_type,member_id,reverted_by_type,reverted_by_id)
 		VALUES(
 			(SELECT user_unique_id FROM user_details),
 			(SELECT user_name FROM user_details),
 			(SELECT user_phone FROM user_details),
-			$2
+			$2,
+			'USER',
+			(SELECT user_id FROM user_details),
+			'ADMIN',
+			$3
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from user wallet
-	cmdTag, err := tx.Exec(ctx, updateUserWalletBalanceQuery, req.Amount, req.PhoneNumber)
+	var userID, beforeBalance, afterBalance string
+	err = tx.QueryRow(ctx, updateUserWalletBalanceQuery, req.Amount, req.PhoneNumber).Scan(&userID, &beforeBalance, &afterBalance)
+	if err == pgx.ErrNoRows {
+		// No row updated => user not found or insufficient balance
+		return fmt.Errorf("user refund failed: %w or user not found", ErrInsufficientBalance)
+	}
 	if err != nil {
 		return err
 	}
 
-	if cmdTag.RowsAffected() == 0 {
-		// No row updated => user not found or insufficient balance
-		return fmt.Errorf("user refund failed: insufficient balance or user not found")
-	}
-
 	// 2. Credit admin wallet
 	if _, err := tx.Exec(ctx, updateAdminWalletBalanceQuery, req.Amount, req.AdminID); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount, req.AdminID); err != nil {
+		slog.Error("query failed", "query", "UserRefund", "error", err)
 		return err
 	}
 
-	// 3. Commit
+	audit.TargetID = userID
+	audit.Before = map[string]string{"user_wallet_balance": beforeBalance}
+	audit.After = map[string]string{"user_wallet_balance": afterBalance, "amount": req.Amount, "phone_number": req.PhoneNumber}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	// 3. Notify the retailer
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "USER", userID, req.Amount, "reverted by admin"); err != nil {
+		return err
+	}
+
+	// 4. Commit
 	return tx.Commit(ctx)
 }
 
-func (q *Query) MasterDistributorRefund(req *structures.RefundRequest) error {
+func (q *Query) MasterDistributorRefund(ctx context.Context, req *structures.RefundRequest, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateMdWalletBalanceQuery := `
 		UPDATE master_distributors
 		SET master_distributor_wallet_balance = master_distributor_wallet_balance - $1::NUMERIC
-		WHERE master_distributor_phone = $2 AND master_distributor_wallet_balance >= $1::NUMERIC;
+		WHERE master_distributor_phone = $2 AND master_distributor_wallet_balance >= $1::NUMERIC
+		RETURNING master_distributor_id::TEXT, (master_distributor_wallet_balance + $1::NUMERIC)::TEXT, master_distributor_wallet_balance::TEXT;
 	`
 	updateAdminWalletBalanceQuery := `
 		UPDATE admins
@@ -229,54 +282,72 @@ func (q *Query) MasterDistributorRefund(req *structures.RefundRequest) error {
 
 	addToHistory := `
 		WITH md_details AS(
-			SELECT master_distributor_unique_id, master_distributor_name, master_distributor_phone
+			SELECT master_distributor_unique_id, master_distributor_name, master_distributor_phone, master_distributor_id
 			FROM master_distributors WHERE master_distributor_phone=$1
 		)
-		INSERT INTO revert_history(unique_id,name,phone,amount)
+		INSERT INTO revert_history(unique_id,name,phone,amount,member_type,member_id,reverted_by_type,reverted_by_id)
 		VALUES(
 			(SELECT master_distributor_unique_id FROM md_details),
 			(SELECT master_distributor_name FROM md_details),
 			(SELECT master_distributor_phone FROM md_details),
-			$2
+			$2,
+			'MASTER_DISTRIBUTOR',
+			(SELECT master_distributor_id FROM md_details),
+			'ADMIN',
+			$3
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
-	cmdTag, err := tx.Exec(ctx, updateMdWalletBalanceQuery, req.Amount, req.PhoneNumber)
+	var masterDistributorID, beforeBalance, afterBalance string
+	err = tx.QueryRow(ctx, updateMdWalletBalanceQuery, req.Amount, req.PhoneNumber).Scan(&masterDistributorID, &beforeBalance, &afterBalance)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("master distributor refund failed: %w or MD not found", ErrInsufficientBalance)
+	}
 	if err != nil {
 		return err
 	}
 
-	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("master distributor refund failed: insufficient balance or MD not found")
-	}
-
 	// 2. Credit admin wallet
 	if _, err := tx.Exec(ctx, updateAdminWalletBalanceQuery, req.Amount, req.AdminID); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount, req.AdminID); err != nil {
+		slog.Error("query failed", "query", "MasterDistributorRefund", "error", err)
+		return err
+	}
+
+	audit.TargetID = masterDistributorID
+	audit.Before = map[string]string{"master_distributor_wallet_balance": beforeBalance}
+	audit.After = map[string]string{"master_distributor_wallet_balance": afterBalance, "amount": req.Amount, "phone_number": req.PhoneNumber}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	// Notify the master distributor
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "MASTER_DISTRIBUTOR", masterDistributorID, req.Amount, "reverted by admin"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) DistributorRefund(req *structures.RefundRequest) error {
+func (q *Query) DistributorRefund(ctx context.Context, req *structures.RefundRequest, audit *structures.AuditLogEntry) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateDistributorWalletBalanceQuery := `
 		UPDATE distributors
 		SET distributor_wallet_balance = distributor_wallet_balance - $1::NUMERIC
-		WHERE distributor_phone = $2 AND distributor_wallet_balance >= $1::NUMERIC;
+		WHERE distributor_phone = $2 AND distributor_wallet_balance >= $1::NUMERIC
+		RETURNING distributor_id::TEXT, (distributor_wallet_balance + $1::NUMERIC)::TEXT, distributor_wallet_balance::TEXT;
 	`
 	updateAdminWalletBalanceQuery := `
 		UPDATE admins
@@ -285,50 +356,67 @@ func (q *Query) DistributorRefund(req *structures.RefundRequest) error {
 	`
 	addToHistory := `
 		WITH dis_details AS(
-			SELECT distributor_unique_id, distributor_name, distributor_phone
+			SELECT distributor_unique_id, distributor_name, distributor_phone, distributor_id
 			FROM distributors WHERE distributor_phone=$1
 		)
-		INSERT INTO revert_history(unique_id,name,phone,amount)
+		INSERT INTO revert_history(unique_id,name,phone,amount,member_type,member_id,reverted_by_type,reverted_by_id)
 		VALUES(
 			(SELECT distributor_unique_id FROM dis_details),
 			(SELECT distributor_name FROM dis_details),
 			(SELECT distributor_phone FROM dis_details),
-			$2
+			$2,
+			'DISTRIBUTOR',
+			(SELECT distributor_id FROM dis_details),
+			'ADMIN',
+			$3
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from distributor wallet
-	cmdTag, err := tx.Exec(ctx, updateDistributorWalletBalanceQuery, req.Amount, req.PhoneNumber)
+	var distributorID, beforeBalance, afterBalance string
+	err = tx.QueryRow(ctx, updateDistributorWalletBalanceQuery, req.Amount, req.PhoneNumber).Scan(&distributorID, &beforeBalance, &afterBalance)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("distributor refund failed: %w or distributor not found", ErrInsufficientBalance)
+	}
 	if err != nil {
 		return err
 	}
 
-	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("distributor refund failed: insufficient balance or distributor not found")
-	}
-
 	// 2. Credit admin wallet
 	if _, err := tx.Exec(ctx, updateAdminWalletBalanceQuery, req.Amount, req.AdminID); err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount, req.AdminID); err != nil {
+		slog.Error("query failed", "query", "DistributorRefund", "error", err)
+		return err
+	}
+
+	audit.TargetID = distributorID
+	audit.Before = map[string]string{"distributor_wallet_balance": beforeBalance}
+	audit.After = map[string]string{"distributor_wallet_balance": afterBalance, "amount": req.Amount, "phone_number": req.PhoneNumber}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	// Notify the distributor
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "DISTRIBUTOR", distributorID, req.Amount, "reverted by admin"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) MDUserRefund(req *structures.MasterDistributorFundRetailerRequest) error {
+func (q *Query) MDUserRefund(ctx context.Context, req *structures.MasterDistributorFundRetailerRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateMdWalletBalanceQuery := `
 		UPDATE master_distributors
 		SET master_distributor_wallet_balance = master_distributor_wallet_balance + $1::NUMERIC
@@ -342,25 +430,27 @@ func (q *Query) MDUserRefund(req *structures.MasterDistributorFundRetailerReques
 
 	addToHistory := `
 		WITH user_details AS(
-			SELECT user_unique_id, user_name, user_phone
+			SELECT user_unique_id, user_name, user_phone, user_id
 			FROM users WHERE user_phone=$1
 		)
-		INSERT INTO revert_history(unique_id,name,phone,amount)
+		INSERT INTO revert_history(unique_id,name,phone,amount,member_type,member_id,reverted_by_type,reverted_by_id)
 		VALUES(
 			(SELECT user_unique_id FROM user_details),
 			(SELECT user_name FROM user_details),
 			(SELECT user_phone FROM user_details),
-			$2
+			$2,
+			'USER',
+			(SELECT user_id FROM user_details),
+			'MASTER_DISTRIBUTOR',
+			$3
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
 	cmdTag, err := tx.Exec(ctx, updateAdminWalletBalanceQuery, req.Amount, req.PhoneNumber)
@@ -377,15 +467,30 @@ func (q *Query) MDUserRefund(req *structures.MasterDistributorFundRetailerReques
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount, req.MasterDistributorID); err != nil {
+		slog.Error("query failed", "query", "MDUserRefund", "error", err)
+		return err
+	}
+
+	// Notify both wallets
+	userID, err := walletOwnerIDByPhone(ctx, tx, "USER", req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "USER", userID, req.Amount, "reverted by master distributor"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "MASTER_DISTRIBUTOR", req.MasterDistributorID, req.Amount, "reverted from retailer"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) MDDistributorRefund(req *structures.MasterDistributorFundRetailerRequest) error {
+func (q *Query) MDDistributorRefund(ctx context.Context, req *structures.MasterDistributorFundRetailerRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateMdWalletBalanceQuery := `
 		UPDATE master_distributors
 		SET master_distributor_wallet_balance = master_distributor_wallet_balance + $1::NUMERIC
@@ -399,25 +504,27 @@ func (q *Query) MDDistributorRefund(req *structures.MasterDistributorFundRetaile
 
 	addToHistory := `
 		WITH distributor_details AS(
-			SELECT distributor_unique_id, distributor_name, distributor_phone
+			SELECT distributor_unique_id, distributor_name, distributor_phone, distributor_id
 			FROM distributors WHERE distributor_phone=$1
 		)
-		INSERT INTO revert_history(unique_id,name,phone,amount)
+		INSERT INTO revert_history(unique_id,name,phone,amount,member_type,member_id,reverted_by_type,reverted_by_id)
 		VALUES(
 			(SELECT distributor_unique_id FROM distributor_details),
 			(SELECT distributor_name FROM distributor_details),
 			(SELECT distributor_phone FROM distributor_details),
-			$2
+			$2,
+			'DISTRIBUTOR',
+			(SELECT distributor_id FROM distributor_details),
+			'MASTER_DISTRIBUTOR',
+			$3
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
 	cmdTag, err := tx.Exec(ctx, updateAdminWalletBalanceQuery, req.Amount, req.PhoneNumber)
@@ -434,15 +541,30 @@ func (q *Query) MDDistributorRefund(req *structures.MasterDistributorFundRetaile
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount, req.MasterDistributorID); err != nil {
+		slog.Error("query failed", "query", "MDDistributorRefund", "error", err)
+		return err
+	}
+
+	// Notify both wallets
+	distributorID, err := walletOwnerIDByPhone(ctx, tx, "DISTRIBUTOR", req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "DISTRIBUTOR", distributorID, req.Amount, "reverted by master distributor"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "MASTER_DISTRIBUTOR", req.MasterDistributorID, req.Amount, "reverted from distributor"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) DistributorUserRefund(req *structures.DistributorFundRetailerRequest) error {
+func (q *Query) DistributorUserRefund(ctx context.Context, req *structures.DistributorFundRetailerRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateMdWalletBalanceQuery := `
 		UPDATE distributors
 		SET distributor_wallet_balance = distributor_wallet_balance + $1::NUMERIC
@@ -456,25 +578,27 @@ func (q *Query) DistributorUserRefund(req *structures.DistributorFundRetailerReq
 
 	addToHistory := `
 		WITH user_details AS(
-			SELECT user_unique_id, user_name, user_phone
+			SELECT user_unique_id, user_name, user_phone, user_id
 			FROM users WHERE user_phone=$1
 		)
-		INSERT INTO revert_history(unique_id,name,phone,amount)
+		INSERT INTO revert_history(unique_id,name,phone,amount,member_type,member_id,reverted_by_type,reverted_by_id)
 		VALUES(
 			(SELECT user_unique_id FROM user_details),
 			(SELECT user_name FROM user_details),
 			(SELECT user_phone FROM user_details),
-			$2
+			$2,
+			'USER',
+			(SELECT user_id FROM user_details),
+			'DISTRIBUTOR',
+			$3
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
 	cmdTag, err := tx.Exec(ctx, updateAdminWalletBalanceQuery, req.Amount, req.PhoneNumber)
@@ -491,15 +615,30 @@ func (q *Query) DistributorUserRefund(req *structures.DistributorFundRetailerReq
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+	if _, err := tx.Exec(ctx, addToHistory, req.PhoneNumber, req.Amount, req.DistributorID); err != nil {
+		slog.Error("query failed", "query", "DistributorUserRefund", "error", err)
+		return err
+	}
+
+	// Notify both wallets
+	userID, err := walletOwnerIDByPhone(ctx, tx, "USER", req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "USER", userID, req.Amount, "reverted by distributor"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "DISTRIBUTOR", req.DistributorID, req.Amount, "reverted from retailer"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) MasterDistributorFundRetailer(req *structures.MasterDistributorFundRetailerRequest) error {
+func (q *Query) MasterDistributorFundRetailer(ctx context.Context, req *structures.MasterDistributorFundRetailerRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateMdWalletBalanceQuery := `
 		UPDATE master_distributors
 		SET master_distributor_wallet_balance = master_distributor_wallet_balance - $1::NUMERIC
@@ -548,40 +687,53 @@ func (q *Query) MasterDistributorFundRetailer(req *structures.MasterDistributorF
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
 	cmdTag, err := tx.Exec(ctx, updateMdWalletBalanceQuery, req.Amount, req.MasterDistributorID)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundRetailer", "error", err)
 		return err
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("master distributor refund failed: insufficient balance or user not found")
+		return fmt.Errorf("master distributor fund transfer failed: %w", ErrInsufficientBalance)
 	}
 
 	// 2. Credit admin wallet
 	if _, err := tx.Exec(ctx, updateUserWalletBalanceQuery, req.Amount, req.PhoneNumber); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundRetailer", "error", err)
 		return err
 	}
 
 	if _, err := tx.Exec(ctx, updateTransaction, req.MasterDistributorID, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundRetailer", "error", err)
+		return err
+	}
+
+	// Notify both wallets
+	userID, err := walletOwnerIDByPhone(ctx, tx, "USER", req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "MASTER_DISTRIBUTOR", req.MasterDistributorID, req.Amount, "fund transfer to retailer"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "USER", userID, req.Amount, "fund transfer from master distributor"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) MasterDistributorFundDistributor(req *structures.MasterDistributorFundRetailerRequest) error {
+func (q *Query) MasterDistributorFundDistributor(ctx context.Context, req *structures.MasterDistributorFundRetailerRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateMdWalletBalanceQuery := `
 		UPDATE master_distributors
 		SET master_distributor_wallet_balance = master_distributor_wallet_balance - $1::NUMERIC
@@ -630,45 +782,58 @@ INSERT INTO transactions (
 );
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundDistributor", "error", err)
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
 	cmdTag, err := tx.Exec(ctx, updateMdWalletBalanceQuery, req.Amount, req.MasterDistributorID)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundDistributor", "error", err)
 		return err
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("master distributor refund failed: insufficient balance or user not found")
+		return fmt.Errorf("master distributor fund transfer failed: %w", ErrInsufficientBalance)
 	}
 
 	// 2. Credit admin wallet
 	if _, err := tx.Exec(ctx, updateUserWalletBalanceQuery, req.Amount, req.PhoneNumber); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundDistributor", "error", err)
 		return err
 	}
 
 	if _, err := tx.Exec(ctx, updateTransaction, req.MasterDistributorID, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "MasterDistributorFundDistributor", "error", err)
+		return err
+	}
+
+	// Notify both wallets
+	distributorID, err := walletOwnerIDByPhone(ctx, tx, "DISTRIBUTOR", req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "MASTER_DISTRIBUTOR", req.MasterDistributorID, req.Amount, "fund transfer to distributor"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "DISTRIBUTOR", distributorID, req.Amount, "fund transfer from master distributor"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) DistributorFundRetailer(req *structures.DistributorFundRetailerRequest) error {
+func (q *Query) DistributorFundRetailer(ctx context.Context, req *structures.DistributorFundRetailerRequest) error {
+	ctx, cancel := q.writeContext(ctx)
+	defer cancel()
+
 	updateDistributorWalletBalanceQuery := `
 		UPDATE distributors
 		SET distributor_wallet_balance = distributor_wallet_balance - $1::NUMERIC
-		WHERE distributor_id = $2  AND distributor_wallet_balance >= $1::NUMERIC;
+		WHERE distributor_id = $2  AND distributor_wallet_balance + available_credit(distributor_id) >= $1::NUMERIC;
 	`
 	updateUserWalletBalanceQuery := `
 		UPDATE users
@@ -714,41 +879,54 @@ func (q *Query) DistributorFundRetailer(req *structures.DistributorFundRetailerR
 		);
 	`
 
-	ctx := context.Background()
-
 	tx, err := q.Pool.Begin(ctx)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "DistributorFundRetailer", "error", err)
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer rollbackTx(ctx, tx)
 
 	// 1. Deduct from MD wallet
 	cmdTag, err := tx.Exec(ctx, updateDistributorWalletBalanceQuery, req.Amount, req.DistributorID)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "DistributorFundRetailer", "error", err)
 		return err
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("distributor refund failed: insufficient balance or user not found")
+		return fmt.Errorf("distributor fund transfer failed: %w", ErrInsufficientBalance)
 	}
 
 	// 2. Credit admin wallet
 	if _, err := tx.Exec(ctx, updateUserWalletBalanceQuery, req.Amount, req.PhoneNumber); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "DistributorFundRetailer", "error", err)
 		return err
 	}
 
 	if _, err := tx.Exec(ctx, updateTransaction, req.DistributorID, req.PhoneNumber, req.Amount); err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "DistributorFundRetailer", "error", err)
+		return err
+	}
+
+	// Notify both wallets
+	userID, err := walletOwnerIDByPhone(ctx, tx, "USER", req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, "DISTRIBUTOR", req.DistributorID, req.Amount, "fund transfer to retailer"); err != nil {
+		return err
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "USER", userID, req.Amount, "fund transfer from distributor"); err != nil {
 		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (q *Query) GetRevertHistoryPhone(phoneNumber string) (*[]structures.GetRevertHistory, error) {
+func (q *Query) GetRevertHistoryPhone(ctx context.Context, phoneNumber string) (*[]structures.GetRevertHistory, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
 	query := `
 		SELECT revert_id::TEXT, unique_id, name, phone, amount, created_at::TEXT
 		FROM revert_history
@@ -756,9 +934,9 @@ func (q *Query) GetRevertHistoryPhone(phoneNumber string) (*[]structures.GetReve
 	`
 	var revertHistories []structures.GetRevertHistory
 
-	res, err := q.Pool.Query(context.Background(), query, phoneNumber)
+	res, err := q.Pool.Query(ctx, query, phoneNumber)
 	if err != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "GetRevertHistoryPhone", "error", err)
 		return nil, fmt.Errorf("failed to fetch revert history")
 	}
 	defer res.Close()
@@ -773,27 +951,36 @@ func (q *Query) GetRevertHistoryPhone(phoneNumber string) (*[]structures.GetReve
 			&revertHistrory.Amount,
 			&revertHistrory.CreatedAt,
 		); err != nil {
-			log.Println(err)
+			slog.Error("query failed", "query", "GetRevertHistoryPhone", "error", err)
 			return nil, fmt.Errorf("failed to fetch revert history")
 		}
 		revertHistories = append(revertHistories, revertHistrory)
 	}
 
 	if res.Err() != nil {
-		log.Println(err)
+		slog.Error("query failed", "query", "GetRevertHistoryPhone", "error", err)
 		return nil, fmt.Errorf("failed to fetch revert history")
 	}
 	return &revertHistories, nil
 }
 
-func (q *Query) GetRevertHistory() (*[]structures.GetRevertHistory, error) {
+// GetRevertHistory returns the reverts of the admin's members
+func (q *Query) GetRevertHistory(ctx context.Context, adminID string) (*[]structures.GetRevertHistory, error) {
+	ctx, cancel := q.reportContext(ctx)
+	defer cancel()
+
 	query := `
 		SELECT revert_id::TEXT, unique_id, name, phone, amount, created_at::TEXT
-		FROM revert_history;
+		FROM revert_history
+		WHERE phone IN (
+			SELECT master_distributor_phone FROM master_distributors WHERE admin_id = $1
+			UNION ALL SELECT distributor_phone FROM distributors WHERE admin_id = $1
+			UNION ALL SELECT user_phone FROM users WHERE admin_id = $1
+		);
 	`
 	var revertHistories []structures.GetRevertHistory
 
-	res, err := q.Pool.Query(context.Background(), query)
+	res, err := q.Pool.Query(ctx, query, adminID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch revert history")
 	}
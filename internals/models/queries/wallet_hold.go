@@ -0,0 +1,314 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPayoutHoldReleased is returned when a payout whose money already went
+// back to the retailer is marked successful
+var ErrPayoutHoldReleased = errors.New("payout hold already released")
+
+// payoutHold is what a payout takes out of the retailer's wallet and the
+// commission it pays up the chain once it goes through
+type payoutHold struct {
+	userID              string
+	payoutTransactionID string
+	amount              string
+	adminID             string
+	masterDistributorID string
+	distributorID       string
+	totalCommission     string
+	adminRate           string
+	masterRate          string
+	distributorRate     string
+}
+
+// placePayoutHold records money that has left the retailer's wallet for a
+// payout the provider has not settled yet
+func placePayoutHold(ctx context.Context, tx pgx.Tx, hold *payoutHold) error {
+	shares, err := pkg.HoldShares(hold.totalCommission, hold.adminRate, hold.masterRate, hold.distributorRate)
+	if err != nil {
+		return fmt.Errorf("split payout commission: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO wallet_holds (
+			user_id, payout_transaction_id, amount, admin_id, master_distributor_id, distributor_id,
+			admin_share, master_distributor_share, distributor_share
+		) VALUES (
+			$1, $2, $3::NUMERIC, $4, $5, $6, $7::NUMERIC, $8::NUMERIC, $9::NUMERIC
+		);
+	`, hold.userID, hold.payoutTransactionID, hold.amount, hold.adminID, hold.masterDistributorID, hold.distributorID,
+		shares[0], shares[1], shares[2]); err != nil {
+		return fmt.Errorf("insert payout hold: %w", err)
+	}
+	return nil
+}
+
+// settlePayoutHold captures a payout's hold when it succeeded, paying the
+// commission shares, and releases it back to the retailer when it failed.
+// A captured payout that fails afterwards is refunded. Payouts that are
+// still pending, or whose hold is already settled the same way or that
+// predate holds, are left alone.
+func settlePayoutHold(ctx context.Context, tx pgx.Tx, payoutTransactionID string, status string) error {
+	if status != "SUCCESS" && status != "FAILED" {
+		return nil
+	}
+
+	var userID, amount, holdStatus string
+	var adminID, masterDistributorID, distributorID string
+	var adminShare, masterShare, distributorShare string
+	err := tx.QueryRow(ctx, `
+		SELECT user_id::TEXT, amount::TEXT, hold_status,
+			admin_id::TEXT, master_distributor_id::TEXT, distributor_id::TEXT,
+			admin_share::TEXT, master_distributor_share::TEXT, distributor_share::TEXT
+		FROM wallet_holds
+		WHERE payout_transaction_id = $1
+		FOR UPDATE;
+	`, payoutTransactionID).Scan(&userID, &amount, &holdStatus, &adminID, &masterDistributorID, &distributorID,
+		&adminShare, &masterShare, &distributorShare)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lock payout hold: %w", err)
+	}
+	if (holdStatus == pkg.HoldReleased || holdStatus == pkg.HoldRefunded) && status == "SUCCESS" {
+		return ErrPayoutHoldReleased
+	}
+	// A payout the provider fails after reporting it successful is reversed
+	// like a refund, the shares it paid are taken back
+	if holdStatus == pkg.HoldCaptured && status == "FAILED" {
+		return refundPayoutHold(ctx, tx, payoutTransactionID)
+	}
+	if holdStatus != pkg.HoldHeld {
+		return nil
+	}
+
+	if status == "FAILED" {
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET user_wallet_balance = user_wallet_balance + $2::NUMERIC WHERE user_id = $1;
+		`, userID, amount); err != nil {
+			return fmt.Errorf("release payout hold: %w", err)
+		}
+		if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "USER", userID, amount, "failed payout released"); err != nil {
+			return err
+		}
+	} else {
+		for _, share := range []struct {
+			ownerType string
+			ownerID   string
+			amount    string
+		}{
+			{"ADMIN", adminID, adminShare},
+			{"MASTER_DISTRIBUTOR", masterDistributorID, masterShare},
+			{"DISTRIBUTOR", distributorID, distributorShare},
+		} {
+			owner := walletOwners[share.ownerType]
+			if _, err := tx.Exec(ctx, fmt.Sprintf(`
+				UPDATE %[1]s SET %[2]s = %[2]s + $2::NUMERIC WHERE %[3]s = $1;
+			`, owner.table, owner.balanceColumn, owner.idColumn), share.ownerID, share.amount); err != nil {
+				return fmt.Errorf("pay payout commission: %w", err)
+			}
+		}
+	}
+
+	next := pkg.HoldCaptured
+	if status == "FAILED" {
+		next = pkg.HoldReleased
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE wallet_holds SET hold_status = $2, settled_at = NOW() WHERE payout_transaction_id = $1;
+	`, payoutTransactionID, next); err != nil {
+		return fmt.Errorf("settle payout hold: %w", err)
+	}
+	return nil
+}
+
+// refundPayoutHold reverses the captured hold of a payout that is refunded
+// after it went through. The retailer gets back everything the payout took
+// and each commission share is taken back from the member it was paid to.
+func refundPayoutHold(ctx context.Context, tx pgx.Tx, payoutTransactionID string) error {
+	var userID, amount string
+	var adminID, masterDistributorID, distributorID string
+	var adminShare, masterShare, distributorShare string
+	err := tx.QueryRow(ctx, `
+		SELECT user_id::TEXT, amount::TEXT,
+			admin_id::TEXT, master_distributor_id::TEXT, distributor_id::TEXT,
+			ROUND(admin_share, 2)::TEXT, ROUND(master_distributor_share, 2)::TEXT, ROUND(distributor_share, 2)::TEXT
+		FROM wallet_holds
+		WHERE payout_transaction_id = $1 AND hold_status = 'CAPTURED'
+		FOR UPDATE;
+	`, payoutTransactionID).Scan(&userID, &amount, &adminID, &masterDistributorID, &distributorID,
+		&adminShare, &masterShare, &distributorShare)
+	if err != nil {
+		return fmt.Errorf("lock captured payout hold: %w", err)
+	}
+
+	for _, share := range []payoutRefundShare{
+		{"ADMIN", adminID, adminShare},
+		{"MASTER_DISTRIBUTOR", masterDistributorID, masterShare},
+		{"DISTRIBUTOR", distributorID, distributorShare},
+	} {
+		if share.amount == "0.00" {
+			continue
+		}
+		owner := walletOwners[share.ownerType]
+		tag, err := tx.Exec(ctx, fmt.Sprintf(`
+			UPDATE %[1]s SET %[2]s = %[2]s - $2::NUMERIC WHERE %[3]s = $1 AND %[2]s >= $2::NUMERIC;
+		`, owner.table, owner.balanceColumn, owner.idColumn), share.ownerID, share.amount)
+		if err != nil {
+			return fmt.Errorf("take back payout commission: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("take back %s payout commission: %w", share.ownerType, ErrInsufficientBalance)
+		}
+		if err := recordWalletEvent(ctx, tx, pkg.EventWalletDebited, share.ownerType, share.ownerID, share.amount, "refunded payout commission taken back"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET user_wallet_balance = user_wallet_balance + $2::NUMERIC WHERE user_id = $1;
+	`, userID, amount); err != nil {
+		return fmt.Errorf("refund payout hold: %w", err)
+	}
+	if err := recordWalletEvent(ctx, tx, pkg.EventWalletCredited, "USER", userID, amount, "refunded payout returned"); err != nil {
+		return err
+	}
+	if err := recordPayoutRefund(ctx, tx, payoutTransactionID, userID, amount, []payoutRefundShare{
+		{"ADMIN", adminID, adminShare},
+		{"MASTER_DISTRIBUTOR", masterDistributorID, masterShare},
+		{"DISTRIBUTOR", distributorID, distributorShare},
+	}); err != nil {
+		return err
+	}
+
+	// settled_at keeps the capture on the day it happened
+	if _, err := tx.Exec(ctx, `
+		UPDATE wallet_holds SET hold_status = 'REFUNDED', refunded_at = NOW() WHERE payout_transaction_id = $1;
+	`, payoutTransactionID); err != nil {
+		return fmt.Errorf("mark payout hold refunded: %w", err)
+	}
+	return nil
+}
+
+// payoutRefundShare is a commission share a refunded payout takes back from
+// the member it was paid to
+type payoutRefundShare struct {
+	ownerType string
+	ownerID   string
+	amount    string
+}
+
+// recordPayoutRefund writes what a refund of a payout that went through
+// moved into the transaction history. Each share taken back is a transfer
+// from its member to the retailer and the rest of the refunded amount is
+// credited to the retailer, so the postings of the wallet integrity check
+// add up to the refund.
+func recordPayoutRefund(ctx context.Context, tx pgx.Tx, payoutTransactionID string, userID string, refunded string, shares []payoutRefundShare) error {
+	remarks := "PAYOUT REFUND " + payoutTransactionID
+	taken := make([]string, 0, len(shares))
+	for _, share := range shares {
+		if share.amount == "0.00" {
+			continue
+		}
+		owner := walletOwners[share.ownerType]
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO transactions (
+				transactor_id, receiver_id, transactor_name, receiver_name, transactor_type, receiver_type,
+				transaction_type, transaction_service, amount, transaction_status, remarks
+			)
+			SELECT o.%[2]s, u.user_id, o.%[3]s, u.user_name, $3, 'USER',
+				'DEBIT', 'PAYOUT', $4::NUMERIC, 'SUCCESS', $5
+			FROM %[1]s o
+			JOIN users u ON u.user_id = $2
+			WHERE o.%[2]s = $1;
+		`, owner.table, owner.idColumn, owner.nameColumn), share.ownerID, userID, share.ownerType, share.amount, remarks); err != nil {
+			return fmt.Errorf("record payout refund commission: %w", err)
+		}
+		taken = append(taken, share.amount)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO transactions (
+			transactor_id, transactor_name, transactor_type,
+			transaction_type, transaction_service, amount, transaction_status, remarks
+		)
+		SELECT user_id, user_name, 'USER',
+			'CREDIT', 'PAYOUT', $2::NUMERIC - (SELECT COALESCE(SUM(a::NUMERIC), 0) FROM unnest($3::TEXT[]) a), 'SUCCESS', $4
+		FROM users
+		WHERE user_id = $1;
+	`, userID, refunded, taken, remarks); err != nil {
+		return fmt.Errorf("record payout refund: %w", err)
+	}
+	return nil
+}
+
+// GetUserWalletSummary splits a retailer's money into what they can spend
+// and what is held for payouts still pending
+func (q *Query) GetUserWalletSummary(ctx context.Context, userID string) (*structures.WalletSummary, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	var res structures.WalletSummary
+	err := q.Pool.QueryRow(ctx, `
+		SELECT u.user_wallet_balance::TEXT,
+			COALESCE(h.held, 0)::TEXT,
+			(u.user_wallet_balance + COALESCE(h.held, 0))::TEXT
+		FROM users u
+		LEFT JOIN (
+			SELECT user_id, SUM(amount) AS held FROM wallet_holds
+			WHERE user_id = $1 AND hold_status = 'HELD'
+			GROUP BY user_id
+		) h ON h.user_id = u.user_id
+		WHERE u.user_id = $1;
+	`, userID).Scan(&res.Available, &res.Held, &res.Total)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetUserWalletHolds lists a retailer's holds, the ones still held first
+func (q *Query) GetUserWalletHolds(ctx context.Context, userID string, limit int) ([]structures.WalletHold, error) {
+	ctx, cancel := q.readContext(ctx)
+	defer cancel()
+
+	rows, err := q.Pool.Query(ctx, `
+		SELECT h.hold_id::TEXT, h.payout_transaction_id::TEXT, h.amount::TEXT, h.hold_status,
+			ps.beneficiary_name, ps.transaction_status, h.created_at, h.settled_at
+		FROM wallet_holds h
+		JOIN payout_service ps ON ps.payout_transaction_id = h.payout_transaction_id
+		WHERE h.user_id = $1
+		ORDER BY h.hold_status <> 'HELD', h.created_at DESC
+		LIMIT $2;
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []structures.WalletHold
+	for rows.Next() {
+		var h structures.WalletHold
+		if err := rows.Scan(
+			&h.HoldID,
+			&h.PayoutTransactionID,
+			&h.Amount,
+			&h.HoldStatus,
+			&h.BeneficiaryName,
+			&h.TransactionStatus,
+			&h.CreatedAt,
+			&h.SettledAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, h)
+	}
+	return res, rows.Err()
+}
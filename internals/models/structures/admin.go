@@ -0,0 +1,72 @@
+package structures
+
+import "time"
+
+// AdminInviteAcceptRequest registers the invited admin. The email has to be
+// the one the invite was sent to.
+type AdminInviteAcceptRequest struct {
+	InviteToken string `json:"invite_token" validate:"required"`
+	AdminRegisterRequest
+}
+
+type AdminInviteRequest struct {
+	AdminID    string `json:"admin_id" validate:"required,uuid4"`
+	AdminEmail string `json:"admin_email" validate:"required,email"`
+	AdminRole  string `json:"admin_role" validate:"required,oneof=SUPER_ADMIN FINANCE SUPPORT READ_ONLY"`
+}
+
+// AdminInviteCreate is a new invite as stored, holding the token hash only
+type AdminInviteCreate struct {
+	TokenHash  string
+	AdminEmail string
+	AdminRole  string
+	InvitedBy  string
+	TTL        time.Duration
+}
+
+type AdminInvite struct {
+	InviteID        string     `json:"invite_id"`
+	AdminEmail      string     `json:"admin_email"`
+	AdminRole       string     `json:"admin_role"`
+	InvitedBy       string     `json:"invited_by"`
+	Status          string     `json:"status"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	AcceptedAt      *time.Time `json:"accepted_at,omitempty"`
+	AcceptedAdminID *string    `json:"accepted_admin_id,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// AdminInviteCreated is returned once, the token cannot be read back later
+type AdminInviteCreated struct {
+	AdminInvite
+	InviteToken string `json:"invite_token"`
+}
+
+type AdminInviteRevokeRequest struct {
+	AdminID  string `json:"admin_id" validate:"required,uuid4"`
+	InviteID string `json:"invite_id" validate:"required,uuid4"`
+}
+
+type AdminRoleUpdateRequest struct {
+	AdminID       string `json:"admin_id" validate:"required,uuid4"`
+	TargetAdminID string `json:"target_admin_id" validate:"required,uuid4"`
+	AdminRole     string `json:"admin_role" validate:"required,oneof=SUPER_ADMIN FINANCE SUPPORT READ_ONLY"`
+}
+
+type AdminAccount struct {
+	AdminID       string    `json:"admin_id"`
+	AdminUniqueID string    `json:"admin_unique_id"`
+	AdminName     string    `json:"admin_name"`
+	AdminEmail    string    `json:"admin_email"`
+	AdminPhone    string    `json:"admin_phone"`
+	AdminRole     string    `json:"admin_role"`
+	AdminBlocked  bool      `json:"admin_blocked"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type AdminResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,92 @@
+package structures
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ApprovalPolicy makes an operation above ThresholdAmount wait for a second
+// approver. With WindowMinutes set, the operations a maker, or anyone on the
+// same target, ran without approval in that many minutes count towards the
+// threshold too. Operations without an enabled policy run straight away.
+type ApprovalPolicy struct {
+	AdminID         string    `json:"admin_id"`
+	ActionType      string    `json:"action_type"`
+	ThresholdAmount string    `json:"threshold_amount"`
+	TTLMinutes      int       `json:"ttl_minutes"`
+	WindowMinutes   int       `json:"window_minutes"`
+	Enabled         bool      `json:"enabled"`
+	UpdatedBy       string    `json:"updated_by"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ApprovalPolicyRequest struct {
+	AdminID         string `json:"admin_id" validate:"required,uuid4"`
+	ActionType      string `json:"action_type" validate:"required,oneof=WALLET_TOPUP USER_REFUND MASTER_DISTRIBUTOR_REFUND DISTRIBUTOR_REFUND PAYOUT_STATUS_UPDATE"`
+	ThresholdAmount string `json:"threshold_amount" validate:"required,numeric"`
+	TTLMinutes      int    `json:"ttl_minutes" validate:"omitempty,min=5,max=10080"`
+	WindowMinutes   int    `json:"window_minutes" validate:"min=0,max=10080"`
+	Enabled         bool   `json:"enabled"`
+}
+
+// ApprovalCheck is an operation about to run, checked against the policy of
+// its admin. Target is what the operation is on, like the member's phone
+// number for refunds.
+type ApprovalCheck struct {
+	AdminID    string
+	ActionType string
+	MakerID    string
+	Target     string
+	Amount     string
+}
+
+// PendingActionCreate is an operation held back for a checker. Payload is
+// the maker's request, replayed as is once approved.
+type PendingActionCreate struct {
+	AdminID    string
+	ActionType string
+	Amount     string
+	Payload    any
+	MakerID    string
+	MakerRole  string
+	TTL        time.Duration
+}
+
+type PendingAction struct {
+	ActionID        string          `json:"action_id"`
+	AdminID         string          `json:"admin_id"`
+	ActionType      string          `json:"action_type"`
+	Amount          string          `json:"amount"`
+	Payload         json.RawMessage `json:"payload"`
+	MakerID         string          `json:"maker_id"`
+	MakerRole       string          `json:"maker_role"`
+	Status          string          `json:"status"`
+	CheckerID       string          `json:"checker_id,omitempty"`
+	CheckerRole     string          `json:"checker_role,omitempty"`
+	RejectionReason string          `json:"rejection_reason,omitempty"`
+	FailureReason   string          `json:"failure_reason,omitempty"`
+	ExpiresAt       time.Time       `json:"expires_at"`
+	DecidedAt       *time.Time      `json:"decided_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+type PendingActionFilter struct {
+	Status string `query:"status"`
+}
+
+type PendingActionApproveRequest struct {
+	AdminID  string `json:"admin_id" validate:"required,uuid4"`
+	ActionID string `json:"action_id" validate:"required,uuid4"`
+}
+
+type PendingActionRejectRequest struct {
+	AdminID  string `json:"admin_id" validate:"required,uuid4"`
+	ActionID string `json:"action_id" validate:"required,uuid4"`
+	Reason   string `json:"reason" validate:"required,min=3,max=500"`
+}
+
+type ApprovalResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
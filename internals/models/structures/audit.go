@@ -0,0 +1,58 @@
+package structures
+
+import "encoding/json"
+
+// AuditLogEntry describes a privileged action before it is written to the audit log.
+// Before and After are filled by the query performing the action.
+type AuditLogEntry struct {
+	ActorID    string
+	ActorRole  string
+	Action     string
+	TargetType string
+	TargetID   string
+	IPAddress  string
+	UserAgent  string
+	RequestID  string
+	Before     any
+	After      any
+}
+
+type AuditLog struct {
+	AuditID    int64           `json:"audit_id"`
+	ActorID    string          `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	RequestID  string          `json:"request_id"`
+	BeforeData json.RawMessage `json:"before_data"`
+	AfterData  json.RawMessage `json:"after_data"`
+	PrevHash   string          `json:"prev_hash"`
+	EntryHash  string          `json:"entry_hash"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+type AuditLogFilter struct {
+	ActorID  string `query:"actor_id"`
+	Action   string `query:"action"`
+	TargetID string `query:"target_id"`
+	From     string `query:"from"`
+	To       string `query:"to"`
+	Limit    int    `query:"limit"`
+	Offset   int    `query:"offset"`
+}
+
+type AuditVerificationResult struct {
+	Valid          bool   `json:"valid"`
+	CheckedEntries int64  `json:"checked_entries"`
+	BrokenAuditID  int64  `json:"broken_audit_id,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+type AuditResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -1,10 +1,14 @@
 package structures
 
+import "time"
+
 // User Authentication Models
 
 type UserLoginRequest struct {
-	Phone string `json:"user_phone" validate:"required,phoneIN"`
-	OTP   string `json:"user_otp"`
+	Phone             string `json:"user_phone" validate:"required,phoneIN"`
+	OTP               string `json:"user_otp"`
+	DeviceFingerprint string `json:"device_fingerprint" validate:"max=512"`
+	DeviceName        string `json:"device_name" validate:"max=100"`
 }
 
 type UserRegistrationRequest struct {
@@ -36,11 +40,45 @@ type UserAuthResponse struct {
 	MasterDistributorID string `json:"master_distributor_id" validate:"required,uuid4"`
 	DistributorID       string `json:"distributor_id" validate:"required,uuid4"`
 	IsMpinSet           bool   `json:"is_mpin_set"`
+	DeviceID            string `json:"device_id,omitempty"`
 }
 
 type UserMpinRequest struct {
-	UserID   string `json:"user_id"`
-	UserMPIN string `json:"mpin"`
+	UserID   string `json:"user_id" validate:"required,uuid4"`
+	UserMPIN string `json:"mpin" validate:"required,numeric,min=4,max=6"`
+}
+
+type UserMpinOTPRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid4"`
+}
+
+type UserMpinResetRequest struct {
+	UserID  string `json:"user_id" validate:"required,uuid4"`
+	OTP     string `json:"otp" validate:"required"`
+	NewMPIN string `json:"new_mpin" validate:"required,numeric,min=4,max=6"`
+}
+
+type UserMpinChangeRequest struct {
+	UserID      string `json:"user_id" validate:"required,uuid4"`
+	OTP         string `json:"otp" validate:"required"`
+	CurrentMPIN string `json:"mpin" validate:"required"`
+	NewMPIN     string `json:"new_mpin" validate:"required,numeric,min=4,max=6"`
+}
+
+// MpinVerificationResult is the outcome of one MPIN check against the attempt limits
+type MpinVerificationResult struct {
+	Valid             bool
+	Locked            bool
+	LockedUntil       time.Time
+	FailedAttempts    int
+	RemainingAttempts int
+}
+
+// LoginAttemptResult is the state of a login after a wrong password
+type LoginAttemptResult struct {
+	FailedAttempts int
+	Locked         bool
+	LockedUntil    time.Time
 }
 
 // Admin Authentication Models
@@ -61,6 +99,7 @@ type AdminAuthResponse struct {
 	AdminID       string `json:"admin_id" validate:"required,uuid4"`
 	AdminUniqueID string `json:"admin_unique_id" validate:"required"`
 	AdminName     string `json:"admin_name" validate:"required,min=2,max=50"`
+	AdminRole     string `json:"admin_role" validate:"required"`
 }
 
 // Master Distributor Authentication Models
@@ -235,3 +274,55 @@ type UpdateDistributorProfile struct {
 	DistributorDateOfBirth  string `json:"distributor_date_of_birth"`
 	DistributorGender       string `json:"distributor_gender"`
 }
+
+type UserOTPRequest struct {
+	UserID  string `json:"user_id" validate:"required,uuid4"`
+	Purpose string `json:"purpose" validate:"required,oneof=PAYOUT_CONFIRMATION BENEFICIARY_ADD"`
+}
+
+// OTPIssue describes a new OTP together with the limits it is issued under
+type OTPIssue struct {
+	Phone          string
+	Purpose        string
+	CodeHash       string
+	IPAddress      string
+	TTL            time.Duration
+	MaxAttempts    int
+	ResendCooldown time.Duration
+	PhoneHourlyCap int
+	IPHourlyCap    int
+}
+
+// Password Models
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,passwordStrong"`
+}
+
+// ForgotPasswordRequest identifies the account by its email address or phone number
+type ForgotPasswordRequest struct {
+	Login string `json:"login" validate:"required"`
+}
+
+type PasswordResetRequest struct {
+	Login       string `json:"login" validate:"required"`
+	OTP         string `json:"otp" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,passwordStrong"`
+}
+
+type UserPasswordLoginRequest struct {
+	Phone             string `json:"user_phone" validate:"required,phoneIN"`
+	Password          string `json:"user_password" validate:"required"`
+	DeviceFingerprint string `json:"device_fingerprint" validate:"max=512"`
+	DeviceName        string `json:"device_name" validate:"max=100"`
+}
+
+// PasswordAccount holds the credentials and contacts of any role's account
+type PasswordAccount struct {
+	ID           string
+	Role         string
+	Phone        string
+	Email        string
+	PasswordHash string
+}
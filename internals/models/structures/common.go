@@ -78,3 +78,13 @@ type CommonResponse struct {
 	Status  string      `json:"status"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+// Actor is the authenticated caller resolved from the bearer token
+type Actor struct {
+	ID   string `json:"actor_id"`
+	Role string `json:"actor_role"`
+	// DeviceID is the device a retailer session is bound to
+	DeviceID string `json:"device_id,omitempty"`
+	// StaffID is set when a staff user acts for the admin in ID
+	StaffID string `json:"staff_id,omitempty"`
+}
@@ -0,0 +1,59 @@
+package structures
+
+import "time"
+
+// CreditLine lets a member's wallet go below zero by up to CreditLimit. What
+// is drawn has to be repaid within RepaymentDays, after which the line is
+// overdue and lends nothing more.
+type CreditLine struct {
+	CreditLineID  string     `json:"credit_line_id"`
+	MemberID      string     `json:"member_id"`
+	MemberType    string     `json:"member_type"`
+	MemberName    string     `json:"member_name"`
+	LenderID      string     `json:"lender_id"`
+	LenderType    string     `json:"lender_type"`
+	CreditLimit   string     `json:"credit_limit"`
+	RepaymentDays int        `json:"repayment_days"`
+	Balance       string     `json:"balance"`
+	Outstanding   string     `json:"outstanding"`
+	Available     string     `json:"available"`
+	DrawnAt       *time.Time `json:"drawn_at,omitempty"`
+	DueAt         *time.Time `json:"due_at,omitempty"`
+	Overdue       bool       `json:"overdue"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// CreditLineRequest extends or changes a member's credit line. The lender is
+// the master distributor or distributor of the route it is sent to.
+type CreditLineRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	MemberID            string `json:"member_id" validate:"required,uuid4"`
+	MemberType          string `json:"member_type" validate:"required,oneof=DISTRIBUTOR USER"`
+	CreditLimit         string `json:"credit_limit" validate:"required,numeric"`
+	RepaymentDays       int    `json:"repayment_days" validate:"required,min=1,max=90"`
+}
+
+type CreditLineDeleteRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	MemberID            string `json:"member_id" validate:"required,uuid4"`
+}
+
+// CreditLineEvent is a draw on a credit line, a repayment of it or it
+// becoming overdue
+type CreditLineEvent struct {
+	EventID          int64     `json:"event_id"`
+	CreditLineID     string    `json:"credit_line_id"`
+	EventType        string    `json:"event_type"`
+	Amount           string    `json:"amount"`
+	OutstandingAfter string    `json:"outstanding_after"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type CreditResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,69 @@
+package structures
+
+import "time"
+
+// UserDevice is a device a retailer has signed in from
+type UserDevice struct {
+	DeviceID    string     `json:"device_id"`
+	DeviceName  string     `json:"device_name"`
+	IPAddress   string     `json:"ip_address"`
+	UserAgent   string     `json:"user_agent"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy   *string    `json:"revoked_by,omitempty"`
+}
+
+// UserDeviceState describes the device a retailer is signing in from and
+// the devices they already have
+type UserDeviceState struct {
+	DeviceID      string
+	Active        bool
+	ActiveDevices int
+	TotalDevices  int
+}
+
+// UserDeviceRegistration trusts a device for the retailer. Alert raises a
+// NEW_DEVICE_LOGIN notification with it.
+type UserDeviceRegistration struct {
+	UserID          string
+	FingerprintHash string
+	DeviceName      string
+	IPAddress       string
+	UserAgent       string
+	Alert           bool
+}
+
+// UserDeviceScope selects a retailer's devices, restricted to retailers under
+// the distributor or admin when those are set
+type UserDeviceScope struct {
+	UserID        string
+	DistributorID string
+	AdminID       string
+}
+
+type UserDeviceRevokeRequest struct {
+	UserID        string `json:"user_id" validate:"required,uuid4"`
+	DeviceID      string `json:"device_id" validate:"required,uuid4"`
+	DistributorID string `json:"distributor_id" validate:"omitempty,uuid4"`
+	AdminID       string `json:"admin_id" validate:"omitempty,uuid4"`
+}
+
+type DeviceVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	MPIN           string `json:"mpin" validate:"required"`
+}
+
+// DeviceChallenge is the payload of the token a login from a new device gets
+// until the device is verified
+type DeviceChallenge struct {
+	UserID          string `json:"device_user_id"`
+	FingerprintHash string `json:"device_fingerprint_hash"`
+	DeviceName      string `json:"device_name"`
+}
+
+type DeviceResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,28 @@
+package structures
+
+// OperationalMetrics is the business state sampled on every /metrics scrape
+type OperationalMetrics struct {
+	PayoutCountsByStatus         map[string]int64
+	PayoutAmountsByStatus        map[string]float64
+	PendingFundRequests          int64
+	PendingFundRequestAmount     float64
+	OldestPendingFundRequestSecs float64
+	WalletFloatByRole            map[string]float64
+}
+
+type ReadinessCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessReport struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+type HealthResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,59 @@
+package structures
+
+import "time"
+
+// IntegrityCheck is the outcome of checking one day's wallet movements
+// against the records that should explain them
+type IntegrityCheck struct {
+	CheckDate            string    `json:"check_date"`
+	AccountsChecked      int       `json:"accounts_checked"`
+	DriftedAccounts      int       `json:"drifted_accounts"`
+	OrphanedTransactions int       `json:"orphaned_transactions"`
+	CheckedAt            time.Time `json:"checked_at"`
+}
+
+// WalletDrift is a wallet whose balance at the end of a day differs from
+// its opening balance plus the day's recorded movements, or whose credit
+// drawn differs from what its credit line recorded
+type WalletDrift struct {
+	CheckDate       string `json:"check_date"`
+	OwnerType       string `json:"owner_type"`
+	OwnerID         string `json:"owner_id"`
+	OwnerName       string `json:"owner_name"`
+	OpeningBalance  string `json:"opening_balance"`
+	ExpectedBalance string `json:"expected_balance"`
+	ActualBalance   string `json:"actual_balance"`
+	Drift           string `json:"drift"`
+	CreditDrift     string `json:"credit_drift"`
+}
+
+// OrphanedTransaction is a transfer whose receiver was never found, so the
+// sender was debited with nobody credited
+type OrphanedTransaction struct {
+	TransactionID      string    `json:"transaction_id"`
+	TransactorType     string    `json:"transactor_type"`
+	TransactorID       string    `json:"transactor_id"`
+	TransactorName     string    `json:"transactor_name"`
+	ReceiverType       string    `json:"receiver_type"`
+	TransactionService string    `json:"transaction_service"`
+	Amount             string    `json:"amount"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// IntegrityReport is what a check found for one admin's wallets
+type IntegrityReport struct {
+	Check                IntegrityCheck        `json:"check"`
+	Drifts               []WalletDrift         `json:"drifts"`
+	OrphanedTransactions []OrphanedTransaction `json:"orphaned_transactions"`
+}
+
+type IntegrityCheckRequest struct {
+	AdminID   string `json:"admin_id" validate:"omitempty,uuid4"`
+	CheckDate string `json:"check_date" validate:"required"`
+}
+
+type IntegrityResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,56 @@
+package structures
+
+import "time"
+
+// ServiceTotal is what went into and out of a wallet through one service
+// over a day
+type ServiceTotal struct {
+	In  string `json:"in"`
+	Out string `json:"out"`
+}
+
+// BalanceSnapshot is a wallet's day as it stood at the end of that day in IST
+type BalanceSnapshot struct {
+	OwnerType       string                  `json:"owner_type"`
+	OwnerID         string                  `json:"owner_id"`
+	SnapshotDate    string                  `json:"snapshot_date"`
+	OpeningBalance  string                  `json:"opening_balance"`
+	ClosingBalance  string                  `json:"closing_balance"`
+	TotalCredits    string                  `json:"total_credits"`
+	TotalDebits     string                  `json:"total_debits"`
+	ServiceTotals   map[string]ServiceTotal `json:"service_totals"`
+	CreditDrawn     string                  `json:"credit_drawn"`
+	CreditRecovered string                  `json:"credit_recovered"`
+	CreatedAt       time.Time               `json:"created_at"`
+}
+
+// BalanceAsOf is a wallet's balance at the end of a day. Snapshot is set
+// once the day has been snapshotted.
+type BalanceAsOf struct {
+	OwnerType string           `json:"owner_type"`
+	OwnerID   string           `json:"owner_id"`
+	Date      string           `json:"date"`
+	Balance   string           `json:"balance"`
+	Snapshot  *BalanceSnapshot `json:"snapshot,omitempty"`
+}
+
+// ClosePeriodRequest closes the books up to and including PeriodEnd
+type ClosePeriodRequest struct {
+	AdminID   string `json:"admin_id" validate:"omitempty,uuid4"`
+	PeriodEnd string `json:"period_end" validate:"required"`
+}
+
+// ClosedPeriod is a closing of the books. Payouts and transactions made on
+// or before PeriodEnd can no longer be changed.
+type ClosedPeriod struct {
+	AdminID   string    `json:"admin_id"`
+	PeriodEnd string    `json:"period_end"`
+	ClosedBy  string    `json:"closed_by"`
+	ClosedAt  time.Time `json:"closed_at"`
+}
+
+type LedgerResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
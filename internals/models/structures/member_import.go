@@ -0,0 +1,47 @@
+package structures
+
+// MemberImportRow is one master distributor, distributor or retailer of a
+// bulk import. ParentRef names the member's parent by unique ID or phone and
+// may point at a row earlier in the same file.
+type MemberImportRow struct {
+	RowNumber      int      `json:"row_number"`
+	MemberType     string   `json:"member_type" validate:"required,oneof=MASTER_DISTRIBUTOR DISTRIBUTOR USER"`
+	ParentRef      string   `json:"parent_ref,omitempty"`
+	Name           string   `json:"name" validate:"required,min=2,max=50"`
+	Email          string   `json:"email" validate:"required,email"`
+	Phone          string   `json:"phone" validate:"required,phoneIN"`
+	Password       string   `json:"-" validate:"required,passwordStrong"`
+	AadharNumber   string   `json:"-" validate:"required"`
+	PanNumber      string   `json:"-" validate:"required"`
+	DateOfBirth    string   `json:"-" validate:"required"`
+	Gender         string   `json:"-" validate:"required"`
+	City           string   `json:"-" validate:"required"`
+	State          string   `json:"-" validate:"required"`
+	Address        string   `json:"-" validate:"required"`
+	Pincode        string   `json:"-" validate:"required"`
+	BusinessName   string   `json:"-" validate:"required"`
+	BusinessType   string   `json:"-" validate:"required"`
+	GSTNumber      string   `json:"-"`
+	RowStatus      string   `json:"row_status"`
+	Errors         []string `json:"errors,omitempty"`
+	MemberID       string   `json:"member_id,omitempty"`
+	MemberUniqueID string   `json:"member_unique_id,omitempty"`
+}
+
+// MemberImport is the outcome of a bulk import, or of its dry run
+type MemberImport struct {
+	AdminID   string            `json:"admin_id"`
+	Mode      string            `json:"mode"`
+	DryRun    bool              `json:"dry_run"`
+	TotalRows int               `json:"total_rows"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Committed bool              `json:"committed"`
+	Rows      []MemberImportRow `json:"rows"`
+}
+
+type MemberImportResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
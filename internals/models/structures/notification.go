@@ -0,0 +1,48 @@
+package structures
+
+// NotificationEvent is written to the outbox inside the transaction that
+// caused it and delivered once that transaction commits.
+type NotificationEvent struct {
+	EventType     string
+	RecipientID   string
+	RecipientType string
+	Data          map[string]string
+}
+
+// NotificationDelivery is a claimed outbox event with everything needed to
+// deliver it: the recipient's contacts, language and enabled channels.
+type NotificationDelivery struct {
+	EventID       int64
+	EventType     string
+	RecipientID   string
+	RecipientType string
+	Data          map[string]string
+	Phone         string
+	Email         string
+	Language      string
+	Channels      []string
+}
+
+type NotificationPreference struct {
+	EventType string `json:"event_type" validate:"required,oneof=WALLET_CREDITED WALLET_DEBITED FUND_REQUEST_DECIDED PAYOUT_STATUS_CHANGED LOW_BALANCE NEW_DEVICE_LOGIN SCHEDULED_TRANSFER_FAILED CREDIT_OVERDUE WALLET_DRIFT"`
+	Channel   string `json:"channel" validate:"required,oneof=SMS EMAIL WHATSAPP"`
+	Enabled   bool   `json:"enabled"`
+}
+
+type NotificationPreferencesRequest struct {
+	OwnerID     string                   `json:"owner_id" validate:"required,uuid4"`
+	Language    string                   `json:"language" validate:"omitempty,oneof=en hi"`
+	Preferences []NotificationPreference `json:"preferences" validate:"dive"`
+}
+
+type NotificationPreferencesResponse struct {
+	OwnerID     string                   `json:"owner_id"`
+	Language    string                   `json:"language"`
+	Preferences []NotificationPreference `json:"preferences"`
+}
+
+type NotificationResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
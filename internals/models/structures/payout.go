@@ -29,6 +29,9 @@ type PayoutInitilizationRequest struct {
 	Remarks         string `json:"remarks"`
 	Commission      string `json:"commission"`
 	MPIN            string `json:"mpin"`
+	// BatchID is set for rows of a bulk payout, which are paid from the
+	// batch's reserve instead of the wallet
+	BatchID string `json:"-"`
 }
 
 type PayoutApiRequest struct {
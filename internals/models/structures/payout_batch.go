@@ -0,0 +1,51 @@
+package structures
+
+import "time"
+
+// PayoutBatch is a bulk payout. The wallet is debited for every valid row
+// when the batch is created and each row then draws on that reserve, what is
+// left over is returned once the batch completes.
+type PayoutBatch struct {
+	BatchID          string           `json:"batch_id"`
+	UserID           string           `json:"user_id"`
+	MobileNumber     string           `json:"mobile_number"`
+	TotalRows        int              `json:"total_rows"`
+	ValidRows        int              `json:"valid_rows"`
+	ReservedAmount   string           `json:"reserved_amount"`
+	ReserveRemaining string           `json:"reserve_remaining"`
+	BatchStatus      string           `json:"batch_status"`
+	StatusCounts     map[string]int   `json:"status_counts,omitempty"`
+	Rows             []PayoutBatchRow `json:"rows,omitempty"`
+	CreatedAt        time.Time        `json:"created_at"`
+	CompletedAt      *time.Time       `json:"completed_at,omitempty"`
+}
+
+// PayoutBatchRow is one payout of a batch, as uploaded and as it ended up
+type PayoutBatchRow struct {
+	RowNumber           int    `json:"row_number"`
+	AccountNumber       string `json:"account_number"`
+	IFSCCode            string `json:"ifsc_code"`
+	BeneficiaryName     string `json:"beneficiary_name"`
+	BankName            string `json:"bank_name,omitempty"`
+	Amount              string `json:"amount"`
+	TransferType        string `json:"transfer_type"`
+	Reference           string `json:"reference"`
+	RowStatus           string `json:"row_status,omitempty"`
+	PayoutTransactionID string `json:"payout_transaction_id,omitempty"`
+	Message             string `json:"message,omitempty"`
+}
+
+// PayoutBatchRequest is a bulk payout sent as JSON. The same fields are read
+// from the form when a CSV file is uploaded instead.
+type PayoutBatchRequest struct {
+	UserID       string           `json:"user_id" validate:"required,uuid4"`
+	MobileNumber string           `json:"mobile_number" validate:"required"`
+	MPIN         string           `json:"mpin" validate:"required"`
+	Rows         []PayoutBatchRow `json:"rows"`
+}
+
+type PayoutBatchResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
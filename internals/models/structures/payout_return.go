@@ -0,0 +1,56 @@
+package structures
+
+import "time"
+
+// PayoutReturnRequest records that a successful payout came back from the
+// bank or provider. CommissionPolicy defaults to the configured policy.
+type PayoutReturnRequest struct {
+	AdminID             string `json:"admin_id" validate:"omitempty,uuid4"`
+	PayoutTransactionID string `json:"payout_transaction_id" validate:"required,uuid"`
+	Outcome             string `json:"outcome" validate:"required,oneof=RETURNED REVERSED"`
+	ReturnReference     string `json:"return_reference" validate:"required,max=100"`
+	Reason              string `json:"reason" validate:"max=255"`
+	CommissionPolicy    string `json:"commission_policy" validate:"omitempty,oneof=CLAWBACK KEEP"`
+}
+
+// PayoutReturn is a returned payout and what its return moved. The retailer
+// got RetailerRefund back and each clawback was taken from the member it
+// had paid commission to.
+type PayoutReturn struct {
+	ReturnID                  string    `json:"return_id"`
+	PayoutTransactionID       string    `json:"payout_transaction_id"`
+	Outcome                   string    `json:"outcome"`
+	ReturnReference           string    `json:"return_reference"`
+	Reason                    string    `json:"reason"`
+	CommissionPolicy          string    `json:"commission_policy"`
+	Principal                 string    `json:"principal"`
+	RetailerRefund            string    `json:"retailer_refund"`
+	AdminClawback             string    `json:"admin_clawback"`
+	MasterDistributorClawback string    `json:"master_distributor_clawback"`
+	DistributorClawback       string    `json:"distributor_clawback"`
+	UserID                    string    `json:"user_id"`
+	BankCode                  string    `json:"bank_code"`
+	BankName                  string    `json:"bank_name"`
+	RecordedBy                string    `json:"recorded_by"`
+	CreatedAt                 time.Time `json:"created_at"`
+}
+
+// BankReturnRate is how many of the payouts that went through to a bank
+// over a period came back. Banks are told apart by the first four
+// characters of the IFSC code.
+type BankReturnRate struct {
+	BankCode       string `json:"bank_code"`
+	BankName       string `json:"bank_name"`
+	Payouts        int    `json:"payouts"`
+	Returned       int    `json:"returned"`
+	Reversed       int    `json:"reversed"`
+	ReturnRate     string `json:"return_rate"`
+	PayoutAmount   string `json:"payout_amount"`
+	ReturnedAmount string `json:"returned_amount"`
+}
+
+type PayoutReturnResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,81 @@
+package structures
+
+import "time"
+
+type Permission struct {
+	Permission  string `json:"permission"`
+	Description string `json:"description"`
+}
+
+type Role struct {
+	RoleName    string    `json:"role_name"`
+	Description string    `json:"description"`
+	IsSystem    bool      `json:"is_system"`
+	Permissions []string  `json:"permissions"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RoleRequest struct {
+	AdminID     string   `json:"admin_id" validate:"required,uuid4"`
+	RoleName    string   `json:"role_name" validate:"required"`
+	Description string   `json:"description" validate:"max=200"`
+	Permissions []string `json:"permissions" validate:"required,min=1,dive,required"`
+}
+
+type RoleDeleteRequest struct {
+	AdminID  string `json:"admin_id" validate:"required,uuid4"`
+	RoleName string `json:"role_name" validate:"required"`
+}
+
+type StaffCreateRequest struct {
+	AdminID       string `json:"admin_id" validate:"required,uuid4"`
+	StaffName     string `json:"staff_name" validate:"required,min=2,max=50"`
+	StaffEmail    string `json:"staff_email" validate:"required,email"`
+	StaffPhone    string `json:"staff_phone" validate:"required,phoneIN"`
+	StaffPassword string `json:"staff_password" validate:"required,passwordStrong"`
+	RoleName      string `json:"role_name" validate:"required"`
+}
+
+type StaffRoleRequest struct {
+	AdminID  string `json:"admin_id" validate:"required,uuid4"`
+	StaffID  string `json:"staff_id" validate:"required,uuid4"`
+	RoleName string `json:"role_name" validate:"required"`
+}
+
+type StaffBlockRequest struct {
+	AdminID string `json:"admin_id" validate:"required,uuid4"`
+	StaffID string `json:"staff_id" validate:"required,uuid4"`
+	Blocked bool   `json:"blocked"`
+}
+
+type StaffUser struct {
+	StaffID      string    `json:"staff_id"`
+	AdminID      string    `json:"admin_id"`
+	StaffName    string    `json:"staff_name"`
+	StaffEmail   string    `json:"staff_email"`
+	StaffPhone   string    `json:"staff_phone"`
+	RoleName     string    `json:"role_name"`
+	StaffBlocked bool      `json:"staff_blocked"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type StaffLoginRequest struct {
+	StaffEmail    string `json:"staff_email" validate:"required,email"`
+	StaffPassword string `json:"staff_password" validate:"required"`
+}
+
+// StaffAuthResponse is the session of a staff user. AdminID is the admin
+// tenant the staff user works for, so admin routes act on its data.
+type StaffAuthResponse struct {
+	StaffID   string `json:"staff_id" validate:"required,uuid4"`
+	AdminID   string `json:"admin_id" validate:"required,uuid4"`
+	StaffName string `json:"staff_name" validate:"required"`
+	RoleName  string `json:"role_name" validate:"required"`
+}
+
+type RBACResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
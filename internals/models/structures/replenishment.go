@@ -0,0 +1,56 @@
+package structures
+
+import "time"
+
+// ReplenishmentRule tops a retailer up to TargetAmount from the funder's
+// wallet whenever a payout leaves them below ThresholdAmount, sending no more
+// than DailyCap a day
+type ReplenishmentRule struct {
+	RuleID           string    `json:"rule_id"`
+	UserID           string    `json:"user_id"`
+	UserName         string    `json:"user_name"`
+	FunderID         string    `json:"funder_id"`
+	FunderType       string    `json:"funder_type"`
+	ThresholdAmount  string    `json:"threshold_amount"`
+	TargetAmount     string    `json:"target_amount"`
+	DailyCap         string    `json:"daily_cap"`
+	Active           bool      `json:"active"`
+	ReplenishedToday string    `json:"replenished_today"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ReplenishmentRuleRequest sets the rule of a retailer. The funder is the
+// master distributor or distributor of the route it is sent to.
+type ReplenishmentRuleRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	UserID              string `json:"user_id" validate:"required,uuid4"`
+	ThresholdAmount     string `json:"threshold_amount" validate:"required,numeric"`
+	TargetAmount        string `json:"target_amount" validate:"required,numeric"`
+	DailyCap            string `json:"daily_cap" validate:"required,numeric"`
+	Active              bool   `json:"active"`
+}
+
+type ReplenishmentRuleDeleteRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	UserID              string `json:"user_id" validate:"required,uuid4"`
+}
+
+// ReplenishmentRun is one top-up made or skipped by a rule
+type ReplenishmentRun struct {
+	RunID               int64     `json:"run_id"`
+	RuleID              string    `json:"rule_id"`
+	PayoutTransactionID string    `json:"payout_transaction_id,omitempty"`
+	Amount              string    `json:"amount"`
+	Status              string    `json:"status"`
+	Reason              string    `json:"reason,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type ReplenishmentResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
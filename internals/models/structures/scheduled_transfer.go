@@ -0,0 +1,98 @@
+package structures
+
+import "time"
+
+// ScheduledTransfer sends funds from a master distributor or distributor to
+// a member of their downline. DAILY and WEEKLY schedules send Amount at
+// RunTime, TOP_UP schedules top the recipient up to TargetAmount whenever
+// their balance is found below ThresholdAmount.
+type ScheduledTransfer struct {
+	ScheduleID      string     `json:"schedule_id"`
+	OwnerID         string     `json:"owner_id"`
+	OwnerType       string     `json:"owner_type"`
+	RecipientID     string     `json:"recipient_id"`
+	RecipientType   string     `json:"recipient_type"`
+	RecipientName   string     `json:"recipient_name"`
+	ScheduleType    string     `json:"schedule_type"`
+	Amount          *string    `json:"amount,omitempty"`
+	RunTime         *string    `json:"run_time,omitempty"`
+	Weekday         *int       `json:"weekday,omitempty"`
+	ThresholdAmount *string    `json:"threshold_amount,omitempty"`
+	TargetAmount    *string    `json:"target_amount,omitempty"`
+	Active          bool       `json:"active"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ScheduledTransferRequest defines a schedule. The owner is the master
+// distributor or distributor of the route it is sent to.
+type ScheduledTransferRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	RecipientID         string `json:"recipient_id" validate:"required,uuid4"`
+	RecipientType       string `json:"recipient_type" validate:"required,oneof=DISTRIBUTOR USER"`
+	ScheduleType        string `json:"schedule_type" validate:"required,oneof=DAILY WEEKLY TOP_UP"`
+	Amount              string `json:"amount" validate:"required_unless=ScheduleType TOP_UP,omitempty,numeric"`
+	RunTime             string `json:"run_time" validate:"required_unless=ScheduleType TOP_UP,omitempty,len=5"`
+	Weekday             *int   `json:"weekday" validate:"required_if=ScheduleType WEEKLY,omitempty,min=0,max=6"`
+	ThresholdAmount     string `json:"threshold_amount" validate:"required_if=ScheduleType TOP_UP,omitempty,numeric"`
+	TargetAmount        string `json:"target_amount" validate:"required_if=ScheduleType TOP_UP,omitempty,numeric"`
+}
+
+// ScheduledTransferCreate is a validated schedule with its owner and first
+// run resolved
+type ScheduledTransferCreate struct {
+	OwnerID   string
+	OwnerType string
+	Request   *ScheduledTransferRequest
+	NextRunAt time.Time
+}
+
+type ScheduledTransferStatusRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	ScheduleID          string `json:"schedule_id" validate:"required,uuid4"`
+	Active              bool   `json:"active"`
+}
+
+type ScheduledTransferDeleteRequest struct {
+	MasterDistributorID string `json:"master_distributor_id" validate:"omitempty,uuid4"`
+	DistributorID       string `json:"distributor_id" validate:"omitempty,uuid4"`
+	ScheduleID          string `json:"schedule_id" validate:"required,uuid4"`
+}
+
+// DueScheduledTransfer is a schedule claimed by the scheduler for the run
+// that was due at ScheduledFor
+type DueScheduledTransfer struct {
+	ScheduleID      string
+	OwnerID         string
+	OwnerType       string
+	RecipientID     string
+	RecipientType   string
+	RecipientName   string
+	RecipientPhone  string
+	ScheduleType    string
+	Amount          string
+	ThresholdAmount string
+	TargetAmount    string
+	ScheduledFor    time.Time
+}
+
+// ScheduledTransferRun is the outcome of one run of a schedule
+type ScheduledTransferRun struct {
+	RunID        int64     `json:"run_id"`
+	ScheduleID   string    `json:"schedule_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Amount       string    `json:"amount"`
+	Status       string    `json:"status"`
+	Reason       string    `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ScheduledTransferResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -0,0 +1,92 @@
+package structures
+
+import "time"
+
+// LoginResult is returned by logins that may need another step. Token is
+// only set once the login is complete; otherwise ChallengeToken is exchanged
+// for it with a TOTP or recovery code, used to enrol first when the role's
+// policy requires two-factor authentication, or used to verify a retailer's
+// new device.
+type LoginResult struct {
+	Token                       string     `json:"token,omitempty"`
+	TwoFactorRequired           bool       `json:"two_factor_required,omitempty"`
+	TwoFactorEnrollmentRequired bool       `json:"two_factor_enrollment_required,omitempty"`
+	TwoFactorEnrollmentDue      *time.Time `json:"two_factor_enrollment_due,omitempty"`
+	DeviceVerificationRequired  bool       `json:"device_verification_required,omitempty"`
+	ChallengeToken              string     `json:"challenge_token,omitempty"`
+}
+
+// TwoFactorChallenge is the payload of a challenge token. Login holds the
+// claims of the token issued once the challenge is met, valid for
+// TokenSeconds. AccountRole is the role of the account when it differs from
+// Role, as for staff users signing in to the admin console.
+type TwoFactorChallenge struct {
+	AccountID    string `json:"two_factor_account_id"`
+	Role         string `json:"two_factor_role"`
+	AccountRole  string `json:"two_factor_account_role,omitempty"`
+	Login        any    `json:"two_factor_login"`
+	TokenSeconds int64  `json:"two_factor_token_seconds"`
+}
+
+// TwoFactorAccountRequest is about the signed in account or, while signing
+// in, the account of the challenge token from the password step
+type TwoFactorAccountRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+}
+
+type TwoFactorCodeRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Token         string   `json:"token,omitempty"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	Required          bool       `json:"required"`
+}
+
+// TwoFactorVerification is the outcome of one code check against the attempt limits
+type TwoFactorVerification struct {
+	Valid            bool
+	UsedRecoveryCode bool
+	Locked           bool
+	LockedUntil      time.Time
+}
+
+type TwoFactorPolicy struct {
+	AdminID    string     `json:"admin_id"`
+	Role       string     `json:"role"`
+	Enforced   bool       `json:"enforced"`
+	GraceUntil *time.Time `json:"grace_until,omitempty"`
+	UpdatedBy  string     `json:"updated_by"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type TwoFactorPolicyRequest struct {
+	AdminID         string `json:"admin_id" validate:"required,uuid4"`
+	Role            string `json:"role" validate:"required,oneof=ADMIN MASTER_DISTRIBUTOR"`
+	Enforced        bool   `json:"enforced"`
+	GracePeriodDays int    `json:"grace_period_days" validate:"min=0,max=90"`
+}
+
+type TwoFactorResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+	Data    any    `json:"data,omitempty"`
+}
@@ -1,5 +1,7 @@
 package structures
 
+import "time"
+
 type AdminWallet struct {
 	WalletID string `json:"wallet_id"`
 	AdminID  string `json:"admin_id"`
@@ -81,7 +83,28 @@ type DistributorFundRetailerRequest struct {
 }
 
 type UpdatePayoutTransaction struct {
+	AdminID               string `json:"admin_id"`
 	PayoutTransactionID   string `json:"payout_transaction_id"`
 	OperatorTransactionID string `json:"operator_transaction_id"`
 	Status                string `json:"status"`
 }
+
+// WalletSummary is a retailer's balance split into what can be spent and
+// what is held for pending payouts
+type WalletSummary struct {
+	Available string `json:"available"`
+	Held      string `json:"held"`
+	Total     string `json:"total"`
+}
+
+// WalletHold is money set aside for a payout until the provider settles it
+type WalletHold struct {
+	HoldID              string     `json:"hold_id"`
+	PayoutTransactionID string     `json:"payout_transaction_id"`
+	Amount              string     `json:"amount"`
+	HoldStatus          string     `json:"hold_status"`
+	BeneficiaryName     string     `json:"beneficiary_name"`
+	TransactionStatus   string     `json:"transaction_status"`
+	CreatedAt           time.Time  `json:"created_at"`
+	SettledAt           *time.Time `json:"settled_at,omitempty"`
+}
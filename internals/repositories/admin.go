@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type adminRepo struct {
+	query         *queries.Query
+	notifications *pkg.NotificationService
+	totpUtils     *pkg.TOTPUtils
+}
+
+func NewAdminRepository(query *queries.Query, notifications *pkg.NotificationService, totpUtils *pkg.TOTPUtils) *adminRepo {
+	return &adminRepo{
+		query:         query,
+		notifications: notifications,
+		totpUtils:     totpUtils,
+	}
+}
+
+// Helper for binding + validation
+func (ar *adminRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// requireSuperAdmin lets only super admins manage other admins. The admin
+// signed in with the bearer token takes precedence over adminID, which is
+// returned as the acting admin.
+func (ar *adminRepo) requireSuperAdmin(e echo.Context, adminID string) (string, error) {
+	if actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor); ok && actor.Role == "ADMIN" {
+		if actor.StaffID != "" {
+			return "", echo.NewHTTPError(403, "Only super admins can manage admins")
+		}
+		adminID = actor.ID
+	}
+	if adminID == "" {
+		return "", echo.NewHTTPError(400, "admin_id is required")
+	}
+	role, err := ar.query.GetAdminRole(e.Request().Context(), adminID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", echo.NewHTTPError(403, "Only super admins can manage admins")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get admin role error", "error", err)
+		return "", echo.NewHTTPError(500, "Failed to verify admin")
+	}
+	if role != pkg.AdminRoleSuperAdmin {
+		return "", echo.NewHTTPError(403, "Only super admins can manage admins")
+	}
+	return adminID, nil
+}
+
+// CreateAdminInvite invites an admin with the given role. The invite token is
+// emailed when the email channel is on, and returned once to the super admin
+// either way so it can be passed on.
+func (ar *adminRepo) CreateAdminInvite(e echo.Context) (*structures.AdminInviteCreated, error) {
+	var req structures.AdminInviteRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	adminID, err := ar.requireSuperAdmin(e, req.AdminID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyStepUp(e, ar.query, ar.totpUtils, "ADMIN", adminID); err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := pkg.GenerateInviteToken()
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "Invite token generation error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create invite")
+	}
+	audit := newAuditEntry(e, "ADMIN_INVITE_CREATE", "ADMIN_INVITE", "", adminID, "ADMIN")
+	invite, err := ar.query.CreateAdminInvite(e.Request().Context(), &structures.AdminInviteCreate{
+		TokenHash:  tokenHash,
+		AdminEmail: req.AdminEmail,
+		AdminRole:  req.AdminRole,
+		InvitedBy:  adminID,
+		TTL:        pkg.AdminInviteTTL,
+	}, audit)
+	if errors.Is(err, queries.ErrAdminEmailTaken) {
+		return nil, echo.NewHTTPError(409, "An admin with this email already exists")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB create admin invite error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create invite")
+	}
+
+	err = ar.notifications.Notify(e.Request().Context(), pkg.Notification{
+		Channel:  pkg.ChannelEmail,
+		To:       invite.AdminEmail,
+		Event:    pkg.EventAdminInvite,
+		Language: pkg.DefaultLanguage,
+		Data: map[string]string{
+			"role":         invite.AdminRole,
+			"invite_token": token,
+			"expires_at":   invite.ExpiresAt.Format("02 Jan 2006 15:04 MST"),
+		},
+	})
+	if err != nil && !errors.Is(err, pkg.ErrChannelDisabled) {
+		slog.ErrorContext(e.Request().Context(), "Admin invite notification error", "error", err)
+	}
+	return &structures.AdminInviteCreated{AdminInvite: *invite, InviteToken: token}, nil
+}
+
+func (ar *adminRepo) GetAdminInvites(e echo.Context) (*[]structures.AdminInvite, error) {
+	if _, err := ar.requireSuperAdmin(e, e.Param("admin_id")); err != nil {
+		return nil, err
+	}
+	res, err := ar.query.GetAdminInvites(e.Request().Context())
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get admin invites error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch invites")
+	}
+	if res == nil {
+		empty := []structures.AdminInvite{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+func (ar *adminRepo) RevokeAdminInvite(e echo.Context) error {
+	var req structures.AdminInviteRevokeRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	adminID, err := ar.requireSuperAdmin(e, req.AdminID)
+	if err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "ADMIN_INVITE_REVOKE", "ADMIN_INVITE", req.InviteID, adminID, "ADMIN")
+	err = ar.query.RevokeAdminInvite(e.Request().Context(), req.InviteID, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Pending invite not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB revoke admin invite error", "error", err)
+		return echo.NewHTTPError(500, "Failed to revoke invite")
+	}
+	return nil
+}
+
+func (ar *adminRepo) GetAdmins(e echo.Context) (*[]structures.AdminAccount, error) {
+	if _, err := ar.requireSuperAdmin(e, e.Param("admin_id")); err != nil {
+		return nil, err
+	}
+	res, err := ar.query.GetAdmins(e.Request().Context())
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get admins error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch admins")
+	}
+	if res == nil {
+		empty := []structures.AdminAccount{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+// UpdateAdminRole moves an admin to another role. At least one super admin
+// always remains.
+func (ar *adminRepo) UpdateAdminRole(e echo.Context) error {
+	var req structures.AdminRoleUpdateRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	adminID, err := ar.requireSuperAdmin(e, req.AdminID)
+	if err != nil {
+		return err
+	}
+	if err := verifyStepUp(e, ar.query, ar.totpUtils, "ADMIN", adminID); err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "ADMIN_ROLE_UPDATE", "ADMIN", req.TargetAdminID, adminID, "ADMIN")
+	err = ar.query.UpdateAdminRole(e.Request().Context(), req.TargetAdminID, req.AdminRole, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Admin not found")
+	}
+	if errors.Is(err, queries.ErrLastSuperAdmin) {
+		return echo.NewHTTPError(409, "At least one super admin must remain")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB update admin role error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update admin role")
+	}
+	return nil
+}
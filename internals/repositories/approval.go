@@ -0,0 +1,292 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type approvalRepo struct {
+	query     *queries.Query
+	totpUtils *pkg.TOTPUtils
+}
+
+func NewApprovalRepository(query *queries.Query, totpUtils *pkg.TOTPUtils) *approvalRepo {
+	return &approvalRepo{
+		query:     query,
+		totpUtils: totpUtils,
+	}
+}
+
+// Helper for binding + validation
+func (ar *approvalRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// requestApproval holds an operation on target back for a second approver
+// when its amount, or what was run without approval within the policy
+// window, is above the policy threshold. It returns nil when the operation
+// can run straight away.
+func requestApproval(e echo.Context, query *queries.Query, actionType string, adminID string, target string, amount string, payload any) (*structures.PendingAction, error) {
+	if _, err := strconv.ParseFloat(amount, 64); err != nil {
+		return nil, echo.NewHTTPError(400, "Invalid amount")
+	}
+	adminID, _ = actingAdmin(e, adminID)
+	audit := newAuditEntry(e, "PENDING_ACTION_CREATE", "PENDING_ACTION", "", adminID, "ADMIN")
+	required, ttlMinutes, err := query.ApprovalRequired(e.Request().Context(), &structures.ApprovalCheck{
+		AdminID:    adminID,
+		ActionType: actionType,
+		MakerID:    audit.ActorID,
+		Target:     target,
+		Amount:     amount,
+	})
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB approval policy lookup error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to check approval policy")
+	}
+	if !required {
+		return nil, nil
+	}
+
+	res, err := query.CreatePendingAction(e.Request().Context(), &structures.PendingActionCreate{
+		AdminID:    adminID,
+		ActionType: actionType,
+		Amount:     amount,
+		Payload:    payload,
+		MakerID:    audit.ActorID,
+		MakerRole:  audit.ActorRole,
+		TTL:        time.Duration(ttlMinutes) * time.Minute,
+	}, audit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB create pending action error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create pending action")
+	}
+	return res, nil
+}
+
+// GetApprovalPolicies returns the admin's policy for every action type
+func (ar *approvalRepo) GetApprovalPolicies(e echo.Context) (*[]structures.ApprovalPolicy, error) {
+	adminID, _ := actingAdmin(e, e.QueryParam("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	res, err := ar.query.GetApprovalPolicies(e.Request().Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get approval policies error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch approval policies")
+	}
+	return res, nil
+}
+
+func (ar *approvalRepo) SetApprovalPolicy(e echo.Context) error {
+	var req structures.ApprovalPolicyRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	if err := verifyStepUp(e, ar.query, ar.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return err
+	}
+	if req.TTLMinutes == 0 {
+		req.TTLMinutes = int(pkg.DefaultApprovalTTL.Minutes())
+	}
+	adminID, _ := actingAdmin(e, req.AdminID)
+	audit := newAuditEntry(e, "APPROVAL_POLICY_UPDATE", "APPROVAL_POLICY", req.ActionType, req.AdminID, "ADMIN")
+	err := ar.query.SetApprovalPolicy(e.Request().Context(), &structures.ApprovalPolicy{
+		AdminID:         adminID,
+		ActionType:      req.ActionType,
+		ThresholdAmount: req.ThresholdAmount,
+		TTLMinutes:      req.TTLMinutes,
+		WindowMinutes:   req.WindowMinutes,
+		Enabled:         req.Enabled,
+		UpdatedBy:       audit.ActorID,
+	}, audit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB set approval policy error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update approval policy")
+	}
+	return nil
+}
+
+func (ar *approvalRepo) GetPendingActions(e echo.Context) (*[]structures.PendingAction, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	var filter structures.PendingActionFilter
+	if err := e.Bind(&filter); err != nil {
+		return nil, echo.NewHTTPError(400, "Invalid filter parameters")
+	}
+	res, err := ar.query.GetPendingActions(e.Request().Context(), adminID, filter.Status)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get pending actions error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch pending actions")
+	}
+	if res == nil {
+		empty := []structures.PendingAction{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+// decisionError maps the errors of deciding a pending action
+func decisionError(e echo.Context, err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(404, "Pending action not found")
+	case errors.Is(err, queries.ErrPendingActionClosed):
+		return echo.NewHTTPError(409, "Pending action has already been decided")
+	case errors.Is(err, queries.ErrPendingActionExpired):
+		return echo.NewHTTPError(410, "Pending action has expired")
+	case errors.Is(err, queries.ErrSameApprover):
+		return echo.NewHTTPError(403, "A pending action must be decided by someone other than its maker")
+	}
+	slog.ErrorContext(e.Request().Context(), "DB decide pending action error", "error", err)
+	return echo.NewHTTPError(500, "Failed to decide pending action")
+}
+
+// ApprovePendingAction lets a checker other than the maker approve a pending
+// action, which then runs as the checker. Approving an action left APPROVED
+// by an earlier cut-off attempt resumes it without running it twice.
+func (ar *approvalRepo) ApprovePendingAction(e echo.Context) (*structures.PendingAction, error) {
+	var req structures.PendingActionApproveRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	adminID, _ := actingAdmin(e, req.AdminID)
+	if err := verifyStepUp(e, ar.query, ar.totpUtils, "ADMIN", adminID); err != nil {
+		return nil, err
+	}
+	audit := newAuditEntry(e, "PENDING_ACTION_APPROVE", "PENDING_ACTION", req.ActionID, adminID, "ADMIN")
+	action, err := ar.query.ApprovePendingAction(e.Request().Context(), adminID, req.ActionID, audit)
+	if err != nil {
+		return nil, decisionError(e, err)
+	}
+
+	ran, err := ar.query.PendingActionRan(e.Request().Context(), action.ActionID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB pending action execution check error", "action_id", action.ActionID, "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to execute pending action, approve it again to retry")
+	}
+	failure := ""
+	if !ran {
+		if err := ar.executePendingAction(e, action); err != nil {
+			slog.ErrorContext(e.Request().Context(), "Pending action execution failed", "action_id", action.ActionID, "error", err)
+			failure = err.Error()
+			if httpErr, ok := err.(*echo.HTTPError); ok {
+				failure, _ = httpErr.Message.(string)
+			}
+			// A concurrent resume may have run it in the meantime
+			if ran, _ := ar.query.PendingActionRan(e.Request().Context(), action.ActionID); ran {
+				failure = ""
+			}
+		}
+	}
+	audit = newAuditEntry(e, "PENDING_ACTION_EXECUTE", "PENDING_ACTION", action.ActionID, adminID, "ADMIN")
+	err = ar.query.FinishPendingAction(e.Request().Context(), action.ActionID, failure, audit)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.ErrorContext(e.Request().Context(), "DB finish pending action error", "action_id", action.ActionID, "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to record the pending action outcome, approve it again to finish")
+	}
+	if failure != "" {
+		return nil, echo.NewHTTPError(422, "Approved, but the action failed: "+failure)
+	}
+	action.Status = pkg.PendingActionExecuted
+	return action, nil
+}
+
+// executePendingAction replays the maker's request of an approved action as
+// its checker. Each replay writes its audit entry under the action's request
+// id so PendingActionRan can tell it committed.
+func (ar *approvalRepo) executePendingAction(e echo.Context, action *structures.PendingAction) error {
+	ctx := e.Request().Context()
+	replayAudit := func(auditAction string, targetType string, targetID string) *structures.AuditLogEntry {
+		audit := newAuditEntry(e, auditAction, targetType, targetID, action.CheckerID, action.CheckerRole)
+		audit.RequestID = pkg.PendingActionRequestID(action.ActionID)
+		return audit
+	}
+	switch action.ActionType {
+	case pkg.ApprovalWalletTopup:
+		var req structures.AdminWalletTopupRequest
+		if err := json.Unmarshal(action.Payload, &req); err != nil {
+			return err
+		}
+		audit := replayAudit("WALLET_TOPUP", "ADMIN", req.AdminId)
+		if err := ar.query.AdminWalletTopup(ctx, &req, audit); err != nil {
+			return echo.NewHTTPError(500, "Failed to top up admin wallet")
+		}
+	case pkg.ApprovalUserRefund, pkg.ApprovalMasterDistributorRefund, pkg.ApprovalDistributorRefund:
+		var req structures.RefundRequest
+		if err := json.Unmarshal(action.Payload, &req); err != nil {
+			return err
+		}
+		refund := ar.query.UserRefund
+		targetType := "USER"
+		switch action.ActionType {
+		case pkg.ApprovalMasterDistributorRefund:
+			refund, targetType = ar.query.MasterDistributorRefund, "MASTER_DISTRIBUTOR"
+		case pkg.ApprovalDistributorRefund:
+			refund, targetType = ar.query.DistributorRefund, "DISTRIBUTOR"
+		}
+		audit := replayAudit(action.ActionType, targetType, "")
+		err := refund(ctx, &req, audit)
+		if errors.Is(err, queries.ErrInsufficientBalance) {
+			return echo.NewHTTPError(422, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+	case pkg.ApprovalPayoutStatusUpdate:
+		var req structures.UpdatePayoutTransaction
+		if err := json.Unmarshal(action.Payload, &req); err != nil {
+			return err
+		}
+		audit := replayAudit("PAYOUT_STATUS_UPDATE", "PAYOUT", req.PayoutTransactionID)
+		err := ar.query.UpdatePayoutTransaction(ctx, &req, audit)
+		if errors.Is(err, queries.ErrPayoutHoldReleased) {
+			return echo.NewHTTPError(409, "Payout amount was already released to the retailer")
+		}
+		if errors.Is(err, queries.ErrPeriodClosed) {
+			return echo.NewHTTPError(409, "Payout belongs to a closed period")
+		}
+		if errors.Is(err, queries.ErrPayoutAlreadyReturned) {
+			return echo.NewHTTPError(409, "Payout was returned")
+		}
+		if errors.Is(err, queries.ErrInsufficientBalance) {
+			return echo.NewHTTPError(422, "Commission paid for the payout cannot be taken back: "+err.Error())
+		}
+		if err != nil {
+			return echo.NewHTTPError(500, "Failed to update payout status")
+		}
+	default:
+		return echo.NewHTTPError(500, "Unknown action type "+action.ActionType)
+	}
+	return nil
+}
+
+func (ar *approvalRepo) RejectPendingAction(e echo.Context) (*structures.PendingAction, error) {
+	var req structures.PendingActionRejectRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	adminID, _ := actingAdmin(e, req.AdminID)
+	audit := newAuditEntry(e, "PENDING_ACTION_REJECT", "PENDING_ACTION", req.ActionID, adminID, "ADMIN")
+	res, err := ar.query.RejectPendingAction(e.Request().Context(), adminID, req.ActionID, req.Reason, audit)
+	if err != nil {
+		return nil, decisionError(e, err)
+	}
+	return res, nil
+}
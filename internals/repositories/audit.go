@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"log/slog"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/labstack/echo/v4"
+)
+
+type auditRepo struct {
+	query *queries.Query
+}
+
+func NewAuditRepository(query *queries.Query) *auditRepo {
+	return &auditRepo{
+		query: query,
+	}
+}
+
+// newAuditEntry collects who did what from where for a privileged action.
+// The actor comes from the bearer token; requests without one fall back to the
+// id and role carried in the request body.
+func newAuditEntry(e echo.Context, action string, targetType string, targetID string, fallbackActorID string, fallbackActorRole string) *structures.AuditLogEntry {
+	entry := &structures.AuditLogEntry{
+		ActorID:    fallbackActorID,
+		ActorRole:  fallbackActorRole,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  e.RealIP(),
+		UserAgent:  e.Request().UserAgent(),
+		RequestID:  e.Response().Header().Get(echo.HeaderXRequestID),
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = e.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	if actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor); ok {
+		entry.ActorID = actor.ID
+		entry.ActorRole = actor.Role
+		if actor.StaffID != "" {
+			entry.ActorID = actor.StaffID
+			entry.ActorRole = pkg.StaffRole
+		}
+	}
+	return entry
+}
+
+func (ar *auditRepo) GetAuditLogs(e echo.Context) (*[]structures.AuditLog, error) {
+	var filter structures.AuditLogFilter
+	if err := e.Bind(&filter); err != nil {
+		return nil, echo.NewHTTPError(400, "Invalid filter parameters")
+	}
+	res, err := ar.query.GetAuditLogs(e.Request().Context(), &filter)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get audit logs error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch audit logs")
+	}
+	if res == nil {
+		empty := []structures.AuditLog{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+func (ar *auditRepo) VerifyAuditLogs(e echo.Context) (*structures.AuditVerificationResult, error) {
+	res, err := ar.query.VerifyAuditLogs(e.Request().Context())
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB verify audit logs error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to verify audit logs")
+	}
+	return res, nil
+}
@@ -1,8 +1,11 @@
 package repositories
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
@@ -15,15 +18,19 @@ type authRepository struct {
 	query         *queries.Query
 	jwtUtils      *pkg.JwtUtils
 	passwordUtils *pkg.PasswordUtils
-	twillioUtils  *pkg.TwillioUtils
+	notifications *pkg.NotificationService
+	otpUtils      *pkg.OTPUtils
+	totpUtils     *pkg.TOTPUtils
 }
 
-func NewAuthRepository(query *queries.Query, jwtUtils *pkg.JwtUtils, passwordUtils *pkg.PasswordUtils, twillioUtils *pkg.TwillioUtils) *authRepository {
+func NewAuthRepository(query *queries.Query, jwtUtils *pkg.JwtUtils, passwordUtils *pkg.PasswordUtils, notifications *pkg.NotificationService, otpUtils *pkg.OTPUtils, totpUtils *pkg.TOTPUtils) *authRepository {
 	return &authRepository{
 		query:         query,
 		jwtUtils:      jwtUtils,
 		passwordUtils: passwordUtils,
-		twillioUtils:  twillioUtils,
+		notifications: notifications,
+		otpUtils:      otpUtils,
+		totpUtils:     totpUtils,
 	}
 }
 
@@ -51,7 +58,7 @@ func (ar *authRepository) validateDBResponse(e echo.Context, res interface{}) er
 func (ar *authRepository) generateTokenFor(res interface{}, duration time.Duration) (string, error) {
 	token, err := ar.jwtUtils.GenerateToken(res, duration)
 	if err != nil {
-		log.Println("Token generation failed:", err)
+		slog.Error("Token generation failed", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to generate token")
 	}
 	return token, nil
@@ -60,33 +67,93 @@ func (ar *authRepository) generateTokenFor(res interface{}, duration time.Durati
 func (ar *authRepository) hashPassword(raw string) (string, error) {
 	hashPassword, err := ar.passwordUtils.HashPassword(raw)
 	if err != nil {
-		log.Println("Password hashing failed:", err)
+		slog.Error("Password hashing failed", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to secure password")
 	}
 	return hashPassword, nil
 }
 
-func (ar *authRepository) verifyPasswordAndLogin(
-	e echo.Context,
-	getDBPass func() (string, error),
-	inputPassword string,
-	loginFunc func() (interface{}, error),
-	tokenDuration time.Duration,
-) (string, error) {
+// loginLockedError answers a login attempt while the login is locked out
+func loginLockedError(e echo.Context, lockedUntil time.Time) error {
+	wait := time.Until(lockedUntil)
+	e.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return echo.NewHTTPError(423, fmt.Sprintf("Too many failed login attempts, try again in %d minutes", int(math.Ceil(wait.Minutes()))))
+}
+
+// recordLoginFailure counts a failed password check against the login and
+// returns failure, or the lockout error once the failure locks the login
+func (ar *authRepository) recordLoginFailure(e echo.Context, role string, login string, failure error) error {
+	res, err := ar.query.RecordLoginFailure(e.Request().Context(), role, login)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB record login failure error", "error", err)
+		return failure
+	}
+	if res.Locked {
+		return loginLockedError(e, res.LockedUntil)
+	}
+	return failure
+}
+
+// checkPassword checks the password of the role's login. Repeated wrong
+// passwords lock the login for a while.
+func (ar *authRepository) checkPassword(e echo.Context, role string, login string, getDBPass func() (string, error), inputPassword string) error {
+	lockedUntil, err := ar.query.GetLoginLockedUntil(e.Request().Context(), role, login)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB login lockout lookup failed", "error", err)
+		return echo.NewHTTPError(500, "Failed to verify password")
+	}
+	if lockedUntil != nil {
+		return loginLockedError(e, *lockedUntil)
+	}
 	dbPass, err := getDBPass()
 	if err != nil {
-		log.Println("DB password retrieval failed:", err)
-		return "", echo.NewHTTPError(401, "Invalid credentials")
+		slog.ErrorContext(e.Request().Context(), "DB password retrieval failed", "error", err)
+		return ar.recordLoginFailure(e, role, login, echo.NewHTTPError(401, "Invalid credentials"))
 	}
 	if err := ar.passwordUtils.VerifyPassword(dbPass, inputPassword); err != nil {
-		return "", echo.NewHTTPError(401, "Invalid password")
+		return ar.recordLoginFailure(e, role, login, echo.NewHTTPError(401, "Invalid password"))
+	}
+	if err := ar.query.ClearLoginFailures(e.Request().Context(), role, login); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB clear login failures error", "error", err)
+	}
+	return nil
+}
+
+// verifyPasswordAndLoad checks the password of the role's login and loads the
+// login response
+func (ar *authRepository) verifyPasswordAndLoad(
+	e echo.Context,
+	role string,
+	login string,
+	getDBPass func() (string, error),
+	inputPassword string,
+	loginFunc func() (interface{}, error),
+) (interface{}, error) {
+	if err := ar.checkPassword(e, role, login, getDBPass, inputPassword); err != nil {
+		return nil, err
 	}
 	res, err := loginFunc()
 	if err != nil {
-		log.Println("Login query failed:", err)
-		return "", echo.NewHTTPError(401, "Failed to log in")
+		slog.ErrorContext(e.Request().Context(), "Login query failed", "error", err)
+		return nil, echo.NewHTTPError(401, "Failed to log in")
 	}
 	if err := ar.validateDBResponse(e, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ar *authRepository) verifyPasswordAndLogin(
+	e echo.Context,
+	role string,
+	login string,
+	getDBPass func() (string, error),
+	inputPassword string,
+	loginFunc func() (interface{}, error),
+	tokenDuration time.Duration,
+) (string, error) {
+	res, err := ar.verifyPasswordAndLoad(e, role, login, getDBPass, inputPassword, loginFunc)
+	if err != nil {
 		return "", err
 	}
 	token, err := ar.generateTokenFor(res, tokenDuration)
@@ -100,8 +167,10 @@ func (ar *authRepository) verifyPasswordAndLogin(
 // Public methods
 // ----------------------------
 
-func (ar *authRepository) RegisterAdmin(e echo.Context) (string, error) {
-	var req structures.AdminRegisterRequest
+// AcceptAdminInvite creates the admin an invite was sent to, with the invite's
+// role. The invite can be used only once.
+func (ar *authRepository) AcceptAdminInvite(e echo.Context) (string, error) {
+	var req structures.AdminInviteAcceptRequest
 	if err := ar.bindAndValidate(e, &req); err != nil {
 		return "", err
 	}
@@ -110,9 +179,13 @@ func (ar *authRepository) RegisterAdmin(e echo.Context) (string, error) {
 		return "", err
 	}
 	req.AdminPassword = hashPassword
-	res, err := ar.query.CreateAdmin(&req)
+	audit := newAuditEntry(e, "ADMIN_INVITE_ACCEPT", "ADMIN", "", "", "ADMIN")
+	res, err := ar.query.AcceptAdminInvite(e.Request().Context(), pkg.HashInviteToken(req.InviteToken), &req.AdminRegisterRequest, audit)
+	if errors.Is(err, queries.ErrAdminInviteInvalid) {
+		return "", echo.NewHTTPError(401, "Invalid or expired invite")
+	}
 	if err != nil {
-		log.Println("DB create admin error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB create admin error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to create admin")
 	}
 	if err := ar.validateDBResponse(e, res); err != nil {
@@ -131,9 +204,9 @@ func (ar *authRepository) RegisterMasterDistributor(e echo.Context) (string, err
 		return "", err
 	}
 	req.MasterDistributorPassword = hashPassword
-	res, err := ar.query.CreateMasterDistributor(&req)
+	res, err := ar.query.CreateMasterDistributor(e.Request().Context(), &req)
 	if err != nil {
-		log.Println("DB create master distributor error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB create master distributor error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to create master distributor")
 	}
 	if err := ar.validateDBResponse(e, res); err != nil {
@@ -152,9 +225,9 @@ func (ar *authRepository) RegisterDistributor(e echo.Context) (string, error) {
 		return "", err
 	}
 	req.DistributorPassword = hashPassword
-	res, err := ar.query.CreateDistributor(&req)
+	res, err := ar.query.CreateDistributor(e.Request().Context(), &req)
 	if err != nil {
-		log.Println("DB create distributor error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB create distributor error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to create distributor")
 	}
 	if err := ar.validateDBResponse(e, res); err != nil {
@@ -173,9 +246,9 @@ func (ar *authRepository) RegisterUser(e echo.Context) (string, error) {
 		return "", err
 	}
 	req.UserPassword = hashPassword
-	res, err := ar.query.CreateUser(&req)
+	res, err := ar.query.CreateUser(e.Request().Context(), &req)
 	if err != nil {
-		log.Println("DB create user error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB create user error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to create user")
 	}
 	if err := ar.validateDBResponse(e, res); err != nil {
@@ -184,32 +257,65 @@ func (ar *authRepository) RegisterUser(e echo.Context) (string, error) {
 	return ar.generateTokenFor(res, time.Hour*24*365)
 }
 
-func (ar *authRepository) LoginAdmin(e echo.Context) (string, error) {
+func (ar *authRepository) LoginAdmin(e echo.Context) (*structures.LoginResult, error) {
 	var req structures.AdminLoginRequest
 	if err := ar.bindAndValidate(e, &req); err != nil {
-		return "", err
+		return nil, err
 	}
-	return ar.verifyPasswordAndLogin(
+	res, err := ar.verifyPasswordAndLoad(
 		e,
-		func() (string, error) { return ar.query.GetAdminPassword(req.AdminEmail) },
+		"ADMIN",
+		req.AdminEmail,
+		func() (string, error) { return ar.query.GetAdminPassword(e.Request().Context(), req.AdminEmail) },
 		req.AdminPassword,
-		func() (interface{}, error) { return ar.query.LoginAdmin(&req) },
-		time.Hour*24*365,
+		func() (interface{}, error) { return ar.query.LoginAdmin(e.Request().Context(), &req) },
 	)
+	if err != nil {
+		return nil, err
+	}
+	return ar.loginWithTwoFactor(e, "ADMIN", "ADMIN", res.(*structures.AdminAuthResponse).AdminID, res, time.Hour*24*365)
 }
 
-func (ar *authRepository) LoginMasterDistributor(e echo.Context) (string, error) {
+// LoginStaff signs in a staff user. The token carries the admin tenant the
+// staff user works for, and its permissions come from the staff user's role.
+// Staff users go through the same two-factor challenge as admins, under the
+// admin policy.
+func (ar *authRepository) LoginStaff(e echo.Context) (*structures.LoginResult, error) {
+	var req structures.StaffLoginRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	res, err := ar.verifyPasswordAndLoad(
+		e,
+		pkg.StaffRole,
+		req.StaffEmail,
+		func() (string, error) { return ar.query.GetStaffPassword(e.Request().Context(), req.StaffEmail) },
+		req.StaffPassword,
+		func() (interface{}, error) { return ar.query.LoginStaff(e.Request().Context(), req.StaffEmail) },
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ar.loginWithTwoFactor(e, "ADMIN", pkg.StaffRole, res.(*structures.StaffAuthResponse).StaffID, res, time.Hour*24)
+}
+
+func (ar *authRepository) LoginMasterDistributor(e echo.Context) (*structures.LoginResult, error) {
 	var req structures.MasterDistributorLoginRequest
 	if err := ar.bindAndValidate(e, &req); err != nil {
-		return "", err
+		return nil, err
 	}
-	return ar.verifyPasswordAndLogin(
+	res, err := ar.verifyPasswordAndLoad(
 		e,
-		func() (string, error) { return ar.query.GetMasterDistributorPassword(req.MasterDistributorEmail) },
+		"MASTER_DISTRIBUTOR",
+		req.MasterDistributorEmail,
+		func() (string, error) { return ar.query.GetMasterDistributorPassword(e.Request().Context(), req.MasterDistributorEmail) },
 		req.MasterDistributorPassword,
-		func() (interface{}, error) { return ar.query.LoginMasterDistributor(&req) },
-		time.Hour*24*365,
+		func() (interface{}, error) { return ar.query.LoginMasterDistributor(e.Request().Context(), &req) },
 	)
+	if err != nil {
+		return nil, err
+	}
+	return ar.loginWithTwoFactor(e, "MASTER_DISTRIBUTOR", "MASTER_DISTRIBUTOR", res.(*structures.MasterDistributorAuthResponse).MasterDistributorID, res, time.Hour*24*365)
 }
 
 func (ar *authRepository) LoginDistributor(e echo.Context) (string, error) {
@@ -219,9 +325,11 @@ func (ar *authRepository) LoginDistributor(e echo.Context) (string, error) {
 	}
 	return ar.verifyPasswordAndLogin(
 		e,
-		func() (string, error) { return ar.query.GetDistributorPassword(req.DistributorEmail) },
+		"DISTRIBUTOR",
+		req.DistributorEmail,
+		func() (string, error) { return ar.query.GetDistributorPassword(e.Request().Context(), req.DistributorEmail) },
 		req.DistributorPassword,
-		func() (interface{}, error) { return ar.query.LoginDistributor(&req) },
+		func() (interface{}, error) { return ar.query.LoginDistributor(e.Request().Context(), &req) },
 		time.Hour*24*365,
 	)
 }
@@ -231,40 +339,40 @@ func (ar *authRepository) LoginUserSendOTP(e echo.Context) (string, error) {
 	if err := ar.bindAndValidate(e, &req); err != nil {
 		return "", err
 	}
-	exists, err := ar.query.CheckUserExistViaPhone(req.Phone)
+	exists, err := ar.query.CheckUserExistViaPhone(e.Request().Context(), req.Phone)
 	if err != nil {
-		log.Println("DB user existence check error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB user existence check error", "error", err)
 		return "", echo.NewHTTPError(404, "Failed to find user")
 	}
 	if !exists {
 		return "", echo.NewHTTPError(404, "User not found")
 	}
-	otp, err := ar.query.GenerateOTPForUser(req.Phone)
-	if err != nil {
-		log.Println("DB OTP generation error:", err)
-		return "", echo.NewHTTPError(500, "Failed to generate OTP")
-	}
-	if err := ar.twillioUtils.SendOTP(req.Phone, otp); err != nil {
-		log.Println("Twillio send error:", err)
-		return "", echo.NewHTTPError(500, "Failed to send OTP")
+	if err := sendOTP(e, ar.query, ar.otpUtils, ar.notifications, req.Phone, pkg.OTPPurposeLogin); err != nil {
+		return "", err
 	}
 	return "OTP sent successfully", nil
 }
 
-func (ar *authRepository) LoginUserValidateOTP(e echo.Context) (string, error) {
+func (ar *authRepository) LoginUserValidateOTP(e echo.Context) (*structures.LoginResult, error) {
 	var req structures.UserLoginRequest
 	if err := ar.bindAndValidate(e, &req); err != nil {
-		return "", err
+		return nil, err
+	}
+	if req.DeviceFingerprint == "" {
+		return nil, echo.NewHTTPError(400, "device_fingerprint is required")
 	}
-	res, err := ar.query.ValidateOTP(&req)
+	if err := verifyOTP(e, ar.query, ar.otpUtils, req.Phone, pkg.OTPPurposeLogin, req.OTP); err != nil {
+		return nil, err
+	}
+	res, err := ar.query.GetUserLoginByPhone(e.Request().Context(), req.Phone)
 	if err != nil {
-		log.Println("DB OTP validation error:", err)
-		return "", echo.NewHTTPError(401, "Invalid or expired OTP")
+		slog.ErrorContext(e.Request().Context(), "DB user login lookup error", "error", err)
+		return nil, echo.NewHTTPError(404, "User not found")
 	}
 	if err := ar.validateDBResponse(e, res); err != nil {
-		return "", err
+		return nil, err
 	}
-	return ar.generateTokenFor(res, time.Hour*24*365)
+	return ar.loginUserDevice(e, res, req.DeviceFingerprint, req.DeviceName)
 }
 
 func (ar *authRepository) SetUserMpin(e echo.Context) (string, error) {
@@ -272,7 +380,15 @@ func (ar *authRepository) SetUserMpin(e echo.Context) (string, error) {
 	if err := ar.bindAndValidate(e, &req); err != nil {
 		return "", err
 	}
-	res, err := ar.query.SetMpin(req.UserID, req.UserMPIN)
+	hash, err := ar.passwordUtils.HashPassword(req.UserMPIN)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "MPIN hashing failed", "error", err)
+		return "", echo.NewHTTPError(500, "Failed to Set MPIN")
+	}
+	res, err := ar.query.SetMpin(e.Request().Context(), req.UserID, hash)
+	if errors.Is(err, queries.ErrMpinAlreadySet) {
+		return "", echo.NewHTTPError(409, "MPIN already set, reset it with an OTP instead")
+	}
 	if err != nil {
 		return "", echo.NewHTTPError(401, "Failed to Set MPIN")
 	}
@@ -285,17 +401,10 @@ func (ar *authRepository) SetUserMpin(e echo.Context) (string, error) {
 
 func (ar *authRepository) VerifyMPIN(e echo.Context) error {
 	var req structures.UserMpinRequest
-	if err := e.Bind(&req); err != nil {
-		return fmt.Errorf("invalid request format")
-	}
-	isValid, err := ar.query.VerifyMPIN(req.UserID, req.UserMPIN)
-	if err != nil {
-		return fmt.Errorf("failed to validate mpin")
-	}
-	if !isValid {
-		return fmt.Errorf("invalid mpin")
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
 	}
-	return nil
+	return verifyUserMpin(e, ar.query, ar.passwordUtils, req.UserID, req.UserMPIN)
 }
 
 func (ar *authRepository) UpdateUserProfile(e echo.Context) error {
@@ -303,7 +412,8 @@ func (ar *authRepository) UpdateUserProfile(e echo.Context) error {
 	if err := e.Bind(&req); err != nil {
 		return fmt.Errorf("invalid request format")
 	}
-	if err := ar.query.UpdateProfile(&req); err != nil {
+	audit := newAuditEntry(e, "PROFILE_UPDATE", "USER", req.UserID, req.UserID, "USER")
+	if err := ar.query.UpdateProfile(e.Request().Context(), &req, audit); err != nil {
 		return fmt.Errorf("failed to update profile")
 	}
 	return nil
@@ -314,9 +424,9 @@ func (ar *authRepository) GetUserProfile(e echo.Context) (*structures.GetUserPro
 	if userId == "" {
 		return nil, fmt.Errorf("user id not found")
 	}
-	res, err := ar.query.FetchProfileDetails(userId)
+	res, err := ar.query.FetchProfileDetails(e.Request().Context(), userId)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(e.Request().Context(), "DB fetch user profile error", "error", err)
 		return nil, fmt.Errorf("failed to fetch profile details")
 	}
 	return res, nil
@@ -328,8 +438,9 @@ func (ar *authRepository) UpdateMasterDistributorProfile(e echo.Context) error {
 		return fmt.Errorf("invalid request format")
 	}
 
-	if err := ar.query.UpdateMasterDistributorProfile(&req); err != nil {
-		log.Println("failed to update master distributor profile:", err)
+	audit := newAuditEntry(e, "PROFILE_UPDATE", "MASTER_DISTRIBUTOR", req.MasterDistributorID, req.MasterDistributorID, "MASTER_DISTRIBUTOR")
+	if err := ar.query.UpdateMasterDistributorProfile(e.Request().Context(), &req, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "failed to update master distributor profile", "error", err)
 		return fmt.Errorf("failed to update profile")
 	}
 
@@ -342,9 +453,9 @@ func (ar *authRepository) GetMasterDistributorProfile(e echo.Context) (*structur
 		return nil, fmt.Errorf("master distributor id not found")
 	}
 
-	res, err := ar.query.FetchMasterDistributorProfileDetails(masterDistributorID)
+	res, err := ar.query.FetchMasterDistributorProfileDetails(e.Request().Context(), masterDistributorID)
 	if err != nil {
-		log.Println("failed to fetch master distributor profile details:", err)
+		slog.ErrorContext(e.Request().Context(), "failed to fetch master distributor profile details", "error", err)
 		return nil, fmt.Errorf("failed to fetch profile details")
 	}
 
@@ -358,8 +469,9 @@ func (ar *authRepository) UpdateDistributorProfile(e echo.Context) error {
 		return fmt.Errorf("invalid request format")
 	}
 
-	if err := ar.query.UpdateDistributorProfile(&req); err != nil {
-		log.Println("failed to update distributor profile:", err)
+	audit := newAuditEntry(e, "PROFILE_UPDATE", "DISTRIBUTOR", req.DistributorID, req.DistributorID, "DISTRIBUTOR")
+	if err := ar.query.UpdateDistributorProfile(e.Request().Context(), &req, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "failed to update distributor profile", "error", err)
 		return fmt.Errorf("failed to update profile")
 	}
 
@@ -372,9 +484,9 @@ func (ar *authRepository) GetDistributorProfile(e echo.Context) (*structures.Get
 		return nil, fmt.Errorf("distributor id not found")
 	}
 
-	res, err := ar.query.FetchDistributorProfileDetails(distributorID)
+	res, err := ar.query.FetchDistributorProfileDetails(e.Request().Context(), distributorID)
 	if err != nil {
-		log.Println("failed to fetch distributor profile details:", err)
+		slog.ErrorContext(e.Request().Context(), "failed to fetch distributor profile details", "error", err)
 		return nil, fmt.Errorf("failed to fetch profile details")
 	}
 
@@ -18,7 +18,7 @@ func NewBankRepo(query *queries.Query) *bankRepo {
 }
 
 func (r *bankRepo) GetBanks(e echo.Context) (*[]structures.BankModel, error) {
-	res , err := r.query.GetBanks()
+	res , err := r.query.GetBanks(e.Request().Context())
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch bank details")
 	}
@@ -30,7 +30,7 @@ func (r *bankRepo) AddNewBank(e echo.Context) error {
 	if err := e.Bind(req); err != nil {
 		return fmt.Errorf("invalid request format")
 	}
-	err := r.query.AddNewBank(req)
+	err := r.query.AddNewBank(e.Request().Context(), req)
 	if err != nil {
 		return fmt.Errorf("failed to add bank")
 	}
@@ -2,7 +2,7 @@ package repositories
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
@@ -22,7 +22,7 @@ func (r *beneficiaryRepo) GetBeneficiaries(e echo.Context) (*[]structures.Benefi
 	if phone == "" {
 		return nil, fmt.Errorf("phone number not found")
 	}
-	res, err := r.query.GetBeneficiaries(phone)
+	res, err := r.query.GetBeneficiaries(e.Request().Context(), phone)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch benificiries")
 	}
@@ -34,9 +34,9 @@ func (r *beneficiaryRepo) AddNewBeneficiary(e echo.Context) error {
 	if err := e.Bind(req); err != nil {
 		return err
 	}
-	err := r.query.AddNewBeneficiary(req)
+	err := r.query.AddNewBeneficiary(e.Request().Context(), req)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(e.Request().Context(), "DB add beneficiary error", "error", err)
 		return fmt.Errorf("failed to add benificary")
 	}
 	return nil
@@ -47,7 +47,7 @@ func (r *beneficiaryRepo) VerifyBeneficiary(e echo.Context) error {
 	if beneficiaryId == "" {
 		return fmt.Errorf("beneficiary id not found")
 	}
-	if err := r.query.VerifyBenificary(beneficiaryId); err != nil {
+	if err := r.query.VerifyBenificary(e.Request().Context(), beneficiaryId); err != nil {
 		return fmt.Errorf("failed to verify beneficiary")
 	}
 	return nil
@@ -58,7 +58,7 @@ func (r *beneficiaryRepo) DeleteBeneficiary(e echo.Context) error {
 	if beneficiaryId == "" {
 		return fmt.Errorf("beneficiary id not found")
 	}
-	if err := r.query.DeleteBeneficiary(beneficiaryId); err != nil {
+	if err := r.query.DeleteBeneficiary(e.Request().Context(), beneficiaryId); err != nil {
 		return fmt.Errorf("failed to delete beneficiary")
 	}
 	return nil
@@ -1,8 +1,9 @@
 package repositories
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
@@ -18,10 +19,10 @@ func NewCommonRepository(q *queries.Query) *commonRepo {
 }
 
 // Master Distributors by Admin ID
-func (r *commonRepo) GetAllMasterDistributorsByAdminID(adminId string) (*[]structures.MasterDistributorGetResponse, error) {
-	res, err := r.query.GetAllMasterDistributorsByID(adminId)
+func (r *commonRepo) GetAllMasterDistributorsByAdminID(ctx context.Context, adminId string) (*[]structures.MasterDistributorGetResponse, error) {
+	res, err := r.query.GetAllMasterDistributorsByID(ctx, adminId)
 	if err != nil {
-		log.Println("DB error while fetching master distributors by admin ID:", err)
+		slog.ErrorContext(ctx, "DB error while fetching master distributors by admin ID", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch master distributors")
 	}
 	if res == nil {
@@ -33,10 +34,10 @@ func (r *commonRepo) GetAllMasterDistributorsByAdminID(adminId string) (*[]struc
 }
 
 // Distributors by Master Distributor ID
-func (r *commonRepo) GetAllDistributorsByMasterDistributorID(masterDistributorId string) (*[]structures.DistributorGetResponse, error) {
-	res, err := r.query.GetAllDistributorsByMasterDistributorID(masterDistributorId)
+func (r *commonRepo) GetAllDistributorsByMasterDistributorID(ctx context.Context, masterDistributorId string) (*[]structures.DistributorGetResponse, error) {
+	res, err := r.query.GetAllDistributorsByMasterDistributorID(ctx, masterDistributorId)
 	if err != nil {
-		log.Println("DB error while fetching distributors by master distributor ID:", err)
+		slog.ErrorContext(ctx, "DB error while fetching distributors by master distributor ID", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch distributors")
 	}
 	if res == nil {
@@ -47,10 +48,10 @@ func (r *commonRepo) GetAllDistributorsByMasterDistributorID(masterDistributorId
 }
 
 // Users by Distributor ID
-func (r *commonRepo) GetAllUsersByDistributorID(distributorId string) (*[]structures.UserGetResponse, error) {
-	res, err := r.query.GetAllUsersByDistributorID(distributorId)
+func (r *commonRepo) GetAllUsersByDistributorID(ctx context.Context, distributorId string) (*[]structures.UserGetResponse, error) {
+	res, err := r.query.GetAllUsersByDistributorID(ctx, distributorId)
 	if err != nil {
-		log.Println("DB error while fetching users by distributor ID:", err)
+		slog.ErrorContext(ctx, "DB error while fetching users by distributor ID", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch users")
 	}
 	if res == nil {
@@ -61,10 +62,10 @@ func (r *commonRepo) GetAllUsersByDistributorID(distributorId string) (*[]struct
 }
 
 // Distributors by Master Distributor ID
-func (r *commonRepo) GetAllDistributorsByAdminID(adminId string) (*[]structures.DistributorGetResponse, error) {
-	res, err := r.query.GetAllDistributorsByAdminID(adminId)
+func (r *commonRepo) GetAllDistributorsByAdminID(ctx context.Context, adminId string) (*[]structures.DistributorGetResponse, error) {
+	res, err := r.query.GetAllDistributorsByAdminID(ctx, adminId)
 	if err != nil {
-		log.Println("DB error while fetching distributors by master distributor ID:", err)
+		slog.ErrorContext(ctx, "DB error while fetching distributors by master distributor ID", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch distributors")
 	}
 	if res == nil {
@@ -75,10 +76,10 @@ func (r *commonRepo) GetAllDistributorsByAdminID(adminId string) (*[]structures.
 }
 
 // Users by Distributor ID
-func (r *commonRepo) GetAllUsersByAdminID(adminId string) (*[]structures.UserGetResponse, error) {
-	res, err := r.query.GetAllUsersByAdminID(adminId)
+func (r *commonRepo) GetAllUsersByAdminID(ctx context.Context, adminId string) (*[]structures.UserGetResponse, error) {
+	res, err := r.query.GetAllUsersByAdminID(ctx, adminId)
 	if err != nil {
-		log.Println("DB error while fetching users by distributor ID:", err)
+		slog.ErrorContext(ctx, "DB error while fetching users by distributor ID", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch users")
 	}
 	if res == nil {
@@ -88,24 +89,24 @@ func (r *commonRepo) GetAllUsersByAdminID(adminId string) (*[]structures.UserGet
 	return res, nil
 }
 
-func (r *commonRepo) GetUserByPhoneNumber(phoneNumber string) (*structures.UserGetResponse, error) {
-	res, err := r.query.GetUserByPhone(phoneNumber)
+func (r *commonRepo) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*structures.UserGetResponse, error) {
+	res, err := r.query.GetUserByPhone(ctx, phoneNumber)
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
 	return res, nil
 }
 
-func (r *commonRepo) GetMasterDistributorByPhoneNumber(phoneNumber string) (*structures.MasterDistributorGetResponse, error) {
-	res, err := r.query.GetMasterDistributorByPhone(phoneNumber)
+func (r *commonRepo) GetMasterDistributorByPhoneNumber(ctx context.Context, phoneNumber string) (*structures.MasterDistributorGetResponse, error) {
+	res, err := r.query.GetMasterDistributorByPhone(ctx, phoneNumber)
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
 	return res, nil
 }
 
-func (r *commonRepo) GetDistributorByPhoneNumber(phoneNumber string) (*structures.DistributorGetResponse, error) {
-	res, err := r.query.GetDistributorsByPhone(phoneNumber)
+func (r *commonRepo) GetDistributorByPhoneNumber(ctx context.Context, phoneNumber string) (*structures.DistributorGetResponse, error) {
+	res, err := r.query.GetDistributorsByPhone(ctx, phoneNumber)
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// creditLineEventLimit bounds the history returned for a credit line
+const creditLineEventLimit = 100
+
+type creditLineRepo struct {
+	query *queries.Query
+}
+
+func NewCreditLineRepository(query *queries.Query) *creditLineRepo {
+	return &creditLineRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (cr *creditLineRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// SaveCreditLine extends credit to a member under the caller. Master
+// distributors lend to distributors and retailers, distributors to retailers.
+func (cr *creditLineRepo) SaveCreditLine(e echo.Context, role string) (*structures.CreditLine, error) {
+	var req structures.CreditLineRequest
+	if err := cr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	lenderID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "DISTRIBUTOR" && req.MemberType != "USER" {
+		return nil, echo.NewHTTPError(400, "Distributors can only extend credit to retailers")
+	}
+	if limit, _ := strconv.ParseFloat(req.CreditLimit, 64); limit <= 0 {
+		return nil, echo.NewHTTPError(400, "credit_limit must be positive")
+	}
+
+	audit := newAuditEntry(e, "CREDIT_LINE_UPDATE", "CREDIT_LINE", "", lenderID, role)
+	res, err := cr.query.SaveCreditLine(e.Request().Context(), lenderID, role, &req, audit)
+	if errors.Is(err, queries.ErrNotInDownline) {
+		return nil, echo.NewHTTPError(403, "Member is not in your downline")
+	}
+	if errors.Is(err, queries.ErrCreditLentElsewhere) {
+		return nil, echo.NewHTTPError(409, "Member already has a credit line from another lender")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB save credit line error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to save credit line")
+	}
+	return res, nil
+}
+
+func (cr *creditLineRepo) GetCreditLines(e echo.Context, role string) (*[]structures.CreditLine, error) {
+	lenderID, err := actingOwner(e, role, e.Param("master_distributor_id"), e.Param("distributor_id"))
+	if err != nil {
+		return nil, err
+	}
+	res, err := cr.query.GetCreditLines(e.Request().Context(), lenderID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get credit lines error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch credit lines")
+	}
+	if res == nil {
+		empty := []structures.CreditLine{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+// GetOwnCreditLine returns the credit line extended to the caller
+func (cr *creditLineRepo) GetOwnCreditLine(e echo.Context, role string) (*structures.CreditLine, error) {
+	actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor)
+	if !ok || actor.Role != role {
+		return nil, echo.NewHTTPError(401, "Session required")
+	}
+	memberID := e.Param("user_id")
+	if role == "DISTRIBUTOR" {
+		memberID = e.Param("distributor_id")
+	}
+	if memberID != actor.ID {
+		return nil, echo.NewHTTPError(403, "Access to another account's data is not allowed")
+	}
+	res, err := cr.query.GetMemberCreditLine(e.Request().Context(), memberID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "No credit line")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get credit line error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch credit line")
+	}
+	return res, nil
+}
+
+// GetOutstandingCredit reports the credit drawn across the admin's network
+func (cr *creditLineRepo) GetOutstandingCredit(e echo.Context) (*[]structures.CreditLine, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	res, err := cr.query.GetOutstandingCredit(e.Request().Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get outstanding credit error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch outstanding credit")
+	}
+	if res == nil {
+		empty := []structures.CreditLine{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+func (cr *creditLineRepo) DeleteCreditLine(e echo.Context, role string) error {
+	var req structures.CreditLineDeleteRequest
+	if err := cr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	lenderID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "CREDIT_LINE_DELETE", "CREDIT_LINE", "", lenderID, role)
+	err = cr.query.DeleteCreditLine(e.Request().Context(), lenderID, req.MemberID, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Credit line not found")
+	}
+	if errors.Is(err, queries.ErrCreditOutstanding) {
+		return echo.NewHTTPError(409, "Credit line cannot be withdrawn while credit is outstanding")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB delete credit line error", "error", err)
+		return echo.NewHTTPError(500, "Failed to delete credit line")
+	}
+	return nil
+}
+
+func (cr *creditLineRepo) GetCreditLineEvents(e echo.Context, role string) (*[]structures.CreditLineEvent, error) {
+	lenderID, err := actingOwner(e, role, e.Param("master_distributor_id"), e.Param("distributor_id"))
+	if err != nil {
+		return nil, err
+	}
+	memberID := e.Param("member_id")
+	if memberID == "" {
+		return nil, echo.NewHTTPError(400, "member_id is required")
+	}
+	res, err := cr.query.GetCreditLineEvents(e.Request().Context(), lenderID, memberID, creditLineEventLimit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get credit line events error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch credit line history")
+	}
+	if res == nil {
+		empty := []structures.CreditLineEvent{}
+		return &empty, nil
+	}
+	return res, nil
+}
@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// loginUserDevice completes a retailer login on the device it comes from.
+// Trusted devices get a session bound to them straight away, and so does the
+// first device of a retailer with none left. Any other device is held back
+// with a challenge until the retailer confirms it with their MPIN.
+func (ar *authRepository) loginUserDevice(e echo.Context, user *structures.UserAuthResponse, fingerprint string, deviceName string) (*structures.LoginResult, error) {
+	fingerprintHash := pkg.HashDeviceFingerprint(fingerprint)
+	state, err := ar.query.GetUserDeviceState(e.Request().Context(), user.UserID, fingerprintHash)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get device state error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to log in")
+	}
+
+	if state.Active {
+		if err := ar.query.TouchUserDevice(e.Request().Context(), state.DeviceID, e.RealIP(), e.Request().UserAgent()); err != nil {
+			slog.ErrorContext(e.Request().Context(), "DB touch device error", "error", err)
+		}
+		return ar.userDeviceLogin(user, state.DeviceID)
+	}
+
+	if state.ActiveDevices == 0 {
+		// No trusted device is left to vouch for this one, so the OTP or
+		// password the retailer just passed has to do
+		deviceID, err := ar.query.RegisterUserDevice(e.Request().Context(), &structures.UserDeviceRegistration{
+			UserID:          user.UserID,
+			FingerprintHash: fingerprintHash,
+			DeviceName:      deviceName,
+			IPAddress:       e.RealIP(),
+			UserAgent:       e.Request().UserAgent(),
+			Alert:           state.TotalDevices > 0,
+		})
+		if err != nil {
+			slog.ErrorContext(e.Request().Context(), "DB register device error", "error", err)
+			return nil, echo.NewHTTPError(500, "Failed to log in")
+		}
+		return ar.userDeviceLogin(user, deviceID)
+	}
+
+	challenge, err := ar.generateTokenFor(structures.DeviceChallenge{
+		UserID:          user.UserID,
+		FingerprintHash: fingerprintHash,
+		DeviceName:      deviceName,
+	}, pkg.DeviceChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &structures.LoginResult{DeviceVerificationRequired: true, ChallengeToken: challenge}, nil
+}
+
+// userDeviceLogin issues a retailer session bound to the device
+func (ar *authRepository) userDeviceLogin(user *structures.UserAuthResponse, deviceID string) (*structures.LoginResult, error) {
+	user.DeviceID = deviceID
+	token, err := ar.generateTokenFor(user, time.Hour*24*365)
+	if err != nil {
+		return nil, err
+	}
+	return &structures.LoginResult{Token: token}, nil
+}
+
+// VerifyUserDevice trusts a new device once the retailer confirms their MPIN
+// and alerts them about it
+func (ar *authRepository) VerifyUserDevice(e echo.Context) (*structures.LoginResult, error) {
+	var req structures.DeviceVerifyRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	data, err := ar.jwtUtils.ParseToken(req.ChallengeToken)
+	if err != nil {
+		return nil, echo.NewHTTPError(401, "Invalid or expired challenge token")
+	}
+	userID, _ := data["device_user_id"].(string)
+	fingerprintHash, _ := data["device_fingerprint_hash"].(string)
+	deviceName, _ := data["device_name"].(string)
+	if userID == "" || fingerprintHash == "" {
+		return nil, echo.NewHTTPError(401, "Invalid or expired challenge token")
+	}
+	if err := verifyUserMpin(e, ar.query, ar.passwordUtils, userID, req.MPIN); err != nil {
+		return nil, err
+	}
+
+	phone, err := ar.query.GetUserPhone(e.Request().Context(), userID)
+	if err != nil {
+		return nil, echo.NewHTTPError(404, "User not found")
+	}
+	user, err := ar.query.GetUserLoginByPhone(e.Request().Context(), phone)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB user login lookup error", "error", err)
+		return nil, echo.NewHTTPError(404, "User not found")
+	}
+	deviceID, err := ar.query.RegisterUserDevice(e.Request().Context(), &structures.UserDeviceRegistration{
+		UserID:          userID,
+		FingerprintHash: fingerprintHash,
+		DeviceName:      deviceName,
+		IPAddress:       e.RealIP(),
+		UserAgent:       e.Request().UserAgent(),
+		Alert:           true,
+	})
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB register device error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to verify device")
+	}
+	return ar.userDeviceLogin(user, deviceID)
+}
+
+type deviceRepo struct {
+	query *queries.Query
+}
+
+func NewDeviceRepository(query *queries.Query) *deviceRepo {
+	return &deviceRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (dr *deviceRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// deviceScope limits device management by a distributor or admin to the
+// retailers under them, and returns who is acting
+func deviceScope(role string, userID string, distributorID string, adminID string) (*structures.UserDeviceScope, string, error) {
+	scope := &structures.UserDeviceScope{UserID: userID}
+	switch role {
+	case "DISTRIBUTOR":
+		if distributorID == "" {
+			return nil, "", echo.NewHTTPError(400, "distributor_id is required")
+		}
+		scope.DistributorID = distributorID
+		return scope, distributorID, nil
+	case "ADMIN":
+		if adminID == "" {
+			return nil, "", echo.NewHTTPError(400, "admin_id is required")
+		}
+		scope.AdminID = adminID
+		return scope, adminID, nil
+	}
+	return scope, userID, nil
+}
+
+func (dr *deviceRepo) GetUserDevices(e echo.Context, role string) (*[]structures.UserDevice, error) {
+	userID := e.Param("user_id")
+	if userID == "" {
+		return nil, echo.NewHTTPError(400, "user_id is required")
+	}
+	scope, _, err := deviceScope(role, userID, e.Param("distributor_id"), e.Param("admin_id"))
+	if err != nil {
+		return nil, err
+	}
+	res, err := dr.query.GetUserDevices(e.Request().Context(), scope)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get user devices error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch devices")
+	}
+	if res == nil {
+		empty := []structures.UserDevice{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+// RevokeUserDevice signs a retailer's device out for good. Retailers revoke
+// their own devices, distributors and admins those of retailers under them.
+func (dr *deviceRepo) RevokeUserDevice(e echo.Context, role string) error {
+	var req structures.UserDeviceRevokeRequest
+	if err := dr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	scope, revokerID, err := deviceScope(role, req.UserID, req.DistributorID, req.AdminID)
+	if err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "DEVICE_REVOKE", "USER_DEVICE", req.DeviceID, revokerID, role)
+	err = dr.query.RevokeUserDevice(e.Request().Context(), scope, req.DeviceID, role, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Active device not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB revoke device error", "error", err)
+		return echo.NewHTTPError(500, "Failed to revoke device")
+	}
+	return nil
+}
@@ -1,7 +1,7 @@
 package repositories
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
@@ -33,8 +33,8 @@ func (fr *fundRequestRepo) CreateFundRequest(e echo.Context) (string, error) {
 	if err := fr.bindAndValidate(e, &req); err != nil {
 		return "", err
 	}
-	if err := fr.query.CreateFundRequest(&req); err != nil {
-		log.Println("DB create fund request error:", err)
+	if err := fr.query.CreateFundRequest(e.Request().Context(), &req); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB create fund request error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to create fund request")
 	}
 	return "Fund request created successfully", nil
@@ -45,8 +45,9 @@ func (fr *fundRequestRepo) RejectFundRequest(e echo.Context) (string, error) {
 	if requestID == "" {
 		return "", echo.NewHTTPError(400, "request_id is required")
 	}
-	if err := fr.query.RejectFundRequest(requestID); err != nil {
-		log.Println("DB reject fund request error:", err)
+	audit := newAuditEntry(e, "FUND_REQUEST_REJECT", "FUND_REQUEST", requestID, "", "ADMIN")
+	if err := fr.query.RejectFundRequest(e.Request().Context(), requestID, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB reject fund request error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to reject fund request")
 	}
 	return "Fund request rejected successfully", nil
@@ -57,8 +58,9 @@ func (fr *fundRequestRepo) AcceptFundRequest(e echo.Context) (string, error) {
 	if err := fr.bindAndValidate(e, &req); err != nil {
 		return "", err
 	}
-	if err := fr.query.AcceptFundRequest(&req); err != nil {
-		log.Println("DB accept fund request error:", err)
+	audit := newAuditEntry(e, "FUND_REQUEST_ACCEPT", "FUND_REQUEST", req.RequestID, req.AdminID, "ADMIN")
+	if err := fr.query.AcceptFundRequest(e.Request().Context(), &req, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB accept fund request error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to accept fund request")
 	}
 	return "Fund request accepted successfully", nil
@@ -69,9 +71,9 @@ func (fr *fundRequestRepo) GetFundRequestsById(e echo.Context) (*[]structures.Ge
 	if requesterID == "" {
 		return nil, echo.NewHTTPError(400, "requester_id is required")
 	}
-	res, err := fr.query.GetFundRequestsById(requesterID)
+	res, err := fr.query.GetFundRequestsById(e.Request().Context(), requesterID)
 	if err != nil {
-		log.Println("DB get fund requests by id error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB get fund requests by id error", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch fund requests")
 	}
 	if res == nil {
@@ -86,9 +88,9 @@ func (fr *fundRequestRepo) GetAllFundRequests(e echo.Context) (*[]structures.Get
 	if adminID == "" {
 		return nil, echo.NewHTTPError(400, "admin_id is required")
 	}
-	res, err := fr.query.GetAllFundRequests(adminID)
+	res, err := fr.query.GetAllFundRequests(e.Request().Context(), adminID)
 	if err != nil {
-		log.Println("DB get all fund requests error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB get all fund requests error", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch fund requests")
 	}
 	if res == nil {
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/labstack/echo/v4"
+)
+
+// providerEnvironment lists the settings each external provider needs to be usable
+var providerEnvironment = map[string][]string{
+	"payout_provider": {"RKIT_API_TOKEN"},
+	"sms_provider":    {"TWILIO_ACCOUNT_SID", "TWILIO_AUTH_TOKEN", "TWILIO_PHONE_NUMBER"},
+}
+
+type healthRepo struct {
+	query *queries.Query
+}
+
+func NewHealthRepository(query *queries.Query) *healthRepo {
+	return &healthRepo{
+		query: query,
+	}
+}
+
+func (hr *healthRepo) Readiness(e echo.Context) *structures.ReadinessReport {
+	report := structures.ReadinessReport{Ready: true}
+
+	ctx, cancel := context.WithTimeout(e.Request().Context(), 2*time.Second)
+	defer cancel()
+	database := structures.ReadinessCheck{Name: "database", Status: "ok"}
+	if err := hr.query.Ping(ctx); err != nil {
+		slog.ErrorContext(e.Request().Context(), "readiness database ping failed", "error", err)
+		database.Status = "failed"
+		database.Error = "database unreachable"
+		report.Ready = false
+	}
+	report.Checks = append(report.Checks, database)
+
+	for _, provider := range []string{"payout_provider", "sms_provider"} {
+		check := structures.ReadinessCheck{Name: provider, Status: "ok"}
+		if provider == "sms_provider" && pkg.NotificationSink(pkg.ChannelSMS) != "twilio" {
+			report.Checks = append(report.Checks, check)
+			continue
+		}
+		var missing []string
+		for _, key := range providerEnvironment[provider] {
+			if os.Getenv(key) == "" {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			check.Status = "failed"
+			check.Error = "missing configuration: " + strings.Join(missing, ", ")
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return &report
+}
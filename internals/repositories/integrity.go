@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// integrityCheckLimit bounds the checks listed at once
+const integrityCheckLimit = 60
+
+type integrityRepo struct {
+	query *queries.Query
+}
+
+func NewIntegrityRepository(query *queries.Query) *integrityRepo {
+	return &integrityRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (ir *integrityRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+func (ir *integrityRepo) GetIntegrityChecks(e echo.Context) (*[]structures.IntegrityCheck, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	res, err := ir.query.GetIntegrityChecks(e.Request().Context(), adminID, integrityCheckLimit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get integrity checks error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch integrity checks")
+	}
+	return res, nil
+}
+
+// GetIntegrityReport returns the drifted wallets and orphaned transactions
+// the check of a day found among the admin's members
+func (ir *integrityRepo) GetIntegrityReport(e echo.Context) (*structures.IntegrityReport, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	day, err := pkg.ParseLedgerDate(e.Param("date"))
+	if err != nil {
+		return nil, echo.NewHTTPError(400, err.Error())
+	}
+	return ir.integrityReport(e, adminID, day)
+}
+
+// RunIntegrityCheck checks a day that has ended again, for instance after
+// its records were corrected, and returns what it found
+func (ir *integrityRepo) RunIntegrityCheck(e echo.Context) (*structures.IntegrityReport, error) {
+	var req structures.IntegrityCheckRequest
+	if err := ir.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	adminID, _ := actingAdmin(e, req.AdminID)
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	day, err := pkg.ParseLedgerDate(req.CheckDate)
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "check_date must be YYYY-MM-DD")
+	}
+	if !day.Before(pkg.StartOfDay(time.Now())) {
+		return nil, echo.NewHTTPError(400, "Only days that have ended can be checked")
+	}
+
+	_, err = ir.query.CheckWalletIntegrity(e.Request().Context(), day)
+	if errors.Is(err, queries.ErrIntegrityNotCovered) {
+		return nil, echo.NewHTTPError(400, "Day is before integrity checks began")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB check wallet integrity error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to check wallet integrity")
+	}
+	return ir.integrityReport(e, adminID, day)
+}
+
+func (ir *integrityRepo) integrityReport(e echo.Context, adminID string, day time.Time) (*structures.IntegrityReport, error) {
+	res, err := ir.query.GetIntegrityReport(e.Request().Context(), adminID, day)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Day has not been checked")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get integrity report error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch integrity report")
+	}
+	return res, nil
+}
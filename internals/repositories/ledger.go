@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// maxSnapshotRangeDays bounds the days listed by one snapshot request
+const maxSnapshotRangeDays = 366
+
+type ledgerRepo struct {
+	query *queries.Query
+}
+
+func NewLedgerRepository(query *queries.Query) *ledgerRepo {
+	return &ledgerRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (lr *ledgerRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// walletOwnerParams reads the wallet a ledger request is about
+func walletOwnerParams(e echo.Context) (string, string, error) {
+	ownerType, ownerID := e.Param("owner_type"), e.Param("owner_id")
+	switch ownerType {
+	case "ADMIN", "MASTER_DISTRIBUTOR", "DISTRIBUTOR", "USER":
+	default:
+		return "", "", echo.NewHTTPError(400, "owner_type must be ADMIN, MASTER_DISTRIBUTOR, DISTRIBUTOR or USER")
+	}
+	if ownerID == "" {
+		return "", "", echo.NewHTTPError(400, "owner_id is required")
+	}
+	return ownerType, ownerID, nil
+}
+
+// GetBalanceAsOf returns a member's balance at the end of the date query
+// parameter, today when it is left out
+func (lr *ledgerRepo) GetBalanceAsOf(e echo.Context) (*structures.BalanceAsOf, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	ownerType, ownerID, err := walletOwnerParams(e)
+	if err != nil {
+		return nil, err
+	}
+	today := pkg.StartOfDay(time.Now())
+	day := today
+	if date := e.QueryParam("date"); date != "" {
+		if day, err = pkg.ParseLedgerDate(date); err != nil {
+			return nil, echo.NewHTTPError(400, err.Error())
+		}
+	}
+	if day.After(today) {
+		return nil, echo.NewHTTPError(400, "date cannot be in the future")
+	}
+
+	res, err := lr.query.GetBalanceAsOf(e.Request().Context(), adminID, ownerType, ownerID, day)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Member not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get balance as of error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch balance")
+	}
+	return res, nil
+}
+
+// GetBalanceSnapshots lists a member's daily snapshots between the from and
+// to query parameters, the last 30 days when they are left out
+func (lr *ledgerRepo) GetBalanceSnapshots(e echo.Context) (*[]structures.BalanceSnapshot, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	ownerType, ownerID, err := walletOwnerParams(e)
+	if err != nil {
+		return nil, err
+	}
+	to := pkg.StartOfDay(time.Now())
+	if value := e.QueryParam("to"); value != "" {
+		if to, err = pkg.ParseLedgerDate(value); err != nil {
+			return nil, echo.NewHTTPError(400, err.Error())
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if value := e.QueryParam("from"); value != "" {
+		if from, err = pkg.ParseLedgerDate(value); err != nil {
+			return nil, echo.NewHTTPError(400, err.Error())
+		}
+	}
+	if from.After(to) {
+		return nil, echo.NewHTTPError(400, "from must not be after to")
+	}
+	if to.Sub(from) >= maxSnapshotRangeDays*24*time.Hour {
+		return nil, echo.NewHTTPError(400, "Snapshots can be listed for at most a year at a time")
+	}
+
+	res, err := lr.query.GetBalanceSnapshots(e.Request().Context(), adminID, ownerType, ownerID, from, to)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Member not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get balance snapshots error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch balance snapshots")
+	}
+	return res, nil
+}
+
+// ClosePeriod closes the admin's books through a past day. Payouts and
+// transactions of its members made on or before it can no longer be changed,
+// except payouts still pending at the provider.
+func (lr *ledgerRepo) ClosePeriod(e echo.Context) (*structures.ClosedPeriod, error) {
+	var req structures.ClosePeriodRequest
+	if err := lr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	adminID, actorID := actingAdmin(e, req.AdminID)
+	if actorID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	periodEnd, err := pkg.ParseLedgerDate(req.PeriodEnd)
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "period_end must be YYYY-MM-DD")
+	}
+	if !periodEnd.Before(pkg.StartOfDay(time.Now())) {
+		return nil, echo.NewHTTPError(400, "Only days that have ended can be closed")
+	}
+
+	audit := newAuditEntry(e, "PERIOD_CLOSE", "PERIOD", req.PeriodEnd, actorID, "ADMIN")
+	res, err := lr.query.ClosePeriod(e.Request().Context(), adminID, periodEnd, actorID, audit)
+	if errors.Is(err, queries.ErrPeriodAlreadyClosed) {
+		return nil, echo.NewHTTPError(409, "Period is already closed")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB close period error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to close period")
+	}
+	return res, nil
+}
+
+func (lr *ledgerRepo) GetClosedPeriods(e echo.Context) (*[]structures.ClosedPeriod, error) {
+	adminID, _ := actingAdmin(e, e.QueryParam("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	res, err := lr.query.GetClosedPeriods(e.Request().Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get closed periods error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch closed periods")
+	}
+	return res, nil
+}
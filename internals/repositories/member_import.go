@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// maxMemberImportFileSize caps an uploaded member import file
+const maxMemberImportFileSize = 2 << 20
+
+type memberImportRepo struct {
+	query         *queries.Query
+	passwordUtils *pkg.PasswordUtils
+}
+
+func NewMemberImportRepository(query *queries.Query, passwordUtils *pkg.PasswordUtils) *memberImportRepo {
+	return &memberImportRepo{
+		query:         query,
+		passwordUtils: passwordUtils,
+	}
+}
+
+// ImportMembers creates master distributors, distributors and retailers from
+// an uploaded CSV file. Every row is checked and reported on. With dry_run
+// nothing is kept. In ATOMIC mode a single bad row keeps the whole file out.
+func (mr *memberImportRepo) ImportMembers(e echo.Context) (*structures.MemberImport, error) {
+	adminID, actorID := actingAdmin(e, e.FormValue("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+
+	mode := strings.ToUpper(e.FormValue("mode"))
+	if mode == "" {
+		mode = pkg.ImportModeAtomic
+	}
+	if mode != pkg.ImportModeAtomic && mode != pkg.ImportModePerRow {
+		return nil, echo.NewHTTPError(400, "mode must be ATOMIC or PER_ROW")
+	}
+	dryRun := false
+	if value := e.FormValue("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return nil, echo.NewHTTPError(400, "dry_run must be true or false")
+		}
+	}
+
+	rows, err := readMemberImportFile(e)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		row.RowNumber = i + 1
+		if problems := validateMemberImportRow(e, row); len(problems) > 0 {
+			row.RowStatus, row.Errors = pkg.ImportRowFailed, problems
+			continue
+		}
+		// A dry run never keeps the row, so its password need not be hashed
+		if !dryRun {
+			hashed, err := mr.passwordUtils.HashPassword(row.Password)
+			if err != nil {
+				slog.ErrorContext(e.Request().Context(), "Password hashing failed", "error", err)
+				return nil, echo.NewHTTPError(500, "Failed to secure password")
+			}
+			row.Password = hashed
+		}
+	}
+
+	imp := &structures.MemberImport{
+		AdminID:   adminID,
+		Mode:      mode,
+		DryRun:    dryRun,
+		TotalRows: len(rows),
+		Rows:      rows,
+	}
+	audit := newAuditEntry(e, "MEMBER_BULK_IMPORT", "MEMBER_IMPORT", "", actorID, "ADMIN")
+	if err := mr.query.ImportMembers(e.Request().Context(), imp, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB import members error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to import members")
+	}
+	return imp, nil
+}
+
+// readMemberImportFile reads the rows of the uploaded file
+func readMemberImportFile(e echo.Context) ([]structures.MemberImportRow, error) {
+	header, err := e.FormFile("file")
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "Import file is required")
+	}
+	if header.Size > maxMemberImportFileSize {
+		return nil, echo.NewHTTPError(400, "Import file is too large")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "Invalid import file")
+	}
+	defer file.Close()
+
+	return parseMemberImportRows(file)
+}
+
+// parseMemberImportRows reads the rows of a member import file. Its first
+// line names the columns, which may come in any order.
+func parseMemberImportRows(file io.Reader) ([]structures.MemberImportRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "Import file has no header")
+	}
+	index := make(map[string]int)
+	for i, name := range columns {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range pkg.MemberImportColumns {
+		if name == "parent_ref" || name == "gst_number" {
+			continue
+		}
+		if _, ok := index[name]; !ok {
+			return nil, echo.NewHTTPError(400, fmt.Sprintf("Import file is missing the %s column", name))
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []structures.MemberImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, echo.NewHTTPError(400, fmt.Sprintf("Invalid import file: %v", err))
+		}
+		if len(rows) == pkg.MaxMemberImportRows {
+			return nil, echo.NewHTTPError(400, fmt.Sprintf("Import can have at most %d rows", pkg.MaxMemberImportRows))
+		}
+		rows = append(rows, structures.MemberImportRow{
+			MemberType:   strings.ToUpper(field(record, "member_type")),
+			ParentRef:    field(record, "parent_ref"),
+			Name:         field(record, "name"),
+			Email:        field(record, "email"),
+			Phone:        field(record, "phone"),
+			Password:     field(record, "password"),
+			AadharNumber: field(record, "aadhar_number"),
+			PanNumber:    strings.ToUpper(field(record, "pan_number")),
+			DateOfBirth:  field(record, "date_of_birth"),
+			Gender:       field(record, "gender"),
+			City:         field(record, "city"),
+			State:        field(record, "state"),
+			Address:      field(record, "address"),
+			Pincode:      field(record, "pincode"),
+			BusinessName: field(record, "business_name"),
+			BusinessType: field(record, "business_type"),
+			GSTNumber:    field(record, "gst_number"),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, echo.NewHTTPError(400, "Import file has no rows")
+	}
+	return rows, nil
+}
+
+// validateMemberImportRow lists everything wrong with a row that can be told
+// without the database
+func validateMemberImportRow(e echo.Context, row *structures.MemberImportRow) []string {
+	var problems []string
+	var fieldErrs validator.ValidationErrors
+	if err := e.Validate(row); errors.As(err, &fieldErrs) {
+		for _, fe := range fieldErrs {
+			if fe.Tag() == "required" {
+				problems = append(problems, importColumnName(fe.Field())+" is required")
+			} else {
+				problems = append(problems, "invalid "+importColumnName(fe.Field()))
+			}
+		}
+	} else if err != nil {
+		problems = append(problems, "invalid row")
+	}
+	if row.PanNumber != "" && !pkg.PANPattern.MatchString(row.PanNumber) {
+		problems = append(problems, "invalid PAN")
+	}
+	if (row.MemberType == "DISTRIBUTOR" || row.MemberType == "USER") && row.ParentRef == "" {
+		problems = append(problems, "parent_ref is required")
+	}
+	return problems
+}
+
+// importColumnName turns a row field name back into its file column
+func importColumnName(field string) string {
+	var sb strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
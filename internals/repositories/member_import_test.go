@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/labstack/echo/v4"
+)
+
+// memberImportHeader is the header of a complete import file, in the
+// documented column order
+var memberImportHeader = strings.Join(pkg.MemberImportColumns, ",") + "\n"
+
+func TestParseMemberImportRows(t *testing.T) {
+	file := memberImportHeader +
+		"distributor, MD-7 ,Asha Rao,asha@example.com,9876543210,Secret@123,123412341234,abcde1234f," +
+		"1990-01-31,F,Pune,MH,\"12, MG Road\",411001,Asha Traders,RETAIL,\n"
+
+	rows, err := parseMemberImportRows(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("parsed %d rows, want 1", len(rows))
+	}
+	want := structures.MemberImportRow{
+		MemberType: "DISTRIBUTOR", ParentRef: "MD-7", Name: "Asha Rao", Email: "asha@example.com",
+		Phone: "9876543210", Password: "Secret@123", AadharNumber: "123412341234", PanNumber: "ABCDE1234F",
+		DateOfBirth: "1990-01-31", Gender: "F", City: "Pune", State: "MH", Address: "12, MG Road",
+		Pincode: "411001", BusinessName: "Asha Traders", BusinessType: "RETAIL",
+	}
+	if fmt.Sprintf("%+v", rows[0]) != fmt.Sprintf("%+v", want) {
+		t.Errorf("row = %+v, want %+v", rows[0], want)
+	}
+}
+
+func TestParseMemberImportRowsReordersColumns(t *testing.T) {
+	file := "Phone,NAME,member_type,email,password,aadhar_number,pan_number,date_of_birth,gender,city," +
+		"state,address,pincode,business_name,business_type\n" +
+		"9876543210,Vikram,master_distributor,v@example.com,Secret@123,1,ABCDE1234F,1990-01-31,M,Pune,MH,Road,411001,VK,RETAIL\n"
+
+	rows, err := parseMemberImportRows(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	row := rows[0]
+	if row.Phone != "9876543210" || row.Name != "Vikram" || row.MemberType != "MASTER_DISTRIBUTOR" {
+		t.Errorf("columns read out of place: %+v", row)
+	}
+	if row.ParentRef != "" || row.GSTNumber != "" {
+		t.Errorf("optional columns left out should be empty: %+v", row)
+	}
+}
+
+func TestParseMemberImportRowsRejectsBadFiles(t *testing.T) {
+	row := "USER,D-1,Asha Rao,asha@example.com,9876543210,Secret@123,1,ABCDE1234F,1990-01-31,F,Pune,MH,Road,411001,AT,RETAIL,\n"
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"empty", "", "Import file has no header"},
+		{"header only", memberImportHeader, "Import file has no rows"},
+		{"missing column", strings.Replace(memberImportHeader, ",pincode", "", 1) + row,
+			"Import file is missing the pincode column"},
+		{"unterminated quote", memberImportHeader + `USER,"D-1` + "\n", "Invalid import file"},
+		{"too many rows", memberImportHeader + strings.Repeat(row, pkg.MaxMemberImportRows+1),
+			fmt.Sprintf("Import can have at most %d rows", pkg.MaxMemberImportRows)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMemberImportRows(strings.NewReader(tt.file))
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Code != 400 {
+				t.Fatalf("err = %v, want a 400", err)
+			}
+			if msg := fmt.Sprint(httpErr.Message); !strings.HasPrefix(msg, tt.want) {
+				t.Errorf("message = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportColumnName(t *testing.T) {
+	for field, want := range map[string]string{
+		"MemberType":   "member_type",
+		"AadharNumber": "aadhar_number",
+		"Name":         "name",
+	} {
+		if got := importColumnName(field); got != want {
+			t.Errorf("importColumnName(%s) = %s, want %s", field, got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// isHashedMpin tells bcrypt hashes apart from MPINs stored before hashing was introduced
+func isHashedMpin(stored string) bool {
+	return strings.HasPrefix(stored, "$2")
+}
+
+// mpinChecker compares against a bcrypt hash, or against a legacy plaintext
+// MPIN which is then rehashed so it is upgraded on its first successful use.
+func mpinChecker(passwordUtils *pkg.PasswordUtils, mpin string) queries.MpinCheck {
+	return func(stored string) (bool, string, error) {
+		if isHashedMpin(stored) {
+			return passwordUtils.VerifyPassword(stored, mpin) == nil, "", nil
+		}
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(mpin)) != 1 {
+			return false, "", nil
+		}
+		hash, err := passwordUtils.HashPassword(mpin)
+		if err != nil {
+			return false, "", err
+		}
+		return true, hash, nil
+	}
+}
+
+// verifyUserMpin checks the MPIN against the attempt limits and turns every
+// outcome other than success into an HTTP error.
+func verifyUserMpin(e echo.Context, query *queries.Query, passwordUtils *pkg.PasswordUtils, userID string, mpin string) error {
+	res, err := query.VerifyUserMpin(e.Request().Context(), userID, mpinChecker(passwordUtils, mpin))
+	if errors.Is(err, queries.ErrMpinNotSet) {
+		return echo.NewHTTPError(400, "MPIN not set")
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "User not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB verify mpin error", "error", err)
+		return echo.NewHTTPError(500, "Failed to verify MPIN")
+	}
+	if res.Valid {
+		return nil
+	}
+	if res.Locked {
+		wait := time.Until(res.LockedUntil)
+		e.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return echo.NewHTTPError(423, fmt.Sprintf("Too many wrong MPIN attempts, try again in %d minutes", int(math.Ceil(wait.Minutes()))))
+	}
+	return echo.NewHTTPError(401, fmt.Sprintf("Wrong MPIN, %d attempts left", res.RemainingAttempts))
+}
+
+func (ar *authRepository) SendMpinResetOTP(e echo.Context) (string, error) {
+	var req structures.UserMpinOTPRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return "", err
+	}
+	phone, err := ar.query.GetUserPhone(e.Request().Context(), req.UserID)
+	if err != nil {
+		return "", echo.NewHTTPError(404, "User not found")
+	}
+	if err := sendOTP(e, ar.query, ar.otpUtils, ar.notifications, phone, pkg.OTPPurposeMpinReset); err != nil {
+		return "", err
+	}
+	return "OTP sent successfully", nil
+}
+
+func (ar *authRepository) ResetUserMpin(e echo.Context) error {
+	var req structures.UserMpinResetRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	return ar.replaceUserMpin(e, "MPIN_RESET", req.UserID, req.OTP, req.NewMPIN)
+}
+
+func (ar *authRepository) ChangeUserMpin(e echo.Context) error {
+	var req structures.UserMpinChangeRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	if err := verifyUserMpin(e, ar.query, ar.passwordUtils, req.UserID, req.CurrentMPIN); err != nil {
+		return err
+	}
+	return ar.replaceUserMpin(e, "MPIN_CHANGE", req.UserID, req.OTP, req.NewMPIN)
+}
+
+func (ar *authRepository) replaceUserMpin(e echo.Context, action string, userID string, otp string, mpin string) error {
+	phone, err := ar.query.GetUserPhone(e.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(404, "User not found")
+	}
+	if err := verifyOTP(e, ar.query, ar.otpUtils, phone, pkg.OTPPurposeMpinReset, otp); err != nil {
+		return err
+	}
+	hash, err := ar.passwordUtils.HashPassword(mpin)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "MPIN hashing failed", "error", err)
+		return echo.NewHTTPError(500, "Failed to update MPIN")
+	}
+	audit := newAuditEntry(e, action, "USER", userID, userID, "USER")
+	err = ar.query.ResetUserMpin(e.Request().Context(), userID, hash, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "User not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB reset mpin error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update MPIN")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"log/slog"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/labstack/echo/v4"
+)
+
+type notificationRepo struct {
+	query *queries.Query
+}
+
+func NewNotificationRepository(query *queries.Query) *notificationRepo {
+	return &notificationRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (nr *notificationRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+func (nr *notificationRepo) GetNotificationPreferences(e echo.Context) (*structures.NotificationPreferencesResponse, error) {
+	ownerID := e.Param("owner_id")
+	if ownerID == "" {
+		return nil, echo.NewHTTPError(400, "owner_id is required")
+	}
+	res, err := nr.query.GetNotificationPreferences(e.Request().Context(), ownerID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get notification preferences error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch notification preferences")
+	}
+	return res, nil
+}
+
+func (nr *notificationRepo) SetNotificationPreferences(e echo.Context) error {
+	var req structures.NotificationPreferencesRequest
+	if err := nr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	if err := nr.query.SetNotificationPreferences(e.Request().Context(), &req); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB set notification preferences error", "error", err)
+		return echo.NewHTTPError(500, "Failed to save notification preferences")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/labstack/echo/v4"
+)
+
+// sendOTP issues a fresh OTP for the purpose and queues it as an SMS to phone.
+// Requests refused by the cooldown or the hourly caps come back as 429 with
+// Retry-After.
+func sendOTP(e echo.Context, query *queries.Query, otpUtils *pkg.OTPUtils, notifications *pkg.NotificationService, phone string, purpose string) error {
+	otp, err := otpUtils.GenerateOTP()
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "OTP generation error", "error", err)
+		return echo.NewHTTPError(500, "Failed to generate OTP")
+	}
+	err = query.IssueOTP(e.Request().Context(), &structures.OTPIssue{
+		Phone:          phone,
+		Purpose:        purpose,
+		CodeHash:       otpUtils.HashOTP(phone, purpose, otp),
+		IPAddress:      e.RealIP(),
+		TTL:            pkg.OTPTTL,
+		MaxAttempts:    pkg.OTPMaxAttempts,
+		ResendCooldown: pkg.OTPResendCooldown,
+		PhoneHourlyCap: pkg.OTPPhoneHourlyCap,
+		IPHourlyCap:    pkg.OTPIPHourlyCap,
+	})
+	var throttled *queries.OTPThrottleError
+	if errors.As(err, &throttled) {
+		seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		e.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(seconds))
+		return echo.NewHTTPError(429, "Too many OTP requests, try again in "+strconv.Itoa(seconds)+" seconds")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB OTP generation error", "error", err)
+		return echo.NewHTTPError(500, "Failed to generate OTP")
+	}
+	err = notifications.Notify(e.Request().Context(), pkg.Notification{
+		Channel:  pkg.ChannelSMS,
+		To:       phone,
+		Event:    pkg.EventOTP,
+		Language: pkg.DefaultLanguage,
+		Data: map[string]string{
+			"otp":              otp,
+			"validity_minutes": strconv.Itoa(int(pkg.OTPTTL.Minutes())),
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "OTP notification error", "error", err)
+		return echo.NewHTTPError(500, "Failed to send OTP")
+	}
+	return nil
+}
+
+// verifyOTP consumes the OTP sent to phone for the purpose, or turns the
+// failure into an HTTP error.
+func verifyOTP(e echo.Context, query *queries.Query, otpUtils *pkg.OTPUtils, phone string, purpose string, otp string) error {
+	err := query.VerifyOTP(e.Request().Context(), phone, purpose, otpUtils.HashOTP(phone, purpose, otp))
+	if errors.Is(err, queries.ErrInvalidOTP) {
+		return echo.NewHTTPError(401, "Invalid or expired OTP")
+	}
+	if errors.Is(err, queries.ErrOTPAttemptsExceeded) {
+		return echo.NewHTTPError(401, "Too many wrong OTP attempts, request a new OTP")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB OTP validation error", "error", err)
+		return echo.NewHTTPError(500, "Failed to verify OTP")
+	}
+	return nil
+}
+
+// SendUserOTP sends an OTP confirming a sensitive retailer action such as a
+// payout or a new beneficiary.
+func (ar *authRepository) SendUserOTP(e echo.Context) (string, error) {
+	var req structures.UserOTPRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return "", err
+	}
+	phone, err := ar.query.GetUserPhone(e.Request().Context(), req.UserID)
+	if err != nil {
+		return "", echo.NewHTTPError(404, "User not found")
+	}
+	if err := sendOTP(e, ar.query, ar.otpUtils, ar.notifications, phone, req.Purpose); err != nil {
+		return "", err
+	}
+	return "OTP sent successfully", nil
+}
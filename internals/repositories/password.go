@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// replacePassword stores newPassword for the account unless it repeats one of
+// the last pkg.PasswordHistoryDepth passwords.
+func (ar *authRepository) replacePassword(e echo.Context, action string, account *structures.PasswordAccount, newPassword string) error {
+	history, err := ar.query.GetPasswordHistory(e.Request().Context(), account.ID, pkg.PasswordHistoryDepth)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get password history error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update password")
+	}
+	if ar.passwordUtils.MatchesAny(append(history, account.PasswordHash), newPassword) {
+		return echo.NewHTTPError(400, "New password must differ from your recent passwords")
+	}
+	hash, err := ar.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, action, account.Role, account.ID, account.ID, account.Role)
+	err = ar.query.UpdatePassword(e.Request().Context(), account.Role, account.ID, hash, pkg.PasswordHistoryDepth, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Account not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB update password error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update password")
+	}
+	return nil
+}
+
+// ChangePassword replaces the password of the signed in account of the role
+// after checking the current one. Wrong current passwords count towards the
+// same lockout as failed logins.
+func (ar *authRepository) ChangePassword(e echo.Context, role string) error {
+	actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor)
+	if !ok || actor.Role != role || actor.StaffID != "" {
+		return echo.NewHTTPError(401, "Sign in to change your password")
+	}
+	var req structures.PasswordChangeRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	account, err := ar.query.GetPasswordAccountByID(e.Request().Context(), role, actor.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Account not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get password account error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update password")
+	}
+	// Logins lock by the email, or the phone for retailers
+	login := account.Email
+	if role == "USER" {
+		login = account.Phone
+	}
+	getDBPass := func() (string, error) { return account.PasswordHash, nil }
+	if err := ar.checkPassword(e, role, login, getDBPass, req.CurrentPassword); err != nil {
+		return err
+	}
+	return ar.replacePassword(e, "PASSWORD_CHANGE", account, req.NewPassword)
+}
+
+// ForgotPassword sends a password reset OTP to the phone registered on the
+// role's account. Unknown logins get the same answer so accounts cannot be
+// discovered through this endpoint.
+func (ar *authRepository) ForgotPassword(e echo.Context, role string) (string, error) {
+	var req structures.ForgotPasswordRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return "", err
+	}
+	const message = "If the account exists, an OTP has been sent to its registered phone"
+	account, err := ar.query.GetPasswordAccountByLogin(e.Request().Context(), role, req.Login)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return message, nil
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get password account error", "error", err)
+		return "", echo.NewHTTPError(500, "Failed to send OTP")
+	}
+	if err := sendOTP(e, ar.query, ar.otpUtils, ar.notifications, account.Phone, pkg.OTPPurposePasswordReset); err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+// ResetPassword sets a new password for the role's account once the reset OTP
+// is confirmed.
+func (ar *authRepository) ResetPassword(e echo.Context, role string) error {
+	var req structures.PasswordResetRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	account, err := ar.query.GetPasswordAccountByLogin(e.Request().Context(), role, req.Login)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(401, "Invalid or expired OTP")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get password account error", "error", err)
+		return echo.NewHTTPError(500, "Failed to reset password")
+	}
+	if err := verifyOTP(e, ar.query, ar.otpUtils, account.Phone, pkg.OTPPurposePasswordReset, req.OTP); err != nil {
+		return err
+	}
+	return ar.replacePassword(e, "PASSWORD_RESET", account, req.NewPassword)
+}
+
+// LoginUserWithPassword lets a retailer sign in with phone and password as an
+// alternative to the login OTP.
+func (ar *authRepository) LoginUserWithPassword(e echo.Context) (*structures.LoginResult, error) {
+	var req structures.UserPasswordLoginRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	if req.DeviceFingerprint == "" {
+		return nil, echo.NewHTTPError(400, "device_fingerprint is required")
+	}
+	res, err := ar.verifyPasswordAndLoad(
+		e,
+		"USER",
+		req.Phone,
+		func() (string, error) {
+			account, err := ar.query.GetPasswordAccountByLogin(e.Request().Context(), "USER", req.Phone)
+			if err != nil {
+				return "", err
+			}
+			return account.PasswordHash, nil
+		},
+		req.Password,
+		func() (interface{}, error) { return ar.query.GetUserLoginByPhone(e.Request().Context(), req.Phone) },
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ar.loginUserDevice(e, res.(*structures.UserAuthResponse), req.DeviceFingerprint, req.DeviceName)
+}
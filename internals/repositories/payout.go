@@ -5,9 +5,10 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"os"
@@ -22,14 +23,16 @@ import (
 )
 
 type payoutRepo struct {
-	query    *queries.Query
-	jwtUtils *pkg.JwtUtils
+	query         *queries.Query
+	jwtUtils      *pkg.JwtUtils
+	passwordUtils *pkg.PasswordUtils
 }
 
-func NewPayoutRepository(query *queries.Query, jwtUtils *pkg.JwtUtils) *payoutRepo {
+func NewPayoutRepository(query *queries.Query, jwtUtils *pkg.JwtUtils, passwordUtils *pkg.PasswordUtils) *payoutRepo {
 	return &payoutRepo{
-		query:    query,
-		jwtUtils: jwtUtils,
+		query:         query,
+		jwtUtils:      jwtUtils,
+		passwordUtils: passwordUtils,
 	}
 }
 
@@ -51,21 +54,11 @@ func (pr *payoutRepo) PayoutRequest(e echo.Context) (string, error) {
 
 	amt, err := strconv.ParseFloat(req.Amount, 64)
 	if err != nil {
-		log.Println("parse amount error:", err)
+		slog.ErrorContext(e.Request().Context(), "parse amount error", "error", err)
 		return "", echo.NewHTTPError(400, "Invalid amount")
 	}
 
-	specialUsers := map[string]bool{
-		"408513e5-e1f7-4d37-8465-4746fdfa0aa8": true,
-		"e081e9b5-2674-4c76-8fe4-d7e97ed9c76e": true,
-		"b113aaf0-4c51-4451-9adf-e38eca36bf5b": true,
-	}
-
-	maxLimit := 25000.0
-	if specialUsers[req.UserID] {
-		maxLimit = 49999
-	}
-
+	maxLimit := payoutAmountLimit(req.UserID)
 	if amt < 1000 || amt > maxLimit {
 		return "", echo.NewHTTPError(
 			400,
@@ -74,9 +67,9 @@ func (pr *payoutRepo) PayoutRequest(e echo.Context) (string, error) {
 	}
 
 	// Check User Balance
-	hasBalance, err := pr.query.CheckUserBalance(req.UserID, req.Amount, req.Commission)
+	hasBalance, err := pr.query.CheckUserBalance(e.Request().Context(), req.UserID, req.Amount, req.Commission)
 	if err != nil {
-		log.Println("DB check user balance error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB check user balance error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to verify wallet balance")
 	}
 	if !hasBalance {
@@ -84,17 +77,12 @@ func (pr *payoutRepo) PayoutRequest(e echo.Context) (string, error) {
 	}
 
 	// Check MPIN
-	hasMpin, err := pr.query.CheckMpin(req.UserID, req.MPIN)
-	if err != nil {
-		log.Println("DB check mpin error:", err)
-		return "", echo.NewHTTPError(500, "Wrong MPIN")
-	}
-	if !hasMpin {
-		return "", echo.NewHTTPError(400, "Wrong MPIN")
+	if err := verifyUserMpin(e, pr.query, pr.passwordUtils, req.UserID, req.MPIN); err != nil {
+		return "", err
 	}
 
 	// Initialize Payout Request (prepare DB entry / partner request id etc.)
-	apiReqBody, err := pr.query.InitilizePayoutRequest(&structures.PayoutInitilizationRequest{
+	apiReqBody, err := pr.query.InitilizePayoutRequest(e.Request().Context(), &structures.PayoutInitilizationRequest{
 		UserID:          req.UserID,
 		MobileNumber:    req.MobileNumber,
 		AccountNumber:   req.AccountNumber,
@@ -106,67 +94,96 @@ func (pr *payoutRepo) PayoutRequest(e echo.Context) (string, error) {
 		Remarks:         req.Remarks,
 		Commission:      req.Commission,
 	})
+	if errors.Is(err, queries.ErrInsufficientBalance) {
+		return "", echo.NewHTTPError(400, "Insufficient balance")
+	}
 	if err != nil {
-		log.Println("DB initialize payout request error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB initialize payout request error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to initialize payout")
 	}
 
-	// Prepare external API request
+	// The wallet is already debited, so the provider call and its outcome must be
+	// recorded even if the client goes away
+	ctx := context.WithoutCancel(e.Request().Context())
+
+	payoutFinal, err := sendPayout(ctx, apiReqBody)
+	if err != nil {
+		return "", err
+	}
+
+	if err := pr.query.FinalPayout(ctx, payoutFinal); err != nil {
+		return "", err
+	}
+
+	return "Transaction successful", nil
+}
+
+// payoutAmountLimit is the largest single payout the retailer may make
+func payoutAmountLimit(userID string) float64 {
+	specialUsers := map[string]bool{
+		"408513e5-e1f7-4d37-8465-4746fdfa0aa8": true,
+		"e081e9b5-2674-4c76-8fe4-d7e97ed9c76e": true,
+		"b113aaf0-4c51-4451-9adf-e38eca36bf5b": true,
+	}
+
+	if specialUsers[userID] {
+		return 49999
+	}
+	return 25000.0
+}
+
+// sendPayout hands a payout that is already debited to the provider and
+// returns the provider's verdict on it
+func sendPayout(ctx context.Context, apiReqBody *structures.PayoutApiRequest) (*structures.PayoutFinal, error) {
 	token := os.Getenv("RKIT_API_TOKEN")
 	if token == "" {
-		log.Println("missing RKIT_API_TOKEN")
-		return "", echo.NewHTTPError(500, "Payout provider configuration error")
+		slog.ErrorContext(ctx, "missing RKIT_API_TOKEN")
+		return nil, echo.NewHTTPError(500, "Payout provider configuration error")
 	}
 
 	url := "https://v2bapi.rechargkit.biz/rkitpayout/payoutTransfer"
 	reqBody, err := json.Marshal(apiReqBody)
 	if err != nil {
-		log.Println("marshal api request error:", err)
-		return "", echo.NewHTTPError(500, "Failed to prepare payout request")
+		slog.ErrorContext(ctx, "marshal api request error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to prepare payout request")
 	}
 
-	apiRequest, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+	apiRequest, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
 	if err != nil {
-		log.Println("create api request error:", err)
-		return "", echo.NewHTTPError(500, "Failed to create payout request")
+		slog.ErrorContext(ctx, "create api request error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create payout request")
 	}
 	apiRequest.Header.Set("Content-Type", "application/json")
 	apiRequest.Header.Set("Authorization", "Bearer "+token)
 
-	client := &http.Client{
-		Timeout: 20 * time.Second,
-	}
+	client := pkg.NewHTTPClient(20 * time.Second)
+	providerStart := time.Now()
 	resp, err := client.Do(apiRequest)
+	pkg.ObserveProviderRequest("rechargkit", "payout_transfer", providerStart, err)
 	if err != nil {
-		log.Println("send api request error:", err)
-		return "", echo.NewHTTPError(502, "Failed to contact payout provider")
+		slog.ErrorContext(ctx, "send api request error", "error", err)
+		return nil, echo.NewHTTPError(502, "Failed to contact payout provider")
 	}
 	defer resp.Body.Close()
 
 	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("read api response error:", err)
-		return "", echo.NewHTTPError(502, "Failed to read payout provider response")
+		slog.ErrorContext(ctx, "read api response error", "error", err)
+		return nil, echo.NewHTTPError(502, "Failed to read payout provider response")
 	}
 
 	var payoutFinal structures.PayoutFinal
-
 	if err := json.Unmarshal(respBytes, &payoutFinal); err != nil {
-		return "", err
-	}
-
-	if err := pr.query.FinalPayout(&payoutFinal); err != nil {
-		return "", err
+		return nil, err
 	}
-
-	return "Transaction successful", nil
+	return &payoutFinal, nil
 }
 
 func (pr *payoutRepo) GetPayoutTransactions(e echo.Context) (*[]structures.GetPayoutLogs, error) {
 	var userId = e.Param("user_id")
-	res, err := pr.query.GetPayoutTransactions(userId)
+	res, err := pr.query.GetPayoutTransactions(e.Request().Context(), userId)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(e.Request().Context(), "DB get payout transactions error", "error", err)
 		return nil, fmt.Errorf("failed to fetch payout transactions")
 	}
 	return res, nil
@@ -213,11 +230,12 @@ func (pr *payoutRepo) VerifyAccountNumber(e echo.Context) (*structures.PayoutVer
 
 	bodyBytes, _ := json.Marshal(payload)
 
-	if err := pr.query.DeductUserBalanceForVerification(userId); err != nil {
+	if err := pr.query.DeductUserBalanceForVerification(e.Request().Context(), userId); err != nil {
 		return nil, err
 	}
 
-	req, _ := http.NewRequest(
+	req, _ := http.NewRequestWithContext(
+		e.Request().Context(),
 		"POST",
 		"https://api.verifya2z.com/api/v1/verification/penny_drop_v2",
 		strings.NewReader(string(bodyBytes)),
@@ -227,7 +245,9 @@ func (pr *payoutRepo) VerifyAccountNumber(e echo.Context) (*structures.PayoutVer
 	req.Header.Add("Token", token)
 	req.Header.Add("content-type", "application/json")
 
-	res, err := http.DefaultClient.Do(req)
+	providerStart := time.Now()
+	res, err := pkg.NewHTTPClient(30 * time.Second).Do(req)
+	pkg.ObserveProviderRequest("verifya2z", "penny_drop", providerStart, err)
 	if err != nil {
 		return nil, err
 	}
@@ -239,21 +259,20 @@ func (pr *payoutRepo) VerifyAccountNumber(e echo.Context) (*structures.PayoutVer
 	var resp structures.PayoutVerifyAccountResponse
 	_ = json.Unmarshal(body, &resp)
 
-	log.Println(string(body))
+	// The raw response carries the account holder details, so only its outcome is logged
+	slog.DebugContext(e.Request().Context(), "account verification response", "http_status", res.StatusCode)
 
 	return &resp, nil
 }
 
-func (pr *payoutRepo) RefundPayoutTransaction(transactionId string) error {
+func (pr *payoutRepo) RefundPayoutTransaction(ctx context.Context, transactionId string) error {
 	var req structures.PayoutRefund
 	req.TransactionID = transactionId
 
-	return pr.query.PayoutTransactionRefund(&req)
+	return pr.query.PayoutTransactionRefund(ctx, &req)
 }
 
 func (pr *payoutRepo) GetPayoutReports(c echo.Context) ([]structures.PayoutReportResponse, error) {
 	var userID = c.Param("user_id")
-	ctx, cancel := context.WithTimeout(c.Request().Context(), time.Second*10)
-	defer cancel()
-	return pr.query.GetPayoutReports(ctx, userID)
+	return pr.query.GetPayoutReports(c.Request().Context(), userID)
 }
@@ -0,0 +1,346 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// maxPayoutBatchFileSize caps an uploaded bulk payout file
+const maxPayoutBatchFileSize = 2 << 20
+
+var (
+	ifscPattern          = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9]{6,20}$`)
+)
+
+// CreatePayoutBatch takes a bulk payout as a CSV upload or a JSON body,
+// reserves its total and starts paying the rows out in the background. The
+// returned batch shows which rows were rejected up front.
+func (pr *payoutRepo) CreatePayoutBatch(e echo.Context) (*structures.PayoutBatch, error) {
+	var req structures.PayoutBatchRequest
+	if strings.HasPrefix(e.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		if err := pr.readPayoutBatchFile(e, &req); err != nil {
+			return nil, err
+		}
+	} else if err := pr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Rows) == 0 {
+		return nil, echo.NewHTTPError(400, "Batch has no rows")
+	}
+	if len(req.Rows) > pkg.MaxPayoutBatchRows {
+		return nil, echo.NewHTTPError(400, fmt.Sprintf("Batch can have at most %d rows", pkg.MaxPayoutBatchRows))
+	}
+
+	if err := verifyUserMpin(e, pr.query, pr.passwordUtils, req.UserID, req.MPIN); err != nil {
+		return nil, err
+	}
+
+	maxLimit := payoutAmountLimit(req.UserID)
+	references := make(map[string]bool)
+	for i := range req.Rows {
+		row := &req.Rows[i]
+		row.RowNumber = i + 1
+		row.RowStatus, row.Message = "", ""
+		if msg := validatePayoutBatchRow(row, maxLimit); msg != "" {
+			row.RowStatus, row.Message = pkg.PayoutRowRejected, msg
+			continue
+		}
+		if row.Reference != "" {
+			if references[row.Reference] {
+				row.RowStatus, row.Message = pkg.PayoutRowRejected, "duplicate reference"
+				continue
+			}
+			references[row.Reference] = true
+		}
+	}
+
+	batch := &structures.PayoutBatch{
+		UserID:       req.UserID,
+		MobileNumber: req.MobileNumber,
+		TotalRows:    len(req.Rows),
+		Rows:         req.Rows,
+	}
+	err := pr.query.CreatePayoutBatch(e.Request().Context(), batch)
+	if errors.Is(err, queries.ErrPayoutBatchEmpty) {
+		return nil, echo.NewHTTPError(400, "No row in the batch can be paid")
+	}
+	if errors.Is(err, queries.ErrInsufficientBalance) {
+		return nil, echo.NewHTTPError(400, "Insufficient balance for the batch total")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB create payout batch error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create payout batch")
+	}
+
+	// The reserve is already taken, so the batch has to run to the end even
+	// after the client has gone
+	go pr.processPayoutBatch(context.WithoutCancel(e.Request().Context()), batch)
+
+	return batch, nil
+}
+
+// readPayoutBatchFile reads a bulk payout from a multipart form
+func (pr *payoutRepo) readPayoutBatchFile(e echo.Context, req *structures.PayoutBatchRequest) error {
+	req.UserID = e.FormValue("user_id")
+	req.MobileNumber = e.FormValue("mobile_number")
+	req.MPIN = e.FormValue("mpin")
+	if err := e.Validate(req); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+
+	header, err := e.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(400, "Batch file is required")
+	}
+	if header.Size > maxPayoutBatchFileSize {
+		return echo.NewHTTPError(400, "Batch file is too large")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return echo.NewHTTPError(400, "Invalid batch file")
+	}
+	defer file.Close()
+
+	req.Rows, err = parsePayoutBatchRows(file)
+	return err
+}
+
+// parsePayoutBatchRows reads the rows of a bulk payout file. The file's first
+// line names its columns, reference being the only optional one.
+func parsePayoutBatchRows(file io.Reader) ([]structures.PayoutBatchRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "Batch file has no header")
+	}
+	index := make(map[string]int)
+	for i, name := range columns {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"account_number", "ifsc_code", "beneficiary_name", "amount", "transfer_type"} {
+		if _, ok := index[name]; !ok {
+			return nil, echo.NewHTTPError(400, fmt.Sprintf("Batch file is missing the %s column", name))
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var rows []structures.PayoutBatchRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, echo.NewHTTPError(400, fmt.Sprintf("Invalid batch file: %v", err))
+		}
+		if len(rows) == pkg.MaxPayoutBatchRows {
+			return nil, echo.NewHTTPError(400, fmt.Sprintf("Batch can have at most %d rows", pkg.MaxPayoutBatchRows))
+		}
+		rows = append(rows, structures.PayoutBatchRow{
+			AccountNumber:   field(record, "account_number"),
+			IFSCCode:        field(record, "ifsc_code"),
+			BeneficiaryName: field(record, "beneficiary_name"),
+			Amount:          field(record, "amount"),
+			TransferType:    field(record, "transfer_type"),
+			Reference:       field(record, "reference"),
+		})
+	}
+	return rows, nil
+}
+
+// validatePayoutBatchRow tidies a row up and says what is wrong with it, if
+// anything. Rows are held to the same limits as single payouts.
+func validatePayoutBatchRow(row *structures.PayoutBatchRow, maxLimit float64) string {
+	row.AccountNumber = strings.TrimSpace(row.AccountNumber)
+	row.IFSCCode = strings.ToUpper(strings.TrimSpace(row.IFSCCode))
+	row.BeneficiaryName = strings.TrimSpace(row.BeneficiaryName)
+	row.Amount = strings.TrimSpace(row.Amount)
+	row.TransferType = strings.ToUpper(strings.TrimSpace(row.TransferType))
+	row.Reference = strings.TrimSpace(row.Reference)
+
+	if !accountNumberPattern.MatchString(row.AccountNumber) {
+		return "invalid account number"
+	}
+	if !ifscPattern.MatchString(row.IFSCCode) {
+		return "invalid IFSC code"
+	}
+	if row.BeneficiaryName == "" {
+		return "beneficiary name is required"
+	}
+	amt, err := strconv.ParseFloat(row.Amount, 64)
+	if err != nil {
+		return "invalid amount"
+	}
+	if amt < 1000 || amt > maxLimit {
+		return fmt.Sprintf("amount must be between 1000 and %.0f", maxLimit)
+	}
+	if row.TransferType != "IMPS" && row.TransferType != "NEFT" {
+		return "transfer type must be IMPS or NEFT"
+	}
+	if len(row.Reference) > 64 {
+		return "reference is longer than 64 characters"
+	}
+	return ""
+}
+
+// processPayoutBatch pays out the queued rows of a batch, a few at a time,
+// and then hands back what is left of the reserve
+func (pr *payoutRepo) processPayoutBatch(ctx context.Context, batch *structures.PayoutBatch) {
+	rows, err := pr.query.GetQueuedPayoutBatchRows(ctx, batch.BatchID)
+	if err != nil {
+		// Housekeeping releases the reserve of a batch that never finishes
+		slog.ErrorContext(ctx, "DB get queued payout batch rows error", "batch_id", batch.BatchID, "error", err)
+		return
+	}
+
+	sem := make(chan struct{}, pkg.PayoutBatchConcurrency())
+	var wg sync.WaitGroup
+	for _, row := range rows {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pr.processPayoutBatchRow(ctx, batch, &row)
+		}()
+	}
+	wg.Wait()
+
+	if err := pr.query.CompletePayoutBatch(ctx, batch.BatchID); err != nil {
+		slog.ErrorContext(ctx, "DB complete payout batch error", "batch_id", batch.BatchID, "error", err)
+	}
+}
+
+func (pr *payoutRepo) processPayoutBatchRow(ctx context.Context, batch *structures.PayoutBatch, row *structures.PayoutBatchRow) {
+	record := func(status string, message string) {
+		row.RowStatus, row.Message = status, message
+		if err := pr.query.UpdatePayoutBatchRow(ctx, batch.BatchID, row); err != nil {
+			slog.ErrorContext(ctx, "DB update payout batch row error", "batch_id", batch.BatchID, "row", row.RowNumber, "error", err)
+		}
+	}
+
+	apiReqBody, err := pr.query.InitilizePayoutRequest(ctx, &structures.PayoutInitilizationRequest{
+		UserID:          batch.UserID,
+		MobileNumber:    batch.MobileNumber,
+		AccountNumber:   row.AccountNumber,
+		IFSCCode:        row.IFSCCode,
+		BankName:        row.BankName,
+		BeneficiaryName: row.BeneficiaryName,
+		Amount:          row.Amount,
+		TransferType:    row.TransferType,
+		Remarks:         row.Reference,
+		BatchID:         batch.BatchID,
+	})
+	if errors.Is(err, queries.ErrInsufficientBalance) {
+		record(pkg.PayoutRowFailed, "batch reserve does not cover this row")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "DB initialize payout request error", "batch_id", batch.BatchID, "row", row.RowNumber, "error", err)
+		record(pkg.PayoutRowFailed, "failed to initialize payout")
+		return
+	}
+	row.PayoutTransactionID = apiReqBody.PartnerRequestID
+	record(pkg.PayoutRowPending, "sent to payout provider")
+
+	payoutFinal, err := sendPayout(ctx, apiReqBody)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			record(pkg.PayoutRowPending, fmt.Sprint(httpErr.Message))
+		} else {
+			record(pkg.PayoutRowPending, "unreadable payout provider response")
+		}
+		return
+	}
+	if err := pr.query.FinalPayout(ctx, payoutFinal); err != nil {
+		slog.ErrorContext(ctx, "DB final payout error", "batch_id", batch.BatchID, "row", row.RowNumber, "error", err)
+		return
+	}
+
+	switch payoutFinal.Status {
+	case 1:
+		record(pkg.PayoutRowSuccess, "")
+	case 3:
+		record(pkg.PayoutRowFailed, "payout failed at provider")
+	default:
+		record(pkg.PayoutRowPending, "awaiting payout provider")
+	}
+}
+
+func (pr *payoutRepo) GetPayoutBatches(e echo.Context) ([]structures.PayoutBatch, error) {
+	res, err := pr.query.GetPayoutBatches(e.Request().Context(), e.Param("user_id"))
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get payout batches error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch payout batches")
+	}
+	if res == nil {
+		res = []structures.PayoutBatch{}
+	}
+	return res, nil
+}
+
+func (pr *payoutRepo) GetPayoutBatch(e echo.Context) (*structures.PayoutBatch, error) {
+	res, err := pr.query.GetPayoutBatch(e.Request().Context(), e.Param("user_id"), e.Param("batch_id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Payout batch not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get payout batch error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch payout batch")
+	}
+	return res, nil
+}
+
+// GetPayoutBatchResult renders a batch as a CSV file with one line per
+// uploaded row and returns it with its file name
+func (pr *payoutRepo) GetPayoutBatchResult(e echo.Context) ([]byte, string, error) {
+	batch, err := pr.GetPayoutBatch(e)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(pkg.PayoutBatchResultHeader); err != nil {
+		return nil, "", err
+	}
+	for _, r := range batch.Rows {
+		if err := w.Write([]string{
+			strconv.Itoa(r.RowNumber), r.AccountNumber, r.IFSCCode, r.BeneficiaryName, r.Amount,
+			r.TransferType, r.Reference, r.RowStatus, r.PayoutTransactionID, r.Message,
+		}); err != nil {
+			return nil, "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), fmt.Sprintf("payout-batch-%s.csv", batch.BatchID), nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/labstack/echo/v4"
+)
+
+func TestParsePayoutBatchRows(t *testing.T) {
+	file := "Amount, IFSC_Code,account_number,beneficiary_name,transfer_type,reference\n" +
+		"1500,HDFC0001234,123456789012,Asha Rao,imps,INV-1\n" +
+		`2000.50,SBIN0004321,987654321,"Rao, Vikram",NEFT` + "\n"
+
+	rows, err := parsePayoutBatchRows(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []structures.PayoutBatchRow{
+		{AccountNumber: "123456789012", IFSCCode: "HDFC0001234", BeneficiaryName: "Asha Rao",
+			Amount: "1500", TransferType: "imps", Reference: "INV-1"},
+		{AccountNumber: "987654321", IFSCCode: "SBIN0004321", BeneficiaryName: "Rao, Vikram",
+			Amount: "2000.50", TransferType: "NEFT"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("parsed %d rows, want %d", len(rows), len(want))
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i+1, rows[i], want[i])
+		}
+	}
+}
+
+func TestParsePayoutBatchRowsRejectsBadFiles(t *testing.T) {
+	header := "account_number,ifsc_code,beneficiary_name,amount,transfer_type\n"
+	tooMany := header + strings.Repeat("123456789012,HDFC0001234,Asha Rao,1500,IMPS\n", pkg.MaxPayoutBatchRows+1)
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"empty", "", "Batch file has no header"},
+		{"missing column", "account_number,ifsc_code,beneficiary_name,amount\n", "Batch file is missing the transfer_type column"},
+		{"unterminated quote", header + `123456789012,HDFC0001234,"Asha Rao,1500,IMPS` + "\n", "Invalid batch file"},
+		{"too many rows", tooMany, fmt.Sprintf("Batch can have at most %d rows", pkg.MaxPayoutBatchRows)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePayoutBatchRows(strings.NewReader(tt.file))
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Code != 400 {
+				t.Fatalf("err = %v, want a 400", err)
+			}
+			if msg := fmt.Sprint(httpErr.Message); !strings.HasPrefix(msg, tt.want) {
+				t.Errorf("message = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePayoutBatchRow(t *testing.T) {
+	valid := func() structures.PayoutBatchRow {
+		return structures.PayoutBatchRow{AccountNumber: " 123456789012 ", IFSCCode: "hdfc0001234",
+			BeneficiaryName: " Asha Rao ", Amount: "1500", TransferType: " imps "}
+	}
+	row := valid()
+	if msg := validatePayoutBatchRow(&row, 25000); msg != "" {
+		t.Fatalf("valid row rejected: %s", msg)
+	}
+	if row.AccountNumber != "123456789012" || row.IFSCCode != "HDFC0001234" ||
+		row.BeneficiaryName != "Asha Rao" || row.TransferType != "IMPS" {
+		t.Errorf("row not tidied: %+v", row)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*structures.PayoutBatchRow)
+		want   string
+	}{
+		{"short account", func(r *structures.PayoutBatchRow) { r.AccountNumber = "12345" }, "invalid account number"},
+		{"bad IFSC", func(r *structures.PayoutBatchRow) { r.IFSCCode = "HDFC1001234" }, "invalid IFSC code"},
+		{"no beneficiary", func(r *structures.PayoutBatchRow) { r.BeneficiaryName = " " }, "beneficiary name is required"},
+		{"amount not a number", func(r *structures.PayoutBatchRow) { r.Amount = "1,500" }, "invalid amount"},
+		{"amount too small", func(r *structures.PayoutBatchRow) { r.Amount = "999.99" }, "amount must be between 1000 and 25000"},
+		{"amount too large", func(r *structures.PayoutBatchRow) { r.Amount = "25000.01" }, "amount must be between 1000 and 25000"},
+		{"bad transfer type", func(r *structures.PayoutBatchRow) { r.TransferType = "RTGS" }, "transfer type must be IMPS or NEFT"},
+		{"long reference", func(r *structures.PayoutBatchRow) { r.Reference = strings.Repeat("x", 65) }, "reference is longer than 64 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := valid()
+			tt.change(&row)
+			if msg := validatePayoutBatchRow(&row, 25000); msg != tt.want {
+				t.Errorf("message = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+}
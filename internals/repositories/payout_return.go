@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// payoutReturnLimit bounds the returns listed at once
+const payoutReturnLimit = 200
+
+type payoutReturnRepo struct {
+	query     *queries.Query
+	totpUtils *pkg.TOTPUtils
+}
+
+func NewPayoutReturnRepository(query *queries.Query, totpUtils *pkg.TOTPUtils) *payoutReturnRepo {
+	return &payoutReturnRepo{
+		query:     query,
+		totpUtils: totpUtils,
+	}
+}
+
+// Helper for binding + validation
+func (prr *payoutReturnRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// RecordPayoutReturn books a successful payout coming back from the bank or
+// provider under its return reference, refunding the retailer and settling
+// the commission by the request's policy or the configured one
+func (prr *payoutReturnRepo) RecordPayoutReturn(e echo.Context) (*structures.PayoutReturn, error) {
+	var req structures.PayoutReturnRequest
+	if err := prr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	if err := verifyStepUp(e, prr.query, prr.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return nil, err
+	}
+	var actorID string
+	req.AdminID, actorID = actingAdmin(e, req.AdminID)
+	if req.AdminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	if req.CommissionPolicy == "" {
+		req.CommissionPolicy = pkg.PayoutReturnCommissionPolicy()
+	}
+
+	audit := newAuditEntry(e, "PAYOUT_RETURN", "PAYOUT", req.PayoutTransactionID, actorID, "ADMIN")
+	res, err := prr.query.RecordPayoutReturn(e.Request().Context(), &req, actorID, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Payout not found")
+	}
+	if errors.Is(err, queries.ErrPayoutNotReturnable) {
+		return nil, echo.NewHTTPError(409, "Only successful payouts can be returned")
+	}
+	if errors.Is(err, queries.ErrPayoutAlreadyReturned) {
+		return nil, echo.NewHTTPError(409, "Payout was already returned")
+	}
+	if errors.Is(err, queries.ErrReturnReferenceUsed) {
+		return nil, echo.NewHTTPError(409, "Return reference was already recorded")
+	}
+	if errors.Is(err, queries.ErrPeriodClosed) {
+		return nil, echo.NewHTTPError(409, "Payout belongs to a closed period")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB record payout return error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to record payout return")
+	}
+	return res, nil
+}
+
+func (prr *payoutReturnRepo) GetPayoutReturns(e echo.Context) (*[]structures.PayoutReturn, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	res, err := prr.query.GetPayoutReturns(e.Request().Context(), adminID, payoutReturnLimit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get payout returns error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch payout returns")
+	}
+	return res, nil
+}
+
+// GetBankReturnRates reports the return rate of each bank over the payouts
+// made between the from and to query parameters, the last 30 days when they
+// are left out
+func (prr *payoutReturnRepo) GetBankReturnRates(e echo.Context) (*[]structures.BankReturnRate, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	var err error
+	to := pkg.StartOfDay(time.Now())
+	if value := e.QueryParam("to"); value != "" {
+		if to, err = pkg.ParseLedgerDate(value); err != nil {
+			return nil, echo.NewHTTPError(400, err.Error())
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if value := e.QueryParam("from"); value != "" {
+		if from, err = pkg.ParseLedgerDate(value); err != nil {
+			return nil, echo.NewHTTPError(400, err.Error())
+		}
+	}
+	if from.After(to) {
+		return nil, echo.NewHTTPError(400, "from must not be after to")
+	}
+
+	res, err := prr.query.GetBankReturnRates(e.Request().Context(), adminID, from, to)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get bank return rates error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch return rates")
+	}
+	return res, nil
+}
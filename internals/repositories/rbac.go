@@ -0,0 +1,266 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type rbacRepo struct {
+	query         *queries.Query
+	passwordUtils *pkg.PasswordUtils
+}
+
+func NewRBACRepository(query *queries.Query, passwordUtils *pkg.PasswordUtils) *rbacRepo {
+	return &rbacRepo{
+		query:         query,
+		passwordUtils: passwordUtils,
+	}
+}
+
+// Helper for binding + validation
+func (rr *rbacRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// actingAdmin returns the admin tenant a request works on, taken from the
+// bearer token when there is one, and who is acting: the admin or one of its
+// staff users.
+func actingAdmin(e echo.Context, adminID string) (string, string) {
+	if actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor); ok && actor.Role == "ADMIN" {
+		if actor.StaffID != "" {
+			return actor.ID, actor.StaffID
+		}
+		return actor.ID, actor.ID
+	}
+	return adminID, adminID
+}
+
+// ensureGrantable stops a caller from handing out permissions it does not
+// hold itself
+func (rr *rbacRepo) ensureGrantable(e echo.Context, adminID string, roleName string) error {
+	staffID := ""
+	if actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor); ok && actor.Role == "ADMIN" {
+		staffID = actor.StaffID
+	}
+	held, err := rr.query.GetActorPermissions(e.Request().Context(), adminID, staffID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get actor permissions error", "error", err)
+		return echo.NewHTTPError(500, "Failed to check permissions")
+	}
+	granted, err := rr.query.GetRolePermissions(e.Request().Context(), adminID, roleName)
+	if errors.Is(err, queries.ErrRoleNotFound) {
+		return echo.NewHTTPError(404, "Role not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get role permissions error", "error", err)
+		return echo.NewHTTPError(500, "Failed to check permissions")
+	}
+	for _, permission := range granted {
+		if !slices.Contains(held, permission) {
+			return echo.NewHTTPError(403, "Cannot grant the permission "+permission+" you do not hold")
+		}
+	}
+	return nil
+}
+
+func (rr *rbacRepo) GetPermissions(e echo.Context) (*[]structures.Permission, error) {
+	res := make([]structures.Permission, 0, len(pkg.PermissionDescriptions))
+	for permission, description := range pkg.PermissionDescriptions {
+		res = append(res, structures.Permission{Permission: permission, Description: description})
+	}
+	slices.SortFunc(res, func(a, b structures.Permission) int {
+		if a.Permission < b.Permission {
+			return -1
+		}
+		if a.Permission > b.Permission {
+			return 1
+		}
+		return 0
+	})
+	return &res, nil
+}
+
+// GetRoles lists the built-in roles and the custom roles of the admin
+func (rr *rbacRepo) GetRoles(e echo.Context) (*[]structures.Role, error) {
+	adminID, _ := actingAdmin(e, e.QueryParam("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	res, err := rr.query.GetRoles(e.Request().Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get roles error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch roles")
+	}
+	if res == nil {
+		empty := []structures.Role{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+// SaveRole creates a custom role of the admin or replaces its permissions.
+// The built-in admin roles cannot be changed.
+func (rr *rbacRepo) SaveRole(e echo.Context) error {
+	var req structures.RoleRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	if !pkg.IsValidRoleName(req.RoleName) {
+		return echo.NewHTTPError(400, "role_name must be upper case letters, digits and underscores")
+	}
+	for _, permission := range req.Permissions {
+		if !pkg.IsValidPermission(permission) {
+			return echo.NewHTTPError(400, "Unknown permission "+permission)
+		}
+	}
+	slices.Sort(req.Permissions)
+	req.Permissions = slices.Compact(req.Permissions)
+
+	adminID, actorID := actingAdmin(e, req.AdminID)
+	req.AdminID = adminID
+	audit := newAuditEntry(e, "ROLE_UPDATE", "ROLE", req.RoleName, actorID, "ADMIN")
+	err := rr.query.SaveRole(e.Request().Context(), &req, audit)
+	if errors.Is(err, queries.ErrSystemRole) {
+		return echo.NewHTTPError(409, "Built-in roles cannot be changed")
+	}
+	if errors.Is(err, queries.ErrRoleNameTaken) {
+		return echo.NewHTTPError(409, "Role name is already taken, choose another")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB save role error", "error", err, "admin_id", adminID)
+		return echo.NewHTTPError(500, "Failed to save role")
+	}
+	return nil
+}
+
+func (rr *rbacRepo) DeleteRole(e echo.Context) error {
+	var req structures.RoleDeleteRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	adminID, actorID := actingAdmin(e, req.AdminID)
+	audit := newAuditEntry(e, "ROLE_DELETE", "ROLE", req.RoleName, actorID, "ADMIN")
+	err := rr.query.DeleteRole(e.Request().Context(), adminID, req.RoleName, audit)
+	if errors.Is(err, queries.ErrRoleNotFound) {
+		return echo.NewHTTPError(404, "Role not found")
+	}
+	if errors.Is(err, queries.ErrSystemRole) {
+		return echo.NewHTTPError(409, "Built-in roles cannot be deleted")
+	}
+	if errors.Is(err, queries.ErrRoleInUse) {
+		return echo.NewHTTPError(409, "Role is still assigned to staff users")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB delete role error", "error", err)
+		return echo.NewHTTPError(500, "Failed to delete role")
+	}
+	return nil
+}
+
+// CreateStaffUser adds a staff user to the admin tenant with a role holding
+// no more than the caller's own permissions
+func (rr *rbacRepo) CreateStaffUser(e echo.Context) (*structures.StaffUser, error) {
+	var req structures.StaffCreateRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	adminID, actorID := actingAdmin(e, req.AdminID)
+	req.AdminID = adminID
+	if err := rr.ensureGrantable(e, adminID, req.RoleName); err != nil {
+		return nil, err
+	}
+	hash, err := rr.passwordUtils.HashPassword(req.StaffPassword)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "Password hashing failed", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to secure password")
+	}
+	req.StaffPassword = hash
+
+	audit := newAuditEntry(e, "STAFF_CREATE", "STAFF", "", actorID, "ADMIN")
+	res, err := rr.query.CreateStaffUser(e.Request().Context(), &req, actorID, audit)
+	if errors.Is(err, queries.ErrRoleNotFound) {
+		return nil, echo.NewHTTPError(404, "Role not found")
+	}
+	if errors.Is(err, queries.ErrStaffEmailTaken) {
+		return nil, echo.NewHTTPError(409, "A staff user with this email already exists")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB create staff user error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create staff user")
+	}
+	return res, nil
+}
+
+func (rr *rbacRepo) GetStaffUsers(e echo.Context) (*[]structures.StaffUser, error) {
+	adminID, _ := actingAdmin(e, e.Param("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	res, err := rr.query.GetStaffUsers(e.Request().Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get staff users error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch staff users")
+	}
+	if res == nil {
+		empty := []structures.StaffUser{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+func (rr *rbacRepo) updateStaffUser(e echo.Context, adminID string, staffID string, roleName string, blocked *bool, action string) error {
+	adminID, actorID := actingAdmin(e, adminID)
+	if actorID == staffID {
+		return echo.NewHTTPError(403, "Staff users cannot change their own access")
+	}
+	audit := newAuditEntry(e, action, "STAFF", staffID, actorID, "ADMIN")
+	err := rr.query.UpdateStaffUser(e.Request().Context(), adminID, staffID, roleName, blocked, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Staff user not found")
+	}
+	if errors.Is(err, queries.ErrRoleNotFound) {
+		return echo.NewHTTPError(404, "Role not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB update staff user error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update staff user")
+	}
+	return nil
+}
+
+func (rr *rbacRepo) AssignStaffRole(e echo.Context) error {
+	var req structures.StaffRoleRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	adminID, _ := actingAdmin(e, req.AdminID)
+	if err := rr.ensureGrantable(e, adminID, req.RoleName); err != nil {
+		return err
+	}
+	return rr.updateStaffUser(e, req.AdminID, req.StaffID, req.RoleName, nil, "STAFF_ROLE_ASSIGN")
+}
+
+func (rr *rbacRepo) SetStaffBlocked(e echo.Context) error {
+	var req structures.StaffBlockRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	action := "STAFF_UNBLOCK"
+	if req.Blocked {
+		action = "STAFF_BLOCK"
+	}
+	return rr.updateStaffUser(e, req.AdminID, req.StaffID, "", &req.Blocked, action)
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// replenishmentRunLimit bounds the top-up history returned for a retailer
+const replenishmentRunLimit = 100
+
+type replenishmentRepo struct {
+	query *queries.Query
+}
+
+func NewReplenishmentRepository(query *queries.Query) *replenishmentRepo {
+	return &replenishmentRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (rr *replenishmentRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// SaveReplenishmentRule sets how a retailer under the caller is topped up
+// after payouts
+func (rr *replenishmentRepo) SaveReplenishmentRule(e echo.Context, role string) (*structures.ReplenishmentRule, error) {
+	var req structures.ReplenishmentRuleRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	funderID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return nil, err
+	}
+	threshold, _ := strconv.ParseFloat(req.ThresholdAmount, 64)
+	target, _ := strconv.ParseFloat(req.TargetAmount, 64)
+	dailyCap, _ := strconv.ParseFloat(req.DailyCap, 64)
+	if threshold < 0 || target <= threshold {
+		return nil, echo.NewHTTPError(400, "target_amount must be above threshold_amount")
+	}
+	if dailyCap <= 0 {
+		return nil, echo.NewHTTPError(400, "daily_cap must be positive")
+	}
+
+	audit := newAuditEntry(e, "REPLENISHMENT_RULE_UPDATE", "REPLENISHMENT_RULE", "", funderID, role)
+	res, err := rr.query.SaveReplenishmentRule(e.Request().Context(), funderID, role, &req, audit)
+	if errors.Is(err, queries.ErrNotInDownline) {
+		return nil, echo.NewHTTPError(403, "Retailer is not in your downline")
+	}
+	if errors.Is(err, queries.ErrRuleFundedElsewhere) {
+		return nil, echo.NewHTTPError(409, "Retailer is already replenished by another funder")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB save replenishment rule error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to save replenishment rule")
+	}
+	return res, nil
+}
+
+func (rr *replenishmentRepo) GetReplenishmentRules(e echo.Context, role string) (*[]structures.ReplenishmentRule, error) {
+	funderID, err := actingOwner(e, role, e.Param("master_distributor_id"), e.Param("distributor_id"))
+	if err != nil {
+		return nil, err
+	}
+	res, err := rr.query.GetReplenishmentRules(e.Request().Context(), funderID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get replenishment rules error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch replenishment rules")
+	}
+	if res == nil {
+		empty := []structures.ReplenishmentRule{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+func (rr *replenishmentRepo) DeleteReplenishmentRule(e echo.Context, role string) error {
+	var req structures.ReplenishmentRuleDeleteRequest
+	if err := rr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	funderID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "REPLENISHMENT_RULE_DELETE", "REPLENISHMENT_RULE", "", funderID, role)
+	err = rr.query.DeleteReplenishmentRule(e.Request().Context(), funderID, req.UserID, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Replenishment rule not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB delete replenishment rule error", "error", err)
+		return echo.NewHTTPError(500, "Failed to delete replenishment rule")
+	}
+	return nil
+}
+
+func (rr *replenishmentRepo) GetReplenishmentRuns(e echo.Context, role string) (*[]structures.ReplenishmentRun, error) {
+	funderID, err := actingOwner(e, role, e.Param("master_distributor_id"), e.Param("distributor_id"))
+	if err != nil {
+		return nil, err
+	}
+	userID := e.Param("user_id")
+	if userID == "" {
+		return nil, echo.NewHTTPError(400, "user_id is required")
+	}
+	res, err := rr.query.GetReplenishmentRuns(e.Request().Context(), funderID, userID, replenishmentRunLimit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get replenishment runs error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch replenishment runs")
+	}
+	if res == nil {
+		empty := []structures.ReplenishmentRun{}
+		return &empty, nil
+	}
+	return res, nil
+}
@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// scheduledTransferRunLimit bounds the run history returned for a schedule
+const scheduledTransferRunLimit = 100
+
+type scheduledTransferRepo struct {
+	query *queries.Query
+}
+
+func NewScheduledTransferRepository(query *queries.Query) *scheduledTransferRepo {
+	return &scheduledTransferRepo{
+		query: query,
+	}
+}
+
+// Helper for binding + validation
+func (sr *scheduledTransferRepo) bindAndValidate(e echo.Context, v interface{}) error {
+	if err := e.Bind(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request format")
+	}
+	if err := e.Validate(v); err != nil {
+		return echo.NewHTTPError(400, "Invalid request data")
+	}
+	return nil
+}
+
+// actingOwner returns the master distributor or distributor of the role
+// signed in for the request. An id sent along must be the caller's own.
+func actingOwner(e echo.Context, role string, masterDistributorID string, distributorID string) (string, error) {
+	actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor)
+	if !ok || actor.Role != role {
+		return "", echo.NewHTTPError(401, "Session required")
+	}
+	ownerID := distributorID
+	if role == "MASTER_DISTRIBUTOR" {
+		ownerID = masterDistributorID
+	}
+	if ownerID != "" && ownerID != actor.ID {
+		return "", echo.NewHTTPError(403, "Access to another account's data is not allowed")
+	}
+	return actor.ID, nil
+}
+
+// validateSchedule checks what the validator tags cannot express and returns
+// the first run of the schedule
+func validateSchedule(role string, req *structures.ScheduledTransferRequest) (time.Time, error) {
+	if role == "DISTRIBUTOR" && req.RecipientType != "USER" {
+		return time.Time{}, echo.NewHTTPError(400, "Distributors can only schedule transfers to retailers")
+	}
+	weekday := 0
+	if req.ScheduleType == pkg.ScheduleTopUp {
+		req.Amount, req.RunTime, req.Weekday = "", "", nil
+		threshold, _ := strconv.ParseFloat(req.ThresholdAmount, 64)
+		target, _ := strconv.ParseFloat(req.TargetAmount, 64)
+		if threshold < 0 || target <= threshold {
+			return time.Time{}, echo.NewHTTPError(400, "target_amount must be above threshold_amount")
+		}
+	} else {
+		req.ThresholdAmount, req.TargetAmount = "", ""
+		if req.ScheduleType == pkg.ScheduleDaily {
+			req.Weekday = nil
+		} else {
+			weekday = *req.Weekday
+		}
+		if amount, _ := strconv.ParseFloat(req.Amount, 64); amount <= 0 {
+			return time.Time{}, echo.NewHTTPError(400, "amount must be positive")
+		}
+		if _, _, err := pkg.ParseRunTime(req.RunTime); err != nil {
+			return time.Time{}, echo.NewHTTPError(400, err.Error())
+		}
+	}
+	nextRunAt, err := pkg.NextScheduledRun(req.ScheduleType, req.RunTime, weekday, time.Now())
+	if err != nil {
+		return time.Time{}, echo.NewHTTPError(400, err.Error())
+	}
+	return nextRunAt, nil
+}
+
+// CreateScheduledTransfer sets up a daily, weekly or top-up transfer from a
+// master distributor or distributor to a member of their downline
+func (sr *scheduledTransferRepo) CreateScheduledTransfer(e echo.Context, role string) (*structures.ScheduledTransfer, error) {
+	var req structures.ScheduledTransferRequest
+	if err := sr.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	ownerID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return nil, err
+	}
+	nextRunAt, err := validateSchedule(role, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	audit := newAuditEntry(e, "SCHEDULED_TRANSFER_CREATE", "SCHEDULED_TRANSFER", "", ownerID, role)
+	res, err := sr.query.CreateScheduledTransfer(e.Request().Context(), &structures.ScheduledTransferCreate{
+		OwnerID:   ownerID,
+		OwnerType: role,
+		Request:   &req,
+		NextRunAt: nextRunAt,
+	}, audit)
+	if errors.Is(err, queries.ErrNotInDownline) {
+		return nil, echo.NewHTTPError(403, "Recipient is not in your downline")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB create scheduled transfer error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to create scheduled transfer")
+	}
+	return res, nil
+}
+
+func (sr *scheduledTransferRepo) GetScheduledTransfers(e echo.Context, role string) (*[]structures.ScheduledTransfer, error) {
+	ownerID, err := actingOwner(e, role, e.Param("master_distributor_id"), e.Param("distributor_id"))
+	if err != nil {
+		return nil, err
+	}
+	res, err := sr.query.GetScheduledTransfers(e.Request().Context(), ownerID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get scheduled transfers error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch scheduled transfers")
+	}
+	if res == nil {
+		empty := []structures.ScheduledTransfer{}
+		return &empty, nil
+	}
+	return res, nil
+}
+
+// SetScheduledTransferStatus pauses or resumes a schedule
+func (sr *scheduledTransferRepo) SetScheduledTransferStatus(e echo.Context, role string) error {
+	var req structures.ScheduledTransferStatusRequest
+	if err := sr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	ownerID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return err
+	}
+	action := "SCHEDULED_TRANSFER_PAUSE"
+	if req.Active {
+		action = "SCHEDULED_TRANSFER_RESUME"
+	}
+	audit := newAuditEntry(e, action, "SCHEDULED_TRANSFER", req.ScheduleID, ownerID, role)
+	err = sr.query.SetScheduledTransferActive(e.Request().Context(), ownerID, req.ScheduleID, req.Active, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Scheduled transfer not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB update scheduled transfer error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update scheduled transfer")
+	}
+	return nil
+}
+
+func (sr *scheduledTransferRepo) DeleteScheduledTransfer(e echo.Context, role string) error {
+	var req structures.ScheduledTransferDeleteRequest
+	if err := sr.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	ownerID, err := actingOwner(e, role, req.MasterDistributorID, req.DistributorID)
+	if err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "SCHEDULED_TRANSFER_DELETE", "SCHEDULED_TRANSFER", req.ScheduleID, ownerID, role)
+	err = sr.query.DeleteScheduledTransfer(e.Request().Context(), ownerID, req.ScheduleID, audit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(404, "Scheduled transfer not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB delete scheduled transfer error", "error", err)
+		return echo.NewHTTPError(500, "Failed to delete scheduled transfer")
+	}
+	return nil
+}
+
+func (sr *scheduledTransferRepo) GetScheduledTransferRuns(e echo.Context, role string) (*[]structures.ScheduledTransferRun, error) {
+	ownerID, err := actingOwner(e, role, e.Param("master_distributor_id"), e.Param("distributor_id"))
+	if err != nil {
+		return nil, err
+	}
+	scheduleID := e.Param("schedule_id")
+	if scheduleID == "" {
+		return nil, echo.NewHTTPError(400, "schedule_id is required")
+	}
+	res, err := sr.query.GetScheduledTransferRuns(e.Request().Context(), ownerID, scheduleID, scheduledTransferRunLimit)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get scheduled transfer runs error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch scheduled transfer runs")
+	}
+	if res == nil {
+		empty := []structures.ScheduledTransferRun{}
+		return &empty, nil
+	}
+	return res, nil
+}
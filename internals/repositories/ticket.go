@@ -2,7 +2,7 @@ package repositories
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
@@ -24,8 +24,8 @@ func (tr *ticketRepo) AddNewTicket(e echo.Context) error {
 	if err := e.Bind(&req); err != nil {
 		return fmt.Errorf("failed to create new ticket")
 	}
-	if err := tr.query.AddNewTicket(req); err != nil {
-		log.Println(err)
+	if err := tr.query.AddNewTicket(e.Request().Context(), req); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB add ticket error", "error", err)
 		return fmt.Errorf("failed to create new ticket")
 	}
 	return nil
@@ -36,7 +36,7 @@ func (tr *ticketRepo) GetAllTickets(e echo.Context) (*[]structures.Ticket, error
 	if adminId == "" {
 		return nil, fmt.Errorf("admin id not found")
 	}
-	res, err := tr.query.GetAllTickets(adminId)
+	res, err := tr.query.GetAllTickets(e.Request().Context(), adminId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch all tickets")
 	}
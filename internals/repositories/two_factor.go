@@ -0,0 +1,441 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Srujankm12/paybazar-api/internals/models/queries"
+	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// totpCheck decrypts the stored secret and compares code with it
+func totpCheck(totpUtils *pkg.TOTPUtils, code string) queries.TOTPCheck {
+	return func(encryptedSecret string, lastStep int64) (int64, bool, error) {
+		secret, err := totpUtils.DecryptSecret(encryptedSecret)
+		if err != nil {
+			return 0, false, err
+		}
+		step, valid := totpUtils.VerifyCode(secret, code, time.Now(), lastStep)
+		return step, valid, nil
+	}
+}
+
+// twoFactorRequired reports whether the policy makes two-factor
+// authentication mandatory now, that is enforced with no grace period left
+func twoFactorRequired(policy *structures.TwoFactorPolicy) bool {
+	return policy.Enforced && (policy.GraceUntil == nil || !time.Now().Before(*policy.GraceUntil))
+}
+
+// verifyTwoFactorCode checks a TOTP or recovery code against the attempt
+// limits and turns every outcome other than success into an HTTP error.
+func verifyTwoFactorCode(e echo.Context, query *queries.Query, totpUtils *pkg.TOTPUtils, ownerID string, code string) error {
+	code = strings.TrimSpace(code)
+	res, err := query.VerifyTwoFactor(e.Request().Context(), ownerID, totpCheck(totpUtils, code), totpUtils.HashRecoveryCode(code))
+	if errors.Is(err, queries.ErrTwoFactorNotEnabled) {
+		return echo.NewHTTPError(400, "Two-factor authentication is not enabled")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB verify two-factor error", "error", err)
+		return echo.NewHTTPError(500, "Failed to verify two-factor code")
+	}
+	if res.Locked {
+		wait := time.Until(res.LockedUntil)
+		e.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return echo.NewHTTPError(423, fmt.Sprintf("Too many wrong two-factor codes, try again in %d minutes", int(math.Ceil(wait.Minutes()))))
+	}
+	if !res.Valid {
+		return echo.NewHTTPError(401, "Invalid two-factor code")
+	}
+	return nil
+}
+
+// verifyStepUp confirms a sensitive action with the code in the X-2FA-Code
+// header. The account comes from the bearer token when it is of the role,
+// otherwise from the request. Accounts without two-factor authentication pass
+// unless the role's policy requires it.
+func verifyStepUp(e echo.Context, query *queries.Query, totpUtils *pkg.TOTPUtils, role string, accountID string) error {
+	if actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor); ok && actor.Role == role {
+		accountID = actor.ID
+		if actor.StaffID != "" {
+			accountID = actor.StaffID
+		}
+	}
+	status := &structures.TwoFactorStatus{}
+	if accountID != "" {
+		var err error
+		status, err = query.GetTwoFactorStatus(e.Request().Context(), accountID)
+		if err != nil {
+			slog.ErrorContext(e.Request().Context(), "DB get two-factor status error", "error", err)
+			return echo.NewHTTPError(500, "Failed to verify two-factor code")
+		}
+	}
+	if !status.Enabled {
+		policy, err := query.GetTwoFactorPolicy(e.Request().Context(), accountID, role)
+		if err != nil {
+			slog.ErrorContext(e.Request().Context(), "DB get two-factor policy error", "error", err)
+			return echo.NewHTTPError(500, "Failed to verify two-factor code")
+		}
+		if twoFactorRequired(policy) {
+			return echo.NewHTTPError(403, "Two-factor authentication must be enabled for this action")
+		}
+		return nil
+	}
+	code := e.Request().Header.Get(pkg.TwoFactorCodeHeader)
+	if code == "" {
+		return echo.NewHTTPError(401, "Two-factor code required in the "+pkg.TwoFactorCodeHeader+" header")
+	}
+	return verifyTwoFactorCode(e, query, totpUtils, accountID, code)
+}
+
+// loginWithTwoFactor finishes a password login. Accounts with two-factor
+// authentication, and accounts that must enrol first, get a challenge token
+// instead of a session token. The role's policy applies to accounts of
+// another accountRole signing in as it, like staff users as ADMIN.
+func (ar *authRepository) loginWithTwoFactor(e echo.Context, role string, accountRole string, accountID string, res interface{}, tokenDuration time.Duration) (*structures.LoginResult, error) {
+	status, err := ar.query.GetTwoFactorStatus(e.Request().Context(), accountID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get two-factor status error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to log in")
+	}
+	policy, err := ar.query.GetTwoFactorPolicy(e.Request().Context(), accountID, role)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get two-factor policy error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to log in")
+	}
+	if status.Enabled || twoFactorRequired(policy) {
+		challenge := structures.TwoFactorChallenge{
+			AccountID:    accountID,
+			Role:         role,
+			Login:        res,
+			TokenSeconds: int64(tokenDuration.Seconds()),
+		}
+		if accountRole != role {
+			challenge.AccountRole = accountRole
+		}
+		challengeToken, err := ar.generateTokenFor(challenge, pkg.TwoFactorChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &structures.LoginResult{
+			TwoFactorRequired:           status.Enabled,
+			TwoFactorEnrollmentRequired: !status.Enabled,
+			ChallengeToken:              challengeToken,
+		}, nil
+	}
+	token, err := ar.generateTokenFor(res, tokenDuration)
+	if err != nil {
+		return nil, err
+	}
+	result := &structures.LoginResult{Token: token}
+	if policy.Enforced {
+		result.TwoFactorEnrollmentDue = policy.GraceUntil
+	}
+	return result, nil
+}
+
+// parseChallenge reads a challenge token issued to the role at login
+func (ar *authRepository) parseChallenge(token string, role string) (*structures.TwoFactorChallenge, error) {
+	data, err := ar.jwtUtils.ParseToken(token)
+	if err != nil {
+		return nil, echo.NewHTTPError(401, "Invalid or expired challenge token")
+	}
+	accountID, _ := data["two_factor_account_id"].(string)
+	challengeRole, _ := data["two_factor_role"].(string)
+	if accountID == "" || challengeRole != role {
+		return nil, echo.NewHTTPError(401, "Invalid or expired challenge token")
+	}
+	accountRole, _ := data["two_factor_account_role"].(string)
+	tokenSeconds, _ := data["two_factor_token_seconds"].(float64)
+	return &structures.TwoFactorChallenge{
+		AccountID:    accountID,
+		Role:         challengeRole,
+		AccountRole:  accountRole,
+		Login:        data["two_factor_login"],
+		TokenSeconds: int64(tokenSeconds),
+	}, nil
+}
+
+// completeChallenge issues the session token a met challenge was for
+func (ar *authRepository) completeChallenge(challenge *structures.TwoFactorChallenge) (string, error) {
+	duration := time.Duration(challenge.TokenSeconds) * time.Second
+	if duration <= 0 {
+		duration = time.Hour * 24 * 365
+	}
+	return ar.generateTokenFor(challenge.Login, duration)
+}
+
+// twoFactorAccount resolves the account of a two-factor request, and the
+// role of that account, from its challenge token while signing in, otherwise
+// from the session, which must be the account's own. Staff users of the
+// admin console have accounts of their own.
+func (ar *authRepository) twoFactorAccount(e echo.Context, role string, challengeToken string) (string, string, *structures.TwoFactorChallenge, error) {
+	if challengeToken == "" {
+		actor, ok := e.Get(pkg.ActorContextKey).(*structures.Actor)
+		if !ok || actor.Role != role {
+			return "", "", nil, echo.NewHTTPError(401, "Sign in, or use the challenge_token from the password step")
+		}
+		if actor.StaffID != "" {
+			return actor.StaffID, pkg.StaffRole, nil, nil
+		}
+		return actor.ID, role, nil, nil
+	}
+	challenge, err := ar.parseChallenge(challengeToken, role)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if challenge.AccountRole != "" {
+		return challenge.AccountID, challenge.AccountRole, challenge, nil
+	}
+	return challenge.AccountID, role, challenge, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and their hashes
+func (ar *authRepository) newRecoveryCodes(e echo.Context) ([]string, []string, error) {
+	codes, err := ar.totpUtils.GenerateRecoveryCodes()
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "Recovery code generation failed", "error", err)
+		return nil, nil, echo.NewHTTPError(500, "Failed to generate recovery codes")
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = ar.totpUtils.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge and a TOTP or recovery
+// code for a session token
+func (ar *authRepository) CompleteTwoFactorLogin(e echo.Context, role string) (string, error) {
+	var req structures.TwoFactorLoginRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return "", err
+	}
+	challenge, err := ar.parseChallenge(req.ChallengeToken, role)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyTwoFactorCode(e, ar.query, ar.totpUtils, challenge.AccountID, req.Code); err != nil {
+		return "", err
+	}
+	return ar.completeChallenge(challenge)
+}
+
+// GetTwoFactorStatus returns the two-factor status of the signed in
+// account, which id must name
+func (ar *authRepository) GetTwoFactorStatus(e echo.Context, role string) (*structures.TwoFactorStatus, error) {
+	id, _, _, err := ar.twoFactorAccount(e, role, "")
+	if err != nil {
+		return nil, err
+	}
+	if e.Param("id") != id {
+		return nil, echo.NewHTTPError(403, "Access to another account's two-factor status is not allowed")
+	}
+	res, err := ar.query.GetTwoFactorStatus(e.Request().Context(), id)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get two-factor status error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch two-factor status")
+	}
+	policy, err := ar.query.GetTwoFactorPolicy(e.Request().Context(), id, role)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get two-factor policy error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch two-factor status")
+	}
+	res.Required = twoFactorRequired(policy)
+	return res, nil
+}
+
+// SetupTwoFactor creates a new secret for the account to add to an
+// authenticator app. It only takes effect once confirmed by EnableTwoFactor.
+func (ar *authRepository) SetupTwoFactor(e echo.Context, role string) (*structures.TwoFactorSetupResponse, error) {
+	var req structures.TwoFactorAccountRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	accountID, accountRole, _, err := ar.twoFactorAccount(e, role, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	account, err := ar.query.GetPasswordAccountByID(e.Request().Context(), accountRole, accountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Account not found")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get account error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to set up two-factor authentication")
+	}
+	secret, err := ar.totpUtils.GenerateSecret()
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "TOTP secret generation failed", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to set up two-factor authentication")
+	}
+	encrypted, err := ar.totpUtils.EncryptSecret(secret)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "TOTP secret encryption failed", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to set up two-factor authentication")
+	}
+	err = ar.query.SaveTwoFactorSecret(e.Request().Context(), account.ID, accountRole, encrypted)
+	if errors.Is(err, queries.ErrTwoFactorAlreadyEnabled) {
+		return nil, echo.NewHTTPError(409, "Two-factor authentication is already enabled")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB save two-factor secret error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to set up two-factor authentication")
+	}
+	return &structures.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: ar.totpUtils.ProvisioningURI(account.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor confirms the pending secret with a first code and returns
+// the recovery codes, shown only this once. Enrolling with a login challenge
+// also completes the login.
+func (ar *authRepository) EnableTwoFactor(e echo.Context, role string) (*structures.TwoFactorEnableResponse, error) {
+	var req structures.TwoFactorCodeRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	accountID, accountRole, challenge, err := ar.twoFactorAccount(e, role, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := ar.newRecoveryCodes(e)
+	if err != nil {
+		return nil, err
+	}
+	audit := newAuditEntry(e, "TWO_FACTOR_ENABLE", accountRole, accountID, accountID, accountRole)
+	err = ar.query.EnableTwoFactor(e.Request().Context(), accountID, totpCheck(ar.totpUtils, strings.TrimSpace(req.Code)), hashes, audit)
+	switch {
+	case errors.Is(err, queries.ErrTwoFactorNotSetUp):
+		return nil, echo.NewHTTPError(400, "Set up two-factor authentication first")
+	case errors.Is(err, queries.ErrTwoFactorAlreadyEnabled):
+		return nil, echo.NewHTTPError(409, "Two-factor authentication is already enabled")
+	case errors.Is(err, queries.ErrInvalidTwoFactorCode):
+		return nil, echo.NewHTTPError(401, "Invalid two-factor code")
+	case err != nil:
+		slog.ErrorContext(e.Request().Context(), "DB enable two-factor error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to enable two-factor authentication")
+	}
+	res := &structures.TwoFactorEnableResponse{RecoveryCodes: codes}
+	if challenge != nil {
+		if res.Token, err = ar.completeChallenge(challenge); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off after a valid code,
+// unless the role's policy enforces it
+func (ar *authRepository) DisableTwoFactor(e echo.Context, role string) error {
+	var req structures.TwoFactorCodeRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	accountID, accountRole, _, err := ar.twoFactorAccount(e, role, req.ChallengeToken)
+	if err != nil {
+		return err
+	}
+	policy, err := ar.query.GetTwoFactorPolicy(e.Request().Context(), accountID, role)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get two-factor policy error", "error", err)
+		return echo.NewHTTPError(500, "Failed to disable two-factor authentication")
+	}
+	if policy.Enforced {
+		return echo.NewHTTPError(403, "Two-factor authentication is enforced for this role")
+	}
+	if err := verifyTwoFactorCode(e, ar.query, ar.totpUtils, accountID, req.Code); err != nil {
+		return err
+	}
+	audit := newAuditEntry(e, "TWO_FACTOR_DISABLE", accountRole, accountID, accountID, accountRole)
+	err = ar.query.DisableTwoFactor(e.Request().Context(), accountID, audit)
+	if errors.Is(err, queries.ErrTwoFactorNotEnabled) {
+		return echo.NewHTTPError(400, "Two-factor authentication is not enabled")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB disable two-factor error", "error", err)
+		return echo.NewHTTPError(500, "Failed to disable two-factor authentication")
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after a valid code
+func (ar *authRepository) RegenerateRecoveryCodes(e echo.Context, role string) ([]string, error) {
+	var req structures.TwoFactorCodeRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return nil, err
+	}
+	accountID, accountRole, _, err := ar.twoFactorAccount(e, role, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyTwoFactorCode(e, ar.query, ar.totpUtils, accountID, req.Code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := ar.newRecoveryCodes(e)
+	if err != nil {
+		return nil, err
+	}
+	audit := newAuditEntry(e, "TWO_FACTOR_RECOVERY_CODES_REGENERATE", accountRole, accountID, accountID, accountRole)
+	err = ar.query.RegenerateRecoveryCodes(e.Request().Context(), accountID, hashes, audit)
+	if errors.Is(err, queries.ErrTwoFactorNotEnabled) {
+		return nil, echo.NewHTTPError(400, "Two-factor authentication is not enabled")
+	}
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB regenerate recovery codes error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to regenerate recovery codes")
+	}
+	return codes, nil
+}
+
+// GetTwoFactorPolicies returns the admin's policy for every role
+func (ar *authRepository) GetTwoFactorPolicies(e echo.Context) (*[]structures.TwoFactorPolicy, error) {
+	adminID, _ := actingAdmin(e, e.QueryParam("admin_id"))
+	if adminID == "" {
+		return nil, echo.NewHTTPError(400, "admin_id is required")
+	}
+	res, err := ar.query.GetTwoFactorPolicies(e.Request().Context(), adminID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get two-factor policies error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to fetch two-factor policies")
+	}
+	return res, nil
+}
+
+// SetTwoFactorPolicy enforces, or stops enforcing, two-factor authentication
+// for a role within the admin's tenant. Members get grace_period_days to
+// enrol before logins without a second factor are refused.
+func (ar *authRepository) SetTwoFactorPolicy(e echo.Context) error {
+	var req structures.TwoFactorPolicyRequest
+	if err := ar.bindAndValidate(e, &req); err != nil {
+		return err
+	}
+	if err := verifyStepUp(e, ar.query, ar.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return err
+	}
+	adminID, actorID := actingAdmin(e, req.AdminID)
+	policy := structures.TwoFactorPolicy{
+		AdminID:   adminID,
+		Role:      req.Role,
+		Enforced:  req.Enforced,
+		UpdatedBy: actorID,
+	}
+	if req.Enforced && req.GracePeriodDays > 0 {
+		graceUntil := time.Now().AddDate(0, 0, req.GracePeriodDays)
+		policy.GraceUntil = &graceUntil
+	}
+	audit := newAuditEntry(e, "TWO_FACTOR_POLICY_UPDATE", "TWO_FACTOR_POLICY", req.Role, actorID, "ADMIN")
+	if err := ar.query.SetTwoFactorPolicy(e.Request().Context(), &policy, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB set two-factor policy error", "error", err)
+		return echo.NewHTTPError(500, "Failed to update two-factor policy")
+	}
+	return nil
+}
@@ -1,21 +1,30 @@
 package repositories
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Srujankm12/paybazar-api/internals/models/queries"
 	"github.com/Srujankm12/paybazar-api/internals/models/structures"
+	"github.com/Srujankm12/paybazar-api/pkg"
+	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
+// walletHoldLimit bounds the holds returned for a retailer
+const walletHoldLimit = 100
+
 type walletRepo struct {
-	query *queries.Query
+	query     *queries.Query
+	totpUtils *pkg.TOTPUtils
 }
 
-func NewWalletRepository(query *queries.Query) *walletRepo {
+func NewWalletRepository(query *queries.Query, totpUtils *pkg.TOTPUtils) *walletRepo {
 	return &walletRepo{
-		query: query,
+		query:     query,
+		totpUtils: totpUtils,
 	}
 }
 
@@ -39,9 +48,9 @@ func (wr *walletRepo) GetAdminWalletBalance(e echo.Context) (string, error) {
 	if adminID == "" {
 		return "", echo.NewHTTPError(400, "admin_id is required")
 	}
-	res, err := wr.query.GetAdminWalletBalance(adminID)
+	res, err := wr.query.GetAdminWalletBalance(e.Request().Context(), adminID)
 	if err != nil {
-		log.Println("DB get admin wallet balance error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB get admin wallet balance error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to retrieve admin wallet balance")
 	}
 	return res, nil
@@ -52,9 +61,9 @@ func (wr *walletRepo) GetMasterDistributorWalletBalance(e echo.Context) (string,
 	if masterDistributorID == "" {
 		return "", echo.NewHTTPError(400, "master_distributor_id is required")
 	}
-	res, err := wr.query.GetMasterDistributorWalletBalance(masterDistributorID)
+	res, err := wr.query.GetMasterDistributorWalletBalance(e.Request().Context(), masterDistributorID)
 	if err != nil {
-		log.Println("DB get master distributor wallet balance error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB get master distributor wallet balance error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to retrieve master distributor wallet balance")
 	}
 	return res, nil
@@ -65,23 +74,41 @@ func (wr *walletRepo) GetDistributorWalletBalance(e echo.Context) (string, error
 	if distributorID == "" {
 		return "", echo.NewHTTPError(400, "distributor_id is required")
 	}
-	res, err := wr.query.GetDistributorWalletBalance(distributorID)
+	res, err := wr.query.GetDistributorWalletBalance(e.Request().Context(), distributorID)
 	if err != nil {
-		log.Println("DB get distributor wallet balance error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB get distributor wallet balance error", "error", err)
 		return "", echo.NewHTTPError(500, "Failed to retrieve distributor wallet balance")
 	}
 	return res, nil
 }
 
-func (wr *walletRepo) GetUserWalletBalance(e echo.Context) (string, error) {
+// GetUserWalletBalance returns what the retailer can spend next to what is
+// held for their pending payouts
+func (wr *walletRepo) GetUserWalletBalance(e echo.Context) (*structures.WalletSummary, error) {
+	userID := e.Param("user_id")
+	if userID == "" {
+		return nil, echo.NewHTTPError(400, "user_id is required")
+	}
+	res, err := wr.query.GetUserWalletSummary(e.Request().Context(), userID)
+	if err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB get user wallet balance error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to retrieve user wallet balance")
+	}
+	return res, nil
+}
+
+func (wr *walletRepo) GetUserWalletHolds(e echo.Context) ([]structures.WalletHold, error) {
 	userID := e.Param("user_id")
 	if userID == "" {
-		return "", echo.NewHTTPError(400, "user_id is required")
+		return nil, echo.NewHTTPError(400, "user_id is required")
 	}
-	res, err := wr.query.GetUserWalletBalance(userID)
+	res, err := wr.query.GetUserWalletHolds(e.Request().Context(), userID, walletHoldLimit)
 	if err != nil {
-		log.Println("DB get user wallet balance error:", err)
-		return "", echo.NewHTTPError(500, "Failed to retrieve user wallet balance")
+		slog.ErrorContext(e.Request().Context(), "DB get user wallet holds error", "error", err)
+		return nil, echo.NewHTTPError(500, "Failed to retrieve wallet holds")
+	}
+	if res == nil {
+		res = []structures.WalletHold{}
 	}
 	return res, nil
 }
@@ -90,16 +117,27 @@ func (wr *walletRepo) GetUserWalletBalance(e echo.Context) (string, error) {
 // Admin Wallet Topup
 // ---------------------------
 
-func (wr *walletRepo) AdminWalletTopup(e echo.Context) (string, error) {
+// AdminWalletTopup tops up the admin wallet, or returns the pending action
+// when the amount needs a second approver
+func (wr *walletRepo) AdminWalletTopup(e echo.Context) (string, *structures.PendingAction, error) {
 	var req structures.AdminWalletTopupRequest
 	if err := wr.bindAndValidate(e, &req); err != nil {
-		return "", err
+		return "", nil, err
+	}
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "ADMIN", req.AdminId); err != nil {
+		return "", nil, err
 	}
-	if err := wr.query.AdminWalletTopup(&req); err != nil {
-		log.Println("DB admin wallet topup error:", err)
-		return "", echo.NewHTTPError(500, "Failed to top up admin wallet")
+	req.AdminId, _ = actingAdmin(e, req.AdminId)
+	pending, err := requestApproval(e, wr.query, pkg.ApprovalWalletTopup, req.AdminId, req.AdminId, req.Amount, &req)
+	if err != nil || pending != nil {
+		return "", pending, err
 	}
-	return "Admin wallet top-up successful", nil
+	audit := newAuditEntry(e, "WALLET_TOPUP", "ADMIN", req.AdminId, req.AdminId, "ADMIN")
+	if err := wr.query.AdminWalletTopup(e.Request().Context(), &req, audit); err != nil {
+		slog.ErrorContext(e.Request().Context(), "DB admin wallet topup error", "error", err)
+		return "", nil, echo.NewHTTPError(500, "Failed to top up admin wallet")
+	}
+	return "Admin wallet top-up successful", nil, nil
 }
 
 // ---------------------------
@@ -111,9 +149,9 @@ func (wr *walletRepo) GetTransactions(e echo.Context) (*[]structures.WalletTrans
 	if id == "" {
 		return nil, echo.NewHTTPError(400, "id is required")
 	}
-	res, err := wr.query.GetTransactions(id)
+	res, err := wr.query.GetTransactions(e.Request().Context(), id)
 	if err != nil {
-		log.Println("DB get transactions error:", err)
+		slog.ErrorContext(e.Request().Context(), "DB get transactions error", "error", err)
 		return nil, echo.NewHTTPError(500, "Failed to fetch transactions")
 	}
 	if res == nil {
@@ -123,37 +161,64 @@ func (wr *walletRepo) GetTransactions(e echo.Context) (*[]structures.WalletTrans
 	return res, nil
 }
 
-func (wr *walletRepo) UserRefund(e echo.Context) error {
+func (wr *walletRepo) UserRefund(e echo.Context) (*structures.PendingAction, error) {
 	var req structures.RefundRequest
 	if err := wr.bindAndValidate(e, &req); err != nil {
-		return err
+		return nil, err
 	}
-	if err := wr.query.UserRefund(&req); err != nil {
-		return fmt.Errorf("low balance")
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return nil, err
 	}
-	return nil
+	req.AdminID, _ = actingAdmin(e, req.AdminID)
+	pending, err := requestApproval(e, wr.query, pkg.ApprovalUserRefund, req.AdminID, req.PhoneNumber, req.Amount, &req)
+	if err != nil || pending != nil {
+		return pending, err
+	}
+	audit := newAuditEntry(e, "USER_REFUND", "USER", "", req.AdminID, "ADMIN")
+	if err := wr.query.UserRefund(e.Request().Context(), &req, audit); err != nil {
+		return nil, fmt.Errorf("low balance")
+	}
+	return nil, nil
 }
 
-func (wr *walletRepo) MasterDistributorRefund(e echo.Context) error {
+func (wr *walletRepo) MasterDistributorRefund(e echo.Context) (*structures.PendingAction, error) {
 	var req structures.RefundRequest
 	if err := wr.bindAndValidate(e, &req); err != nil {
-		return err
+		return nil, err
 	}
-	if err := wr.query.MasterDistributorRefund(&req); err != nil {
-		return fmt.Errorf("low balance")
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return nil, err
 	}
-	return nil
+	req.AdminID, _ = actingAdmin(e, req.AdminID)
+	pending, err := requestApproval(e, wr.query, pkg.ApprovalMasterDistributorRefund, req.AdminID, req.PhoneNumber, req.Amount, &req)
+	if err != nil || pending != nil {
+		return pending, err
+	}
+	audit := newAuditEntry(e, "MASTER_DISTRIBUTOR_REFUND", "MASTER_DISTRIBUTOR", "", req.AdminID, "ADMIN")
+	if err := wr.query.MasterDistributorRefund(e.Request().Context(), &req, audit); err != nil {
+		return nil, fmt.Errorf("low balance")
+	}
+	return nil, nil
 }
 
-func (wr *walletRepo) DistributorRefund(e echo.Context) error {
+func (wr *walletRepo) DistributorRefund(e echo.Context) (*structures.PendingAction, error) {
 	var req structures.RefundRequest
 	if err := wr.bindAndValidate(e, &req); err != nil {
-		return err
+		return nil, err
 	}
-	if err := wr.query.DistributorRefund(&req); err != nil {
-		return fmt.Errorf("low balance")
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return nil, err
 	}
-	return nil
+	req.AdminID, _ = actingAdmin(e, req.AdminID)
+	pending, err := requestApproval(e, wr.query, pkg.ApprovalDistributorRefund, req.AdminID, req.PhoneNumber, req.Amount, &req)
+	if err != nil || pending != nil {
+		return pending, err
+	}
+	audit := newAuditEntry(e, "DISTRIBUTOR_REFUND", "DISTRIBUTOR", "", req.AdminID, "ADMIN")
+	if err := wr.query.DistributorRefund(e.Request().Context(), &req, audit); err != nil {
+		return nil, fmt.Errorf("low balance")
+	}
+	return nil, nil
 }
 
 func (wr *walletRepo) MasterDistributorFundRetailer(e echo.Context) error {
@@ -161,7 +226,7 @@ func (wr *walletRepo) MasterDistributorFundRetailer(e echo.Context) error {
 	if err := wr.bindAndValidate(e, &req); err != nil {
 		return err
 	}
-	if err := wr.query.MasterDistributorFundRetailer(&req); err != nil {
+	if err := wr.query.MasterDistributorFundRetailer(e.Request().Context(), &req); err != nil {
 		return fmt.Errorf("low balance")
 	}
 	return nil
@@ -172,7 +237,7 @@ func (wr *walletRepo) MasterDistributorFundDistributor(e echo.Context) error {
 	if err := wr.bindAndValidate(e, &req); err != nil {
 		return err
 	}
-	if err := wr.query.MasterDistributorFundDistributor(&req); err != nil {
+	if err := wr.query.MasterDistributorFundDistributor(e.Request().Context(), &req); err != nil {
 		return fmt.Errorf("low balance")
 	}
 	return nil
@@ -183,18 +248,19 @@ func (wr *walletRepo) DistributorFundRetailer(e echo.Context) error {
 	if err := wr.bindAndValidate(e, &req); err != nil {
 		return err
 	}
-	if err := wr.query.DistributorFundRetailer(&req); err != nil {
+	if err := wr.query.DistributorFundRetailer(e.Request().Context(), &req); err != nil {
 		return fmt.Errorf("low balance")
 	}
 	return nil
 }
 
-func (wr *walletRepo) GetRevertHistoryPhone(phoneNumber string) (*[]structures.GetRevertHistory, error) {
-	return wr.query.GetRevertHistoryPhone(phoneNumber)
+func (wr *walletRepo) GetRevertHistoryPhone(ctx context.Context, phoneNumber string) (*[]structures.GetRevertHistory, error) {
+	return wr.query.GetRevertHistoryPhone(ctx, phoneNumber)
 }
 
-func (wr *walletRepo) GetRevertHistory() (*[]structures.GetRevertHistory, error) {
-	return wr.query.GetRevertHistory()
+func (wr *walletRepo) GetRevertHistory(e echo.Context) (*[]structures.GetRevertHistory, error) {
+	adminID, _ := actingAdmin(e, "")
+	return wr.query.GetRevertHistory(e.Request().Context(), adminID)
 }
 
 func (wr *walletRepo) MasterDistributorRefundUser(e echo.Context) error {
@@ -202,7 +268,10 @@ func (wr *walletRepo) MasterDistributorRefundUser(e echo.Context) error {
 	if err := wr.bindAndValidate(e, &req); err != nil {
 		return err
 	}
-	if err := wr.query.MDUserRefund(&req); err != nil {
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "MASTER_DISTRIBUTOR", req.MasterDistributorID); err != nil {
+		return err
+	}
+	if err := wr.query.MDUserRefund(e.Request().Context(), &req); err != nil {
 		return fmt.Errorf("low balance")
 	}
 	return nil
@@ -213,7 +282,10 @@ func (wr *walletRepo) MasterDistributorRefundDistributor(e echo.Context) error {
 	if err := wr.bindAndValidate(e, &req); err != nil {
 		return err
 	}
-	if err := wr.query.MDDistributorRefund(&req); err != nil {
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "MASTER_DISTRIBUTOR", req.MasterDistributorID); err != nil {
+		return err
+	}
+	if err := wr.query.MDDistributorRefund(e.Request().Context(), &req); err != nil {
 		return fmt.Errorf("low balance")
 	}
 	return nil
@@ -224,19 +296,53 @@ func (wr *walletRepo) DistributorRefundRetailer(e echo.Context) error {
 	if err := wr.bindAndValidate(e, &req); err != nil {
 		return err
 	}
-	if err := wr.query.DistributorUserRefund(&req); err != nil {
+	if err := wr.query.DistributorUserRefund(e.Request().Context(), &req); err != nil {
 		return fmt.Errorf("low balance")
 	}
 	return nil
 }
 
-func (wr *walletRepo) UpdatePayoutTransaction(e echo.Context) error {
+// UpdatePayoutTransaction overrides a payout's status, or returns the
+// pending action when the payout amount needs a second approver
+func (wr *walletRepo) UpdatePayoutTransaction(e echo.Context) (*structures.PendingAction, error) {
 	var req structures.UpdatePayoutTransaction
 	if err := wr.bindAndValidate(e, &req); err != nil {
-		return err
+		return nil, err
 	}
-	if err := wr.query.UpdatePayoutTransaction(&req); err != nil {
-		return err
+	if req.Status == pkg.PayoutReturned || req.Status == pkg.PayoutReversed {
+		return nil, echo.NewHTTPError(400, "Returned payouts are recorded with a payout return")
 	}
-	return nil
+	if err := verifyStepUp(e, wr.query, wr.totpUtils, "ADMIN", req.AdminID); err != nil {
+		return nil, err
+	}
+	amount, err := wr.query.GetPayoutAmount(e.Request().Context(), req.PayoutTransactionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(404, "Payout not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.AdminID, _ = actingAdmin(e, req.AdminID)
+	pending, err := requestApproval(e, wr.query, pkg.ApprovalPayoutStatusUpdate, req.AdminID, req.PayoutTransactionID, amount, &req)
+	if err != nil || pending != nil {
+		return pending, err
+	}
+	audit := newAuditEntry(e, "PAYOUT_STATUS_UPDATE", "PAYOUT", req.PayoutTransactionID, req.AdminID, "ADMIN")
+	err = wr.query.UpdatePayoutTransaction(e.Request().Context(), &req, audit)
+	if errors.Is(err, queries.ErrPayoutHoldReleased) {
+		return nil, echo.NewHTTPError(409, "Payout amount was already released to the retailer")
+	}
+	if errors.Is(err, queries.ErrPeriodClosed) {
+		return nil, echo.NewHTTPError(409, "Payout belongs to a closed period")
+	}
+	if errors.Is(err, queries.ErrPayoutAlreadyReturned) {
+		return nil, echo.NewHTTPError(409, "Payout was returned")
+	}
+	if errors.Is(err, queries.ErrInsufficientBalance) {
+		return nil, echo.NewHTTPError(422, "Commission paid for the payout cannot be taken back: "+err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
 }
@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Admin roles, only super admins can invite admins or change their roles
+const (
+	AdminRoleSuperAdmin = "SUPER_ADMIN"
+	AdminRoleFinance    = "FINANCE"
+	AdminRoleSupport    = "SUPPORT"
+	AdminRoleReadOnly   = "READ_ONLY"
+)
+
+// AdminInviteTTL bounds how long an admin invite can be accepted
+const AdminInviteTTL = 72 * time.Hour
+
+// GenerateInviteToken returns a single-use invite token and the hash stored
+// in its place
+func GenerateInviteToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	return token, HashInviteToken(token), nil
+}
+
+// HashInviteToken returns the stored form of an invite token
+func HashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package pkg
+
+import "time"
+
+// Admin operations that can need a second approver
+const (
+	ApprovalWalletTopup             = "WALLET_TOPUP"
+	ApprovalUserRefund              = "USER_REFUND"
+	ApprovalMasterDistributorRefund = "MASTER_DISTRIBUTOR_REFUND"
+	ApprovalDistributorRefund       = "DISTRIBUTOR_REFUND"
+	ApprovalPayoutStatusUpdate      = "PAYOUT_STATUS_UPDATE"
+)
+
+// ApprovalActionTypes lists the operations an approval policy can be set for
+var ApprovalActionTypes = []string{
+	ApprovalWalletTopup,
+	ApprovalUserRefund,
+	ApprovalMasterDistributorRefund,
+	ApprovalDistributorRefund,
+	ApprovalPayoutStatusUpdate,
+}
+
+// Pending action statuses. An approved action is executed straight away and
+// ends as EXECUTED or FAILED. One left APPROVED was cut off before its
+// outcome was recorded and is resumed by approving it again.
+const (
+	PendingActionPending  = "PENDING"
+	PendingActionApproved = "APPROVED"
+	PendingActionExecuted = "EXECUTED"
+	PendingActionFailed   = "FAILED"
+	PendingActionRejected = "REJECTED"
+	PendingActionExpired  = "EXPIRED"
+)
+
+// PendingActionRequestID is the request id the audit entry of an approved
+// action's execution is written with. The entry commits with the action's
+// own changes, so it tells a resumed action whether it already ran.
+func PendingActionRequestID(actionID string) string {
+	return "pending_action:" + actionID
+}
+
+// DefaultApprovalTTL is how long a pending action waits for a checker when
+// its policy does not say otherwise
+const DefaultApprovalTTL = 24 * time.Hour
@@ -0,0 +1,22 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Retailer device binding
+const (
+	// DeviceFingerprintHeader carries the fingerprint of the device a
+	// retailer session is bound to
+	DeviceFingerprintHeader = "X-Device-Fingerprint"
+	// DeviceChallengeTTL bounds the time between login and verifying a new device
+	DeviceChallengeTTL = 5 * time.Minute
+)
+
+// HashDeviceFingerprint returns the stored form of a device fingerprint
+func HashDeviceFingerprint(fingerprint string) string {
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package pkg
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Payout hold statuses. A hold is captured when its payout succeeds and
+// released back to the retailer when it fails. A captured hold is refunded
+// when its payout is refunded afterwards.
+const (
+	HoldHeld     = "HELD"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+	HoldRefunded = "REFUNDED"
+)
+
+// HoldShares splits the commission of a payout by the rates of the members
+// it is paid to, each share rounded half away from zero to the paisa the way
+// the wallets store it
+func HoldShares(totalCommission string, rates ...string) ([]string, error) {
+	total, ok := new(big.Rat).SetString(totalCommission)
+	if !ok {
+		return nil, fmt.Errorf("invalid commission %q", totalCommission)
+	}
+	shares := make([]string, 0, len(rates))
+	for _, rate := range rates {
+		r, ok := new(big.Rat).SetString(rate)
+		if !ok {
+			return nil, fmt.Errorf("invalid commission rate %q", rate)
+		}
+		shares = append(shares, roundToPaisa(new(big.Rat).Mul(total, r)))
+	}
+	return shares, nil
+}
+
+func roundToPaisa(x *big.Rat) string {
+	scaled := new(big.Rat).Mul(x, big.NewRat(100, 1))
+	paise, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if new(big.Int).Lsh(rem.Abs(rem), 1).Cmp(scaled.Denom()) >= 0 {
+		paise.Add(paise, big.NewInt(int64(scaled.Sign())))
+	}
+	return new(big.Rat).SetFrac(paise, big.NewInt(100)).FloatString(2)
+}
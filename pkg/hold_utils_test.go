@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHoldShares(t *testing.T) {
+	tests := []struct {
+		name  string
+		total string
+		rates []string
+		want  []string
+	}{
+		// The default commission on a payout of 1000
+		{"default rates", "12", []string{"0.2917", "0.0417", "0.1667"}, []string{"3.50", "0.50", "2.00"}},
+		{"half a paisa rounds up", "1", []string{"0.125", "0.005"}, []string{"0.13", "0.01"}},
+		{"under half a paisa rounds down", "1", []string{"0.0049", "0.1249"}, []string{"0.00", "0.12"}},
+		{"negative rounds away from zero", "-1", []string{"0.125"}, []string{"-0.13"}},
+		{"no commission", "0", []string{"0.2917", "0.0417", "0.1667"}, []string{"0.00", "0.00", "0.00"}},
+		{"exact shares", "50.40", []string{"0.5", "0.25", "0.25"}, []string{"25.20", "12.60", "12.60"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HoldShares(tt.total, tt.rates...)
+			if err != nil {
+				t.Fatalf("HoldShares(%s, %v): %v", tt.total, tt.rates, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("HoldShares(%s, %v) = %v, want %v", tt.total, tt.rates, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHoldSharesRejectsBadInput(t *testing.T) {
+	if _, err := HoldShares("ten", "0.5"); err == nil {
+		t.Error("accepted a commission that is not a number")
+	}
+	if _, err := HoldShares("10", "0.5", "half"); err == nil {
+		t.Error("accepted a rate that is not a number")
+	}
+}
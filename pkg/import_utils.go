@@ -0,0 +1,34 @@
+package pkg
+
+import "regexp"
+
+// Member import commit modes. ATOMIC creates every row or none of them,
+// PER_ROW creates the rows that are fine and reports the rest.
+const (
+	ImportModeAtomic = "ATOMIC"
+	ImportModePerRow = "PER_ROW"
+)
+
+// Member import row statuses. VALID is what a dry run reports for a row that
+// would have been created, ROLLED_BACK is a good row of an ATOMIC import that
+// was undone because another row failed.
+const (
+	ImportRowValid      = "VALID"
+	ImportRowCreated    = "CREATED"
+	ImportRowFailed     = "FAILED"
+	ImportRowRolledBack = "ROLLED_BACK"
+)
+
+// MaxMemberImportRows caps the rows of a single member import
+const MaxMemberImportRows = 200
+
+// MemberImportColumns are the columns a member import file must have.
+// parent_ref and gst_number may be left empty.
+var MemberImportColumns = []string{
+	"member_type", "parent_ref", "name", "email", "phone", "password", "aadhar_number",
+	"pan_number", "date_of_birth", "gender", "city", "state", "address", "pincode",
+	"business_name", "business_type", "gst_number",
+}
+
+// PANPattern matches a permanent account number
+var PANPattern = regexp.MustCompile(`^[A-Z]{5}[0-9]{4}[A-Z]$`)
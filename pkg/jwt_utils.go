@@ -9,6 +9,9 @@ import (
 
 type JwtUtils struct{}
 
+// ActorContextKey is the echo context key holding the authenticated actor
+const ActorContextKey = "actor"
+
 func (JwtUtils) GenerateToken(data interface{}, expiry time.Duration) (string, error) {
 	// JWT secret key
 	var key []byte = []byte("agromart@2025")
@@ -49,3 +52,30 @@ func (JwtUtils) GenerateTokenForExternalAPI(reqid string) (string, error) {
 	// Returning the token
 	return token, nil
 }
+
+func (JwtUtils) ParseToken(token string) (map[string]any, error) {
+	// JWT secret key
+	var key []byte = []byte("agromart@2025")
+
+	// Parsing and verifying the signed token
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwt: %w", err)
+	}
+
+	// Extracting the data claim which holds the login response
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid jwt claims")
+	}
+	data, ok := claims["data"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("jwt has no data claim")
+	}
+	return data, nil
+}
@@ -0,0 +1,23 @@
+package pkg
+
+import (
+	"fmt"
+	"time"
+)
+
+// LedgerDateLayout is how snapshot and closing dates are written
+const LedgerDateLayout = "2006-01-02"
+
+// SnapshotCatchUpDays bounds how many missed days the snapshot job fills in
+// on one run
+const SnapshotCatchUpDays = 31
+
+// ParseLedgerDate reads a date of the form YYYY-MM-DD as a day in
+// ScheduleLocation
+func ParseLedgerDate(date string) (time.Time, error) {
+	t, err := time.ParseInLocation(LedgerDateLayout, date, ScheduleLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be YYYY-MM-DD")
+	}
+	return t, nil
+}
@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type logContextKey string
+
+const (
+	requestIDKey logContextKey = "request_id"
+	actorIDKey   logContextKey = "actor_id"
+	actorRoleKey logContextKey = "actor_role"
+)
+
+// RedactedValue replaces the value of every sensitive attribute
+const RedactedValue = "[REDACTED]"
+
+// sensitiveLogKeys are matched as substrings of lower cased attribute keys
+var sensitiveLogKeys = []string{
+	"mpin",
+	"otp",
+	"password",
+	"aadhar",
+	"aadhaar",
+	"account_number",
+	"account_no",
+	"token",
+	"secret",
+}
+
+// IsSensitiveLogKey reports whether values stored under key must never be logged
+func IsSensitiveLogKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveLogKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRequestID stores the request id so every log written with the context carries it
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request id stored by WithRequestID
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithActor stores the authenticated caller so logs written with the context carry it
+func WithActor(ctx context.Context, actorID string, actorRole string) context.Context {
+	ctx = context.WithValue(ctx, actorIDKey, actorID)
+	return context.WithValue(ctx, actorRoleKey, actorRole)
+}
+
+// contextHandler copies request scoped values from the context into every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if requestID, ok := ctx.Value(requestIDKey).(string); ok && requestID != "" {
+			r.AddAttrs(slog.String("request_id", requestID))
+		}
+		if actorID, ok := ctx.Value(actorIDKey).(string); ok && actorID != "" {
+			actorRole, _ := ctx.Value(actorRoleKey).(string)
+			r.AddAttrs(slog.String("actor_id", actorID), slog.String("actor_role", actorRole))
+		}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			r.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() != slog.KindGroup && IsSensitiveLogKey(attr.Key) {
+		return slog.String(attr.Key, RedactedValue)
+	}
+	return attr
+}
+
+// RotatingFileWriter appends to one file per day inside dir. Write errors are
+// reported on stderr and never stop the process.
+type RotatingFileWriter struct {
+	dir  string
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+func NewRotatingFileWriter(dir string) *RotatingFileWriter {
+	return &RotatingFileWriter{dir: dir}
+}
+
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	day := time.Now().Format("2006-01-02")
+	if w.file == nil || w.day != day {
+		if err := w.rotate(day); err != nil {
+			fmt.Fprintf(os.Stderr, "log file unavailable, dropping to stderr: %v\n", err)
+			return os.Stderr.Write(p)
+		}
+	}
+
+	n, err := w.file.Write(p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write log file: %v\n", err)
+		return os.Stderr.Write(p)
+	}
+	return n, nil
+}
+
+func (w *RotatingFileWriter) rotate(day string) error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return err
+	}
+
+	// Creating a dynamic file based on date to store all the logs based on the date in sepearte file
+	path := filepath.Join(w.dir, fmt.Sprintf("%s_logs.log", day))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.day = day
+	return nil
+}
+
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// NewLogger builds a JSON logger writing to the sinks listed in LOG_SINKS
+// (comma separated: stdout, file). LOG_DIR sets the file sink folder and
+// LOG_LEVEL the minimum level.
+func NewLogger() *slog.Logger {
+	sinks := os.Getenv("LOG_SINKS")
+	if sinks == "" {
+		sinks = "stdout,file"
+	}
+	logDir := os.Getenv("LOG_DIR")
+	if logDir == "" {
+		logDir = ".logs"
+	}
+
+	var writers []io.Writer
+	for _, sink := range strings.Split(sinks, ",") {
+		switch strings.TrimSpace(sink) {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "file":
+			writers = append(writers, NewRotatingFileWriter(logDir))
+		case "":
+		default:
+			fmt.Fprintf(os.Stderr, "unknown log sink %q ignored\n", sink)
+		}
+	}
+	if len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	handler := slog.NewJSONHandler(io.MultiWriter(writers...), &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(contextHandler{handler})
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// MetricsRegistry holds every metric exposed on /metrics
+var MetricsRegistry = prometheus.NewRegistry()
+
+var HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "paybazar",
+	Name:      "http_request_duration_seconds",
+	Help:      "HTTP request latency by method, route and status code.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+var ProviderRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "paybazar",
+	Name:      "provider_request_duration_seconds",
+	Help:      "Latency of calls to external providers by provider, operation and outcome.",
+	Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30},
+}, []string{"provider", "operation", "outcome"})
+
+var NotificationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "paybazar",
+	Name:      "notifications_total",
+	Help:      "Notifications by channel and outcome: queued, sent, retried, failed or dropped.",
+}, []string{"channel", "outcome"})
+
+var RateLimitedRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "paybazar",
+	Name:      "rate_limited_requests_total",
+	Help:      "Requests refused with 429 by route and rate limit policy.",
+}, []string{"route", "policy"})
+
+func init() {
+	MetricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		ProviderRequestDuration,
+		NotificationsTotal,
+		RateLimitedRequestsTotal,
+	)
+}
+
+// ObserveProviderRequest records how long a provider call started at start took
+func ObserveProviderRequest(provider string, operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	ProviderRequestDuration.WithLabelValues(provider, operation, outcome).Observe(time.Since(start).Seconds())
+}
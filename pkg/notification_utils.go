@@ -0,0 +1,482 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Notification events
+const (
+	EventOTP                     = "OTP"
+	EventWalletCredited          = "WALLET_CREDITED"
+	EventWalletDebited           = "WALLET_DEBITED"
+	EventFundRequestDecided      = "FUND_REQUEST_DECIDED"
+	EventPayoutStatusChanged     = "PAYOUT_STATUS_CHANGED"
+	EventLowBalance              = "LOW_BALANCE"
+	EventNewDeviceLogin          = "NEW_DEVICE_LOGIN"
+	EventAdminInvite             = "ADMIN_INVITE"
+	EventScheduledTransferFailed = "SCHEDULED_TRANSFER_FAILED"
+	EventCreditOverdue           = "CREDIT_OVERDUE"
+	EventWalletDrift             = "WALLET_DRIFT"
+)
+
+// NotificationEvents are the events members can set channel preferences for
+var NotificationEvents = []string{
+	EventWalletCredited,
+	EventWalletDebited,
+	EventFundRequestDecided,
+	EventPayoutStatusChanged,
+	EventLowBalance,
+	EventNewDeviceLogin,
+	EventScheduledTransferFailed,
+	EventCreditOverdue,
+	EventWalletDrift,
+}
+
+// NotificationChannels lists every channel in delivery order
+var NotificationChannels = []string{ChannelSMS, ChannelEmail, ChannelWhatsApp}
+
+// DefaultChannelEnabled is used for events a member has no preference for:
+// SMS is on, the other channels have to be opted into
+func DefaultChannelEnabled(channel string) bool {
+	return channel == ChannelSMS
+}
+
+// DefaultLanguage is used when no template exists in the requested language
+const DefaultLanguage = "en"
+
+var (
+	ErrNotificationQueueFull = errors.New("notification queue full")
+	ErrNotificationClosed    = errors.New("notification service closed")
+	ErrChannelDisabled       = errors.New("notification channel disabled")
+)
+
+// Notification asks for event to be sent to a recipient on one channel.
+// Data fills the event's template.
+type Notification struct {
+	Channel  string
+	To       string
+	Event    string
+	Language string
+	Data     map[string]string
+}
+
+// notificationTemplate is the text of one event in one language.
+// WhatsAppParams lists the Data keys passed, in order, to the approved
+// WhatsApp template named by WHATSAPP_TEMPLATE_<EVENT>_<LANGUAGE>.
+type notificationTemplate struct {
+	Subject        string
+	Body           string
+	WhatsAppParams []string
+}
+
+// notificationTemplates holds the text of every event by language
+var notificationTemplates = map[string]map[string]notificationTemplate{
+	EventOTP: {
+		"en": {
+			Subject:        "Your Paybazaar verification code",
+			Body:           "This is a Verification Message from Paybazaar with OTP: {{.otp}}. It is valid for {{.validity_minutes}} minutes, do not share it with anyone.",
+			WhatsAppParams: []string{"otp", "validity_minutes"},
+		},
+		"hi": {
+			Subject:        "आपका Paybazaar सत्यापन कोड",
+			Body:           "Paybazaar सत्यापन OTP: {{.otp}}। यह {{.validity_minutes}} मिनट तक मान्य है, इसे किसी के साथ साझा न करें।",
+			WhatsAppParams: []string{"otp", "validity_minutes"},
+		},
+	},
+	EventWalletCredited: {
+		"en": {
+			Subject:        "Rs {{.amount}} credited to your Paybazaar wallet",
+			Body:           "Rs {{.amount}} has been credited to your Paybazaar wallet ({{.reason}}). Available balance: Rs {{.balance}}.",
+			WhatsAppParams: []string{"amount", "balance"},
+		},
+		"hi": {
+			Subject:        "आपके Paybazaar वॉलेट में Rs {{.amount}} जमा हुए",
+			Body:           "आपके Paybazaar वॉलेट में Rs {{.amount}} जमा किए गए हैं। उपलब्ध बैलेंस: Rs {{.balance}}।",
+			WhatsAppParams: []string{"amount", "balance"},
+		},
+	},
+	EventWalletDebited: {
+		"en": {
+			Subject:        "Rs {{.amount}} debited from your Paybazaar wallet",
+			Body:           "Rs {{.amount}} has been debited from your Paybazaar wallet ({{.reason}}). Available balance: Rs {{.balance}}.",
+			WhatsAppParams: []string{"amount", "balance"},
+		},
+		"hi": {
+			Subject:        "आपके Paybazaar वॉलेट से Rs {{.amount}} कटे",
+			Body:           "आपके Paybazaar वॉलेट से Rs {{.amount}} काटे गए हैं। उपलब्ध बैलेंस: Rs {{.balance}}।",
+			WhatsAppParams: []string{"amount", "balance"},
+		},
+	},
+	EventFundRequestDecided: {
+		"en": {
+			Subject:        "Fund request {{.status}}",
+			Body:           "Your Paybazaar fund request of Rs {{.amount}} has been {{.status}}.",
+			WhatsAppParams: []string{"amount", "status"},
+		},
+		"hi": {
+			Subject:        "फंड अनुरोध {{.status}}",
+			Body:           "Rs {{.amount}} का आपका Paybazaar फंड अनुरोध {{.status}} हो गया है।",
+			WhatsAppParams: []string{"amount", "status"},
+		},
+	},
+	EventPayoutStatusChanged: {
+		"en": {
+			Subject:        "Payout {{.status}}",
+			Body:           "Your Paybazaar payout of Rs {{.amount}} to {{.beneficiary_name}} is {{.status}}. Ref: {{.payout_transaction_id}}.",
+			WhatsAppParams: []string{"amount", "beneficiary_name", "status", "payout_transaction_id"},
+		},
+		"hi": {
+			Subject:        "पेआउट {{.status}}",
+			Body:           "{{.beneficiary_name}} को Rs {{.amount}} का आपका Paybazaar पेआउट {{.status}} है। संदर्भ: {{.payout_transaction_id}}।",
+			WhatsAppParams: []string{"amount", "beneficiary_name", "status", "payout_transaction_id"},
+		},
+	},
+	EventLowBalance: {
+		"en": {
+			Subject:        "Low Paybazaar wallet balance",
+			Body:           "Your Paybazaar wallet balance is Rs {{.balance}}, below Rs {{.threshold}}. Add funds to keep transacting.",
+			WhatsAppParams: []string{"balance", "threshold"},
+		},
+		"hi": {
+			Subject:        "Paybazaar वॉलेट बैलेंस कम है",
+			Body:           "आपका Paybazaar वॉलेट बैलेंस Rs {{.balance}} है, जो Rs {{.threshold}} से कम है। लेनदेन जारी रखने के लिए फंड जोड़ें।",
+			WhatsAppParams: []string{"balance", "threshold"},
+		},
+	},
+	EventNewDeviceLogin: {
+		"en": {
+			Subject:        "New device signed in to Paybazaar",
+			Body:           "Your Paybazaar account was signed in on a new device ({{.device_name}}) at {{.time}}. If this was not you, revoke the device and contact your distributor.",
+			WhatsAppParams: []string{"device_name", "time"},
+		},
+		"hi": {
+			Subject:        "Paybazaar में नए डिवाइस से लॉगिन",
+			Body:           "आपके Paybazaar खाते में {{.time}} पर एक नए डिवाइस ({{.device_name}}) से लॉगिन हुआ। अगर यह आप नहीं थे, तो डिवाइस हटाएं और अपने डिस्ट्रीब्यूटर से संपर्क करें।",
+			WhatsAppParams: []string{"device_name", "time"},
+		},
+	},
+	EventScheduledTransferFailed: {
+		"en": {
+			Subject:        "Scheduled transfer to {{.recipient_name}} {{.status}}",
+			Body:           "Your scheduled Paybazaar transfer of Rs {{.amount}} to {{.recipient_name}} was {{.status}}: {{.reason}}.",
+			WhatsAppParams: []string{"amount", "recipient_name", "status", "reason"},
+		},
+		"hi": {
+			Subject:        "{{.recipient_name}} को शेड्यूल ट्रांसफर {{.status}}",
+			Body:           "{{.recipient_name}} को Rs {{.amount}} का आपका शेड्यूल Paybazaar ट्रांसफर {{.status}}: {{.reason}}।",
+			WhatsAppParams: []string{"amount", "recipient_name", "status", "reason"},
+		},
+	},
+	EventCreditOverdue: {
+		"en": {
+			Subject:        "Credit line of {{.member_name}} is overdue",
+			Body:           "The Paybazaar credit line of {{.member_name}} is overdue: Rs {{.outstanding}} was due on {{.due_date}}. No further credit can be drawn until it is repaid.",
+			WhatsAppParams: []string{"member_name", "outstanding", "due_date"},
+		},
+		"hi": {
+			Subject:        "{{.member_name}} की क्रेडिट लाइन बकाया है",
+			Body:           "{{.member_name}} की Paybazaar क्रेडिट लाइन बकाया है: Rs {{.outstanding}} {{.due_date}} तक देय था। चुकाने तक आगे क्रेडिट नहीं लिया जा सकता।",
+			WhatsAppParams: []string{"member_name", "outstanding", "due_date"},
+		},
+	},
+	EventWalletDrift: {
+		"en": {
+			Subject:        "Wallet balances out of line on {{.date}}",
+			Body:           "The Paybazaar integrity check for {{.date}} found {{.accounts}} wallets whose balance does not match their transactions, off by Rs {{.total_drift}} in all. Review them in the admin panel.",
+			WhatsAppParams: []string{"date", "accounts", "total_drift"},
+		},
+		"hi": {
+			Subject:        "{{.date}} को वॉलेट बैलेंस मेल नहीं खाते",
+			Body:           "{{.date}} की Paybazaar जांच में {{.accounts}} वॉलेट का बैलेंस उनके लेनदेन से मेल नहीं खाता, कुल अंतर Rs {{.total_drift}}। एडमिन पैनल में इनकी समीक्षा करें।",
+			WhatsAppParams: []string{"date", "accounts", "total_drift"},
+		},
+	},
+	EventAdminInvite: {
+		"en": {
+			Subject:        "You are invited to the Paybazaar admin panel",
+			Body:           "You have been invited to join the Paybazaar admin panel as {{.role}}. Register with invite code {{.invite_token}} before {{.expires_at}}.",
+			WhatsAppParams: []string{"role", "invite_token", "expires_at"},
+		},
+	},
+}
+
+// parsedTemplates caches the parsed subject and body of every template
+var parsedTemplates sync.Map
+
+func renderTemplate(key string, text string, data map[string]string) (string, error) {
+	cached, ok := parsedTemplates.Load(key)
+	if !ok {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		cached, _ = parsedTemplates.LoadOrStore(key, tmpl)
+	}
+	var out strings.Builder
+	if err := cached.(*template.Template).Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// RenderNotification turns a notification into the message for its channel,
+// falling back to DefaultLanguage when the language has no template
+func RenderNotification(n *Notification) (*Message, error) {
+	byLanguage, ok := notificationTemplates[n.Event]
+	if !ok {
+		return nil, fmt.Errorf("no template for event %q", n.Event)
+	}
+	language := n.Language
+	tmpl, ok := byLanguage[language]
+	if !ok {
+		language = DefaultLanguage
+		tmpl = byLanguage[language]
+	}
+
+	key := n.Event + "/" + language
+	subject, err := renderTemplate(key+"/subject", tmpl.Subject, n.Data)
+	if err != nil {
+		return nil, fmt.Errorf("render %s subject: %w", key, err)
+	}
+	body, err := renderTemplate(key+"/body", tmpl.Body, n.Data)
+	if err != nil {
+		return nil, fmt.Errorf("render %s body: %w", key, err)
+	}
+
+	msg := Message{
+		Channel: n.Channel,
+		To:      n.To,
+		Event:   n.Event,
+		Subject: subject,
+		Body:    body,
+	}
+	if n.Channel == ChannelWhatsApp {
+		msg.TemplateSid = os.Getenv("WHATSAPP_TEMPLATE_" + n.Event + "_" + strings.ToUpper(language))
+		for _, param := range tmpl.WhatsAppParams {
+			msg.TemplateParams = append(msg.TemplateParams, n.Data[param])
+		}
+	}
+	return &msg, nil
+}
+
+// notificationJob is one message waiting in the queue
+type notificationJob struct {
+	ctx     context.Context
+	msg     *Message
+	attempt int
+}
+
+// NotificationService renders notifications and delivers them from a bounded
+// in-memory queue, so callers never wait on a provider. Failed deliveries are
+// retried with exponential backoff.
+type NotificationService struct {
+	notifiers   map[string]Notifier
+	queue       chan *notificationJob
+	workers     int
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+
+	// pending counts jobs queued, being sent or waiting for a retry
+	pending atomic.Int64
+	closed  atomic.Bool
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewNotificationService builds the service from the NOTIFY_*_SINK settings.
+// NOTIFY_FILE_PATH sets the file sink (default .logs/notifications.log),
+// NOTIFY_WORKERS, NOTIFY_QUEUE_SIZE and NOTIFY_MAX_ATTEMPTS size the queue.
+func NewNotificationService() (*NotificationService, error) {
+	var fileSink *FileNotifier
+	channels := []string{ChannelSMS, ChannelEmail, ChannelWhatsApp}
+	for _, channel := range channels {
+		if NotificationSink(channel) != "file" {
+			continue
+		}
+		path := os.Getenv("NOTIFY_FILE_PATH")
+		if path == "" {
+			path = ".logs/notifications.log"
+		}
+		var err error
+		if fileSink, err = OpenFileNotifier(path); err != nil {
+			return nil, fmt.Errorf("open notification file sink: %w", err)
+		}
+		break
+	}
+
+	notifiers := map[string]Notifier{}
+	for _, channel := range channels {
+		notifier, err := newNotifier(channel, fileSink)
+		if err != nil {
+			return nil, err
+		}
+		if notifier != nil {
+			notifiers[channel] = notifier
+		}
+	}
+	return NewNotificationServiceWith(
+		notifiers,
+		intFromEnv("NOTIFY_WORKERS", 4),
+		intFromEnv("NOTIFY_QUEUE_SIZE", 1000),
+		intFromEnv("NOTIFY_MAX_ATTEMPTS", 5),
+	), nil
+}
+
+// NewNotificationServiceWith builds a service delivering through the given
+// notifiers, keyed by channel
+func NewNotificationServiceWith(notifiers map[string]Notifier, workers int, queueSize int, maxAttempts int) *NotificationService {
+	return &NotificationService{
+		notifiers:   notifiers,
+		queue:       make(chan *notificationJob, queueSize),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		baseDelay:   time.Second,
+		maxDelay:    time.Minute,
+		quit:        make(chan struct{}),
+	}
+}
+
+func intFromEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Enabled reports whether notifications can be sent on channel
+func (s *NotificationService) Enabled(channel string) bool {
+	_, ok := s.notifiers[channel]
+	return ok
+}
+
+// Start launches the delivery workers
+func (s *NotificationService) Start() {
+	for range s.workers {
+		s.wg.Add(1)
+		go s.work()
+	}
+}
+
+// Notify renders n and queues it for delivery. It only fails when the
+// notification cannot be rendered or queued; delivery errors are logged.
+func (s *NotificationService) Notify(ctx context.Context, n Notification) error {
+	if !s.Enabled(n.Channel) {
+		return fmt.Errorf("%w: %s", ErrChannelDisabled, n.Channel)
+	}
+	msg, err := RenderNotification(&n)
+	if err != nil {
+		return err
+	}
+	return s.enqueue(&notificationJob{ctx: context.WithoutCancel(ctx), msg: msg, attempt: 1})
+}
+
+func (s *NotificationService) enqueue(job *notificationJob) error {
+	if s.closed.Load() {
+		return ErrNotificationClosed
+	}
+	s.pending.Add(1)
+	select {
+	case s.queue <- job:
+		NotificationsTotal.WithLabelValues(job.msg.Channel, "queued").Inc()
+		return nil
+	default:
+		s.pending.Add(-1)
+		NotificationsTotal.WithLabelValues(job.msg.Channel, "dropped").Inc()
+		return ErrNotificationQueueFull
+	}
+}
+
+func (s *NotificationService) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case job := <-s.queue:
+			s.deliver(job)
+		}
+	}
+}
+
+func (s *NotificationService) deliver(job *notificationJob) {
+	ctx, span := StartSpan(job.ctx, "notification send")
+	span.SetAttributes(
+		attribute.String("notification.channel", job.msg.Channel),
+		attribute.String("notification.event", job.msg.Event),
+		attribute.Int("notification.attempt", job.attempt),
+	)
+	err := s.notifiers[job.msg.Channel].Send(ctx, job.msg)
+	EndSpan(span, err)
+
+	if err == nil {
+		NotificationsTotal.WithLabelValues(job.msg.Channel, "sent").Inc()
+		s.pending.Add(-1)
+		return
+	}
+	if job.attempt >= s.maxAttempts || s.closed.Load() {
+		slog.ErrorContext(job.ctx, "notification delivery failed", "channel", job.msg.Channel, "event", job.msg.Event, "attempts", job.attempt, "error", err)
+		NotificationsTotal.WithLabelValues(job.msg.Channel, "failed").Inc()
+		s.pending.Add(-1)
+		return
+	}
+
+	delay := s.retryDelay(job.attempt)
+	slog.WarnContext(job.ctx, "notification delivery failed, retrying", "channel", job.msg.Channel, "event", job.msg.Event, "attempt", job.attempt, "retry_in", delay.String(), "error", err)
+	NotificationsTotal.WithLabelValues(job.msg.Channel, "retried").Inc()
+	job.attempt++
+	time.AfterFunc(delay, func() {
+		select {
+		case s.queue <- job:
+		default:
+			slog.ErrorContext(job.ctx, "notification queue full, retry dropped", "channel", job.msg.Channel, "event", job.msg.Event)
+			NotificationsTotal.WithLabelValues(job.msg.Channel, "dropped").Inc()
+			s.pending.Add(-1)
+		}
+	})
+}
+
+// retryDelay doubles with every attempt up to maxDelay, with jitter so
+// retries of a burst do not hit the provider together
+func (s *NotificationService) retryDelay(attempt int) time.Duration {
+	delay := s.baseDelay << (attempt - 1)
+	if delay > s.maxDelay || delay <= 0 {
+		delay = s.maxDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Shutdown stops accepting notifications and waits, until ctx is done, for
+// the queued ones to be delivered. Failures during shutdown are not retried.
+func (s *NotificationService) Shutdown(ctx context.Context) error {
+	s.closed.Store(true)
+	defer func() {
+		close(s.quit)
+		s.wg.Wait()
+	}()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for s.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d notifications not delivered: %w", s.pending.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	twilio "github.com/twilio/twilio-go"
+	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Notification channels
+const (
+	ChannelSMS      = "SMS"
+	ChannelEmail    = "EMAIL"
+	ChannelWhatsApp = "WHATSAPP"
+)
+
+// Message is a rendered notification ready to be delivered on one channel
+type Message struct {
+	Channel string
+	To      string
+	Event   string
+	Subject string
+	Body    string
+	// TemplateSid and TemplateParams are used by channels that only accept
+	// pre-approved templates, such as WhatsApp
+	TemplateSid    string
+	TemplateParams []string
+}
+
+// Notifier delivers a message on a single channel
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NotificationSink returns the sink configured for channel through
+// NOTIFY_SMS_SINK, NOTIFY_EMAIL_SINK or NOTIFY_WHATSAPP_SINK. SMS goes
+// through Twilio unless configured otherwise; the other channels are off.
+func NotificationSink(channel string) string {
+	if sink := strings.ToLower(os.Getenv("NOTIFY_" + channel + "_SINK")); sink != "" {
+		return sink
+	}
+	if channel == ChannelSMS {
+		return "twilio"
+	}
+	return "none"
+}
+
+// newNotifier builds the notifier for channel, or nil when the channel is off
+func newNotifier(channel string, fileSink *FileNotifier) (Notifier, error) {
+	switch sink := NotificationSink(channel); sink {
+	case "none":
+		return nil, nil
+	case "console":
+		return NewFileNotifier(os.Stdout), nil
+	case "file":
+		if fileSink == nil {
+			return nil, fmt.Errorf("file sink for %s notifications is not open", channel)
+		}
+		return fileSink, nil
+	case "twilio":
+		if channel == ChannelSMS {
+			return NewTwilioSMSNotifier(), nil
+		}
+		if channel == ChannelWhatsApp {
+			return NewTwilioWhatsAppNotifier(), nil
+		}
+	case "smtp":
+		if channel == ChannelEmail {
+			return NewSMTPNotifier(), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported sink %q for %s notifications", NotificationSink(channel), channel)
+}
+
+// internationalPhone adds SMS_COUNTRY_CODE (default +91) to numbers stored without one
+func internationalPhone(phone string) string {
+	if strings.HasPrefix(phone, "+") {
+		return phone
+	}
+	countryCode := os.Getenv("SMS_COUNTRY_CODE")
+	if countryCode == "" {
+		countryCode = "+91"
+	}
+	return countryCode + phone
+}
+
+func newTwilioClient() *twilio.RestClient {
+	return twilio.NewRestClientWithParams(twilio.ClientParams{
+		Username: os.Getenv("TWILIO_ACCOUNT_SID"),
+		Password: os.Getenv("TWILIO_AUTH_TOKEN"),
+	})
+}
+
+// TwilioSMSNotifier sends plain text SMS from TWILIO_PHONE_NUMBER
+type TwilioSMSNotifier struct {
+	client *twilio.RestClient
+	from   string
+}
+
+func NewTwilioSMSNotifier() *TwilioSMSNotifier {
+	return &TwilioSMSNotifier{
+		client: newTwilioClient(),
+		from:   os.Getenv("TWILIO_PHONE_NUMBER"),
+	}
+}
+
+func (n *TwilioSMSNotifier) Send(ctx context.Context, msg *Message) error {
+	params := &openapi.CreateMessageParams{}
+	params.SetFrom(n.from)
+	params.SetTo(internationalPhone(msg.To))
+	params.SetBody(msg.Body)
+
+	_, span := StartSpan(ctx, "twilio send_sms", trace.WithSpanKind(trace.SpanKindClient))
+	start := time.Now()
+	_, err := n.client.Api.CreateMessage(params)
+	ObserveProviderRequest("twilio", "send_sms", start, err)
+	EndSpan(span, err)
+	return err
+}
+
+// TwilioWhatsAppNotifier sends WhatsApp messages from TWILIO_WHATSAPP_NUMBER.
+// Messages with a template SID go out as approved content templates, others
+// as free text, which WhatsApp only delivers inside an open conversation.
+type TwilioWhatsAppNotifier struct {
+	client *twilio.RestClient
+	from   string
+}
+
+func NewTwilioWhatsAppNotifier() *TwilioWhatsAppNotifier {
+	return &TwilioWhatsAppNotifier{
+		client: newTwilioClient(),
+		from:   os.Getenv("TWILIO_WHATSAPP_NUMBER"),
+	}
+}
+
+func (n *TwilioWhatsAppNotifier) Send(ctx context.Context, msg *Message) error {
+	params := &openapi.CreateMessageParams{}
+	params.SetFrom("whatsapp:" + internationalPhone(n.from))
+	params.SetTo("whatsapp:" + internationalPhone(msg.To))
+	if msg.TemplateSid != "" {
+		variables := make(map[string]string, len(msg.TemplateParams))
+		for i, value := range msg.TemplateParams {
+			variables[strconv.Itoa(i+1)] = value
+		}
+		encoded, err := json.Marshal(variables)
+		if err != nil {
+			return err
+		}
+		params.SetContentSid(msg.TemplateSid)
+		params.SetContentVariables(string(encoded))
+	} else {
+		params.SetBody(msg.Body)
+	}
+
+	_, span := StartSpan(ctx, "twilio send_whatsapp", trace.WithSpanKind(trace.SpanKindClient))
+	start := time.Now()
+	_, err := n.client.Api.CreateMessage(params)
+	ObserveProviderRequest("twilio", "send_whatsapp", start, err)
+	EndSpan(span, err)
+	return err
+}
+
+// SMTPNotifier sends plain text email through SMTP_HOST:SMTP_PORT, upgrading
+// to TLS when the server offers STARTTLS
+type SMTPNotifier struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// smtpTimeout bounds a whole SMTP conversation
+const smtpTimeout = 30 * time.Second
+
+func NewSMTPNotifier() *SMTPNotifier {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPNotifier{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     port,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, msg *Message) (err error) {
+	_, span := StartSpan(ctx, "smtp send_mail", trace.WithSpanKind(trace.SpanKindClient))
+	start := time.Now()
+	defer func() {
+		ObserveProviderRequest("smtp", "send_mail", start, err)
+		EndSpan(span, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(n.host, n.port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	headers := []string{
+		"From: " + n.from,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	if _, err := io.WriteString(w, strings.Join(headers, "\r\n")+"\r\n\r\n"+msg.Body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileNotifier writes every message as a JSON line instead of delivering it,
+// for development and tests
+type FileNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewFileNotifier(w io.Writer) *FileNotifier {
+	return &FileNotifier{w: w}
+}
+
+// OpenFileNotifier appends messages to the file at path, creating it if needed
+func OpenFileNotifier(path string) (*FileNotifier, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileNotifier(file), nil
+}
+
+func (n *FileNotifier) Send(_ context.Context, msg *Message) error {
+	line, err := json.Marshal(struct {
+		Time    time.Time `json:"time"`
+		Channel string    `json:"channel"`
+		To      string    `json:"to"`
+		Event   string    `json:"event"`
+		Subject string    `json:"subject,omitempty"`
+		Body    string    `json:"body"`
+	}{time.Now(), msg.Channel, msg.To, msg.Event, msg.Subject, msg.Body})
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err = n.w.Write(append(line, '\n'))
+	return err
+}
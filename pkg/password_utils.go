@@ -21,4 +21,18 @@ func (*PasswordUtils) HashPassword(password string) (string, error) {
 func (*PasswordUtils) VerifyPassword(hashedPassword string, normalPassword string) error {
 	// Compare the encrypted password with normal password and return the result
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(normalPassword))
-}
\ No newline at end of file
+}
+
+// PasswordHistoryDepth is the number of recent passwords, the current one
+// included, that a new password may not repeat
+const PasswordHistoryDepth = 5
+
+// MatchesAny reports whether password matches one of the hashed passwords
+func (p *PasswordUtils) MatchesAny(hashedPasswords []string, password string) bool {
+	for _, hashed := range hashedPasswords {
+		if p.VerifyPassword(hashed, password) == nil {
+			return true
+		}
+	}
+	return false
+}
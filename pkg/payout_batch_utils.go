@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Bulk payout row statuses. REJECTED rows failed validation and never touch
+// the wallet, QUEUED rows are waiting for the provider and the rest mirror
+// the payout they became.
+const (
+	PayoutRowRejected = "REJECTED"
+	PayoutRowQueued   = "QUEUED"
+	PayoutRowSuccess  = "SUCCESS"
+	PayoutRowPending  = "PENDING"
+	PayoutRowFailed   = "FAILED"
+)
+
+// Bulk payout batch statuses
+const (
+	PayoutBatchProcessing = "PROCESSING"
+	PayoutBatchCompleted  = "COMPLETED"
+)
+
+// MaxPayoutBatchRows caps the rows of a single bulk payout
+const MaxPayoutBatchRows = 500
+
+// PayoutBatchStaleAfter is how long a batch can stay PROCESSING before
+// housekeeping gives up on its remaining rows and releases the reserve
+const PayoutBatchStaleAfter = time.Hour
+
+// PayoutBatchResultHeader is the header of the downloadable result file
+var PayoutBatchResultHeader = []string{
+	"row_number", "account_number", "ifsc_code", "beneficiary_name", "amount",
+	"transfer_type", "reference", "status", "payout_transaction_id", "message",
+}
+
+// PayoutBatchConcurrency is how many rows of a batch are sent to the
+// provider at once
+func PayoutBatchConcurrency() int {
+	n, err := strconv.Atoi(os.Getenv("BULK_PAYOUT_CONCURRENCY"))
+	if err != nil || n < 1 {
+		return 4
+	}
+	return min(n, 16)
+}
@@ -0,0 +1,32 @@
+package pkg
+
+import (
+	"os"
+	"strings"
+)
+
+// Outcomes of a successful payout that came back. RETURNED is the
+// beneficiary bank sending the money back, for instance because the account
+// is closed, REVERSED is the provider or a chargeback taking it back.
+const (
+	PayoutReturned = "RETURNED"
+	PayoutReversed = "REVERSED"
+)
+
+// What happens to the commission of a returned payout. CLAWBACK takes the
+// shares back from the admin, master distributor and distributor and gives
+// the retailer everything they paid, KEEP leaves the commission paid and
+// only gives the retailer the principal.
+const (
+	ReturnCommissionClawback = "CLAWBACK"
+	ReturnCommissionKeep     = "KEEP"
+)
+
+// PayoutReturnCommissionPolicy is the commission policy of returns that do
+// not name one, read from PAYOUT_RETURN_COMMISSION and CLAWBACK by default
+func PayoutReturnCommissionPolicy() string {
+	if strings.ToUpper(os.Getenv("PAYOUT_RETURN_COMMISSION")) == ReturnCommissionKeep {
+		return ReturnCommissionKeep
+	}
+	return ReturnCommissionClawback
+}
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket holding up to Burst requests and regaining one
+// every Every
+type RateLimit struct {
+	Burst int
+	Every time.Duration
+}
+
+// RateLimitStore keeps the buckets. Take spends one token from the bucket
+// under key and returns zero, or how long to wait when the bucket is empty.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (time.Duration, error)
+}
+
+// Login lockout after repeated wrong passwords
+const (
+	LoginAttemptsPerLockout = 5
+	LoginLockoutDuration    = 15 * time.Minute
+)
+
+// rateLimitSweepInterval is how often idle buckets are dropped from memory
+const rateLimitSweepInterval = 10 * time.Minute
+
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     RateLimit
+}
+
+// MemoryRateLimitStore keeps buckets in process. Limits are per instance, so
+// deployments with several instances should use the database store instead.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*rateLimitBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+	return bucket.take(limit, now), nil
+}
+
+// take refills the bucket for the time since it was last used and spends a
+// token, or returns how long until one is back
+func (b *rateLimitBucket) take(limit RateLimit, now time.Time) time.Duration {
+	b.limit = limit
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()/limit.Every.Seconds())
+	b.updatedAt = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(limit.Every))
+}
+
+// sweep drops buckets that have refilled completely, they hold nothing a new
+// bucket would not
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updatedAt) >= time.Duration(bucket.limit.Burst)*bucket.limit.Every {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitBucketSpendsBurst(t *testing.T) {
+	limit := RateLimit{Burst: 3, Every: 10 * time.Second}
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	bucket := &rateLimitBucket{tokens: float64(limit.Burst), updatedAt: now}
+
+	for i := 0; i < limit.Burst; i++ {
+		if wait := bucket.take(limit, now); wait != 0 {
+			t.Fatalf("request %d waited %v within the burst", i+1, wait)
+		}
+	}
+	if wait := bucket.take(limit, now); wait != limit.Every {
+		t.Errorf("request past the burst waits %v, want %v", wait, limit.Every)
+	}
+}
+
+func TestRateLimitBucketRefills(t *testing.T) {
+	limit := RateLimit{Burst: 2, Every: 10 * time.Second}
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	bucket := &rateLimitBucket{tokens: 0, updatedAt: start}
+
+	tests := []struct {
+		after time.Duration
+		want  time.Duration
+	}{
+		// Four seconds in, 0.4 of a token is back
+		{4 * time.Second, 6 * time.Second},
+		// Refused requests spend nothing, the token is whole at ten seconds
+		{10 * time.Second, 0},
+		// and spent again, so the next one waits a full interval
+		{10 * time.Second, 10 * time.Second},
+		// A long idle refills no more than the burst
+		{10 * time.Minute, 0},
+		{10 * time.Minute, 0},
+		{10 * time.Minute, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if wait := bucket.take(limit, start.Add(tt.after)); wait != tt.want {
+			t.Errorf("at +%v waited %v, want %v", tt.after, wait, tt.want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreKeysBuckets(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 1, Every: time.Hour}
+	ctx := context.Background()
+
+	if wait, _ := store.Take(ctx, "ip:10.0.0.1", limit); wait != 0 {
+		t.Fatalf("first request waited %v", wait)
+	}
+	if wait, _ := store.Take(ctx, "ip:10.0.0.1", limit); wait == 0 {
+		t.Error("second request from the same key was let through")
+	}
+	if wait, _ := store.Take(ctx, "ip:10.0.0.2", limit); wait != 0 {
+		t.Errorf("request from another key waited %v", wait)
+	}
+}
+
+func TestMemoryRateLimitStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Burst: 2, Every: time.Minute}
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	store.buckets["idle"] = &rateLimitBucket{tokens: 0, updatedAt: now.Add(-2 * time.Minute), limit: limit}
+	store.buckets["busy"] = &rateLimitBucket{tokens: 0, updatedAt: now.Add(-time.Minute), limit: limit}
+
+	store.sweep(now)
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Error("bucket still refilling was dropped")
+	}
+}
@@ -0,0 +1,129 @@
+package pkg
+
+import "regexp"
+
+// Back-office permissions checked on admin routes
+const (
+	PermissionMemberView         = "member.view"
+	PermissionMemberCreate       = "member.create"
+	PermissionMemberDevices      = "member.devices"
+	PermissionWalletView         = "wallet.view"
+	PermissionWalletTopup        = "wallet.topup"
+	PermissionWalletRefund       = "wallet.refund"
+	PermissionFundRequestView    = "fundrequest.view"
+	PermissionFundRequestApprove = "fundrequest.approve"
+	PermissionPayoutOverride     = "payout.override"
+	PermissionTicketView         = "ticket.view"
+	PermissionTicketRespond      = "ticket.respond"
+	PermissionAuditView          = "audit.view"
+	PermissionSecurityPolicy     = "security.policy"
+	PermissionAdminManage        = "admin.manage"
+	PermissionStaffManage        = "staff.manage"
+	PermissionRoleManage         = "rbac.manage"
+	PermissionApprovalDecide     = "approval.decide"
+	PermissionApprovalPolicy     = "approval.policy"
+	PermissionPeriodClose        = "period.close"
+)
+
+// PermissionDescriptions lists every permission a role can be given
+var PermissionDescriptions = map[string]string{
+	PermissionMemberView:         "View master distributors, distributors and retailers",
+	PermissionMemberCreate:       "Create master distributors, distributors and retailers",
+	PermissionMemberDevices:      "View and revoke retailer devices",
+	PermissionWalletView:         "View wallet balances, transactions and refund history",
+	PermissionWalletTopup:        "Top up the admin wallet",
+	PermissionWalletRefund:       "Refund members' wallets",
+	PermissionFundRequestView:    "View fund requests",
+	PermissionFundRequestApprove: "Accept and reject fund requests",
+	PermissionPayoutOverride:     "Change the status of payouts",
+	PermissionTicketView:         "View support tickets",
+	PermissionTicketRespond:      "Respond to support tickets",
+	PermissionAuditView:          "View and verify the audit log",
+	PermissionSecurityPolicy:     "Change two-factor enforcement policies",
+	PermissionAdminManage:        "Invite admins and change their roles",
+	PermissionStaffManage:        "Create staff users and assign their roles",
+	PermissionRoleManage:         "Create, change and delete roles",
+	PermissionApprovalDecide:     "Approve and reject pending actions made by others",
+	PermissionApprovalPolicy:     "Change the amounts above which actions need a second approver",
+	PermissionPeriodClose:        "Close accounting periods against further changes",
+}
+
+// IsValidPermission reports whether permission is one of PermissionDescriptions
+func IsValidPermission(permission string) bool {
+	_, ok := PermissionDescriptions[permission]
+	return ok
+}
+
+var roleNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,49}$`)
+
+// IsValidRoleName reports whether name is an upper case role name such as
+// PAYOUT_DESK
+func IsValidRoleName(name string) bool {
+	return roleNamePattern.MatchString(name)
+}
+
+// StaffRole is the role of staff users in the audit log and login lockouts
+const StaffRole = "STAFF"
+
+// SystemRole is a built-in role. Its permissions are restored on every start
+// and cannot be changed through the API.
+type SystemRole struct {
+	Description string
+	Permissions []string
+}
+
+// SystemRoles are the admin tier roles, super admins hold every permission
+var SystemRoles = map[string]SystemRole{
+	AdminRoleSuperAdmin: {
+		Description: "Full access to the back office",
+	},
+	AdminRoleFinance: {
+		Description: "Wallets, fund requests and payouts",
+		Permissions: []string{
+			PermissionMemberView,
+			PermissionWalletView,
+			PermissionWalletTopup,
+			PermissionWalletRefund,
+			PermissionFundRequestView,
+			PermissionFundRequestApprove,
+			PermissionPayoutOverride,
+			PermissionAuditView,
+			PermissionApprovalDecide,
+			PermissionPeriodClose,
+		},
+	},
+	AdminRoleSupport: {
+		Description: "Member onboarding and support tickets",
+		Permissions: []string{
+			PermissionMemberView,
+			PermissionMemberCreate,
+			PermissionMemberDevices,
+			PermissionWalletView,
+			PermissionFundRequestView,
+			PermissionTicketView,
+			PermissionTicketRespond,
+		},
+	},
+	AdminRoleReadOnly: {
+		Description: "View only access",
+		Permissions: []string{
+			PermissionMemberView,
+			PermissionWalletView,
+			PermissionFundRequestView,
+			PermissionTicketView,
+			PermissionAuditView,
+		},
+	},
+}
+
+// SystemRolePermissions returns the permissions of a built-in role
+func SystemRolePermissions(role string) []string {
+	if role == AdminRoleSuperAdmin {
+		permissions := make([]string, 0, len(PermissionDescriptions))
+		for permission := range PermissionDescriptions {
+			permissions = append(permissions, permission)
+		}
+		return permissions
+	}
+	return SystemRoles[role].Permissions
+}
@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scheduled transfer kinds. TOP_UP tops the recipient up to a target amount
+// whenever their balance is found below a threshold.
+const (
+	ScheduleDaily  = "DAILY"
+	ScheduleWeekly = "WEEKLY"
+	ScheduleTopUp  = "TOP_UP"
+)
+
+// Outcomes of a scheduled transfer run
+const (
+	ScheduledRunSuccess = "SUCCESS"
+	ScheduledRunSkipped = "SKIPPED"
+	ScheduledRunFailed  = "FAILED"
+)
+
+// ScheduleLocation is the time zone run times are given in
+var ScheduleLocation = time.FixedZone("IST", 5*60*60+30*60)
+
+// TopUpCheckInterval is how often TOP_UP schedules look at the recipient's
+// balance
+const TopUpCheckInterval = 15 * time.Minute
+
+// ParseRunTime reads a run time of the form HH:MM
+func ParseRunTime(runTime string) (int, int, error) {
+	t, err := time.Parse("15:04", runTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("run_time must be HH:MM")
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// NextScheduledRun returns the first run of a schedule after the given time.
+// Weekday counts from Sunday as 0 and is used by WEEKLY schedules only.
+func NextScheduledRun(scheduleType string, runTime string, weekday int, after time.Time) (time.Time, error) {
+	if scheduleType == ScheduleTopUp {
+		return after.Add(TopUpCheckInterval), nil
+	}
+	hour, minute, err := ParseRunTime(runTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := after.In(ScheduleLocation)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, ScheduleLocation)
+	switch scheduleType {
+	case ScheduleDaily:
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+	case ScheduleWeekly:
+		next = next.AddDate(0, 0, (weekday-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("unknown schedule type %q", scheduleType)
+	}
+	return next, nil
+}
+
+// Outcomes of an automatic replenishment
+const (
+	ReplenishmentSuccess = "SUCCESS"
+	ReplenishmentSkipped = "SKIPPED"
+)
+
+// StartOfDay returns midnight of the day t falls on in ScheduleLocation,
+// where daily limits reset
+func StartOfDay(t time.Time) time.Time {
+	local := t.In(ScheduleLocation)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, ScheduleLocation)
+}
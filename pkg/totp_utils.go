@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults understood by every authenticator app)
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is the number of periods either side of now that are accepted
+	TOTPSkew = 1
+	// TOTPSecretSize is the secret length in bytes, as recommended by RFC 4226
+	TOTPSecretSize = 20
+)
+
+// Two-factor recovery codes and step-up verification
+const (
+	RecoveryCodeCount = 10
+	// TwoFactorCodeHeader carries the TOTP or recovery code confirming a
+	// sensitive action
+	TwoFactorCodeHeader = "X-2FA-Code"
+	// TwoFactorChallengeTTL bounds the time between password and code at login
+	TwoFactorChallengeTTL = 5 * time.Minute
+	// TwoFactorMaxAttempts wrong codes in a row lock two-factor verification
+	// for TwoFactorLockout
+	TwoFactorMaxAttempts = 5
+	TwoFactorLockout     = 15 * time.Minute
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TOTPUtils struct{}
+
+// GenerateSecret returns a new base32 encoded shared secret
+func (*TOTPUtils) GenerateSecret() (string, error) {
+	secret := make([]byte, TOTPSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI authenticator apps scan as a QR code
+func (*TOTPUtils) ProvisioningURI(account string, secret string) string {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "PayBazar"
+	}
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + params.Encode()
+}
+
+// code computes the RFC 4226 HOTP value of secret for the counter
+func (*TOTPUtils) code(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range TOTPDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
+
+// VerifyCode checks code against the secret at now, allowing TOTPSkew periods
+// of clock drift. Only time steps after lastStep are accepted so a code cannot
+// be replayed; the matching step is returned to be stored as the new lastStep.
+func (t *TOTPUtils) VerifyCode(secret string, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := now.Unix() / int64(TOTPPeriod.Seconds())
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if hmac.Equal([]byte(t.code(key, uint64(step))), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// CheckTOTPEncryptionKey reports a missing TOTP_ENCRYPTION_KEY, without which
+// every secret would be sealed with the same well-known key
+func CheckTOTPEncryptionKey() error {
+	if os.Getenv("TOTP_ENCRYPTION_KEY") == "" {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY is not set")
+	}
+	return nil
+}
+
+// secretKey derives the AES-256 key protecting stored secrets from
+// TOTP_ENCRYPTION_KEY
+func secretKey() []byte {
+	key := sha256.Sum256([]byte(os.Getenv("TOTP_ENCRYPTION_KEY")))
+	return key[:]
+}
+
+// EncryptSecret seals a TOTP secret with AES-GCM for storage
+func (*TOTPUtils) EncryptSecret(secret string) (string, error) {
+	block, err := aes.NewCipher(secretKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// DecryptSecret opens a secret sealed by EncryptSecret
+func (*TOTPUtils) DecryptSecret(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode totp secret: %w", err)
+	}
+	block, err := aes.NewCipher(secretKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("totp secret is too short")
+	}
+	secret, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// GenerateRecoveryCodes returns RecoveryCodeCount single use codes formatted
+// as two groups of five characters
+func (*TOTPUtils) GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage, ignoring case, spaces
+// and dashes in what the user typed
+func (*TOTPUtils) HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	mac := hmac.New(sha256.New, secretKey())
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the shared secret of the RFC 4226 and RFC 6238 test vectors
+const rfcSecret = "12345678901234567890"
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226 appendix D
+	tests := []struct {
+		counter uint64
+		want    string
+	}{
+		{0, "755224"},
+		{1, "287082"},
+		{2, "359152"},
+		{3, "969429"},
+		{4, "338314"},
+		{5, "254676"},
+		{6, "287922"},
+		{7, "162583"},
+		{8, "399871"},
+		{9, "520489"},
+	}
+	totp := &TOTPUtils{}
+	for _, tt := range tests {
+		if got := totp.code([]byte(rfcSecret), tt.counter); got != tt.want {
+			t.Errorf("code(counter %d) = %s, want %s", tt.counter, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, cut down to the six digits used here
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	totp := &TOTPUtils{}
+	secret := base32.StdEncoding.EncodeToString([]byte(rfcSecret))
+	for _, tt := range tests {
+		now := time.Unix(tt.unix, 0)
+		step, ok := totp.VerifyCode(secret, tt.want, now, 0)
+		if !ok {
+			t.Errorf("VerifyCode(%s at %d) rejected a valid code", tt.want, tt.unix)
+			continue
+		}
+		if want := tt.unix / int64(TOTPPeriod.Seconds()); step != want {
+			t.Errorf("VerifyCode(%s at %d) step = %d, want %d", tt.want, tt.unix, step, want)
+		}
+	}
+}
+
+func TestVerifyCodeRejects(t *testing.T) {
+	totp := &TOTPUtils{}
+	secret := base32.StdEncoding.EncodeToString([]byte(rfcSecret))
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / int64(TOTPPeriod.Seconds())
+
+	tests := []struct {
+		name     string
+		code     string
+		now      time.Time
+		lastStep int64
+	}{
+		{"wrong code", "000000", now, 0},
+		{"short code", "50471", now, 0},
+		{"replayed step", "050471", now, step},
+		{"outside skew", "050471", now.Add(3 * TOTPPeriod), 0},
+	}
+	for _, tt := range tests {
+		if _, ok := totp.VerifyCode(secret, tt.code, tt.now, tt.lastStep); ok {
+			t.Errorf("%s: VerifyCode accepted %s", tt.name, tt.code)
+		}
+	}
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "test-key")
+	totp := &TOTPUtils{}
+	sealed, err := totp.EncryptSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("EncryptSecret: %v", err)
+	}
+	secret, err := totp.DecryptSecret(sealed)
+	if err != nil {
+		t.Fatalf("DecryptSecret: %v", err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("DecryptSecret = %s, want JBSWY3DPEHPK3PXP", secret)
+	}
+
+	t.Setenv("TOTP_ENCRYPTION_KEY", "other-key")
+	if _, err := totp.DecryptSecret(sealed); err == nil {
+		t.Error("DecryptSecret opened a secret sealed with another key")
+	}
+}
+
+func TestCheckTOTPEncryptionKey(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "")
+	if err := CheckTOTPEncryptionKey(); err == nil {
+		t.Error("CheckTOTPEncryptionKey accepted an empty key")
+	}
+	t.Setenv("TOTP_ENCRYPTION_KEY", "set")
+	if err := CheckTOTPEncryptionKey(); err != nil {
+		t.Errorf("CheckTOTPEncryptionKey: %v", err)
+	}
+}